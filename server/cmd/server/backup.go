@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/backup"
+	"github.com/monzim/db_proxy/v1/internal/config"
+	"github.com/monzim/db_proxy/v1/internal/database"
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/repository"
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// runBackup implements `server backup --config-id=<uuid>`. It runs one
+// backup of the given database config with the same code path as the
+// server and exits, for hosts that drive backups from cron instead of
+// running the HTTP service. The run is recorded with trigger "cli" and
+// notifies the database's channel as usual.
+//
+// Exit codes: 0 when the backup succeeded, 1 when it failed or was skipped
+// (maintenance mode, paused, outside its backup window), 2 for usage errors.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	configID := fs.String("config-id", "", "ID of the database config to back up (required)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	id, err := uuid.Parse(*configID)
+	if err != nil {
+		log.Printf("--config-id must be a database config UUID")
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 1
+	}
+	logging.SetLevel(cfg.Server.LogLevel)
+	if err := configureStorage(&cfg.Storage); err != nil {
+		log.Printf("Invalid storage configuration: %v", err)
+		return 1
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		log.Printf("Failed to connect to database: %v", err)
+		return 1
+	}
+	defer db.Close()
+	// The CLI may be the only thing run after an upgrade, so bring the
+	// schema up to date exactly as the server would.
+	if err := db.AutoMigrate(); err != nil {
+		log.Printf("Failed to run auto-migration: %v", err)
+		return 1
+	}
+	repo := repository.NewGORM(db.DB)
+
+	dbConfig, err := repo.GetDatabaseConfig(id)
+	if err != nil {
+		log.Printf("Failed to load database config: %v", err)
+		return 1
+	}
+	if dbConfig == nil {
+		log.Printf("Database config %s not found", id)
+		return 1
+	}
+
+	// Maintenance mode holds every backup, so cron-driven runs stop with the
+	// scheduler's.
+	maintenance, err := repo.GetMaintenanceState()
+	if err != nil {
+		log.Printf("Failed to load maintenance state: %v", err)
+		return 1
+	}
+	if maintenance.Enabled {
+		log.Printf("[BACKUP] Skipping backup of %q: maintenance mode is enabled", dbConfig.Name)
+		return 1
+	}
+
+	backupSvc := backup.NewService(repo)
+	backupSvc.SetPgBinDirs(cfg.Postgres.BinDirs)
+	backupSvc.SetKeyCollisionCheck(cfg.Storage.KeyCollisionCheck)
+	// Patterns were validated by config.Load.
+	hostPolicy, _ := utils.NewHostPolicy(cfg.Hosts.Allow, cfg.Hosts.Deny)
+	backupSvc.SetHostPolicy(hostPolicy)
+	if err := backupSvc.LoadSSLModeCache(); err != nil {
+		log.Printf("⚠️  Failed to load SSL mode cache, every host will try SSL first: %v", err)
+	}
+
+	record, err := repo.CreateBackup(dbConfig.ID, models.BackupStatusPending, models.BackupTriggerCLI, nil)
+	if err != nil {
+		log.Printf("Failed to create backup record: %v", err)
+		return 1
+	}
+	log.Printf("[BACKUP] Starting backup %s of %q", record.ID, dbConfig.Name)
+	if err := backupSvc.ExecuteBackupWithID(dbConfig, record.ID); err != nil {
+		log.Printf("[BACKUP] ❌ Backup of %q failed: %v", dbConfig.Name, err)
+		return 1
+	}
+
+	// ExecuteBackupWithID reports most failures, and skips, only on the
+	// record.
+	result, err := repo.GetBackup(record.ID)
+	if err != nil || result == nil {
+		log.Printf("Failed to read backup result: %v", err)
+		return 1
+	}
+	if result.Status != models.BackupStatusSuccess {
+		reason := string(result.Status)
+		if result.ErrorMessage != nil {
+			reason = *result.ErrorMessage
+		}
+		log.Printf("[BACKUP] ❌ Backup of %q failed: %s", dbConfig.Name, reason)
+		return 1
+	}
+	log.Printf("[BACKUP] ✅ Backup %s of %q uploaded to %s", result.ID, dbConfig.Name, result.StoragePath)
+	return 0
+}
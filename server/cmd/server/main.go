@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,10 +18,13 @@ import (
 	"github.com/monzim/db_proxy/v1/internal/crypto"
 	"github.com/monzim/db_proxy/v1/internal/database"
 	"github.com/monzim/db_proxy/v1/internal/handlers"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"github.com/monzim/db_proxy/v1/internal/notification"
 	"github.com/monzim/db_proxy/v1/internal/repository"
 	"github.com/monzim/db_proxy/v1/internal/scheduler"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 )
 
 // @title PostgreSQL Backup Service API
@@ -42,22 +47,33 @@ import (
 // @description Type "Bearer" followed by a space and the JWT token.
 
 func main() {
-	log.Println("Starting PostgreSQL Backup Service...")
+	// Operational subcommands run instead of the HTTP server.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rotate-key":
+			os.Exit(runRotateKey(os.Args[2:]))
+		case "backup":
+			os.Exit(runBackup(os.Args[2:]))
+		}
+	}
 
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	logging.SetLevel(cfg.Server.LogLevel)
+
+	logging.Infof("Starting PostgreSQL Backup Service...")
 
 	// Initialize database
-	db, err := database.New(cfg.Database.GetDSN())
+	db, err := database.New(&cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
-	log.Println("Database connection established")
+	logging.Infof("Database connection established")
 
 	// Run GORM auto-migration
 	if err := db.AutoMigrate(); err != nil {
@@ -74,22 +90,57 @@ func main() {
 
 	// Seed demo data (creates demo user and sample data)
 	if err := database.SeedDemoData(repo); err != nil {
-		log.Printf("[DEMO] ⚠️  Failed to seed demo data: %v", err)
+		logging.Warnf("[DEMO] ⚠️  Failed to seed demo data: %v", err)
 		// Don't fatal - demo data is optional
 	}
 
 	// Initialize JWT manager
-	jwtMgr := auth.NewJWTManager(cfg.JWT.Secret, cfg.JWT.Expiration)
+	jwtMgr, err := newJWTManager(&cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to initialize JWT signing: %v", err)
+	}
+
+	if err := configureStorage(&cfg.Storage); err != nil {
+		log.Fatalf("Invalid storage configuration: %v", err)
+	}
 
 	// Initialize backup service
 	backupSvc := backup.NewService(repo)
+	backupSvc.SetPgBinDirs(cfg.Postgres.BinDirs)
+	backupSvc.SetUploadRetryWindow(time.Duration(cfg.Storage.UploadRetryMinutes) * time.Minute)
+	backupSvc.SetKeyCollisionCheck(cfg.Storage.KeyCollisionCheck)
+	if err := backupSvc.SetRestoreTempDir(cfg.Restore.TempDir); err != nil {
+		log.Fatalf("Invalid RESTORE_TEMP_DIR: %v", err)
+	}
+	backupSvc.SetMaxConcurrentRestores(cfg.Restore.MaxConcurrent)
+	// Patterns were validated by config.Load.
+	hostPolicy, _ := utils.NewHostPolicy(cfg.Hosts.Allow, cfg.Hosts.Deny)
+	backupSvc.SetHostPolicy(hostPolicy)
+	if n := backupSvc.SweepRestoreTemp(time.Duration(cfg.Restore.TempMaxAgeMinutes) * time.Minute); n > 0 {
+		logging.Infof("Removed %d stale restore temp file(s)", n)
+	}
+	if err := backupSvc.LoadSSLModeCache(); err != nil {
+		logging.Warnf("⚠️  Failed to load SSL mode cache, every host will try SSL first: %v", err)
+	}
+	backupSvc.LogPgToolchain()
 
 	// Initialize scheduler
-	sched := scheduler.NewScheduler(repo, backupSvc)
+	sched := scheduler.NewScheduler(repo, backupSvc, cfg.Scheduler.CronSeconds)
+	sched.SetMaxConcurrent(cfg.Scheduler.MaxConcurrent)
+	sched.SetFreshnessGrace(time.Duration(cfg.Scheduler.FreshnessGraceMinutes) * time.Minute)
+	sched.SetJitter(time.Duration(cfg.Scheduler.JitterMinutes) * time.Minute)
+	maintenance, err := repo.GetMaintenanceState()
+	if err != nil {
+		log.Fatalf("Failed to load maintenance state: %v", err)
+	}
+	sched.SetMaintenance(maintenance.Enabled)
 	if err := sched.Start(); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
 	}
 	defer sched.Stop()
+	if cfg.Scheduler.FreshnessCheckMinutes > 0 {
+		sched.StartFreshnessChecker(time.Duration(cfg.Scheduler.FreshnessCheckMinutes) * time.Minute)
+	}
 
 	// Initialize activity log cleanup service (60 days retention)
 	cleanupSvc := cleanup.NewService(repo, 60*24*time.Hour)
@@ -98,10 +149,23 @@ func main() {
 	}
 	defer cleanupSvc.Stop()
 
+	otpCleanupSvc := cleanup.NewOTPService(repo, time.Duration(cfg.Discord.OTPRetention)*time.Minute)
+	if err := otpCleanupSvc.Start(); err != nil {
+		log.Fatalf("Failed to start OTP cleanup service: %v", err)
+	}
+	defer otpCleanupSvc.Stop()
+
+	// Queue outbound Discord notifications so concurrent backups sharing a
+	// webhook are paced instead of tripping its rate limit.
+	notifyQueue := notification.NewQueue(time.Duration(cfg.Discord.QueueInterval)*time.Millisecond, cfg.Discord.QueueSize)
+	notification.SetDefaultQueue(notifyQueue)
+
 	// Initialize Discord notifier
 	var notifier *notification.DiscordNotifier
 	if cfg.Discord.WebhookURL != "" {
 		notifier = notification.NewDiscordNotifier(cfg.Discord.WebhookURL, "PostgreSQL Backup Service")
+		notifier.SetRetryPolicy(cfg.Discord.MaxAttempts, time.Duration(cfg.Discord.MaxRetryAfter)*time.Second)
+		notifier.UseQueue(notifyQueue)
 		notifier.SendMessage("🚀 **PostgreSQL Backup Service Started**\n✅ System is now online and ready to manage backups.")
 	}
 
@@ -109,7 +173,7 @@ func main() {
 	if err := repo.LogActivity(nil, models.ActionSystemStartup, models.LogLevelInfo,
 		"system", nil, "System",
 		"PostgreSQL Backup Service started successfully", "", ""); err != nil {
-		log.Printf("[ACTIVITY_LOG] ⚠️  Failed to log system startup: %v", err)
+		logging.Warnf("[ACTIVITY_LOG] ⚠️  Failed to log system startup: %v", err)
 	}
 
 	// Initialize crypto for at-rest encryption of server credentials
@@ -123,10 +187,12 @@ func main() {
 	h := handlers.New(repo, jwtMgr, backupSvc, sched, notifier, otpExpiry,
 		cfg.Turnstile.Enabled, cfg.Turnstile.SecretKey, cfg.Turnstile.Timeout,
 		cipher, cfg)
+	handlers.SetJSONLimits(int64(cfg.Server.MaxBodyBytes), cfg.Server.StrictJSON)
 
 	// Initialize TOTP manager for 2FA
 	totpConfig := auth.DefaultTOTPConfig()
 	totpConfig.Issuer = "DumpStation" // Customize issuer name
+	totpConfig.BackupCodeCount = cfg.TwoFactor.BackupCodes
 	totpMgr := auth.NewTOTPManager(totpConfig)
 
 	// Setup routes with 2FA support
@@ -144,7 +210,7 @@ func main() {
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server listening on %s", addr)
+		logging.Infof("Server listening on %s", addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
@@ -155,13 +221,13 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logging.Infof("Shutting down server...")
 
 	// Log system shutdown
 	if err := repo.LogActivity(nil, models.ActionSystemShutdown, models.LogLevelInfo,
 		"system", nil, "System",
 		"PostgreSQL Backup Service shutting down", "", ""); err != nil {
-		log.Printf("[ACTIVITY_LOG] ⚠️  Failed to log system shutdown: %v", err)
+		logging.Warnf("[ACTIVITY_LOG] ⚠️  Failed to log system shutdown: %v", err)
 	}
 
 	// Send shutdown notification
@@ -177,7 +243,63 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	log.Println("Server exited gracefully")
+	// Stop running backups rather than leave pg_dump orphaned.
+	backupSvc.Shutdown()
+
+	// Flush queued notifications, including the shutdown message above.
+	if err := notifyQueue.Close(ctx); err != nil {
+		logging.Warnf("Notification queue not drained before shutdown: %v", err)
+	}
+
+	logging.Infof("Server exited gracefully")
+}
+
+// configureStorage applies the process-wide object storage settings: the
+// upload ACL, ${NAME} references and the HTTP transport.
+func configureStorage(cfg *config.ObjectStorageConfig) error {
+	if err := storage.SetObjectACL(cfg.ObjectACL); err != nil {
+		return err
+	}
+	if err := utils.SetAllowedEnvRefs(cfg.EnvVars); err != nil {
+		return fmt.Errorf("STORAGE_ENV_VARS: %w", err)
+	}
+	storageHTTP := storage.HTTPOptions{StallTimeout: time.Duration(cfg.HTTPTimeoutSeconds) * time.Second}
+	if cfg.HTTPProxy != "" {
+		proxyURL, err := url.Parse(cfg.HTTPProxy)
+		if err != nil {
+			return fmt.Errorf("STORAGE_HTTP_PROXY: %w", err)
+		}
+		storageHTTP.ProxyURL = proxyURL
+	}
+	return storage.SetHTTPOptions(storageHTTP)
+}
+
+// newJWTManager builds the HS256 manager by default, or loads the PEM keys
+// for an asymmetric algorithm.
+func newJWTManager(cfg *config.JWTConfig) (*auth.JWTManager, error) {
+	if cfg.Algorithm == "HS256" {
+		return auth.NewJWTManager(cfg.Secret, cfg.Expiration), nil
+	}
+
+	signingKey, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT_PRIVATE_KEY_FILE: %w", err)
+	}
+	retired := make(map[string][]byte, len(cfg.RetiredKeyFiles))
+	for kid, path := range cfg.RetiredKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read retired JWT key %q: %w", kid, err)
+		}
+		retired[kid] = data
+	}
+
+	mgr, err := auth.NewAsymmetricJWTManager(cfg.Algorithm, cfg.KeyID, signingKey, retired, cfg.Expiration)
+	if err != nil {
+		return nil, err
+	}
+	logging.Infof("JWT signing: %s with key %q (%d retired key(s) accepted)", cfg.Algorithm, cfg.KeyID, len(retired))
+	return mgr, nil
 }
 
 // ensureSystemUser ensures the single system user exists in the database
@@ -200,16 +322,16 @@ func ensureSystemUser(repo *repository.Repository) error {
 	}
 
 	if user != nil {
-		log.Printf("[AUTH] ✅ System user exists: %s (%s)", user.DiscordUsername, user.Email)
+		logging.Debugf("[AUTH] ✅ System user exists: %s (%s)", user.DiscordUsername, user.Email)
 		return nil
 	}
 
 	// Create the system user using raw SQL since CreateUser is disabled
-	log.Printf("[AUTH] 🔧 Creating system user: %s (%s)", systemUsername, systemEmail)
+	logging.Infof("[AUTH] 🔧 Creating system user: %s (%s)", systemUsername, systemEmail)
 	if err := repo.SeedSystemUser(systemUsername, systemEmail); err != nil {
 		return err
 	}
 
-	log.Printf("[AUTH] ✅ System user created successfully")
+	logging.Infof("[AUTH] ✅ System user created successfully")
 	return nil
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/monzim/db_proxy/v1/internal/config"
+	"github.com/monzim/db_proxy/v1/internal/crypto"
+	"github.com/monzim/db_proxy/v1/internal/database"
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/repository"
+)
+
+// runRotateKey implements `server rotate-key`. It re-encrypts every stored
+// secret from the current DUMPSTATION_SECRET_KEY (or --old-key) to
+// --new-key in one transaction. Run it with the service stopped, then
+// deploy the new key; a running server keeps the old key in memory and
+// would fail to decrypt rotated rows.
+//
+// Only server connection passwords are encrypted at rest today. Backup
+// archives are not encrypted, so there is no backup key metadata to re-wrap.
+func runRotateKey(args []string) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	oldKey := fs.String("old-key", "", "base64 key the secrets are currently encrypted with (default: DUMPSTATION_SECRET_KEY)")
+	newKey := fs.String("new-key", os.Getenv("DUMPSTATION_NEW_SECRET_KEY"), "base64 key to re-encrypt with (default: DUMPSTATION_NEW_SECRET_KEY)")
+	dryRun := fs.Bool("dry-run", false, "verify every secret decrypts and report counts without writing")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 1
+	}
+	logging.SetLevel(cfg.Server.LogLevel)
+	if *oldKey == "" {
+		*oldKey = cfg.Secret.Key
+	}
+	if *newKey == "" {
+		log.Printf("--new-key (or DUMPSTATION_NEW_SECRET_KEY) is required")
+		return 2
+	}
+	if *newKey == *oldKey {
+		log.Printf("new key is identical to the old key; nothing to rotate")
+		return 2
+	}
+
+	from, err := crypto.NewCipher(*oldKey)
+	if err != nil {
+		log.Printf("Invalid old key: %v", err)
+		return 1
+	}
+	to, err := crypto.NewCipher(*newKey)
+	if err != nil {
+		log.Printf("Invalid new key: %v", err)
+		return 1
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		log.Printf("Failed to connect to database: %v", err)
+		return 1
+	}
+	defer db.Close()
+
+	repo := repository.NewGORM(db.DB)
+	result, err := repo.RotateServerConnectionSecrets(func(ct string) (string, error) {
+		return crypto.ReEncrypt(from, to, ct)
+	}, *dryRun)
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	if err != nil {
+		log.Printf("[ROTATE] ❌ Key rotation failed, no changes were written: %v", err)
+		return 1
+	}
+	if *dryRun {
+		log.Printf("[ROTATE] Dry run: %d/%d secrets would be re-encrypted", result.Rotated, result.Scanned)
+		return 0
+	}
+
+	meta, _ := json.Marshal(result)
+	if err := repo.LogActivity(nil, models.ActionSecretsRotated, models.LogLevelSuccess,
+		"system", nil, "System",
+		fmt.Sprintf("Re-encrypted %d stored secrets with a new key", result.Rotated),
+		string(meta), ""); err != nil {
+		log.Printf("[ACTIVITY_LOG] ⚠️  Failed to log key rotation: %v", err)
+	}
+	log.Printf("[ROTATE] ✅ Re-encrypted %d secrets. Set DUMPSTATION_SECRET_KEY to the new key before restarting.", result.Rotated)
+	return 0
+}
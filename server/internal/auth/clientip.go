@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// trustedProxyHeaders is true when the operator has explicitly opted in to
+// trusting X-Forwarded-For / X-Real-IP. Without this opt-in any client could
+// spoof their source IP, defeating rate limiting and poisoning audit logs.
+//
+// Set TRUST_PROXY_HEADERS=true (or =1) in the environment when the service
+// runs behind a load balancer that strips/sets these headers.
+var trustedProxyHeaders = func() bool {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("TRUST_PROXY_HEADERS")))
+	return v == "1" || v == "true" || v == "yes"
+}()
+
+// trustedProxies restricts header trust to requests whose immediate peer is
+// one of these networks. Set TRUSTED_PROXIES to a comma-separated list of
+// CIDRs or bare IPs (e.g. "10.0.0.0/8,::1"); setting it implies
+// TRUST_PROXY_HEADERS. When empty and TRUST_PROXY_HEADERS is on, every peer
+// is trusted, which is only safe if the service is unreachable except
+// through the proxy.
+var trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
+// parseTrustedProxies parses a comma-separated CIDR/IP list. Invalid entries
+// are logged and skipped rather than failing startup.
+func parseTrustedProxies(raw string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for entry := range strings.SplitSeq(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap().WithZone("")
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		log.Printf("[AUTH] ⚠️  Ignoring invalid TRUSTED_PROXIES entry %q", entry)
+	}
+	return prefixes
+}
+
+// GetIPAddress extracts the client's IP address from the HTTP request.
+// X-Forwarded-For and X-Real-IP are only honored when the immediate peer is
+// a trusted proxy (see trustedProxies / TRUST_PROXY_HEADERS); otherwise the
+// RemoteAddr is used. Returns an IP string with no port or zone — never the
+// raw, unparsed header value, so log injection via crafted headers is
+// impossible.
+func GetIPAddress(r *http.Request) string {
+	return clientIP(r, trustedProxyHeaders, trustedProxies)
+}
+
+func clientIP(r *http.Request, trustHeaders bool, proxies []netip.Prefix) string {
+	peer, ok := parseHostAddr(r.RemoteAddr)
+	if !ok {
+		return ""
+	}
+
+	isTrusted := func(addr netip.Addr) bool {
+		if len(proxies) == 0 {
+			return trustHeaders
+		}
+		for _, p := range proxies {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	if !isTrusted(peer) {
+		return peer.String()
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		// Walk right to left: each trusted proxy appended the address it
+		// received from, so the first untrusted hop is the real client.
+		// Anything left of it was supplied by the client and is ignored.
+		var leftmost netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, ok := parseHostAddr(strings.TrimSpace(hops[i]))
+			if !ok {
+				break
+			}
+			if !isTrusted(addr) {
+				return addr.String()
+			}
+			leftmost = addr
+		}
+		if leftmost.IsValid() {
+			return leftmost.String()
+		}
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		if addr, ok := parseHostAddr(strings.TrimSpace(realIP)); ok {
+			return addr.String()
+		}
+	}
+	return peer.String()
+}
+
+// parseHostAddr parses an IP that may carry a port ("1.2.3.4:80",
+// "[2001:db8::1]:443") or an IPv6 zone, returning the bare address with
+// IPv4-mapped IPv6 unwrapped.
+func parseHostAddr(s string) (netip.Addr, bool) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap().WithZone(""), true
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().WithZone(""), true
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := parseTrustedProxies("10.0.0.0/8, ::1, bogus")
+	if len(proxies) != 2 {
+		t.Fatalf("parsed %d proxies, want 2 (invalid entry skipped)", len(proxies))
+	}
+
+	tests := []struct {
+		name         string
+		remote       string
+		xff          string
+		realIP       string
+		trustHeaders bool
+		proxies      bool
+		want         string
+	}{
+		{name: "ipv4 remote", remote: "203.0.113.7:5555", want: "203.0.113.7"},
+		{name: "ipv6 remote with port", remote: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "ipv6 remote with zone", remote: "[fe80::1%eth0]:443", want: "fe80::1"},
+		{name: "ipv4-mapped remote", remote: "[::ffff:203.0.113.7]:80", want: "203.0.113.7"},
+		{name: "headers ignored without opt-in", remote: "203.0.113.7:1", xff: "198.51.100.1", want: "203.0.113.7"},
+		{name: "untrusted peer cannot spoof", remote: "203.0.113.7:1", xff: "198.51.100.1", proxies: true, want: "203.0.113.7"},
+		{name: "trusted peer forwards client", remote: "10.1.2.3:1", xff: "198.51.100.1", proxies: true, want: "198.51.100.1"},
+		{name: "forged left entry skipped", remote: "10.1.2.3:1", xff: "1.1.1.1, 198.51.100.1, 10.9.9.9", proxies: true, want: "198.51.100.1"},
+		{name: "ipv6 forwarded with port", remote: "[::1]:9000", xff: "[2001:db8::5]:1234", proxies: true, want: "2001:db8::5"},
+		{name: "x-real-ip from trusted peer", remote: "10.1.2.3:1", realIP: "198.51.100.2", proxies: true, want: "198.51.100.2"},
+		{name: "garbage header falls back to peer", remote: "10.1.2.3:1", xff: "<script>", proxies: true, want: "10.1.2.3"},
+		{name: "legacy opt-in trusts any peer", remote: "203.0.113.7:1", xff: "198.51.100.1", trustHeaders: true, want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			p := proxies
+			if !tt.proxies {
+				p = nil
+			}
+			if got := clientIP(r, tt.trustHeaders, p); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK is a single public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewAsymmetricJWTManager creates a JWT manager that signs with an RSA or
+// ECDSA private key and verifies against a set of public keys selected by
+// the token's `kid` header.
+//
+// algorithm is RS256/RS384/RS512 or ES256/ES384/ES512. signingKeyPEM is the
+// PKCS#1, PKCS#8 or SEC1 encoded private key, published under keyID.
+// retiredKeys maps older key IDs to PEM public keys that are still accepted
+// for verification, so the signing key can be rotated without invalidating
+// tokens issued before the switch.
+func NewAsymmetricJWTManager(algorithm, keyID string, signingKeyPEM []byte, retiredKeys map[string][]byte, expirationMinutes int) (*JWTManager, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q (use HS256, RS256 or ES256)", algorithm)
+	}
+	if keyID == "" {
+		return nil, fmt.Errorf("a key ID is required for %s signing", algorithm)
+	}
+
+	signer, err := parsePrivateKeyPEM(signingKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	if err := checkKeyMatchesMethod(signer.Public(), method); err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+
+	verifyKeys := map[string]crypto.PublicKey{keyID: signer.Public()}
+	for kid, data := range retiredKeys {
+		if kid == keyID {
+			return nil, fmt.Errorf("retired key %q reuses the active key ID", kid)
+		}
+		pub, err := parsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("retired key %q: %w", kid, err)
+		}
+		if err := checkKeyMatchesMethod(pub, method); err != nil {
+			return nil, fmt.Errorf("retired key %q: %w", kid, err)
+		}
+		verifyKeys[kid] = pub
+	}
+
+	return &JWTManager{
+		method:          method,
+		signKey:         signer,
+		keyID:           keyID,
+		verifyKeys:      verifyKeys,
+		expiration:      time.Duration(expirationMinutes) * time.Minute,
+		twoFAExpiration: 5 * time.Minute,
+	}, nil
+}
+
+// IsAsymmetric reports whether tokens are signed with a public-key
+// algorithm, i.e. whether a JWKS document is meaningful.
+func (jm *JWTManager) IsAsymmetric() bool {
+	return jm.verifyKeys != nil
+}
+
+// JWKS returns every public key currently accepted for verification. It is
+// empty for HS256 managers, whose secret must never be published.
+func (jm *JWTManager) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	kids := make([]string, 0, len(jm.verifyKeys))
+	for kid := range jm.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: jm.method.Alg()}
+		switch pub := jm.verifyKeys[kid].(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// parsePrivateKeyPEM accepts PKCS#8, PKCS#1 (RSA) and SEC1 (EC) keys.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unrecognised private key encoding %q", block.Type)
+}
+
+// parsePublicKeyPEM accepts PKIX public keys and PKCS#1 RSA public keys.
+func parsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unrecognised public key encoding %q", block.Type)
+}
+
+// checkKeyMatchesMethod rejects e.g. an RSA key configured for ES256, and
+// an EC key on a curve other than the one the ES algorithm mandates.
+func checkKeyMatchesMethod(pub crypto.PublicKey, method jwt.SigningMethod) error {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA:
+		if _, ok := pub.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("%s requires an RSA key, got %T", m.Alg(), pub)
+		}
+	case *jwt.SigningMethodECDSA:
+		ec, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an ECDSA key, got %T", m.Alg(), pub)
+		}
+		want := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}[m.Alg()]
+		if ec.Curve != want {
+			return fmt.Errorf("%s requires curve %s, got %s", m.Alg(), want.Params().Name, ec.Curve.Params().Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newECKeyPEM returns a fresh P-256 key as PKCS#8 private and PKIX public PEM.
+func newECKeyPEM(t *testing.T) (priv, pub []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+// TestAsymmetricJWTManager_Rotation issues a token with the old key, rotates
+// to a new key with the old one retired, and checks the old token still
+// validates while a token from an unknown key does not.
+func TestAsymmetricJWTManager_Rotation(t *testing.T) {
+	t.Parallel()
+
+	oldPriv, oldPub := newECKeyPEM(t)
+	newPriv, _ := newECKeyPEM(t)
+	strangerPriv, _ := newECKeyPEM(t)
+
+	oldMgr, err := NewAsymmetricJWTManager("ES256", "k1", oldPriv, nil, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _, err := oldMgr.GenerateToken(uuid.New(), "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewAsymmetricJWTManager("ES256", "k2", newPriv, map[string][]byte{"k1": oldPub}, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.ValidateToken(oldToken); err != nil {
+		t.Errorf("token signed by retired key rejected: %v", err)
+	}
+	newToken, _, err := rotated.GenerateToken(uuid.New(), "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.ValidateToken(newToken); err != nil {
+		t.Errorf("token signed by active key rejected: %v", err)
+	}
+
+	// Same kid as the active key but a different private key must fail.
+	stranger, err := NewAsymmetricJWTManager("ES256", "k2", strangerPriv, nil, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, _, _ := stranger.GenerateToken(uuid.New(), "", false, false)
+	if _, err := rotated.ValidateToken(forged); err == nil {
+		t.Error("token signed by unknown key accepted")
+	}
+
+	if got := len(rotated.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS has %d keys, want 2", got)
+	}
+}
+
+// TestHS256TokensRejectedByAsymmetricManager guards against algorithm
+// confusion: an HMAC token must never validate against an RSA/EC manager.
+func TestHS256TokensRejectedByAsymmetricManager(t *testing.T) {
+	t.Parallel()
+
+	priv, _ := newECKeyPEM(t)
+	mgr, err := NewAsymmetricJWTManager("ES256", "k1", priv, nil, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacToken, _, err := NewJWTManager("secret", 30).GenerateToken(uuid.New(), "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.ValidateToken(hmacToken); err == nil {
+		t.Error("HS256 token accepted by ES256 manager")
+	}
+	if mgr.JWKS().Keys[0].Crv != "P-256" {
+		t.Errorf("unexpected curve %q", mgr.JWKS().Keys[0].Crv)
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto"
 	"fmt"
 	"time"
 
@@ -26,6 +27,7 @@ type Claims struct {
 	TokenType         TokenType `json:"token_type,omitempty"`          // Type of token (full or 2fa)
 	IsDemo            bool      `json:"is_demo,omitempty"`             // True if this is a demo account
 	IsAdmin           bool      `json:"is_admin,omitempty"`            // True if user has admin privileges
+	IsViewer          bool      `json:"is_viewer,omitempty"`           // True if user is a read-only viewer
 	SessionStartedAt  int64     `json:"sst,omitempty"`                 // Unix seconds; carried across refreshes to enforce absolute cap
 	jwt.RegisteredClaims
 }
@@ -39,17 +41,23 @@ const SessionAbsoluteMax = 12 * time.Hour
 // cap has been hit. The caller should force a fresh login.
 var ErrSessionExpired = fmt.Errorf("session has exceeded absolute lifetime; please log in again")
 
-// JWTManager handles JWT operations
+// JWTManager handles JWT operations. It signs with HS256 and a shared
+// secret by default; NewAsymmetricJWTManager switches it to RSA/ECDSA
+// with a kid-indexed set of verification keys.
 type JWTManager struct {
-	secret          string
+	method          jwt.SigningMethod
+	signKey         interface{}                 // []byte for HMAC, crypto.Signer otherwise
+	keyID           string                      // kid header on issued tokens; empty for HS256
+	verifyKeys      map[string]crypto.PublicKey // kid -> public key; nil for HS256
 	expiration      time.Duration
 	twoFAExpiration time.Duration // Shorter expiration for 2FA pending tokens
 }
 
-// NewJWTManager creates a new JWT manager
+// NewJWTManager creates a new HS256 JWT manager
 func NewJWTManager(secret string, expirationMinutes int) *JWTManager {
 	return &JWTManager{
-		secret:          secret,
+		method:          jwt.SigningMethodHS256,
+		signKey:         []byte(secret),
 		expiration:      time.Duration(expirationMinutes) * time.Minute,
 		twoFAExpiration: 5 * time.Minute, // 5 minutes to complete 2FA
 	}
@@ -60,20 +68,20 @@ func NewJWTManager(secret string, expirationMinutes int) *JWTManager {
 func (jm *JWTManager) Expiration() time.Duration { return jm.expiration }
 
 // GenerateToken generates a new JWT token with a fresh session start.
-func (jm *JWTManager) GenerateToken(userID uuid.UUID, discordUserID string, isAdmin bool) (string, time.Time, error) {
-	return jm.generateToken(userID, discordUserID, true, TokenTypeFull, false, isAdmin, 0)
+func (jm *JWTManager) GenerateToken(userID uuid.UUID, discordUserID string, isAdmin, isViewer bool) (string, time.Time, error) {
+	return jm.generateToken(userID, discordUserID, true, TokenTypeFull, false, isAdmin, isViewer, 0)
 }
 
 // GenerateDemoToken generates a JWT token for a demo account.
 func (jm *JWTManager) GenerateDemoToken(userID uuid.UUID, discordUserID string) (string, time.Time, error) {
-	return jm.generateToken(userID, discordUserID, true, TokenTypeFull, true, false, 0)
+	return jm.generateToken(userID, discordUserID, true, TokenTypeFull, true, false, false, 0)
 }
 
 // GenerateTokenWithOptions generates a JWT token with additional options.
 // Kept for callers that need fine-grained control; new code should prefer
 // GenerateToken / RefreshToken.
 func (jm *JWTManager) GenerateTokenWithOptions(userID uuid.UUID, discordUserID string, twoFactorVerified bool, tokenType TokenType, isDemo bool, isAdmin bool) (string, time.Time, error) {
-	return jm.generateToken(userID, discordUserID, twoFactorVerified, tokenType, isDemo, isAdmin, 0)
+	return jm.generateToken(userID, discordUserID, twoFactorVerified, tokenType, isDemo, isAdmin, false, 0)
 }
 
 // generateToken builds and signs a JWT. sessionStartedAt = 0 means "this is
@@ -86,6 +94,7 @@ func (jm *JWTManager) generateToken(
 	tokenType TokenType,
 	isDemo bool,
 	isAdmin bool,
+	isViewer bool,
 	sessionStartedAt int64,
 ) (string, time.Time, error) {
 	var expiration time.Duration
@@ -108,6 +117,7 @@ func (jm *JWTManager) generateToken(
 		TokenType:         tokenType,
 		IsDemo:            isDemo,
 		IsAdmin:           isAdmin,
+		IsViewer:          isViewer,
 		SessionStartedAt:  sessionStartedAt,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
@@ -115,8 +125,11 @@ func (jm *JWTManager) generateToken(
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jm.secret))
+	token := jwt.NewWithClaims(jm.method, claims)
+	if jm.keyID != "" {
+		token.Header["kid"] = jm.keyID
+	}
+	tokenString, err := token.SignedString(jm.signKey)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -126,11 +139,13 @@ func (jm *JWTManager) generateToken(
 
 // Generate2FAToken generates a temporary token for 2FA verification
 func (jm *JWTManager) Generate2FAToken(userID uuid.UUID, discordUserID string, isAdmin bool) (string, time.Time, error) {
-	return jm.generateToken(userID, discordUserID, false, TokenType2FA, false, isAdmin, 0)
+	return jm.generateToken(userID, discordUserID, false, TokenType2FA, false, isAdmin, false, 0)
 }
 
 // RefreshToken issues a new full-access JWT that preserves the original
-// SessionStartedAt and identity claims. It refuses to refresh:
+// SessionStartedAt and identity claims. Role claims are copied from claims
+// as given, so callers should load them from the database first. It
+// refuses to refresh:
 //   - a 2FA-pending token (TokenType2FA) — that token must be exchanged via
 //     /auth/2fa/verify, not refreshed,
 //   - a session that has exceeded SessionAbsoluteMax — the user must log in
@@ -163,6 +178,7 @@ func (jm *JWTManager) RefreshToken(claims *Claims) (token string, expiresAt time
 		TokenTypeFull,
 		claims.IsDemo,
 		claims.IsAdmin,
+		claims.IsViewer,
 		sessionStart,
 	)
 	if err != nil {
@@ -173,12 +189,8 @@ func (jm *JWTManager) RefreshToken(claims *Claims) (token string, expiresAt time
 
 // ValidateToken validates a JWT token and returns the claims
 func (jm *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jm.secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, jm.keyFunc,
+		jwt.WithValidMethods([]string{jm.method.Alg()}))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -192,6 +204,21 @@ func (jm *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// keyFunc picks the verification key. HS256 uses the shared secret;
+// asymmetric managers look the key up by the token's kid so tokens signed
+// by a retired key keep validating until they expire.
+func (jm *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if jm.verifyKeys == nil {
+		return jm.signKey, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jm.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
 // Validate2FAToken validates a 2FA pending token
 func (jm *JWTManager) Validate2FAToken(tokenString string) (*Claims, error) {
 	claims, err := jm.ValidateToken(tokenString)
@@ -23,15 +23,19 @@ type TOTPConfig struct {
 	Digits    int
 	Period    uint
 	Algorithm otp.Algorithm
+	// BackupCodeCount is how many recovery codes GenerateBackupCodes issues
+	// when asked for the configured count.
+	BackupCodeCount int
 }
 
 // DefaultTOTPConfig returns the default TOTP configuration
 func DefaultTOTPConfig() *TOTPConfig {
 	return &TOTPConfig{
-		Issuer:    "dumpstation.monzim.com",
-		Digits:    8,
-		Period:    30, // 30 seconds
-		Algorithm: otp.AlgorithmSHA256,
+		Issuer:          "dumpstation.monzim.com",
+		Digits:          8,
+		Period:          30, // 30 seconds
+		Algorithm:       otp.AlgorithmSHA256,
+		BackupCodeCount: 10,
 	}
 }
 
@@ -48,6 +52,11 @@ func NewTOTPManager(config *TOTPConfig) *TOTPManager {
 	return &TOTPManager{config: config}
 }
 
+// BackupCodeCount returns the configured number of backup codes.
+func (tm *TOTPManager) BackupCodeCount() int {
+	return tm.config.BackupCodeCount
+}
+
 // TOTPSetupResult contains the data needed for user to set up 2FA
 type TOTPSetupResult struct {
 	Secret        string `json:"secret"`           // Base32-encoded secret for manual entry
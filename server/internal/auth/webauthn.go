@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthnChallengeTTL bounds how long a registration or login ceremony may
+// take, matching the timeout sent to the browser.
+const WebAuthnChallengeTTL = 5 * time.Minute
+
+// webAuthnAlgorithms are the passkey key algorithms offered at
+// registration, in preference order.
+var webAuthnAlgorithms = []webauthncose.COSEAlgorithmIdentifier{
+	webauthncose.AlgES256,
+	webauthncose.AlgEdDSA,
+	webauthncose.AlgRS256,
+}
+
+// webAuthnCredentialParameters returns webAuthnAlgorithms as the
+// parameters a registration is verified against.
+func webAuthnCredentialParameters() []protocol.CredentialParameter {
+	params := make([]protocol.CredentialParameter, len(webAuthnAlgorithms))
+	for i, alg := range webAuthnAlgorithms {
+		params[i] = protocol.CredentialParameter{Type: protocol.PublicKeyCredentialType, Algorithm: alg}
+	}
+	return params
+}
+
+// WebAuthnManager verifies passkey registrations and assertions for one
+// relying party with go-webauthn. The server asks for "none" attestation
+// and has no metadata service, so any authenticator the user controls can
+// be registered.
+type WebAuthnManager struct {
+	rpID    string
+	rpName  string
+	origins []string
+}
+
+// NewWebAuthnManager returns a manager for rpID (the site's host name, e.g.
+// "dumpstation.example.com"). origins lists the exact browser origins
+// allowed to run ceremonies; empty means "https://" + rpID. It returns nil
+// when rpID is empty, which disables passkeys.
+func NewWebAuthnManager(rpID, rpName string, origins []string) *WebAuthnManager {
+	if rpID == "" {
+		return nil
+	}
+	if rpName == "" {
+		rpName = "DumpStation"
+	}
+	if len(origins) == 0 {
+		origins = []string{"https://" + rpID}
+	}
+	return &WebAuthnManager{
+		rpID:    rpID,
+		rpName:  rpName,
+		origins: origins,
+	}
+}
+
+// NewWebAuthnChallenge returns a random base64url challenge.
+func NewWebAuthnChallenge() (string, error) {
+	challenge, err := protocol.CreateChallenge()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return challenge.String(), nil
+}
+
+// PublicKeyCredentialCreationOptions is the "publicKey" member passed to
+// navigator.credentials.create. Binary values are base64url strings.
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions is the "publicKey" member passed to
+// navigator.credentials.get.
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnRelyingParty identifies the server to the authenticator.
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUserEntity identifies the account a passkey belongs to.
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// WebAuthnCredentialParameter is one acceptable key algorithm.
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// WebAuthnCredentialDescriptor names an existing credential.
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// WebAuthnAuthenticatorSelection states authenticator requirements.
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions builds registration options for the user with the given
+// handle (an opaque, stable ID) and name. existing lists the raw IDs of
+// credentials already registered so the browser doesn't register the same
+// authenticator twice.
+func (m *WebAuthnManager) CreationOptions(challenge string, userHandle []byte, name string, existing [][]byte) PublicKeyCredentialCreationOptions {
+	return PublicKeyCredentialCreationOptions{
+		Challenge: challenge,
+		RP:        WebAuthnRelyingParty{ID: m.rpID, Name: m.rpName},
+		User: WebAuthnUserEntity{
+			ID:          base64.RawURLEncoding.EncodeToString(userHandle),
+			Name:        name,
+			DisplayName: name,
+		},
+		PubKeyCredParams:   credentialParameters(),
+		Timeout:            WebAuthnChallengeTTL.Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(existing),
+		AuthenticatorSelection: WebAuthnAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions builds login options limited to the user's credentials.
+func (m *WebAuthnManager) RequestOptions(challenge string, allowed [][]byte) PublicKeyCredentialRequestOptions {
+	return PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		RPID:             m.rpID,
+		Timeout:          WebAuthnChallengeTTL.Milliseconds(),
+		AllowCredentials: credentialDescriptors(allowed),
+		UserVerification: "preferred",
+	}
+}
+
+func credentialParameters() []WebAuthnCredentialParameter {
+	out := make([]WebAuthnCredentialParameter, 0, len(webAuthnAlgorithms))
+	for _, alg := range webAuthnAlgorithms {
+		out = append(out, WebAuthnCredentialParameter{Type: "public-key", Alg: int(alg)})
+	}
+	return out
+}
+
+func credentialDescriptors(ids [][]byte) []WebAuthnCredentialDescriptor {
+	out := make([]WebAuthnCredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, WebAuthnCredentialDescriptor{Type: "public-key", ID: base64.RawURLEncoding.EncodeToString(id)})
+	}
+	return out
+}
+
+// WebAuthnCredential is a verified, newly registered passkey.
+type WebAuthnCredential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key as sent by the authenticator
+	SignCount uint32
+}
+
+// VerifyRegistration checks a navigator.credentials.create response
+// against the expected challenge and returns the credential to store.
+func (m *WebAuthnManager) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*WebAuthnCredential, error) {
+	raw := protocol.AuthenticatorAttestationResponse{
+		AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientDataJSON},
+		AttestationObject:     attestationObject,
+	}
+	parsed, err := raw.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation: %w", err)
+	}
+	pcc := &protocol.ParsedCredentialCreationData{
+		Response: *parsed,
+		Raw:      protocol.CredentialCreationResponse{AttestationResponse: raw},
+	}
+	// User verification is only "preferred", so only presence is required.
+	if _, err := pcc.Verify(challenge, false, true, m.rpID, m.origins, nil,
+		protocol.TopOriginIgnoreVerificationMode, nil, webAuthnCredentialParameters()); err != nil {
+		return nil, err
+	}
+
+	authData := parsed.AttestationObject.AuthData
+	return &WebAuthnCredential{
+		ID:        authData.AttData.CredentialID,
+		PublicKey: authData.AttData.CredentialPublicKey,
+		SignCount: authData.Counter,
+	}, nil
+}
+
+// VerifyAssertion checks a navigator.credentials.get response made with the
+// stored credential credentialID. It returns the authenticator's new
+// signature counter, which the caller stores.
+func (m *WebAuthnManager) VerifyAssertion(challenge string, credentialID, publicKey []byte, storedCount uint32, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	id := base64.RawURLEncoding.EncodeToString(credentialID)
+	raw := protocol.CredentialAssertionResponse{
+		PublicKeyCredential: protocol.PublicKeyCredential{
+			Credential: protocol.Credential{ID: id, Type: string(protocol.PublicKeyCredentialType)},
+			RawID:      credentialID,
+		},
+		AssertionResponse: protocol.AuthenticatorAssertionResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientDataJSON},
+			AuthenticatorData:     authenticatorData,
+			Signature:             signature,
+		},
+	}
+	parsed, err := raw.Parse()
+	if err != nil {
+		return 0, fmt.Errorf("invalid assertion: %w", err)
+	}
+	if err := parsed.Verify(challenge, m.rpID, m.origins, nil,
+		protocol.TopOriginIgnoreVerificationMode, "", false, true, publicKey); err != nil {
+		return 0, err
+	}
+
+	// A counter that doesn't advance means the key may have been cloned.
+	// Authenticators that don't keep one always report zero.
+	counter := webauthn.Authenticator{SignCount: storedCount}
+	counter.UpdateCounter(parsed.Response.AuthenticatorData.Counter)
+	if counter.CloneWarning {
+		return 0, errors.New("signature counter did not increase; the authenticator may be cloned")
+	}
+	return counter.SignCount, nil
+}
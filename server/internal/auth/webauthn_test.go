@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+// Authenticator data flags.
+const (
+	authFlagUserPresent  = 0x01
+	authFlagAttestedData = 0x40
+)
+
+// cborEncode is a test-only encoder for the CBOR subset attestation
+// objects and COSE keys use. Map keys are written in the order given as
+// alternating key/value pairs.
+func cborEncode(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case [][2]any:
+		out := head(5, uint64(len(x)))
+		for _, kv := range x {
+			out = append(out, cborEncode(kv[0])...)
+			out = append(out, cborEncode(kv[1])...)
+		}
+		return out
+	}
+	panic("unsupported")
+}
+
+// testAuthenticator is a software ES256 authenticator.
+type testAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	credID []byte
+	count  uint32
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	data := append(rpHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	if attested {
+		x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+		y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credID)))
+		data = append(data, a.credID...)
+		data = append(data, cborEncode([][2]any{{1, 2}, {3, -7}, {-1, 1}, {-2, x}, {-3, y}})...)
+	}
+	return data
+}
+
+func clientDataJSON(t *testing.T, typ, challenge, origin string) []byte {
+	t.Helper()
+	raw, err := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestWebAuthnRegisterAndAssert(t *testing.T) {
+	m := NewWebAuthnManager("ds.example.com", "", nil)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &testAuthenticator{key: key, credID: []byte("cred-1")}
+	const origin = "https://ds.example.com"
+
+	challenge, _ := NewWebAuthnChallenge()
+	attObj := cborEncode([][2]any{
+		{"fmt", "none"},
+		{"attStmt", [][2]any{}},
+		{"authData", a.authData("ds.example.com", authFlagUserPresent|authFlagAttestedData, true)},
+	})
+	cred, err := m.VerifyRegistration(challenge, clientDataJSON(t, "webauthn.create", challenge, origin), attObj)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if string(cred.ID) != "cred-1" {
+		t.Fatalf("credential ID = %q", cred.ID)
+	}
+	if _, err := m.VerifyRegistration("other", clientDataJSON(t, "webauthn.create", challenge, origin), attObj); err == nil {
+		t.Error("registration accepted with the wrong challenge")
+	}
+	if _, err := m.VerifyRegistration(challenge, clientDataJSON(t, "webauthn.create", challenge, "https://evil.example"), attObj); err == nil {
+		t.Error("registration accepted from another origin")
+	}
+
+	assert := func(challenge, rpID string, count uint32) (uint32, error) {
+		a.count = count
+		cd := clientDataJSON(t, "webauthn.get", challenge, origin)
+		authData := a.authData(rpID, authFlagUserPresent, false)
+		hash := sha256.Sum256(cd)
+		digest := sha256.Sum256(append(append([]byte(nil), authData...), hash[:]...))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.VerifyAssertion(challenge, cred.ID, cred.PublicKey, 4, cd, authData, sig)
+	}
+
+	challenge, _ = NewWebAuthnChallenge()
+	if got, err := assert(challenge, "ds.example.com", 5); err != nil || got != 5 {
+		t.Fatalf("VerifyAssertion = %d, %v; want 5", got, err)
+	}
+	if _, err := assert(challenge, "ds.example.com", 4); err == nil {
+		t.Error("assertion accepted with a counter that did not increase")
+	}
+	if _, err := assert(challenge, "other.example.com", 6); err == nil {
+		t.Error("assertion accepted for another RP ID")
+	}
+
+	a.count = 7
+	cd := clientDataJSON(t, "webauthn.get", challenge, origin)
+	if _, err := m.VerifyAssertion(challenge, cred.ID, cred.PublicKey, 4, cd, a.authData("ds.example.com", authFlagUserPresent, false), []byte("bogus")); err == nil {
+		t.Error("assertion accepted with a bad signature")
+	}
+}
+
+// TestVerifyRegistrationRejectsMalformed feeds attestation objects that
+// aren't valid CBOR or lack authenticator data.
+func TestVerifyRegistrationRejectsMalformed(t *testing.T) {
+	m := NewWebAuthnManager("ds.example.com", "", nil)
+	challenge, _ := NewWebAuthnChallenge()
+	cd := clientDataJSON(t, "webauthn.create", challenge, "https://ds.example.com")
+	for _, in := range [][]byte{
+		{},
+		{0x5f},       // indefinite byte string
+		{0x42, 0x01}, // truncated byte string
+		{0xa1, 0x01}, // map missing its value
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // absurd array length
+		cborEncode([][2]any{{"fmt", "none"}, {"attStmt", [][2]any{}}}),
+	} {
+		if _, err := m.VerifyRegistration(challenge, cd, in); err == nil {
+			t.Errorf("VerifyRegistration(% x) succeeded", in)
+		}
+	}
+}
+
+// Real ceremonies against webauthn.io, from the go-webauthn test suite: a
+// Titan key registering with "none" attestation, and a MacOS Touch ID
+// login with its stored credential.
+const (
+	realRegistrationChallenge  = "sVt4ScceMzqFSnfAq8hgLzblvo3fa4_aFVEcIESHIJ0"
+	realRegistrationClientData = "eyJjaGFsbGVuZ2UiOiJzVnQ0U2NjZU16cUZTbmZBcThoZ0x6Ymx2bzNmYTRfYUZWRWNJRVNISUowIiwib3JpZ2luIjoiaHR0cHM6Ly93ZWJhdXRobi5pbyIsInR5cGUiOiJ3ZWJhdXRobi5jcmVhdGUifQ"
+	realAttestationObject      = "o2NmbXRkbm9uZWdhdHRTdG10oGhhdXRoRGF0YVjEdKbqkhPJnC90siSSsyDPQCYqlMGpUKA5fyklC2CEHvBBAAAAAAAAAAAAAAAAAAAAAAAAAAAAQOia8u9zP1lVg6Fy7BsUbAVVR6T1g6TctRExl1BLyS3UwJ-RMOpwxlOlvIjt2ZHCxKq_ggcL8dKdlgMc7fEYsEGlAQIDJiABIVgg--n_QvZithDycYmnifk6vMHiwBP6kugn2PlsnvkrcSgiWCBAlBYm2B-rMtQlp5MxGTLoGDHoktxb0p364Hy2BH9U2Q"
+	realRegistrationCredID     = "6Jry73M_WVWDoXLsGxRsBVVHpPWDpNy1ETGXUEvJLdTAn5Ew6nDGU6W8iO3ZkcLEqr-CBwvx0p2WAxzt8RiwQQ"
+
+	realAssertionChallenge  = "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k"
+	realAssertionCredID     = "AI7D5q2P0LS-Fal9ZT7CHM2N5BLbUunF92T8b6iYC199bO2kagSuU05-5dZGqb1SP0A0lyTWng"
+	realAssertionPublicKey  = "pQMmIAEhWCAoCF-x0dwEhzQo-ABxHIAgr_5WL6cJceREc81oIwFn7iJYIHEHx8ZhBIE42L26-rSC_3l0ZaWEmsHAKyP9rgslApUdAQI"
+	realAssertionClientData = "eyJjaGFsbGVuZ2UiOiJFNFBUY0lIX0hmWDFwQzZTaWdrMVNDOU5BbGdlenROMDQzOXZpOHpfYzlrIiwibmV3X2tleXNfbWF5X2JlX2FkZGVkX2hlcmUiOiJkbyBub3QgY29tcGFyZSBjbGllbnREYXRhSlNPTiBhZ2FpbnN0IGEgdGVtcGxhdGUuIFNlZSBodHRwczovL2dvby5nbC95YWJQZXgiLCJvcmlnaW4iOiJodHRwczovL3dlYmF1dGhuLmlvIiwidHlwZSI6IndlYmF1dGhuLmdldCJ9"
+	realAuthenticatorData   = "dKbqkhPJnC90siSSsyDPQCYqlMGpUKA5fyklC2CEHvBFXJJiGa3OAAI1vMYKZIsLJfHwVQMANwCOw-atj9C0vhWpfWU-whzNjeQS21Lpxfdk_G-omAtffWztpGoErlNOfuXWRqm9Uj9ANJck1p6lAQIDJiABIVggKAhfsdHcBIc0KPgAcRyAIK_-Vi-nCXHkRHPNaCMBZ-4iWCBxB8fGYQSBONi9uvq0gv95dGWlhJrBwCsj_a4LJQKVHQ"
+	realAssertionSignature  = "MEUCIBtIVOQxzFYdyWQyxaLR0tik1TnuPhGVhXVSNgFwLmN5AiEAnxXdCq0UeAVGWxOaFcjBZ_mEZoXqNboY5IkQDdlWZYc"
+	realAssertionSignCount  = 1553097241
+)
+
+func mustB64(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+func TestWebAuthnRealAuthenticator(t *testing.T) {
+	m := NewWebAuthnManager("webauthn.io", "", nil)
+
+	cred, err := m.VerifyRegistration(realRegistrationChallenge, mustB64(t, realRegistrationClientData), mustB64(t, realAttestationObject))
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if got := base64.RawURLEncoding.EncodeToString(cred.ID); got != realRegistrationCredID {
+		t.Errorf("credential ID = %s, want %s", got, realRegistrationCredID)
+	}
+	if len(cred.PublicKey) == 0 {
+		t.Error("registration returned no public key")
+	}
+
+	credID, publicKey := mustB64(t, realAssertionCredID), mustB64(t, realAssertionPublicKey)
+	clientData, authData, sig := mustB64(t, realAssertionClientData), mustB64(t, realAuthenticatorData), mustB64(t, realAssertionSignature)
+	count, err := m.VerifyAssertion(realAssertionChallenge, credID, publicKey, 0, clientData, authData, sig)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if count != realAssertionSignCount {
+		t.Errorf("sign count = %d, want %d", count, realAssertionSignCount)
+	}
+	if _, err := m.VerifyAssertion(realAssertionChallenge, credID, publicKey, realAssertionSignCount, clientData, authData, sig); err == nil {
+		t.Error("replayed assertion accepted")
+	}
+	if _, err := NewWebAuthnManager("example.com", "", []string{"https://webauthn.io"}).
+		VerifyAssertion(realAssertionChallenge, credID, publicKey, 0, clientData, authData, sig); err == nil {
+		t.Error("assertion accepted for another RP ID")
+	}
+}
+
+// FuzzVerifyRegistration checks hostile attestation objects are rejected
+// without panicking.
+func FuzzVerifyRegistration(f *testing.F) {
+	seed, err := base64.RawURLEncoding.DecodeString(realAttestationObject)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Add([]byte{0xa1, 0x01})
+	m := NewWebAuthnManager("webauthn.io", "", nil)
+	clientData, err := base64.RawURLEncoding.DecodeString(realRegistrationClientData)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, attestation []byte) {
+		_, _ = m.VerifyRegistration(realRegistrationChallenge, clientData, attestation)
+	})
+}
+
+// FuzzVerifyAssertion checks hostile authenticator data and stored keys
+// are rejected without panicking.
+func FuzzVerifyAssertion(f *testing.F) {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			f.Fatal(err)
+		}
+		return b
+	}
+	f.Add(decode(realAuthenticatorData), decode(realAssertionPublicKey))
+	f.Add([]byte{}, []byte{0xa1, 0x01})
+	m := NewWebAuthnManager("webauthn.io", "", nil)
+	credID, clientData, sig := decode(realAssertionCredID), decode(realAssertionClientData), decode(realAssertionSignature)
+	f.Fuzz(func(t *testing.T, authData, publicKey []byte) {
+		_, _ = m.VerifyAssertion(realAssertionChallenge, credID, publicKey, 0, clientData, authData, sig)
+	})
+}
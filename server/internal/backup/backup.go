@@ -4,33 +4,132 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"github.com/monzim/db_proxy/v1/internal/notification"
 	"github.com/monzim/db_proxy/v1/internal/repository"
 	"github.com/monzim/db_proxy/v1/internal/storage"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 )
 
+// dumpTimeout bounds pg_dump. The upload has its own limit in the storage
+// package, so a slow dump doesn't eat into it.
+const dumpTimeout = 30 * time.Minute
+
 // Service handles backup operations
 type Service struct {
 	repo           *repository.Repository
 	versionManager *VersionManager
+	retries        uploadRetries
+	progress       transferProgress
+	skipKeyCheck   bool   // see SetKeyCollisionCheck
+	restoreDir     string // see SetRestoreTempDir
+	restores       restoreLimiter
+	hostPolicy     *utils.HostPolicy // see SetHostPolicy
+	// ctx is the parent of every run's context; Shutdown cancels it.
+	ctx      context.Context
+	shutdown context.CancelFunc
 }
 
 // NewService creates a new backup service
 func NewService(repo *repository.Repository) *Service {
+	ctx, shutdown := context.WithCancel(context.Background())
 	return &Service{
+		ctx:            ctx,
+		shutdown:       shutdown,
 		repo:           repo,
 		versionManager: NewVersionManager(),
+		retries:        uploadRetries{entries: make(map[uuid.UUID]*retainedUpload)},
+		progress:       transferProgress{entries: make(map[uuid.UUID]*models.BackupProgress)},
+	}
+}
+
+// Shutdown cancels in-flight backup runs: their pg_dump is killed and any
+// upload aborted, and they are recorded as failed. Call it when the server
+// is stopping.
+func (s *Service) Shutdown() {
+	if s.shutdown != nil {
+		s.shutdown()
+	}
+}
+
+// runContext is the parent context for a backup run.
+func (s *Service) runContext() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// LoadSSLModeCache seeds the per-server SSL mode cache from the database
+// and persists later probe results, so hosts without SSL skip the SSL
+// attempt across restarts.
+func (s *Service) LoadSSLModeCache() error {
+	entries, err := s.repo.ListSSLModeCache()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		s.versionManager.LoadSSLMode(e.HostPort, SSLMode(e.SSLMode), e.CheckedAt)
+	}
+	s.versionManager.OnSSLModeChange(func(key string, mode SSLMode, checkedAt time.Time) {
+		entry := &models.SSLModeCacheEntry{HostPort: key, SSLMode: string(mode), CheckedAt: checkedAt}
+		if err := s.repo.SaveSSLModeCacheEntry(entry); err != nil {
+			log.Printf("Failed to persist SSL mode for %s: %v", key, err)
+		}
+	})
+	return nil
+}
+
+// SetPgBinDirs configures per-major-version PostgreSQL client directories.
+func (s *Service) SetPgBinDirs(dirs map[string]string) {
+	s.versionManager.SetBinDirs(dirs)
+}
+
+// LogPgToolchain probes the configured pg_dump/psql binaries and logs which
+// versions are usable, so a missing client shows up at startup rather than
+// on the first scheduled backup.
+func (s *Service) LogPgToolchain() []BinaryCheck {
+	checks := s.versionManager.CheckBinaries()
+	for _, c := range checks {
+		if c.Error != "" {
+			logging.Warnf("[PG_TOOLS] ⚠️  %s (PostgreSQL %s) at %s unavailable: %s", c.Tool, c.Major, c.Path, c.Error)
+			continue
+		}
+		logging.Infof("[PG_TOOLS] ✅ %s (PostgreSQL %s) at %s: %s", c.Tool, c.Major, c.Path, c.Version)
+	}
+	return checks
+}
+
+// CheckPgToolchain probes pg_dump/psql for the PATH default, configured
+// majors and the given extra majors without logging.
+func (s *Service) CheckPgToolchain(extraMajors ...string) []BinaryCheck {
+	return s.versionManager.CheckBinaries(extraMajors...)
+}
+
+// largeObjectArgs maps DatabaseConfig.Blobs to pg_dump options. --blobs and
+// --no-blobs are understood by every pg_dump DumpStation supports (16 added
+// --large-objects as the new spelling and kept the old one). --blobs is
+// left off schema-only dumps, which carry no data to include.
+func largeObjectArgs(dbConfig *models.DatabaseConfig) []string {
+	switch {
+	case dbConfig.Blobs == nil:
+		return nil
+	case !*dbConfig.Blobs:
+		return []string{"--no-blobs"}
+	case dbConfig.SchemaOnly:
+		return nil
 	}
+	return []string{"--blobs"}
 }
 
 // truncateAndRewind clears any bytes already written to f and resets the
@@ -88,6 +187,14 @@ func writePgPassFile(dbConfig *models.DatabaseConfig) (string, error) {
 	return f.Name(), nil
 }
 
+// recordEvent appends a phase to the backup timeline. Failures are only
+// logged: the timeline is diagnostic and must never fail a backup.
+func (s *Service) recordEvent(backupID uuid.UUID, phase models.BackupEventPhase, message string) {
+	if err := s.repo.AddBackupEvent(backupID, phase, message); err != nil {
+		log.Printf("Failed to record backup event %s for %s: %v", phase, backupID, err)
+	}
+}
+
 // ExecuteBackup performs a database backup
 func (s *Service) ExecuteBackup(dbConfig *models.DatabaseConfig) error {
 	return s.ExecuteBackupWithID(dbConfig, uuid.Nil)
@@ -97,7 +204,13 @@ func (s *Service) ExecuteBackup(dbConfig *models.DatabaseConfig) error {
 func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID uuid.UUID) error {
 	// Check if config is paused
 	if dbConfig.Paused {
-		log.Printf("Skipping backup for paused database: %s", dbConfig.Name)
+		s.skipBackup(dbConfig, backupID, "database is paused")
+		return nil
+	}
+	// Checked here rather than only when scheduling so runs delayed by the
+	// concurrency limit can't spill out of the window either.
+	if !dbConfig.InBackupWindow(time.Now()) {
+		s.skipBackup(dbConfig, backupID, fmt.Sprintf("outside backup window %s", dbConfig.BackupWindow))
 		return nil
 	}
 
@@ -112,7 +225,7 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		}
 	} else {
 		// Create backup record for scheduled backups
-		backup, err = s.repo.CreateBackup(dbConfig.ID, models.BackupStatusPending)
+		backup, err = s.repo.CreateBackup(dbConfig.ID, models.BackupStatusPending, models.BackupTriggerSchedule, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create backup record: %w", err)
 		}
@@ -124,6 +237,8 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		log.Printf("Failed to update backup status to running: %v", err)
 	}
 
+	s.recordEvent(backup.ID, models.BackupEventStarted, "")
+
 	// Audit: backup started. Demo accounts are suppressed at the repo
 	// layer, so it's safe to log unconditionally.
 	bid := backup.ID
@@ -139,21 +254,17 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		"",
 	)
 
+	if err := s.checkHost(dbConfig.Host); err != nil {
+		return s.handleBackupError(backup.ID, dbConfig, err.Error())
+	}
+
 	// Get storage config
 	storageConfig, err := s.repo.GetStorageConfig(dbConfig.StorageID)
 	if err != nil {
 		return s.handleBackupError(backup.ID, dbConfig, fmt.Sprintf("failed to get storage config: %v", err))
 	}
 
-	// Get notification config — may fan out to Discord, Telegram, or both
-	// depending on which credentials the user has filled in.
-	var notifier notification.Notifier
-	if dbConfig.NotificationID != nil {
-		notifConfig, err := s.repo.GetNotificationConfig(*dbConfig.NotificationID)
-		if err == nil && notifConfig != nil {
-			notifier = notification.NotifierFromConfig(notifConfig)
-		}
-	}
+	notifier := s.notifierFor(dbConfig)
 
 	// Detect PostgreSQL version if not set or needs refresh
 	postgresVersion := dbConfig.PostgresVersion
@@ -169,14 +280,25 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 
 	log.Printf("Using PostgreSQL version: %s for database %s", postgresVersion, dbConfig.Name)
 
+	// Best effort: the dump itself doesn't need it.
+	if sourceSize, err := s.sourceDatabaseSize(s.versionManager.GetPsqlVersion(postgresVersion), dbConfig); err != nil {
+		log.Printf("Warning: Could not read size of database %s: %v", dbConfig.Name, err)
+	} else if err := s.repo.SetBackupSourceSize(backup.ID, sourceSize); err != nil {
+		log.Printf("Failed to persist source database size: %v", err)
+	}
+
 	// Perform backup
 	startTime := time.Now()
 	timestamp := startTime.Format("20060102_150405")
 
 	log.Printf("Starting backup for database: %s (PostgreSQL %s)", dbConfig.Name, postgresVersion)
 
+	// runCtx ends when the service shuts down, which stops the dump and
+	// aborts an in-flight upload. Each step adds its own deadline.
+	runCtx := s.runContext()
+
 	// Create pg_dump command with version-specific settings
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := context.WithTimeout(runCtx, dumpTimeout)
 	defer cancel()
 
 	pgDumpCmd := s.versionManager.GetPgDumpVersion(postgresVersion)
@@ -190,11 +312,18 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		pgDumpMajor := s.versionManager.ParseMajorVersion(pgDumpVersionInfo)
 		if !s.versionManager.IsCompatibleVersion(pgDumpMajor, postgresVersion) {
 			log.Printf("Warning: pg_dump version %s may not be compatible with PostgreSQL %s. Attempting anyway.", pgDumpMajor, postgresVersion)
+			s.recordVersionWarning(backup.ID, dbConfig, notifier, fmt.Sprintf(
+				"pg_dump %s is older than the PostgreSQL %s server; the dump may be incomplete. Install pg_dump %s or newer.",
+				pgDumpMajor, postgresVersion, postgresVersion))
 		}
 	}
 
 	dumpFormat := s.versionManager.GetDumpFormatForVersion(postgresVersion)
 	compressionLevel := s.versionManager.GetDumpCompressionLevel(postgresVersion)
+	if dbConfig.SchemaOnly {
+		// Schema dumps are small and must stay text so they can be diffed.
+		dumpFormat = string(models.DumpFormatPlain)
+	}
 
 	args := []string{
 		"--host", dbConfig.Host,
@@ -202,19 +331,53 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		"--username", dbConfig.Username,
 		"--dbname", dbConfig.DBName,
 		"--no-password",
-		"--verbose",
+	}
+	if !dbConfig.NoVerbose {
+		args = append(args, "--verbose")
+	}
+	if dbConfig.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if dbConfig.NoOwner {
+		args = append(args, "--no-owner")
+	}
+	if dbConfig.NoPrivileges {
+		args = append(args, "--no-acl")
+	}
+	args = append(args, largeObjectArgs(dbConfig)...)
+	if dbConfig.LockWaitTimeoutSeconds > 0 {
+		// pg_dump resets statement_timeout and lock_timeout to 0 on its own
+		// session, so a server-side timeout set through PGOPTIONS would not
+		// stick; --lock-wait-timeout is what bounds the wait for table locks.
+		args = append(args, fmt.Sprintf("--lock-wait-timeout=%ds", dbConfig.LockWaitTimeoutSeconds))
+	}
+	// Re-checked here as well as on input so a row edited directly in the
+	// database can't smuggle in an option outside the allowlist.
+	if len(dbConfig.ExtraArgs) > 0 {
+		if err := utils.ValidatePgDumpExtraArgs(dbConfig.ExtraArgs); err != nil {
+			return s.handleBackupError(backup.ID, dbConfig, fmt.Sprintf("invalid extra pg_dump arguments: %v", err))
+		}
+		args = append(args, dbConfig.ExtraArgs...)
 	}
 
-	// Add format-specific arguments. Storage object name embeds backup.ID
-	// (UUID) so concurrent backups of the same database within the same
-	// second cannot collide on the destination key.
-	var backupFilename string
+	// Add format-specific arguments. The object name comes from the
+	// database's filename template, which always embeds backup.ID (UUID) so
+	// concurrent backups within the same second cannot collide on the
+	// destination key; resolveObjectKey guards against templates that
+	// don't. Both formats use the same template; only the extension differs.
+	baseFilename := utils.RenderFilename(dbConfig.FilenameTemplate, utils.FilenameVars{
+		Name:     dbConfig.Name,
+		DBName:   dbConfig.DBName,
+		BackupID: backup.ID.String(),
+		Time:     startTime,
+	})
+	var fileExt string
 	if dumpFormat == "custom" {
 		args = append(args, "-Fc", "-Z", compressionLevel)
-		backupFilename = fmt.Sprintf("%s_%s_%s.dump", dbConfig.Name, timestamp, backup.ID.String())
+		fileExt = ".dump"
 	} else {
 		args = append(args, "--format=plain")
-		backupFilename = fmt.Sprintf("%s_%s_%s.sql", dbConfig.DBName, timestamp, backup.ID.String())
+		fileExt = ".sql"
 	}
 
 	// Create local temp file via os.CreateTemp so concurrent backups never
@@ -227,13 +390,29 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 	defer outFile.Close()
 	defer os.Remove(tempFilePath)
 
+	// MaxSizeBytes limits the stored object. A custom dump is uploaded as
+	// pg_dump writes it, so it can be stopped as soon as it is too big; a
+	// plain dump is gzipped first and is checked once compressed.
+	var streamLimit *int64
+	if dumpFormat != string(models.DumpFormatPlain) {
+		streamLimit = dbConfig.MaxSizeBytes
+	}
+
 	// Execute backup with SSL fallback
-	sslMode, err := s.executeBackupWithSSLFallback(ctx, pgDumpCmd, args, dbConfig, outFile)
+	s.recordEvent(backup.ID, models.BackupEventDumpStarted, fmt.Sprintf("%s (%s format)", pgDumpCmd, dumpFormat))
+	sslMode, dumpStderr, err := s.executeBackupWithSSLFallback(ctx, pgDumpCmd, postgresVersion, args, dbConfig, outFile, streamLimit)
+	s.recordDumpLog(backup.ID, dbConfig, dumpStderr)
+	var missing *missingBinaryError
+	if errors.Is(err, errMaxSizeExceeded) || errors.As(err, &missing) {
+		// Nothing is uploaded; the partial dump is removed with the temp file.
+		return s.handleBackupError(backup.ID, dbConfig, err.Error())
+	}
 	if err != nil {
-		return s.handleBackupError(backup.ID, dbConfig, fmt.Sprintf("pg_dump failed: %v", err))
+		return s.handleBackupError(backup.ID, dbConfig, dumpFailureMessage(err, dumpStderr))
 	}
 
 	log.Printf("Backup executed successfully with SSL mode: %s", sslMode)
+	s.recordSSLMode(backup.ID, dbConfig, notifier, sslMode)
 
 	// Get file size
 	fileInfo, err := outFile.Stat()
@@ -242,6 +421,34 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 	}
 
 	sizeBytes := fileInfo.Size()
+	s.recordEvent(backup.ID, models.BackupEventDumpFinished, fmt.Sprintf("%d bytes", sizeBytes))
+
+	// Plain dumps are uncompressed SQL; gzip them before upload. Custom
+	// dumps are already compressed by pg_dump.
+	uploadPath := tempFilePath
+	compression := ""
+	if dumpFormat == string(models.DumpFormatPlain) {
+		gzPath, gzSize, err := gzipToTemp(outFile)
+		if err != nil {
+			return s.handleBackupError(backup.ID, dbConfig, fmt.Sprintf("failed to compress dump: %v", err))
+		}
+		defer os.Remove(gzPath)
+		log.Printf("Compressed plain dump for %s: %d -> %d bytes", dbConfig.Name, sizeBytes, gzSize)
+		uploadPath = gzPath
+		compression = models.CompressionGzip
+		fileExt += ".gz"
+		sizeBytes = gzSize
+		if err := checkStoredSize(gzSize, dbConfig.MaxSizeBytes); err != nil {
+			return s.handleBackupError(backup.ID, dbConfig, err.Error())
+		}
+	}
+
+	// A missing checksum only weakens later verification; never fail the
+	// backup over it.
+	checksum, err := fileSHA256(uploadPath)
+	if err != nil {
+		log.Printf("Failed to checksum dump for %s: %v", dbConfig.Name, err)
+	}
 
 	// Upload to storage
 	storageClient, err := storage.NewStorageClient(storageConfig)
@@ -249,7 +456,18 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		return s.handleBackupError(backup.ID, dbConfig, fmt.Sprintf("failed to create storage client: %v", err))
 	}
 
-	objectKey := storage.GetObjectKey(dbConfig.ID.String(), backupFilename)
+	var exists objectExistsFunc
+	if !s.skipKeyCheck {
+		exists = storageClient.ObjectExists
+	}
+	backupFilename, objectKey, err := resolveObjectKey(runCtx, exists, dbConfig.ID.String(), baseFilename, fileExt)
+	if err != nil {
+		return s.handleBackupError(backup.ID, dbConfig, err.Error())
+	}
+	if err := s.repo.SetBackupFileName(backup.ID, backupFilename); err != nil {
+		log.Printf("Failed to persist backup file name: %v", err)
+	}
+
 	metadata := map[string]string{
 		"database":         dbConfig.Name,
 		"database-id":      dbConfig.ID.String(),
@@ -258,22 +476,141 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 		"postgres-version": postgresVersion,
 		"dump-format":      dumpFormat,
 	}
+	if compression != "" {
+		metadata["compression"] = compression
+	}
+	if dbConfig.NoOwner {
+		metadata["no-owner"] = "true"
+	}
+	if dbConfig.NoPrivileges {
+		metadata["no-privileges"] = "true"
+	}
+	if dbConfig.Blobs != nil {
+		metadata["blobs"] = fmt.Sprintf("%t", *dbConfig.Blobs)
+	}
+	if len(dbConfig.ExtraArgs) > 0 {
+		metadata["extra-args"] = strings.Join(dbConfig.ExtraArgs, " ")
+	}
+	artifact := &dumpArtifact{
+		path:            uploadPath,
+		objectKey:       objectKey,
+		metadata:        metadata,
+		sizeBytes:       sizeBytes,
+		dumpFormat:      dumpFormat,
+		compression:     compression,
+		postgresVersion: postgresVersion,
+		schemaOnly:      dbConfig.SchemaOnly,
+		checksum:        checksum,
+		storageID:       storageConfig.ID,
+	}
 
-	if err := storageClient.UploadFile(tempFilePath, objectKey, metadata); err != nil {
-		return s.handleBackupError(backup.ID, dbConfig, fmt.Sprintf("failed to upload to storage: %v", err))
+	if uploadErr := s.uploadArtifact(runCtx, backup.ID, storageClient, storageConfig, artifact); uploadErr != nil {
+		errorMsg := fmt.Sprintf("failed to upload to %s (%s): %v", storageConfig.Name, storageConfig.Provider, uploadErr)
+		// The dump itself is good: keep it for retry-upload instead of
+		// making the next attempt hit the source database again.
+		_ = outFile.Close()
+		if until, ok := s.retainUpload(backup.ID, artifact); ok {
+			errorMsg += fmt.Sprintf("; dump kept for retry until %s", until.UTC().Format(time.RFC3339))
+		}
+		return s.handleBackupError(backup.ID, dbConfig, errorMsg)
 	}
 
+	s.completeBackup(backup.ID, dbConfig, storageClient, notifier, artifact, startTime)
+	return nil
+}
+
+// dumpArtifact is a finished dump on local disk and everything needed to
+// upload it and record the result.
+type dumpArtifact struct {
+	path            string
+	objectKey       string
+	metadata        map[string]string
+	sizeBytes       int64
+	dumpFormat      string
+	compression     string
+	postgresVersion string
+	schemaOnly      bool
+	checksum        string // hex SHA-256 of the uploaded object; empty if unknown
+	storageID       uuid.UUID
+}
+
+// notifierFor builds the database's notifier — which may fan out to
+// Discord, Telegram, or both depending on which credentials the user has
+// filled in — or returns nil when none is configured.
+func (s *Service) notifierFor(dbConfig *models.DatabaseConfig) notification.Notifier {
+	if dbConfig.NotificationID == nil {
+		return nil
+	}
+	notifConfig, err := s.repo.GetNotificationConfig(*dbConfig.NotificationID)
+	if err != nil || notifConfig == nil {
+		return nil
+	}
+	return notification.NotifierFromConfig(notifConfig)
+}
+
+// uploadArtifact uploads the dump and records the attempt on the backup.
+func (s *Service) uploadArtifact(ctx context.Context, backupID uuid.UUID, storageClient *storage.StorageClient, storageConfig *models.StorageConfig, artifact *dumpArtifact) error {
+	s.recordEvent(backupID, models.BackupEventUploadStarted, artifact.objectKey)
+	upload, err := s.repo.StartBackupUpload(backupID, storageConfig, artifact.objectKey)
+	if err != nil {
+		log.Printf("Failed to record upload start: %v", err)
+	}
+	progress, done := s.progress.start(backupID, models.TransferUpload)
+	uploadErr := storageClient.UploadFileWithProgress(ctx, artifact.path, artifact.objectKey, artifact.metadata, progress)
+	done()
+	if upload != nil {
+		if err := s.repo.FinishBackupUpload(upload.ID, uploadErr); err != nil {
+			log.Printf("Failed to record upload result: %v", err)
+		}
+	}
+	if uploadErr != nil {
+		return uploadErr
+	}
+	s.recordEvent(backupID, models.BackupEventUploadFinished, "")
+	return nil
+}
+
+// completeBackup marks an uploaded backup successful, notifies, audits and
+// applies the rotation policy.
+func (s *Service) completeBackup(backupID uuid.UUID, dbConfig *models.DatabaseConfig, storageClient *storage.StorageClient, notifier notification.Notifier, artifact *dumpArtifact, startTime time.Time) {
+	sizeBytes := artifact.sizeBytes
+	dumpFormat := artifact.dumpFormat
+
 	// Update backup record as success
-	err = s.repo.UpdateBackupStatus(backup.ID, models.BackupStatusSuccess, &sizeBytes, objectKey, nil)
+	err := s.repo.UpdateBackupStatus(backupID, models.BackupStatusSuccess, &sizeBytes, artifact.objectKey, nil)
 	if err != nil {
 		log.Printf("Failed to update backup status to success: %v", err)
 	}
+	s.recordEvent(backupID, models.BackupEventCompleted, "")
+
+	// A success ends any failure streak; the next failure alerts right away.
+	if err := s.repo.ClearNotificationState(dbConfig.ID, models.NotificationEventBackupFailure); err != nil {
+		log.Printf("Failed to reset notification cooldown: %v", err)
+	}
 
 	// Persist the dump format so the restore path can pick the right tool
 	// (pg_restore for custom, psql for plain).
-	if err := s.repo.SetBackupDumpFormat(backup.ID, models.DumpFormat(dumpFormat)); err != nil {
+	if err := s.repo.SetBackupDumpFormat(backupID, models.DumpFormat(dumpFormat)); err != nil {
 		log.Printf("Failed to persist dump format: %v", err)
 	}
+	if err := s.repo.SetBackupPostgresVersion(backupID, artifact.postgresVersion); err != nil {
+		log.Printf("Failed to persist postgres version: %v", err)
+	}
+	if artifact.compression != "" {
+		if err := s.repo.SetBackupCompression(backupID, artifact.compression); err != nil {
+			log.Printf("Failed to persist compression: %v", err)
+		}
+	}
+	if artifact.checksum != "" {
+		if err := s.repo.SetBackupChecksum(backupID, artifact.checksum); err != nil {
+			log.Printf("Failed to persist checksum: %v", err)
+		}
+	}
+	if artifact.schemaOnly {
+		if err := s.repo.SetBackupSchemaOnly(backupID); err != nil {
+			log.Printf("Failed to persist schema-only flag: %v", err)
+		}
+	}
 
 	duration := time.Since(startTime)
 	log.Printf("Backup completed for %s in %v. File size: %d bytes (format: %s)", dbConfig.Name, duration, sizeBytes, dumpFormat)
@@ -284,7 +621,7 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 	}
 
 	// Audit: backup completed.
-	bidDone := backup.ID
+	bidDone := backupID
 	completedMeta := fmt.Sprintf(`{"size_bytes":%d,"duration":"%s"}`, sizeBytes, duration.Round(time.Second))
 	_ = s.repo.LogActivity(
 		&dbConfig.UserID,
@@ -304,9 +641,82 @@ func (s *Service) ExecuteBackupWithID(dbConfig *models.DatabaseConfig, backupID
 	// retention policy will catch up on the next run.
 	if err := s.cleanupOldBackups(dbConfig, storageClient); err != nil {
 		log.Printf("Cleanup failed for %s (backup itself succeeded): %v", dbConfig.Name, err)
+		s.recordEvent(backupID, models.BackupEventCleanup, err.Error())
+	} else {
+		s.recordEvent(backupID, models.BackupEventCleanup, "")
 	}
+}
 
-	return nil
+// recordVersionWarning persists a pg_dump/server skew warning on the backup
+// and notifies once per episode: if the previous backup of this database
+// already carried a warning, the operator has been told.
+func (s *Service) recordVersionWarning(backupID uuid.UUID, dbConfig *models.DatabaseConfig, notifier notification.Notifier, warning string) {
+	if err := s.repo.SetBackupVersionWarning(backupID, warning); err != nil {
+		log.Printf("Failed to persist version warning: %v", err)
+	}
+	if notifier == nil {
+		return
+	}
+	warnedBefore, err := s.repo.PreviousBackupHadVersionWarning(dbConfig.ID, backupID)
+	if err != nil {
+		log.Printf("Failed to check previous version warning: %v", err)
+		return
+	}
+	if !warnedBefore {
+		notifier.SendMessage(fmt.Sprintf("⚠️ **Backup version warning: %s**\n%s", dbConfig.Name, warning))
+	}
+}
+
+// recordSSLMode persists the sslmode the dump used. The first time a
+// database falls back to an unencrypted connection the operator is warned;
+// later plaintext dumps stay quiet until SSL works again.
+func (s *Service) recordSSLMode(backupID uuid.UUID, dbConfig *models.DatabaseConfig, notifier notification.Notifier, sslMode SSLMode) {
+	if err := s.repo.SetBackupSSLMode(backupID, string(sslMode)); err != nil {
+		log.Printf("Failed to persist backup SSL mode: %v", err)
+	}
+	// A database that pins sslmode=disable chose plaintext; there is no
+	// fallback to warn about.
+	if _, pinned := pinnedSSLMode(dbConfig); sslMode != SSLModeDisable || notifier == nil || pinned {
+		return
+	}
+	prev, err := s.repo.PreviousBackupSSLMode(dbConfig.ID, backupID)
+	if err != nil {
+		log.Printf("Failed to check previous backup SSL mode: %v", err)
+		return
+	}
+	if prev != string(SSLModeDisable) {
+		notifier.SendMessage(fmt.Sprintf("⚠️ **Backup without SSL: %s**\nThe server rejected SSL, so pg_dump fell back to an unencrypted connection. Credentials and data crossed the network in plaintext.", dbConfig.Name))
+	}
+}
+
+// skipBackup records a run that was not attempted. Paused databases should
+// have no scheduler job, so this mostly catches races with pause/resume; the
+// audit entry explains the gap in the backup history. A record already
+// created by a manual trigger is closed out so it doesn't stay pending.
+func (s *Service) skipBackup(dbConfig *models.DatabaseConfig, backupID uuid.UUID, reason string) {
+	log.Printf("Skipping backup for %s: %s", dbConfig.Name, reason)
+
+	meta := map[string]string{"reason": reason}
+	if backupID != uuid.Nil {
+		msg := "skipped: " + reason
+		if err := s.repo.UpdateBackupStatus(backupID, models.BackupStatusFailed, nil, "", &msg); err != nil {
+			log.Printf("Failed to close out skipped backup: %v", err)
+		}
+		meta["backup_id"] = backupID.String()
+	}
+
+	metaBytes, _ := json.Marshal(meta)
+	_ = s.repo.LogActivity(
+		&dbConfig.UserID,
+		models.ActionBackupSkipped,
+		models.LogLevelInfo,
+		"database",
+		&dbConfig.ID,
+		dbConfig.Name,
+		fmt.Sprintf("Backup skipped for %q: %s", dbConfig.Name, reason),
+		string(metaBytes),
+		"",
+	)
 }
 
 // handleBackupError handles backup errors
@@ -317,6 +727,7 @@ func (s *Service) handleBackupError(backupID uuid.UUID, dbConfig *models.Databas
 	if err != nil {
 		log.Printf("Failed to update backup status to failed: %v", err)
 	}
+	s.recordEvent(backupID, models.BackupEventFailed, errorMsg)
 
 	// Audit: backup failed. JSON-encode the error message so embedded quotes
 	// don't break the JSONB column.
@@ -335,44 +746,182 @@ func (s *Service) handleBackupError(backupID uuid.UUID, dbConfig *models.Databas
 	)
 
 	// Send failure notification across every configured channel.
-	if dbConfig.NotificationID != nil {
-		notifConfig, err := s.repo.GetNotificationConfig(*dbConfig.NotificationID)
-		if err == nil && notifConfig != nil {
-			notification.NotifierFromConfig(notifConfig).SendBackupFailure(dbConfig.Name, errorMsg)
+	s.notifyFailure(dbConfig, models.NotificationEventBackupFailure, errorMsg)
+
+	return fmt.Errorf("%s", errorMsg)
+}
+
+// handleRestoreError audits and notifies a failed restore. audit carries
+// the job ID and masked target; the error is added to it.
+func (s *Service) handleRestoreError(backupID uuid.UUID, dbConfig *models.DatabaseConfig, audit map[string]string, err error) error {
+	log.Printf("Restore error: %s", err)
+
+	bidFail := backupID
+	meta := map[string]string{"error": err.Error()}
+	for k, v := range audit {
+		meta[k] = v
+	}
+	metaBytes, _ := json.Marshal(meta)
+	_ = s.repo.LogActivity(
+		&dbConfig.UserID,
+		models.ActionRestoreFailed,
+		models.LogLevelError,
+		"backup",
+		&bidFail,
+		dbConfig.Name,
+		fmt.Sprintf("Restore failed for backup %q into %s", dbConfig.Name, audit["target"]),
+		string(metaBytes),
+		"",
+	)
+	s.notifyFailure(dbConfig, models.NotificationEventRestoreFailure, err.Error())
+
+	return fmt.Errorf("%s", err)
+}
+
+// notifyFailure sends a failure alert for dbConfig, honouring the
+// notification config's cooldown: repeats inside the window are dropped,
+// and the first failure after it becomes a single "still failing" reminder.
+func (s *Service) notifyFailure(dbConfig *models.DatabaseConfig, event, errorMsg string) {
+	if dbConfig.NotificationID == nil {
+		return
+	}
+	notifConfig, err := s.repo.GetNotificationConfig(*dbConfig.NotificationID)
+	if err != nil || notifConfig == nil {
+		return
+	}
+	notifier := notification.NotifierFromConfig(notifConfig)
+
+	if notifConfig.CooldownMinutes > 0 {
+		cooldown := time.Duration(notifConfig.CooldownMinutes) * time.Minute
+		decision, err := s.repo.ClaimNotification(dbConfig.ID, event, cooldown)
+		switch {
+		case err != nil:
+			// Fail open: a missed alert is worse than a duplicate.
+			log.Printf("Notification cooldown check failed for %s: %v", dbConfig.Name, err)
+		case !decision.Send:
+			log.Printf("Suppressed %s notification for %s (cooldown %s)", event, dbConfig.Name, cooldown)
+			return
+		case decision.Suppressed > 0:
+			notifier.SendMessage(fmt.Sprintf("🔁 **Still failing: %s**\n%d more %s alert(s) suppressed since %s\nLatest error: %s",
+				dbConfig.Name, decision.Suppressed, strings.ReplaceAll(event, "_", " "),
+				decision.Since.UTC().Format(time.RFC3339), errorMsg))
+			return
 		}
 	}
 
-	return fmt.Errorf("%s", errorMsg)
+	switch event {
+	case models.NotificationEventRestoreFailure:
+		notifier.SendRestoreFailure(dbConfig.Name, errorMsg)
+	default:
+		notifier.SendBackupFailure(dbConfig.Name, errorMsg)
+	}
+}
+
+// checkRestoreTargetVersion compares the backup's source PostgreSQL major
+// (from the row, or the object's postgres-version metadata for older
+// backups) with the target server. Unknown versions on either side are
+// not an error; the restore proceeds as before.
+func (s *Service) checkRestoreTargetVersion(backup *models.Backup, storageClient *storage.StorageClient, target *models.DatabaseConfig) (string, error) {
+	sourceVersion := backup.PostgresVersion
+	if sourceVersion == "" && backup.StoragePath != "" {
+		if meta, err := storageClient.GetMetadata(backup.StoragePath); err == nil {
+			sourceVersion = meta["postgres-version"]
+		} else {
+			log.Printf("Warning: could not read backup metadata for version check: %v", err)
+		}
+	}
+
+	targetVersion, err := s.versionManager.DetectPostgresVersion(target)
+	if err != nil {
+		log.Printf("Warning: could not detect restore target version: %v", err)
+		return "", nil
+	}
+
+	note, err := s.versionManager.CheckRestoreCompatibility(sourceVersion, targetVersion)
+	if note != "" {
+		log.Printf("Restore version note: %s", note)
+	}
+	return note, err
 }
 
 // executeBackupWithSSLFallback executes pg_dump with automatic SSL fallback
-// Tries with SSL first, then without SSL if the first attempt fails with SSL-related errors
-func (s *Service) executeBackupWithSSLFallback(ctx context.Context, pgDumpCmd string, args []string, dbConfig *models.DatabaseConfig, outFile *os.File) (SSLMode, error) {
+// Tries with SSL first, then without SSL if the first attempt fails with SSL-related errors.
+// Hosts recently seen rejecting SSL skip straight to the non-SSL attempt.
+// It also returns pg_dump's stderr, which may carry warnings on success and
+// holds the cause on failure; when both SSL modes were tried it holds both.
+func (s *Service) executeBackupWithSSLFallback(ctx context.Context, pgDumpCmd, postgresVersion string, args []string, dbConfig *models.DatabaseConfig, outFile *os.File, limit *int64) (SSLMode, string, error) {
+	// A missing binary would otherwise fail both SSL attempts with exec's
+	// opaque "executable file not found".
+	if err := checkBinary("pg_dump", pgDumpCmd, postgresVersion); err != nil {
+		return SSLModeRequire, "", err
+	}
+	extraEnv, err := pgEnv(dbConfig)
+	if err != nil {
+		return SSLModeRequire, "", err
+	}
+
 	// Stage credentials in a 0600 passfile instead of PGPASSWORD env var so
 	// other processes on the box cannot read the password through procfs.
 	passfilePath, err := writePgPassFile(dbConfig)
 	if err != nil {
-		return SSLModeRequire, fmt.Errorf("prepare pgpass: %w", err)
+		return SSLModeRequire, "", fmt.Errorf("prepare pgpass: %w", err)
 	}
 	defer os.Remove(passfilePath)
+	appName := pgAppName("backup", dbConfig.Name)
 
-	// Try with SSL first
-	sslMode := SSLModeRequire
-	cmd := exec.CommandContext(ctx, pgDumpCmd, args...)
-	cmd.Env = append(os.Environ(),
-		"PGPASSFILE="+passfilePath,
-		fmt.Sprintf("PGSSLMODE=%s", sslMode),
-	)
+	runDump := func(sslMode SSLMode, stderr *bytes.Buffer) (*limitedWriter, error) {
+		cmd := exec.CommandContext(ctx, pgDumpCmd, args...)
+		cmd.Env = append(os.Environ(),
+			"PGPASSFILE="+passfilePath,
+			fmt.Sprintf("PGSSLMODE=%s", sslMode),
+			"PGAPPNAME="+appName,
+			fmt.Sprintf("PGCONNECT_TIMEOUT=%d", dbConfig.ConnectTimeout()),
+		)
+		cmd.Env = append(cmd.Env, extraEnv...)
+		var limiter *limitedWriter
+		cmd.Stdout, limiter = dumpOutput(outFile, limit)
+		cmd.Stderr = stderr
+		return limiter, cmd.Run()
+	}
 
-	cmd.Stdout = outFile
+	if mode, ok := pinnedSSLMode(dbConfig); ok {
+		var stderr bytes.Buffer
+		limiter, err := runDump(mode, &stderr)
+		if err == nil {
+			return mode, stderr.String(), nil
+		}
+		if limiter.exceeded() {
+			return mode, stderr.String(), limiter.err()
+		}
+		return mode, stderr.String(), fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderr.String())
+	}
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	if s.versionManager.SkipSSLAttempt(dbConfig.Host, dbConfig.Port) {
+		log.Printf("Skipping SSL attempt for %s: server recently rejected SSL", dbConfig.Name)
+		var stderr bytes.Buffer
+		limiter, err := runDump(SSLModeDisable, &stderr)
+		if err == nil {
+			return SSLModeDisable, stderr.String(), nil
+		}
+		if limiter.exceeded() {
+			return SSLModeDisable, stderr.String(), limiter.err()
+		}
+		return SSLModeDisable, stderr.String(), fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderr.String())
+	}
 
-	err = cmd.Run()
+	// Try with SSL first
+	sslMode := SSLModeRequire
+	var stderr bytes.Buffer
+	limiter, err := runDump(sslMode, &stderr)
 	if err == nil {
-		// Success with SSL
-		return sslMode, nil
+		// Success with SSL; forget an earlier fallback if this was a re-probe
+		if s.versionManager.GetSSLModeForDatabase(dbConfig.Host, dbConfig.Port) != SSLModeRequire {
+			s.versionManager.SetSSLMode(dbConfig.Host, dbConfig.Port, SSLModeRequire)
+		}
+		return sslMode, stderr.String(), nil
+	}
+	if limiter.exceeded() {
+		return sslMode, stderr.String(), limiter.err()
 	}
 
 	stderrMsg := stderr.String()
@@ -401,54 +950,57 @@ func (s *Service) executeBackupWithSSLFallback(ctx context.Context, pgDumpCmd st
 		// Wipe partial bytes left by the failed first attempt; otherwise the
 		// second attempt would append, producing a corrupted dump.
 		if err := truncateAndRewind(outFile); err != nil {
-			return sslMode, fmt.Errorf("failed to reset backup file before retry: %w", err)
+			return sslMode, stderrMsg, fmt.Errorf("failed to reset backup file before retry: %w", err)
 		}
 
-		// Reset the stderr buffer for the second attempt
-		var stderr2 bytes.Buffer
-
 		// Try without SSL (reuse the same passfile)
 		sslMode = SSLModeDisable
-		cmd2 := exec.CommandContext(ctx, pgDumpCmd, args...)
-		cmd2.Env = append(os.Environ(),
-			"PGPASSFILE="+passfilePath,
-			fmt.Sprintf("PGSSLMODE=%s", sslMode),
-		)
-
-		cmd2.Stdout = outFile
-		cmd2.Stderr = &stderr2
-
-		err2 := cmd2.Run()
+		var stderr2 bytes.Buffer
+		limiter, err2 := runDump(sslMode, &stderr2)
 		if err2 == nil {
 			// Success without SSL - update cache
 			log.Printf("Backup succeeded without SSL for database: %s", dbConfig.Name)
 			s.versionManager.SetSSLMode(dbConfig.Host, dbConfig.Port, SSLModeDisable)
-			return sslMode, nil
+			return sslMode, stderr2.String(), nil
+		}
+		bothStderr := stderrMsg + "\n--- retried without SSL ---\n" + stderr2.String()
+		if limiter.exceeded() {
+			return sslMode, bothStderr, limiter.err()
 		}
 
 		// Both attempts failed
-		return sslMode, fmt.Errorf("pg_dump failed with both SSL and non-SSL modes. SSL error: %s, Non-SSL error: %s", stderrMsg, stderr2.String())
+		return sslMode, bothStderr, fmt.Errorf("pg_dump failed with both SSL and non-SSL modes. SSL error: %s, Non-SSL error: %s", stderrMsg, stderr2.String())
 	}
 
 	// Not an SSL error, just return the original error
-	return sslMode, fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderrMsg)
+	return sslMode, stderrMsg, fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderrMsg)
 }
 
-// executeRestoreWithSSLFallback executes psql restore with automatic SSL fallback
-func (s *Service) executeRestoreWithSSLFallback(ctx context.Context, psqlCmd string, args []string, targetDBConfig *models.DatabaseConfig) (SSLMode, error) {
+// executeRestoreWithSSLFallback executes psql restore with automatic SSL fallback.
+// appName labels the session in pg_stat_activity on the target server.
+func (s *Service) executeRestoreWithSSLFallback(ctx context.Context, psqlCmd string, args []string, targetDBConfig *models.DatabaseConfig, appName string) (SSLMode, error) {
 	passfilePath, err := writePgPassFile(targetDBConfig)
 	if err != nil {
 		return SSLModeRequire, fmt.Errorf("prepare pgpass: %w", err)
 	}
 	defer os.Remove(passfilePath)
+	extraEnv, err := pgEnv(targetDBConfig)
+	if err != nil {
+		return SSLModeRequire, err
+	}
 
-	// Try with SSL first
-	sslMode := SSLModeRequire
+	// Try with SSL first, unless the target pins its own sslmode
+	sslMode, pinned := pinnedSSLMode(targetDBConfig)
+	if !pinned {
+		sslMode = SSLModeRequire
+	}
 	cmd := exec.CommandContext(ctx, psqlCmd, args...)
 	cmd.Env = append(os.Environ(),
 		"PGPASSFILE="+passfilePath,
 		fmt.Sprintf("PGSSLMODE=%s", sslMode),
+		"PGAPPNAME="+appName,
 	)
+	cmd.Env = append(cmd.Env, extraEnv...)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -479,7 +1031,7 @@ func (s *Service) executeRestoreWithSSLFallback(ctx context.Context, psqlCmd str
 	}
 
 	// If SSL error, try without SSL
-	if isSSLError {
+	if isSSLError && !pinned {
 		log.Printf("SSL connection failed for restore, attempting without SSL: %s", stderrMsg)
 
 		// Reset the stderr buffer for the second attempt
@@ -491,7 +1043,9 @@ func (s *Service) executeRestoreWithSSLFallback(ctx context.Context, psqlCmd str
 		cmd2.Env = append(os.Environ(),
 			"PGPASSFILE="+passfilePath,
 			fmt.Sprintf("PGSSLMODE=%s", sslMode),
+			"PGAPPNAME="+appName,
 		)
+		cmd2.Env = append(cmd2.Env, extraEnv...)
 
 		cmd2.Stderr = &stderr2
 
@@ -530,19 +1084,26 @@ func (s *Service) cleanupOldBackups(dbConfig *models.DatabaseConfig, storageClie
 		return fmt.Errorf("list backups: %w", err)
 	}
 
-	// Filter successful backups only
+	// Filter successful backups only. Pinned backups and those with a
+	// future RetainUntil are held outside the policy: they neither count
+	// toward the limit nor expire.
+	now := time.Now()
 	var successBackups []*models.Backup
 	for _, b := range backups {
-		if b.Status == models.BackupStatusSuccess {
-			successBackups = append(successBackups, b)
+		if b.Status != models.BackupStatusSuccess || b.Pinned {
+			continue
 		}
+		if b.RetainUntil != nil && b.RetainUntil.After(now) {
+			continue
+		}
+		successBackups = append(successBackups, b)
 	}
 
 	var toDelete []*models.Backup
 
 	switch dbConfig.RotationPolicyType {
 	case models.RotationPolicyDays:
-		cutoffTime := time.Now().AddDate(0, 0, -dbConfig.RotationPolicyValue)
+		cutoffTime := now.AddDate(0, 0, -dbConfig.RotationPolicyValue)
 		for _, b := range successBackups {
 			if b.StartedAt.Before(cutoffTime) {
 				toDelete = append(toDelete, b)
@@ -592,28 +1153,152 @@ func (s *Service) cleanupOldBackups(dbConfig *models.DatabaseConfig, storageClie
 
 // ExecuteRestore performs a database restore
 func (s *Service) ExecuteRestore(backupID uuid.UUID, req *models.RestoreRequest) error {
+	backup, dbConfig, err := s.loadRestoreSource(backupID, req)
+	if err != nil {
+		return err
+	}
+
+	// Create restore job
+	job, err := s.repo.CreateRestoreJob(backupID, req)
+	if err != nil {
+		return fmt.Errorf("failed to create restore job: %w", err)
+	}
+
+	release := s.acquireRestoreSlot()
+	defer release()
+	err = s.runRestore(job, backup, dbConfig, req)
+	s.finishRestoreJob(job.ID, err, nil)
+	return err
+}
+
+// loadRestoreSource fetches the backup and its database config and checks
+// the request can be applied to the backup.
+func (s *Service) loadRestoreSource(backupID uuid.UUID, req *models.RestoreRequest) (*models.Backup, *models.DatabaseConfig, error) {
 	// Get backup info
 	backup, err := s.repo.GetBackup(backupID)
 	if err != nil {
-		return fmt.Errorf("failed to get backup: %w", err)
+		return nil, nil, fmt.Errorf("failed to get backup: %w", err)
 	}
 	if backup == nil {
-		return fmt.Errorf("backup not found")
+		return nil, nil, fmt.Errorf("backup not found")
 	}
 
 	// Get database config
 	dbConfig, err := s.repo.GetDatabaseConfig(backup.DatabaseID)
 	if err != nil {
-		return fmt.Errorf("failed to get database config: %w", err)
+		return nil, nil, fmt.Errorf("failed to get database config: %w", err)
+	}
+	if dbConfig == nil {
+		return nil, nil, fmt.Errorf("database config %s not found", backup.DatabaseID)
+	}
+	if req != nil {
+		if err := req.ValidateTables(backup.DumpFormat); err != nil {
+			return nil, nil, err
+		}
 	}
+	return backup, dbConfig, nil
+}
 
-	// Create restore job
-	job, err := s.repo.CreateRestoreJob(backupID, req)
-	if err != nil {
-		return fmt.Errorf("failed to create restore job: %w", err)
+// finishRestoreJob records the job's outcome; failures to do so are only
+// logged since the restore itself has already finished.
+func (s *Service) finishRestoreJob(jobID uuid.UUID, restoreErr error, testResult *string) {
+	status, errMsg := models.BackupStatusSuccess, ""
+	if restoreErr != nil {
+		status, errMsg = models.BackupStatusFailed, restoreErr.Error()
+	}
+	if err := s.repo.FinishRestoreJob(jobID, status, errMsg, testResult); err != nil {
+		log.Printf("Failed to record restore job %s outcome: %v", jobID, err)
+	}
+}
+
+// restoreTargetConfig builds the connection a restore uses: target's, with
+// any overrides from req applied. target's pg_env comes along unless req
+// points the restore at another host, where those settings may not apply.
+func restoreTargetConfig(target *models.DatabaseConfig, req *models.RestoreRequest) (*models.DatabaseConfig, error) {
+	cfg := &models.DatabaseConfig{
+		Host:     target.Host,
+		Port:     target.Port,
+		Username: target.Username,
+		DBName:   target.DBName,
+		Password: target.Password,
+		PgEnv:    target.PgEnv,
+		Name:     "restore_target",
+	}
+	if req == nil {
+		return cfg, nil
+	}
+	// Never pair the source password with another server.
+	if err := req.ValidateTargetCredentials(target); err != nil {
+		return nil, err
+	}
+	if req.TargetHost != "" {
+		cfg.Host = req.TargetHost
+		cfg.PgEnv = nil
+	}
+	if req.TargetPort != 0 {
+		cfg.Port = req.TargetPort
 	}
+	if req.TargetDBName != "" {
+		cfg.DBName = req.TargetDBName
+	}
+	if req.TargetUser != "" {
+		cfg.Username = req.TargetUser
+	}
+	if req.TargetPassword != "" {
+		cfg.Password = req.TargetPassword
+	}
+	return cfg, nil
+}
 
-	// Audit: restore started.
+// runRestore downloads the backup and restores it into the job's target,
+// which defaults to the source database.
+func (s *Service) runRestore(job *models.RestoreJob, backup *models.Backup, dbConfig *models.DatabaseConfig, req *models.RestoreRequest) error {
+	backupID := backup.ID
+	if err := s.repo.MarkRestoreJobRunning(job.ID); err != nil {
+		log.Printf("Failed to mark restore job %s running: %v", job.ID, err)
+	}
+
+	// Determine target: the source database unless the request names
+	// another config. The caller has checked the user may use that config.
+	target := dbConfig
+	if req != nil && req.TargetDatabaseID != nil {
+		if err := req.ValidateTargetDatabase(); err != nil {
+			return err
+		}
+		targetConfig, err := s.repo.GetDatabaseConfig(*req.TargetDatabaseID)
+		if err != nil {
+			return fmt.Errorf("failed to get target database config: %w", err)
+		}
+		if targetConfig == nil {
+			return fmt.Errorf("target database config %s not found", *req.TargetDatabaseID)
+		}
+		target = targetConfig
+	}
+	targetDBConfig, err := restoreTargetConfig(target, req)
+	if err != nil {
+		return err
+	}
+	if err := s.checkHost(targetDBConfig.Host); err != nil {
+		return err
+	}
+	targetHost := targetDBConfig.Host
+	targetPort := targetDBConfig.Port
+	targetDBName := targetDBConfig.DBName
+	targetUser := targetDBConfig.Username
+
+	// Audit: restore started. The target is masked; every later entry for
+	// this job carries the same job ID and target.
+	audit := map[string]string{
+		"restore_job_id": job.ID.String(),
+		"target":         utils.MaskConnectionTarget(targetUser, targetHost, targetPort, targetDBName),
+	}
+	if target != dbConfig {
+		audit["target_database_id"] = target.ID.String()
+	}
+	if len(job.Tables) > 0 {
+		audit["tables"] = strings.Join(job.Tables, ",")
+	}
+	startedMeta, _ := json.Marshal(audit)
 	bidRestore := backupID
 	_ = s.repo.LogActivity(
 		&dbConfig.UserID,
@@ -622,54 +1307,55 @@ func (s *Service) ExecuteRestore(backupID uuid.UUID, req *models.RestoreRequest)
 		"backup",
 		&bidRestore,
 		dbConfig.Name,
-		fmt.Sprintf("Restore started for backup %q", dbConfig.Name),
-		"",
+		fmt.Sprintf("Restore started for backup %q into %s", dbConfig.Name, audit["target"]),
+		string(startedMeta),
 		"",
 	)
 
-	// Determine target
-	targetHost := dbConfig.Host
-	targetPort := dbConfig.Port
-	targetDBName := dbConfig.DBName
-	targetUser := dbConfig.Username
-	targetPassword := dbConfig.Password
-
-	if req != nil {
-		if req.TargetHost != "" {
-			targetHost = req.TargetHost
-		}
-		if req.TargetPort != 0 {
-			targetPort = req.TargetPort
-		}
-		if req.TargetDBName != "" {
-			targetDBName = req.TargetDBName
-		}
-		if req.TargetUser != "" {
-			targetUser = req.TargetUser
-		}
-		if req.TargetPassword != "" {
-			targetPassword = req.TargetPassword
-		}
-	}
-
 	// Get storage config
 	storageConfig, err := s.repo.GetStorageConfig(dbConfig.StorageID)
 	if err != nil {
-		return fmt.Errorf("failed to get storage config: %w", err)
+		return s.handleRestoreError(backupID, dbConfig, audit, fmt.Errorf("failed to get storage config: %w", err))
 	}
 
 	// Download backup file
 	storageClient, err := storage.NewStorageClient(storageConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create storage client: %w", err)
+		return s.handleRestoreError(backupID, dbConfig, audit, fmt.Errorf("failed to create storage client: %w", err))
 	}
 
-	tempFilePath := filepath.Join(os.TempDir(), fmt.Sprintf("restore_%s.sql", job.ID))
+	// Refuse to restore into an older major before downloading anything;
+	// otherwise the user gets a wall of psql/pg_restore syntax errors.
+	versionNote, err := s.checkRestoreTargetVersion(backup, storageClient, targetDBConfig)
+	if err != nil {
+		return s.handleRestoreError(backupID, dbConfig, audit, err)
+	}
+
+	tempFilePath, err := s.createRestoreTemp(fmt.Sprintf("restore_%s.sql", job.ID))
+	if err != nil {
+		return s.handleRestoreError(backupID, dbConfig, audit, err)
+	}
 	defer os.Remove(tempFilePath)
 
 	log.Printf("Downloading backup file: %s", backup.StoragePath)
-	if err := storageClient.DownloadFile(backup.StoragePath, tempFilePath); err != nil {
-		return fmt.Errorf("failed to download backup: %w", err)
+	progress, done := s.progress.start(backupID, models.TransferDownload)
+	err = storageClient.DownloadFileWithProgress(backup.StoragePath, tempFilePath, progress)
+	done()
+	if err != nil {
+		return s.handleRestoreError(backupID, dbConfig, audit, fmt.Errorf("failed to download backup: %w", err))
+	}
+
+	// Compressed plain dumps must be inflated first; psql --file reads raw SQL.
+	if backup.Compression == models.CompressionGzip || strings.HasSuffix(backup.StoragePath, ".gz") {
+		plainPath, err := s.createRestoreTemp(fmt.Sprintf("restore_%s_plain.sql", job.ID))
+		if err != nil {
+			return s.handleRestoreError(backupID, dbConfig, audit, err)
+		}
+		defer os.Remove(plainPath)
+		if err := gunzipFile(tempFilePath, plainPath); err != nil {
+			return s.handleRestoreError(backupID, dbConfig, audit, fmt.Errorf("failed to decompress backup: %w", err))
+		}
+		tempFilePath = plainPath
 	}
 
 	// Execute restore
@@ -702,8 +1388,18 @@ func (s *Service) ExecuteRestore(backupID uuid.UUID, req *models.RestoreRequest)
 			"--no-password",
 			"--no-owner",
 			"--no-privileges",
-			tempFilePath,
 		}
+		// A full pg_restore brings large objects back along with the tables
+		// that reference them, but any --table selection skips them.
+		if req != nil {
+			for _, table := range req.Tables {
+				restoreArgs = append(restoreArgs, "--table", table)
+			}
+			if len(req.Tables) > 0 && (dbConfig.Blobs == nil || *dbConfig.Blobs) {
+				log.Printf("Restoring selected tables of backup %s: large objects in the dump are not restored", backupID)
+			}
+		}
+		restoreArgs = append(restoreArgs, tempFilePath)
 	default:
 		// "plain" or unset (legacy backups predating DumpFormat persistence).
 		restoreCmd = s.versionManager.GetPsqlVersion(postgresVersion)
@@ -718,48 +1414,24 @@ func (s *Service) ExecuteRestore(backupID uuid.UUID, req *models.RestoreRequest)
 	}
 
 	// Execute restore with SSL fallback
-	targetDBConfig := &models.DatabaseConfig{
-		Host:     targetHost,
-		Port:     targetPort,
-		Username: targetUser,
-		DBName:   targetDBName,
-		Password: targetPassword,
-		Name:     "restore_target",
-	}
-
-	_, err = s.executeRestoreWithSSLFallback(ctx, restoreCmd, restoreArgs, targetDBConfig)
+	_, err = s.executeRestoreWithSSLFallback(ctx, restoreCmd, restoreArgs, targetDBConfig, pgAppName("restore", dbConfig.Name))
 	if err != nil {
-		log.Printf("Restore error: %s", err)
-
-		// Audit + notify on failure.
-		bidFail := backupID
-		metaBytes, _ := json.Marshal(map[string]string{"error": err.Error()})
-		_ = s.repo.LogActivity(
-			&dbConfig.UserID,
-			models.ActionRestoreFailed,
-			models.LogLevelError,
-			"backup",
-			&bidFail,
-			dbConfig.Name,
-			fmt.Sprintf("Restore failed for backup %q", dbConfig.Name),
-			string(metaBytes),
-			"",
-		)
-		if dbConfig.NotificationID != nil {
-			notifConfig, nErr := s.repo.GetNotificationConfig(*dbConfig.NotificationID)
-			if nErr == nil && notifConfig != nil {
-				notification.NotifierFromConfig(notifConfig).SendRestoreFailure(dbConfig.Name, err.Error())
-			}
-		}
-
-		return fmt.Errorf("%s", err)
+		return s.handleRestoreError(backupID, dbConfig, audit, err)
 	}
 
 	log.Printf("Restore completed successfully for backup %s", backupID)
+	if err := s.repo.ClearNotificationState(dbConfig.ID, models.NotificationEventRestoreFailure); err != nil {
+		log.Printf("Failed to reset notification cooldown: %v", err)
+	}
 
 	// Audit: restore completed.
 	bidDone := backupID
-	completedMeta := fmt.Sprintf(`{"target":"%s@%s/%s"}`, targetUser, targetHost, targetDBName)
+	completed := audit
+	if versionNote != "" {
+		completed["version_note"] = versionNote
+	}
+	completedMetaBytes, _ := json.Marshal(completed)
+	completedMeta := string(completedMetaBytes)
 	_ = s.repo.LogActivity(
 		&dbConfig.UserID,
 		models.ActionRestoreCompleted,
@@ -767,13 +1439,14 @@ func (s *Service) ExecuteRestore(backupID uuid.UUID, req *models.RestoreRequest)
 		"backup",
 		&bidDone,
 		dbConfig.Name,
-		fmt.Sprintf("Restore completed for %q", dbConfig.Name),
+		fmt.Sprintf("Restore completed for %q into %s", dbConfig.Name, audit["target"]),
 		completedMeta,
 		"",
 	)
 
-	// Send success notification across every configured channel.
-	if dbConfig.NotificationID != nil {
+	// Send success notification across every configured channel. Test
+	// restores report through their job instead.
+	if dbConfig.NotificationID != nil && (req == nil || !req.Test) {
 		notifConfig, err := s.repo.GetNotificationConfig(*dbConfig.NotificationID)
 		if err == nil && notifConfig != nil {
 			targetDesc := fmt.Sprintf("%s@%s/%s", targetUser, targetHost, targetDBName)
@@ -781,6 +1454,5 @@ func (s *Service) ExecuteRestore(backupID uuid.UUID, req *models.RestoreRequest)
 		}
 	}
 
-	_ = job
 	return nil
 }
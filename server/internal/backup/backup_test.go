@@ -1,11 +1,19 @@
 package backup
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
 )
 
 // TestTruncateAndRewind verifies that bytes from a failed first write are
@@ -89,3 +97,541 @@ func TestOSCreateTempUniqueness(t *testing.T) {
 		t.Fatalf("got %d unique paths, want %d", len(seen), n)
 	}
 }
+
+// TestGzipRoundTrip checks a plain dump survives gzipToTemp → gunzipFile
+// byte-for-byte, including when the source offset is not at zero.
+func TestGzipRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src, err := os.Create(filepath.Join(dir, "dump.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = src.Close() })
+
+	want := strings.Repeat("INSERT INTO t VALUES (1, 'row');\n", 1000)
+	if _, err := src.WriteString(want); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, size, err := gzipToTemp(src)
+	if err != nil {
+		t.Fatalf("gzipToTemp: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Remove(gz) })
+	if size <= 0 || size >= int64(len(want)) {
+		t.Errorf("compressed size %d, want 0 < size < %d", size, len(want))
+	}
+
+	out := filepath.Join(dir, "restored.sql")
+	if err := gunzipFile(gz, out); err != nil {
+		t.Fatalf("gunzipFile: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("round trip mismatch: got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+// TestPgAppName checks the application_name stays ASCII and within
+// Postgres' 63-byte limit.
+func TestPgAppName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		purpose, name, want string
+	}{
+		{"backup", "Production DB", "dumpstation:backup:Production DB"},
+		{"restore", "", "dumpstation:restore"},
+		{"backup", "prod\nдб", "dumpstation:backup:prod___"},
+		{"backup", strings.Repeat("x", 100), ("dumpstation:backup:" + strings.Repeat("x", 100))[:63]},
+	}
+	for _, tt := range tests {
+		if got := pgAppName(tt.purpose, tt.name); got != tt.want {
+			t.Errorf("pgAppName(%q, %q) = %q, want %q", tt.purpose, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, lw := dumpOutput(&buf, nil)
+	if lw != nil || w != &buf {
+		t.Fatal("no limit should write straight to the file")
+	}
+	if lw.exceeded() {
+		t.Fatal("nil limiter reported exceeded")
+	}
+
+	limit := int64(8)
+	w, lw = dumpOutput(&buf, &limit)
+	if _, err := w.Write([]byte("12345678")); err != nil {
+		t.Fatalf("write up to the limit: %v", err)
+	}
+	if _, err := w.Write([]byte("9")); !errors.Is(err, errMaxSizeExceeded) {
+		t.Fatalf("write past limit err = %v, want errMaxSizeExceeded", err)
+	}
+	if !lw.exceeded() || !errors.Is(lw.err(), errMaxSizeExceeded) {
+		t.Fatal("limiter did not record the exceeded limit")
+	}
+	if buf.Len() != 8 {
+		t.Errorf("wrote %d bytes, want 8", buf.Len())
+	}
+}
+
+// TestCheckStoredSize checks plain dumps are held to the limit by their
+// compressed size, the size that is uploaded.
+func TestCheckStoredSize(t *testing.T) {
+	limit := int64(100)
+	if err := checkStoredSize(100, &limit); err != nil {
+		t.Errorf("size at the limit: %v", err)
+	}
+	if err := checkStoredSize(101, &limit); !errors.Is(err, errMaxSizeExceeded) {
+		t.Errorf("size over the limit err = %v, want errMaxSizeExceeded", err)
+	}
+	if err := checkStoredSize(1<<40, nil); err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+}
+
+// TestRetainUpload checks a failed upload's dump survives its temp file,
+// can be claimed exactly once, and is removed when the window expires.
+func TestRetainUpload(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{retries: uploadRetries{entries: make(map[uuid.UUID]*retainedUpload)}}
+	write := func() string {
+		f, err := os.CreateTemp(t.TempDir(), "dump-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("dump")
+		f.Close()
+		return f.Name()
+	}
+
+	id := uuid.New()
+	if _, ok := s.retainUpload(id, &dumpArtifact{path: write()}); ok {
+		t.Fatal("retained with retries disabled")
+	}
+
+	s.SetUploadRetryWindow(time.Minute)
+	src := write()
+	if _, ok := s.retainUpload(id, &dumpArtifact{path: src}); !ok {
+		t.Fatal("dump not retained")
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("retained dump still at its temp path")
+	}
+	entry := s.claimRetained(id)
+	if entry == nil {
+		t.Fatal("claim returned nil")
+	}
+	defer os.Remove(entry.artifact.path)
+	if got, _ := os.ReadFile(entry.artifact.path); string(got) != "dump" {
+		t.Errorf("retained dump = %q, want dump", got)
+	}
+	if s.claimRetained(id) != nil {
+		t.Error("second claim succeeded")
+	}
+
+	s.SetUploadRetryWindow(10 * time.Millisecond)
+	id2 := uuid.New()
+	if _, ok := s.retainUpload(id2, &dumpArtifact{path: write()}); !ok {
+		t.Fatal("dump not retained")
+	}
+	s.retries.mu.Lock()
+	path := s.retries.entries[id2].artifact.path
+	s.retries.mu.Unlock()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expired dump not removed")
+	}
+	if s.HasRetainedUpload(id2) {
+		t.Error("entry not dropped after the window")
+	}
+}
+
+// TestResolveObjectKeyAvoidsTakenKeys verifies that a backup never reuses
+// an existing object key: a free key is used as-is and a taken one gets a
+// random suffix in front of the extension.
+func TestResolveObjectKeyAvoidsTakenKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	taken := map[string]bool{"backups/db1/nightly.sql.gz": true}
+	exists := func(_ context.Context, key string) (bool, error) { return taken[key], nil }
+
+	name, key, err := resolveObjectKey(ctx, exists, "db1", "other", ".dump")
+	if err != nil || name != "other.dump" || key != "backups/db1/other.dump" {
+		t.Fatalf("free key: got %q %q %v", name, key, err)
+	}
+
+	name, key, err = resolveObjectKey(ctx, exists, "db1", "nightly", ".sql.gz")
+	if err != nil {
+		t.Fatalf("taken key: %v", err)
+	}
+	if taken[key] || !strings.HasPrefix(name, "nightly-") || !strings.HasSuffix(name, ".sql.gz") || len(name) != len("nightly-xxxxxx.sql.gz") {
+		t.Fatalf("taken key: got %q %q", name, key)
+	}
+
+	always := func(context.Context, string) (bool, error) { return true, nil }
+	if _, _, err := resolveObjectKey(ctx, always, "db1", "nightly", ".dump"); err == nil {
+		t.Fatal("expected an error when every candidate key is taken")
+	}
+
+	failing := func(context.Context, string) (bool, error) { return false, errors.New("403 Forbidden") }
+	if name, _, err := resolveObjectKey(ctx, failing, "db1", "nightly", ".dump"); err != nil || name != "nightly.dump" {
+		t.Fatalf("failed check should fall back to the plain name, got %q %v", name, err)
+	}
+}
+
+// TestSetRestoreTempDir_DefaultFallsBack checks that a default restore dir
+// someone else planted (here a symlink) is not used, and that a private
+// directory is created instead.
+func TestSetRestoreTempDir_DefaultFallsBack(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	elsewhere := t.TempDir()
+	planted := filepath.Join(tmp, defaultRestoreDirName)
+	if err := os.Symlink(elsewhere, planted); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{}
+	if err := s.SetRestoreTempDir(""); err != nil {
+		t.Fatalf("SetRestoreTempDir: %v", err)
+	}
+	dir := s.restoreTempDir()
+	if dir == planted || filepath.Dir(dir) != tmp || !strings.HasPrefix(filepath.Base(dir), defaultRestoreDirName+"-") {
+		t.Fatalf("restore dir = %s, want a fresh %s-* dir in %s", dir, defaultRestoreDirName, tmp)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() || info.Mode().Perm() != 0o700 {
+		t.Fatalf("restore dir = %v, %v; want a 0700 directory", info, err)
+	}
+
+	if err := s.SetRestoreTempDir(planted); err == nil {
+		t.Fatal("SetRestoreTempDir accepted a symlink")
+	}
+}
+
+// TestSweepRestoreTemp verifies the startup sweep removes only stale
+// restore files, leaving recent ones and unrelated files alone, and that
+// the restore dir is restricted to the owner.
+func TestSweepRestoreTemp(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	dir := filepath.Join(t.TempDir(), "restore")
+
+	s := &Service{}
+	if err := s.SetRestoreTempDir(dir); err != nil {
+		t.Fatalf("SetRestoreTempDir: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("restore dir mode = %v, %v; want 0700", info.Mode().Perm(), err)
+	}
+
+	id := uuid.New().String()
+	stale, err := s.createRestoreTemp("restore_" + id + ".sql")
+	if err != nil {
+		t.Fatalf("createRestoreTemp: %v", err)
+	}
+	if info, _ := os.Stat(stale); info.Mode().Perm() != 0o600 {
+		t.Fatalf("restore file mode = %v, want 0600", info.Mode().Perm())
+	}
+	fresh, _ := s.createRestoreTemp("restore_dryrun_" + uuid.New().String() + ".dump")
+	legacy := filepath.Join(os.TempDir(), "restore_"+uuid.New().String()+"_plain.sql")
+	unrelated := filepath.Join(dir, "restore_notes.txt")
+	for _, p := range []string{legacy, unrelated} {
+		if err := os.WriteFile(p, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-3 * time.Hour)
+	for _, p := range []string{stale, legacy, unrelated} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := s.SweepRestoreTemp(2 * time.Hour); n != 2 {
+		t.Fatalf("removed %d files, want 2", n)
+	}
+	for path, want := range map[string]bool{stale: false, legacy: false, fresh: true, unrelated: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+}
+
+// TestRestoreSlotsQueueExcessRestores verifies that restores beyond the
+// limit wait for a slot and are reported as queued meanwhile.
+func TestRestoreSlotsQueueExcessRestores(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{}
+	s.SetMaxConcurrentRestores(1)
+	release := s.acquireRestoreSlot()
+
+	acquired := make(chan func())
+	go func() { acquired <- s.acquireRestoreSlot() }()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, queued, _ := s.RestoreStatus(); queued == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second restore was not queued")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case <-acquired:
+		t.Fatal("second restore started while the only slot was taken")
+	default:
+	}
+	if running, _, max := s.RestoreStatus(); running != 1 || max != 1 {
+		t.Fatalf("running = %d, max = %d; want 1, 1", running, max)
+	}
+
+	release()
+	select {
+	case second := <-acquired:
+		second()
+	case <-time.After(time.Second):
+		t.Fatal("queued restore did not start after the slot was freed")
+	}
+	if running, queued, _ := s.RestoreStatus(); running != 0 || queued != 0 {
+		t.Fatalf("after both finished: running = %d, queued = %d", running, queued)
+	}
+}
+
+func TestLargeObjectArgs(t *testing.T) {
+	t.Parallel()
+
+	yes, no := true, false
+	tests := []struct {
+		name   string
+		config models.DatabaseConfig
+		want   []string
+	}{
+		{"default", models.DatabaseConfig{}, nil},
+		{"blobs", models.DatabaseConfig{Blobs: &yes}, []string{"--blobs"}},
+		{"no blobs", models.DatabaseConfig{Blobs: &no}, []string{"--no-blobs"}},
+		{"schema only", models.DatabaseConfig{Blobs: &yes, SchemaOnly: true}, nil},
+		{"schema only without blobs", models.DatabaseConfig{Blobs: &no, SchemaOnly: true}, []string{"--no-blobs"}},
+	}
+	for _, tt := range tests {
+		got := largeObjectArgs(&tt.config)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: largeObjectArgs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScrubDumpLog(t *testing.T) {
+	t.Parallel()
+
+	in := "pg_dump: error: connection to server failed: host=db password=hunter2 dbname=app\n" +
+		"pg_dump: detail: postgres://backup:s3cret@db:5432/app\n" +
+		"pg_dump: password 'p@ss' rejected"
+	got := scrubDumpLog(in, "p@ss")
+	for _, secret := range []string{"hunter2", "s3cret", "p@ss"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("scrubbed log still contains %q:\n%s", secret, got)
+		}
+	}
+	if !strings.Contains(got, "postgres://backup:****@db") {
+		t.Errorf("URL user was not kept:\n%s", got)
+	}
+}
+
+func TestTrimDumpLogKeepsTail(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	for i := 0; i < maxDumpLogLines+50; i++ {
+		fmt.Fprintf(&b, "pg_dump: dumping contents of table \"public.t%d\"\n", i)
+	}
+	got, truncated := trimDumpLog(b.String())
+	if !truncated {
+		t.Fatal("truncated = false for output over the line limit")
+	}
+	lines := strings.Split(got, "\n")
+	if len(lines) != maxDumpLogLines {
+		t.Fatalf("kept %d lines, want %d", len(lines), maxDumpLogLines)
+	}
+	if want := fmt.Sprintf("\"public.t%d\"", maxDumpLogLines+49); !strings.HasSuffix(lines[len(lines)-1], want) {
+		t.Errorf("last line = %q, want the final table", lines[len(lines)-1])
+	}
+
+	if short, truncated := trimDumpLog("pg_dump: warning\n"); truncated || short != "pg_dump: warning" {
+		t.Errorf("short log = %q, %v", short, truncated)
+	}
+}
+
+func TestCheckBinaryReportsInstallHint(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "pg_dump")
+	err := checkBinary("pg_dump", missing, "15")
+	var mb *missingBinaryError
+	if !errors.As(err, &mb) {
+		t.Fatalf("checkBinary(%s) = %v, want a missingBinaryError", missing, err)
+	}
+	want := "pg_dump for PostgreSQL 15 not found on server; install postgresql-client-15"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+
+	if err := checkBinary("pg_dump", "dumpstation-no-such-tool", "latest"); !strings.Contains(fmt.Sprint(err), "install the PostgreSQL client tools") {
+		t.Errorf("unpinned missing tool: error = %v", err)
+	}
+	if err := checkBinary("sh", "sh", ""); err != nil {
+		t.Errorf("checkBinary(sh) = %v, want nil", err)
+	}
+}
+
+func TestPgEnv(t *testing.T) {
+	t.Parallel()
+
+	cfg := &models.DatabaseConfig{PgEnv: map[string]string{
+		"PGSSLMODE": "verify-full",
+		"PGOPTIONS": "-c search_path=app",
+	}}
+	env, err := pgEnv(cfg)
+	if err != nil {
+		t.Fatalf("pgEnv: %v", err)
+	}
+	want := []string{"PGOPTIONS=-c search_path=app", "PGSSLMODE=verify-full"}
+	if strings.Join(env, "|") != strings.Join(want, "|") {
+		t.Errorf("pgEnv = %q, want %q", env, want)
+	}
+	if mode, ok := pinnedSSLMode(cfg); !ok || mode != "verify-full" {
+		t.Errorf("pinnedSSLMode = %q, %v, want verify-full", mode, ok)
+	}
+
+	if _, err := pgEnv(&models.DatabaseConfig{PgEnv: map[string]string{"PGPASSFILE": "/etc/shadow"}}); err == nil {
+		t.Error("pgEnv accepted PGPASSFILE stored directly in the database")
+	}
+	if _, ok := pinnedSSLMode(&models.DatabaseConfig{}); ok {
+		t.Error("pinnedSSLMode reported a mode for a config without pg_env")
+	}
+}
+
+// TestRestorePgEnv verifies a restore into a registered config runs psql
+// with that config's pg_env, keeps its pinned sslmode without falling back
+// to plaintext, and that a custom target host drops the settings.
+func TestRestorePgEnv(t *testing.T) {
+	t.Parallel()
+
+	target := &models.DatabaseConfig{
+		Host: "db.internal", Port: 5432, Username: "app", DBName: "app", Password: "secret",
+		PgEnv: map[string]string{"PGSSLMODE": "verify-full", "PGOPTIONS": "-c search_path=app"},
+	}
+	cfg, err := restoreTargetConfig(target, &models.RestoreRequest{TargetDBName: "app_scratch"})
+	if err != nil {
+		t.Fatalf("restoreTargetConfig: %v", err)
+	}
+	if cfg.DBName != "app_scratch" || cfg.Host != "db.internal" {
+		t.Errorf("target = %s/%s, want db.internal/app_scratch", cfg.Host, cfg.DBName)
+	}
+
+	// The fake psql appends its environment and fails as an SSL error
+	// would; a pinned sslmode must not be retried with sslmode=disable.
+	dir := t.TempDir()
+	envLog := filepath.Join(dir, "env.log")
+	psql := filepath.Join(dir, "psql")
+	script := "#!/bin/sh\nenv >> " + envLog + "\necho '--' >> " + envLog + "\necho 'SSL error: certificate verify failed' >&2\nexit 2\n"
+	if err := os.WriteFile(psql, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{}
+	mode, err := s.executeRestoreWithSSLFallback(context.Background(), psql, nil, cfg, "dumpstation-test")
+	if err == nil {
+		t.Fatal("restore succeeded, want the fake psql's failure")
+	}
+	if mode != "verify-full" {
+		t.Errorf("sslmode = %q, want verify-full", mode)
+	}
+	out, err := os.ReadFile(envLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := strings.Count(string(out), "--\n"); runs != 1 {
+		t.Errorf("psql ran %d times, want 1", runs)
+	}
+	for _, want := range []string{"PGOPTIONS=-c search_path=app", "PGSSLMODE=verify-full"} {
+		if !strings.Contains(string(out), want+"\n") {
+			t.Errorf("psql env is missing %q", want)
+		}
+	}
+	if strings.Contains(string(out), "PGSSLMODE=disable") {
+		t.Error("psql was retried with PGSSLMODE=disable")
+	}
+
+	custom, err := restoreTargetConfig(target, &models.RestoreRequest{TargetHost: "other.example", TargetPassword: "other"})
+	if err != nil {
+		t.Fatalf("restoreTargetConfig: %v", err)
+	}
+	if len(custom.PgEnv) != 0 {
+		t.Errorf("custom target host kept pg_env %v", custom.PgEnv)
+	}
+}
+
+// TestRestoreTargetCredentials checks that the source password is only
+// reused on the source's own server: another host or port needs its own.
+func TestRestoreTargetCredentials(t *testing.T) {
+	t.Parallel()
+
+	target := &models.DatabaseConfig{Host: "db.internal", Port: 5432, Username: "app", DBName: "app", Password: "secret"}
+	cases := []struct {
+		name    string
+		req     models.RestoreRequest
+		wantErr bool
+	}{
+		{"other dbname", models.RestoreRequest{TargetDBName: "app_scratch"}, false},
+		{"same port", models.RestoreRequest{TargetPort: 5432}, false},
+		{"other port", models.RestoreRequest{TargetPort: 6432}, true},
+		{"other port with password", models.RestoreRequest{TargetPort: 6432, TargetPassword: "other"}, false},
+		{"other host", models.RestoreRequest{TargetHost: "other.example"}, true},
+	}
+	for _, tc := range cases {
+		cfg, err := restoreTargetConfig(target, &tc.req)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if err == nil && tc.req.TargetPassword == "" && cfg.Password != "secret" {
+			t.Errorf("%s: password = %q, want the source's", tc.name, cfg.Password)
+		}
+	}
+}
+
+// TestShutdownCancelsRuns checks that Shutdown ends the context backup runs
+// derive their dump and upload deadlines from.
+func TestShutdownCancelsRuns(t *testing.T) {
+	s := NewService(nil)
+	ctx := s.runContext()
+	if ctx.Err() != nil {
+		t.Fatalf("run context done before Shutdown: %v", ctx.Err())
+	}
+	s.Shutdown()
+	if ctx.Err() == nil {
+		t.Error("run context still live after Shutdown")
+	}
+	// A Service built as a literal (as in tests) still gets a usable context.
+	if (&Service{}).runContext() == nil {
+		t.Error("zero Service has no run context")
+	}
+}
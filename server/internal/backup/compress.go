@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzipToTemp compresses src into a new temp file and returns its path and
+// size. src is read from offset zero. The caller removes the returned file.
+func gzipToTemp(src *os.File) (string, int64, error) {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", 0, fmt.Errorf("seek dump: %w", err)
+	}
+
+	dst, err := os.CreateTemp("", "dumpstation-*.sql.gz")
+	if err != nil {
+		return "", 0, fmt.Errorf("create gzip tempfile: %w", err)
+	}
+	path := dst.Name()
+	fail := func(err error) (string, int64, error) {
+		dst.Close()
+		os.Remove(path)
+		return "", 0, err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		return fail(fmt.Errorf("gzip dump: %w", err))
+	}
+	if err := zw.Close(); err != nil {
+		return fail(fmt.Errorf("finish gzip: %w", err))
+	}
+	info, err := dst.Stat()
+	if err != nil {
+		return fail(fmt.Errorf("stat gzip: %w", err))
+	}
+	if err := dst.Close(); err != nil {
+		return fail(fmt.Errorf("close gzip: %w", err))
+	}
+	return path, info.Size(), nil
+}
+
+// gunzipFile decompresses the gzip file at src into dst.
+func gunzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open compressed backup: %w", err)
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("read gzip header: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create decompressed file: %w", err)
+	}
+	if _, err := io.Copy(out, zr); err != nil {
+		out.Close()
+		return fmt.Errorf("gunzip backup: %w", err)
+	}
+	return out.Close()
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path, i.e. of the
+// object exactly as it is uploaded.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// Captured pg_dump output is cut to its last maxDumpLogLines lines and
+// maxDumpLogBytes bytes: the end of the output is where failures show up.
+const (
+	maxDumpLogLines = 300
+	maxDumpLogBytes = 32 << 10
+)
+
+// secretInLogRe matches credentials that can appear in libpq and pg_dump
+// messages: password=... in a connection string and the password part of
+// a postgres:// URL.
+var secretInLogRe = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)|(postgres(?:ql)?://[^:/@\s]+:)[^@\s]+@`)
+
+// scrubDumpLog removes password from out, along with anything that looks
+// like a password in a connection string or URL.
+func scrubDumpLog(out, password string) string {
+	if password != "" {
+		out = strings.ReplaceAll(out, password, "****")
+	}
+	return secretInLogRe.ReplaceAllStringFunc(out, func(m string) string {
+		sub := secretInLogRe.FindStringSubmatch(m)
+		if sub[1] != "" {
+			return sub[1] + "****"
+		}
+		return sub[3] + "****@"
+	})
+}
+
+// trimDumpLog keeps the tail of out within maxDumpLogLines and
+// maxDumpLogBytes, and reports whether anything was dropped.
+func trimDumpLog(out string) (string, bool) {
+	out = strings.TrimRight(out, "\n")
+	lines := strings.Split(out, "\n")
+	truncated := false
+	if len(lines) > maxDumpLogLines {
+		lines = lines[len(lines)-maxDumpLogLines:]
+		truncated = true
+	}
+	size := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		size += len(lines[i]) + 1
+		if size > maxDumpLogBytes {
+			lines = lines[i+1:]
+			truncated = true
+			break
+		}
+	}
+	return strings.Join(lines, "\n"), truncated
+}
+
+// recordDumpLog stores the scrubbed tail of pg_dump's stderr for the
+// backup. Failing to store it is logged, never fatal to the backup.
+func (s *Service) recordDumpLog(backupID uuid.UUID, dbConfig *models.DatabaseConfig, stderr string) {
+	if strings.TrimSpace(stderr) == "" {
+		return
+	}
+	tail, truncated := trimDumpLog(scrubDumpLog(stderr, dbConfig.Password))
+	entry := &models.BackupLog{
+		BackupID:  backupID,
+		Log:       tail,
+		Truncated: truncated,
+		Verbose:   !dbConfig.NoVerbose,
+	}
+	if err := s.repo.SaveBackupLog(entry); err != nil {
+		log.Printf("Failed to save pg_dump log for backup %s: %v", backupID, err)
+	}
+}
@@ -0,0 +1,18 @@
+package backup
+
+import (
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// SetHostPolicy makes backups and restores re-check the database host just
+// before connecting. The API checks hosts when they are saved, but a name
+// can resolve somewhere else by the time a scheduled run starts. A nil
+// policy allows every host. Call before serving requests.
+func (s *Service) SetHostPolicy(p *utils.HostPolicy) {
+	s.hostPolicy = p
+}
+
+// checkHost reports whether the policy lets the service connect to host.
+func (s *Service) checkHost(host string) error {
+	return s.hostPolicy.Check(host)
+}
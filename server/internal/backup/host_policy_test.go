@@ -0,0 +1,28 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/dbadmin"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// TestTestRestoreRechecksHost checks that a test restore refuses a server
+// the host policy denies before it connects to create the scratch database.
+func TestTestRestoreRechecksHost(t *testing.T) {
+	policy, err := utils.NewHostPolicy(nil, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("NewHostPolicy: %v", err)
+	}
+	s := &Service{}
+	s.SetHostPolicy(policy)
+
+	err = s.testRestoreInto(&models.RestoreJob{}, &models.Backup{}, &models.DatabaseConfig{},
+		&models.RestoreRequest{TargetDBName: "dumpstation_test"},
+		dbadmin.Options{Host: "10.1.2.3", Port: 5432}, &models.TestRestoreResult{})
+	if err == nil || !strings.Contains(err.Error(), "host policy") {
+		t.Fatalf("testRestoreInto error = %v, want a host policy error", err)
+	}
+}
@@ -0,0 +1,40 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+)
+
+// missingBinaryError reports a PostgreSQL client tool that is not installed
+// on the server, with the package to install. Without it, exec's
+// "executable file not found" reaches the backup record and notification.
+type missingBinaryError struct {
+	tool  string // e.g. "pg_dump"
+	major string // PostgreSQL major the tool was picked for; "" or "latest" when unpinned
+	err   error
+}
+
+func (e *missingBinaryError) Error() string {
+	if e.major == "" || e.major == "latest" {
+		return fmt.Sprintf("%s not found on server; install the PostgreSQL client tools (postgresql-client)", e.tool)
+	}
+	return fmt.Sprintf("%s for PostgreSQL %s not found on server; install postgresql-client-%s", e.tool, e.major, e.major)
+}
+
+func (e *missingBinaryError) Unwrap() error { return e.err }
+
+// checkBinary returns a *missingBinaryError when cmd, a bare tool name
+// looked up in PATH or a path from the version manager, can't be run.
+func checkBinary(tool, cmd, major string) error {
+	_, err := exec.LookPath(cmd)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return &missingBinaryError{tool: tool, major: major, err: err}
+	}
+	// Present but not runnable (e.g. permissions): let exec report it.
+	return nil
+}
@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// maxKeyAttempts bounds how many suffixed names are tried before a backup
+// gives up on finding a free object key.
+const maxKeyAttempts = 5
+
+// SetKeyCollisionCheck turns the pre-upload existence check on or off.
+// When on (the default), a backup whose object key is already taken is
+// renamed with a random suffix instead of overwriting the existing object.
+func (s *Service) SetKeyCollisionCheck(enabled bool) {
+	s.skipKeyCheck = !enabled
+}
+
+// objectExistsFunc reports whether an object key is already in use.
+type objectExistsFunc func(ctx context.Context, objectKey string) (bool, error)
+
+// resolveObjectKey returns the file name and object key a backup uploads
+// to. base is the rendered filename template and ext the extension (".dump",
+// ".sql.gz"). Templates always embed the backup ID, but a template edited
+// directly in the database may not, so a taken key gets "-<6 hex>" appended
+// to base until one is free. A failed existence check is logged and the
+// plain name used: buckets that allow PutObject but not HeadObject must
+// keep working.
+func resolveObjectKey(ctx context.Context, exists objectExistsFunc, databaseID, base, ext string) (string, string, error) {
+	filename := base + ext
+	for attempt := 0; attempt < maxKeyAttempts; attempt++ {
+		if attempt > 0 {
+			suffix, err := randomKeySuffix()
+			if err != nil {
+				return "", "", err
+			}
+			filename = base + "-" + suffix + ext
+		}
+		key := storage.GetObjectKey(databaseID, filename)
+		if exists == nil {
+			return filename, key, nil
+		}
+		taken, err := exists(ctx, key)
+		if err != nil {
+			log.Printf("Could not check whether %s exists, uploading without the collision guard: %v", key, err)
+			return filename, key, nil
+		}
+		if !taken {
+			return filename, key, nil
+		}
+		log.Printf("Object key %s is already taken, choosing another name", key)
+	}
+	return "", "", fmt.Errorf("no free object key for %s%s after %d attempts", base, ext, maxKeyAttempts)
+}
+
+func randomKeySuffix() (string, error) {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate object key suffix: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,36 @@
+package backup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// permissionDeniedRe matches the object named in PostgreSQL's "permission
+// denied" error, e.g.
+//
+//	pg_dump: error: query failed: ERROR:  permission denied for table audit_log
+//
+// Servers before PostgreSQL 11 say "relation" instead of "table".
+var permissionDeniedRe = regexp.MustCompile(`permission denied for (table|schema|sequence|view|materialized view|function|large object|relation) ("[^"]+"|\S+)`)
+
+// deniedObject returns the object pg_dump's stderr says the backup user may
+// not read, as "table audit_log", or "" if there is none. pg_dump stops at
+// the first object it cannot read, so a failed dump names at most one.
+func deniedObject(stderr string) string {
+	m := permissionDeniedRe.FindStringSubmatch(stderr)
+	if m == nil {
+		return ""
+	}
+	return m[1] + " " + strings.TrimRight(m[2], ".,;")
+}
+
+// dumpFailureMessage is the error recorded for a failed pg_dump. A missing
+// privilege is called out by object, so the owner can grant access or
+// exclude it without reading through pg_dump's output.
+func dumpFailureMessage(err error, stderr string) string {
+	if object := deniedObject(stderr); object != "" {
+		return fmt.Sprintf("pg_dump failed: the backup user has no access to %s; grant it or exclude it with extra_args: %v", object, err)
+	}
+	return fmt.Sprintf("pg_dump failed: %v", err)
+}
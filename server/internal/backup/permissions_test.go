@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestDumpFailureNamesDeniedObject runs a fake pg_dump that fails the way
+// pg_dump does when the backup user cannot lock a table, and checks the
+// recorded error names that table.
+func TestDumpFailureNamesDeniedObject(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pgDump := filepath.Join(dir, "pg_dump")
+	script := "#!/bin/sh\n" +
+		"echo 'pg_dump: error: query failed: ERROR:  permission denied for table audit_log' >&2\n" +
+		"echo 'pg_dump: detail: Query was: LOCK TABLE public.audit_log IN ACCESS SHARE MODE' >&2\n" +
+		"exit 1\n"
+	if err := os.WriteFile(pgDump, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Create(filepath.Join(dir, "out.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	dbConfig := &models.DatabaseConfig{
+		Name: "app", Host: "db.internal", Port: 5432, DBName: "app", Username: "backup", Password: "secret",
+		PgEnv: map[string]string{"PGSSLMODE": "require"},
+	}
+	s := &Service{}
+	_, stderr, err := s.executeBackupWithSSLFallback(context.Background(), pgDump, "16", nil, dbConfig, out, nil)
+	if err == nil {
+		t.Fatal("dump succeeded, want the fake pg_dump's failure")
+	}
+	msg := dumpFailureMessage(err, stderr)
+	if !strings.Contains(msg, "no access to table audit_log") {
+		t.Errorf("failure message = %q, want it to name table audit_log", msg)
+	}
+
+	if got := dumpFailureMessage(err, "pg_dump: error: connection to server failed"); strings.Contains(got, "no access") {
+		t.Errorf("connection failure reported as a permission problem: %q", got)
+	}
+}
+
+func TestDeniedObject(t *testing.T) {
+	for stderr, want := range map[string]string{
+		"pg_dump: error: query failed: ERROR:  permission denied for table audit_log":          "table audit_log",
+		"pg_dump: [archiver (db)] query failed: ERROR:  permission denied for relation ledger": "relation ledger",
+		"pg_dump: error: query failed: ERROR:  permission denied for schema billing":           "schema billing",
+		"pg_dump: error: connection to server failed":                                          "",
+	} {
+		if got := deniedObject(stderr); got != want {
+			t.Errorf("deniedObject(%q) = %q, want %q", stderr, got, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// pgEnv returns dbConfig's extra libpq variables as KEY=value pairs to
+// append after DumpStation's own, in a stable order. They are checked again
+// here because configs can reach the database without the API's validator.
+func pgEnv(dbConfig *models.DatabaseConfig) ([]string, error) {
+	if len(dbConfig.PgEnv) == 0 {
+		return nil, nil
+	}
+	if err := utils.ValidatePgEnv(dbConfig.PgEnv); err != nil {
+		return nil, fmt.Errorf("invalid pg_env: %w", err)
+	}
+	env := make([]string, 0, len(dbConfig.PgEnv))
+	for _, key := range utils.SortedPgEnvKeys(dbConfig.PgEnv) {
+		env = append(env, key+"="+dbConfig.PgEnv[key])
+	}
+	return env, nil
+}
+
+// pinnedSSLMode reports the sslmode a database sets through PGSSLMODE in
+// its pg_env. A pinned mode is used as-is: the require-then-disable
+// fallback would otherwise override the owner's choice.
+func pinnedSSLMode(dbConfig *models.DatabaseConfig) (SSLMode, bool) {
+	mode, ok := dbConfig.PgEnv["PGSSLMODE"]
+	if !ok {
+		return "", false
+	}
+	return SSLMode(mode), true
+}
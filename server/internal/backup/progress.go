@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// transferProgress holds the in-flight upload or download of each backup.
+// Entries are dropped when the transfer ends, so nothing outlives a
+// restart.
+type transferProgress struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]*models.BackupProgress
+}
+
+// start registers a transfer and returns the callback to feed storage and
+// the function that removes the entry again.
+func (t *transferProgress) start(backupID uuid.UUID, operation string) (storage.ProgressFunc, func()) {
+	now := time.Now()
+	entry := &models.BackupProgress{
+		BackupID:  backupID,
+		Active:    true,
+		Operation: operation,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	t.mu.Lock()
+	t.entries[backupID] = entry
+	t.mu.Unlock()
+
+	update := func(done, total int64) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		entry.BytesDone = done
+		entry.BytesTotal = total
+		entry.UpdatedAt = time.Now()
+	}
+	finish := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// A later transfer of the same backup may have replaced us.
+		if t.entries[backupID] == entry {
+			delete(t.entries, backupID)
+		}
+	}
+	return update, finish
+}
+
+// Progress returns the running transfer for backupID. The result has
+// Active false when nothing is being transferred.
+func (s *Service) Progress(backupID uuid.UUID) models.BackupProgress {
+	s.progress.mu.Lock()
+	defer s.progress.mu.Unlock()
+	entry, ok := s.progress.entries[backupID]
+	if !ok {
+		return models.BackupProgress{BackupID: backupID}
+	}
+	p := *entry
+	if p.BytesTotal > 0 {
+		p.Percent = float64(p.BytesDone*1000/p.BytesTotal) / 10
+	}
+	return p
+}
@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+func TestTransferProgress(t *testing.T) {
+	s := &Service{progress: transferProgress{entries: make(map[uuid.UUID]*models.BackupProgress)}}
+	id := uuid.New()
+
+	if p := s.Progress(id); p.Active {
+		t.Fatalf("progress before start = %+v, want inactive", p)
+	}
+
+	update, finish := s.progress.start(id, models.TransferUpload)
+	update(256, 1024)
+	p := s.Progress(id)
+	if !p.Active || p.Operation != models.TransferUpload || p.BytesDone != 256 || p.BytesTotal != 1024 || p.Percent != 25 {
+		t.Fatalf("progress mid-transfer = %+v", p)
+	}
+
+	// A second transfer of the same backup replaces the first; the first
+	// finishing must not remove it.
+	update2, finish2 := s.progress.start(id, models.TransferDownload)
+	finish()
+	update2(10, 20)
+	if p := s.Progress(id); p.Operation != models.TransferDownload || p.Percent != 50 {
+		t.Fatalf("progress after replacement = %+v", p)
+	}
+	finish2()
+	if p := s.Progress(id); p.Active {
+		t.Fatalf("progress after finish = %+v, want inactive", p)
+	}
+}
@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// maxDryRunEntries caps how many table-of-contents lines a dry run returns.
+const maxDryRunEntries = 1000
+
+// RestoreDryRun is the outcome of validating a backup without restoring it.
+// Valid is false when pg_restore cannot read the archive or a requested
+// table is not in it.
+type RestoreDryRun struct {
+	Valid         bool     `json:"valid"`
+	EntryCount    int      `json:"entry_count" example:"42"`
+	Entries       []string `json:"entries"` // pg_restore --list lines, at most 1000
+	Truncated     bool     `json:"truncated,omitempty"`
+	MissingTables []string `json:"missing_tables,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// DryRunRestore downloads a custom-format backup and reads its table of
+// contents with pg_restore --list. Nothing connects to the restore target.
+// An unreadable archive is reported in the result; the error is only for
+// failures to fetch the dump or run pg_restore at all.
+func (s *Service) DryRunRestore(ctx context.Context, backupID uuid.UUID, req *models.RestoreRequest) (*RestoreDryRun, error) {
+	backup, dbConfig, err := s.loadRestoreSource(backupID, req)
+	if err != nil {
+		return nil, err
+	}
+	if backup.DumpFormat != models.DumpFormatCustom {
+		return nil, fmt.Errorf("dry runs require a custom-format backup")
+	}
+
+	storageConfig, err := s.repo.GetStorageConfig(dbConfig.StorageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage config: %w", err)
+	}
+	storageClient, err := storage.NewStorageClient(storageConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+
+	tempFilePath, err := s.createRestoreTemp(fmt.Sprintf("restore_dryrun_%s.dump", uuid.New()))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFilePath)
+	if err := storageClient.DownloadFile(backup.StoragePath, tempFilePath); err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+
+	postgresVersion := dbConfig.PostgresVersion
+	if postgresVersion == "" {
+		postgresVersion = "latest"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.versionManager.GetPgRestoreVersion(postgresVersion), "--list", tempFilePath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("run pg_restore: %w", err)
+		}
+		return &RestoreDryRun{Error: strings.TrimSpace(stderr.String())}, nil
+	}
+
+	entries := parseRestoreList(stdout.String())
+	result := &RestoreDryRun{
+		EntryCount:    len(entries),
+		Entries:       entries,
+		MissingTables: missingTables(entries, req.Tables),
+	}
+	if len(result.Entries) > maxDryRunEntries {
+		result.Entries = result.Entries[:maxDryRunEntries]
+		result.Truncated = true
+	}
+	result.Valid = len(result.MissingTables) == 0
+	return result, nil
+}
+
+// parseRestoreList returns the entries of pg_restore --list output,
+// dropping the ";" header comments and blank lines.
+func parseRestoreList(out string) []string {
+	var entries []string
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries
+}
+
+// tocTableEntry matches "<id>; <oid> <oid> TABLE <schema> <name> <owner>"
+// and its TABLE DATA counterpart.
+var tocTableEntry = regexp.MustCompile(`^\d+; \d+ \d+ TABLE (?:DATA )?(\S+) (\S+)`)
+
+// missingTables returns the requested tables with no TABLE entry in the
+// archive. Like pg_restore --table, names match in any schema.
+func missingTables(entries, tables []string) []string {
+	if len(tables) == 0 {
+		return nil
+	}
+	present := make(map[string]bool)
+	for _, e := range entries {
+		if m := tocTableEntry.FindStringSubmatch(e); m != nil {
+			present[m[2]] = true
+		}
+	}
+	var missing []string
+	for _, t := range tables {
+		if !present[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
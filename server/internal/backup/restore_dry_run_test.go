@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"slices"
+	"testing"
+)
+
+const restoreListOutput = `;
+; Archive created at 2026-10-01 02:00:03 UTC
+;     dbname: shop
+;     TOC Entries: 6
+;
+;
+; Selected TOC Entries:
+;
+215; 1259 16386 TABLE public orders app
+216; 1259 16390 TABLE public order_items app
+3340; 0 16386 TABLE DATA public orders app
+3341; 0 16390 TABLE DATA public order_items app
+3190; 2606 16395 CONSTRAINT public orders orders_pkey app
+
+3350; 0 0 ACL - SCHEMA public app
+`
+
+func TestParseRestoreList(t *testing.T) {
+	entries := parseRestoreList(restoreListOutput)
+	if len(entries) != 6 {
+		t.Fatalf("got %d entries, want 6: %q", len(entries), entries)
+	}
+	if entries[0] != "215; 1259 16386 TABLE public orders app" {
+		t.Errorf("first entry = %q", entries[0])
+	}
+}
+
+func TestMissingTables(t *testing.T) {
+	entries := parseRestoreList(restoreListOutput)
+	if got := missingTables(entries, nil); got != nil {
+		t.Errorf("no tables requested: got %q, want nil", got)
+	}
+	got := missingTables(entries, []string{"orders", "customers", "order_items", "public"})
+	if want := []string{"customers", "public"}; !slices.Equal(got, want) {
+		t.Errorf("missingTables = %q, want %q", got, want)
+	}
+}
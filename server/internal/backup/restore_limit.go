@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"sync/atomic"
+)
+
+// restoreLimiter caps how many restores (including test restores) run at
+// once so a mass recovery can't swamp the target servers. Jobs waiting for
+// a slot stay "pending" until they get one. Dry runs don't take a slot:
+// they only download the dump and read its table of contents, never
+// touching a target server, and the caller waits for them synchronously.
+type restoreLimiter struct {
+	slots   chan struct{} // nil means unlimited
+	max     int
+	queued  atomic.Int32
+	running atomic.Int32
+}
+
+// SetMaxConcurrentRestores limits how many restores run at once; extra
+// restores wait their turn. n <= 0 means unlimited. Call before serving
+// requests.
+func (s *Service) SetMaxConcurrentRestores(n int) {
+	s.restores.max = 0
+	s.restores.slots = nil
+	if n > 0 {
+		s.restores.max = n
+		s.restores.slots = make(chan struct{}, n)
+	}
+}
+
+// acquireRestoreSlot waits for a free restore slot and returns the function
+// that frees it.
+func (s *Service) acquireRestoreSlot() (release func()) {
+	if s.restores.slots != nil {
+		s.restores.queued.Add(1)
+		s.restores.slots <- struct{}{}
+		s.restores.queued.Add(-1)
+	}
+	s.restores.running.Add(1)
+	return func() {
+		s.restores.running.Add(-1)
+		if s.restores.slots != nil {
+			<-s.restores.slots
+		}
+	}
+}
+
+// RestoreStatus reports how many restores are running and how many are
+// waiting for a slot under the SetMaxConcurrentRestores limit.
+func (s *Service) RestoreStatus() (running, queued, max int) {
+	return int(s.restores.running.Load()), int(s.restores.queued.Load()), s.restores.max
+}
@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// restoreTempPattern matches the files restores stage downloads in:
+// restore_<job id>.sql, restore_<job id>_plain.sql and
+// restore_dryrun_<id>.dump. Requiring the UUID keeps the sweep away from
+// other programs' files when the directory is the shared system temp dir.
+var restoreTempPattern = regexp.MustCompile(`^(restore_|restore_dryrun_)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// defaultRestoreDirName is the restore temp dir used in the system temp dir
+// when none is configured.
+const defaultRestoreDirName = "dumpstation-restore"
+
+// SetRestoreTempDir sets where restores download backups to, creating the
+// directory if needed and restricting it to the service's user (0700):
+// downloaded dumps hold the full contents of the source database.
+//
+// Empty uses dumpstation-restore in the system temp dir. Anyone can create
+// that path first, so if it is not a directory owned by the service's user
+// a fresh private directory from os.MkdirTemp is used instead.
+func (s *Service) SetRestoreTempDir(dir string) error {
+	if dir != "" {
+		if err := prepareRestoreDir(dir); err != nil {
+			return err
+		}
+		s.restoreDir = dir
+		return nil
+	}
+
+	dir = filepath.Join(os.TempDir(), defaultRestoreDirName)
+	if err := prepareRestoreDir(dir); err != nil {
+		log.Printf("Not using %s for restores: %v", dir, err)
+		if dir, err = os.MkdirTemp("", defaultRestoreDirName+"-"); err != nil {
+			return fmt.Errorf("create restore temp dir: %w", err)
+		}
+	}
+	s.restoreDir = dir
+	return nil
+}
+
+// prepareRestoreDir creates dir if needed, checks it is a real directory
+// owned by the service's user and restricts it to that user.
+func prepareRestoreDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create restore temp dir: %w", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("stat restore temp dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("restore temp dir %s is not a directory", dir)
+	}
+	if !ownedByCurrentUser(info) {
+		return fmt.Errorf("restore temp dir %s is owned by another user", dir)
+	}
+	// MkdirAll leaves an existing directory's mode alone.
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return fmt.Errorf("restrict restore temp dir: %w", err)
+	}
+	return nil
+}
+
+// restoreTempDir is the directory restore downloads are written to.
+func (s *Service) restoreTempDir() string {
+	if s.restoreDir != "" {
+		return s.restoreDir
+	}
+	return os.TempDir()
+}
+
+// createRestoreTemp creates an empty, owner-only file called name in the
+// restore temp dir and returns its path. Creating it up front fixes the
+// mode before the download writes any data into it.
+func (s *Service) createRestoreTemp(name string) (string, error) {
+	path := filepath.Join(s.restoreTempDir(), name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("create restore temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("create restore temp file: %w", err)
+	}
+	return path, nil
+}
+
+// SweepRestoreTemp removes restore temp files older than maxAge, left
+// behind when the process was killed mid-restore. It covers the configured
+// directory and the system temp dir, where earlier versions wrote them, and
+// returns how many files were removed. Run it at startup, before any
+// restore can be in progress.
+func (s *Service) SweepRestoreTemp(maxAge time.Duration) int {
+	dirs := []string{s.restoreTempDir()}
+	if sys := os.TempDir(); filepath.Clean(sys) != filepath.Clean(dirs[0]) {
+		dirs = append(dirs, sys)
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			log.Printf("Failed to read %s for stale restore files: %v", dir, err)
+			continue
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || !restoreTempPattern.MatchString(e.Name()) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if err := os.Remove(path); err != nil {
+				log.Printf("Failed to remove stale restore file %s: %v", path, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed
+}
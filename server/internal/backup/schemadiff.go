@@ -0,0 +1,136 @@
+package backup
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// SchemaObject is one object from a plain-format pg_dump, identified by the
+// "-- Name: ...; Type: ...; Schema: ..." header pg_dump writes before it.
+type SchemaObject struct {
+	Type       string `json:"type" example:"TABLE"`
+	Schema     string `json:"schema" example:"public"`
+	Name       string `json:"name" example:"users"`
+	Definition string `json:"definition"`
+}
+
+// SchemaChange is an object present in both dumps with a different
+// definition.
+type SchemaChange struct {
+	Type   string `json:"type" example:"TABLE"`
+	Schema string `json:"schema" example:"public"`
+	Name   string `json:"name" example:"users"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// SchemaDiff lists what changed from an older schema dump to a newer one.
+type SchemaDiff struct {
+	Added   []SchemaObject `json:"added"`
+	Removed []SchemaObject `json:"removed"`
+	Changed []SchemaChange `json:"changed"`
+}
+
+func (o SchemaObject) key() string {
+	return o.Type + "\x00" + o.Schema + "\x00" + o.Name
+}
+
+// parseSchemaDump splits a plain pg_dump into objects in dump order.
+// Comments, blank lines and psql meta-commands (e.g. \restrict, whose key is
+// random per dump) are dropped so only real DDL is compared; the SET
+// preamble before the first object is ignored.
+func parseSchemaDump(r io.Reader) ([]SchemaObject, error) {
+	var (
+		objects []SchemaObject
+		current *SchemaObject
+		body    []string
+	)
+	flush := func() {
+		if current != nil {
+			current.Definition = strings.Join(body, "\n")
+			objects = append(objects, *current)
+		}
+		body = body[:0]
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "-- Name: ") {
+			flush()
+			current = parseObjectHeader(strings.TrimPrefix(line, "-- "))
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, `\`) {
+			continue
+		}
+		body = append(body, strings.TrimRight(line, " \t"))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return objects, nil
+}
+
+// parseObjectHeader parses "Name: x; Type: TABLE; Schema: public; Owner: y".
+func parseObjectHeader(header string) *SchemaObject {
+	obj := &SchemaObject{}
+	for _, part := range strings.Split(header, "; ") {
+		k, v, _ := strings.Cut(part, ": ")
+		switch k {
+		case "Name":
+			obj.Name = v
+		case "Type":
+			obj.Type = v
+		case "Schema":
+			if v != "-" {
+				obj.Schema = v
+			}
+		}
+	}
+	return obj
+}
+
+// DiffSchemaDumps compares two plain-format schema dumps. Added and changed
+// objects follow the newer dump's order, removed ones the older dump's.
+func DiffSchemaDumps(older, newer io.Reader) (*SchemaDiff, error) {
+	before, err := parseSchemaDump(older)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parseSchemaDump(newer)
+	if err != nil {
+		return nil, err
+	}
+
+	oldByKey := make(map[string]SchemaObject, len(before))
+	for _, o := range before {
+		oldByKey[o.key()] = o
+	}
+	newKeys := make(map[string]bool, len(after))
+
+	diff := &SchemaDiff{Added: []SchemaObject{}, Removed: []SchemaObject{}, Changed: []SchemaChange{}}
+	for _, o := range after {
+		newKeys[o.key()] = true
+		prev, ok := oldByKey[o.key()]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, o)
+		case prev.Definition != o.Definition:
+			diff.Changed = append(diff.Changed, SchemaChange{
+				Type: o.Type, Schema: o.Schema, Name: o.Name,
+				Before: prev.Definition, After: o.Definition,
+			})
+		}
+	}
+	for _, o := range before {
+		if !newKeys[o.key()] {
+			diff.Removed = append(diff.Removed, o)
+		}
+	}
+	return diff, nil
+}
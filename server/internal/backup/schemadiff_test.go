@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+)
+
+const schemaV1 = `--
+-- PostgreSQL database dump
+--
+
+\restrict abc123
+
+SET statement_timeout = 0;
+
+--
+-- Name: users; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    email text
+);
+
+
+ALTER TABLE public.users OWNER TO app;
+
+--
+-- Name: legacy; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.legacy (
+    id integer
+);
+
+--
+-- PostgreSQL database dump complete
+--
+
+\unrestrict abc123
+`
+
+const schemaV2 = `--
+-- PostgreSQL database dump
+--
+
+\restrict zzz999
+
+SET statement_timeout = 0;
+
+--
+-- Name: users; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    email text NOT NULL
+);
+
+
+ALTER TABLE public.users OWNER TO app;
+
+--
+-- Name: orders; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.orders (
+    id integer
+);
+
+--
+-- PostgreSQL database dump complete
+--
+
+\unrestrict zzz999
+`
+
+// TestDiffSchemaDumps covers an added, a removed and a changed table, and
+// checks per-dump noise (the \restrict key, comments) is not reported.
+func TestDiffSchemaDumps(t *testing.T) {
+	t.Parallel()
+
+	diff, err := DiffSchemaDumps(strings.NewReader(schemaV1), strings.NewReader(schemaV2))
+	if err != nil {
+		t.Fatalf("DiffSchemaDumps: %v", err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].Name != "orders" || diff.Added[0].Type != "TABLE" {
+		t.Errorf("Added = %+v, want orders", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "legacy" {
+		t.Errorf("Removed = %+v, want legacy", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Name != "users" {
+		t.Fatalf("Changed = %+v, want users", diff.Changed)
+	}
+	if !strings.Contains(diff.Changed[0].After, "email text NOT NULL") {
+		t.Errorf("Changed.After = %q", diff.Changed[0].After)
+	}
+
+	same, err := DiffSchemaDumps(strings.NewReader(schemaV1), strings.NewReader(schemaV1))
+	if err != nil {
+		t.Fatalf("DiffSchemaDumps: %v", err)
+	}
+	if len(same.Added)+len(same.Removed)+len(same.Changed) != 0 {
+		t.Errorf("identical dumps produced a diff: %+v", same)
+	}
+}
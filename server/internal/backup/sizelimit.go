@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errMaxSizeExceeded is returned once a backup grows past the database's
+// MaxSizeBytes.
+var errMaxSizeExceeded = errors.New("exceeded max size")
+
+// checkStoredSize fails when size, the size of the object about to be
+// uploaded, is over limit. A nil limit allows any size.
+func checkStoredSize(size int64, limit *int64) error {
+	if limit == nil || size <= *limit {
+		return nil
+	}
+	return fmt.Errorf("%w: compressed dump is %d bytes, limit %d", errMaxSizeExceeded, size, *limit)
+}
+
+// limitedWriter counts bytes written through it and fails the write that
+// would cross limit. Used as pg_dump's stdout, the failed write closes the
+// pipe so the dump stops as soon as it is too big instead of after it has
+// filled the disk.
+type limitedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+	hit     bool
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.written+int64(len(p)) > lw.limit {
+		lw.hit = true
+		return 0, errMaxSizeExceeded
+	}
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+	return n, err
+}
+
+// dumpOutput returns the writer pg_dump should write to: outFile itself, or
+// outFile behind a limitedWriter when limit is set. The limitedWriter is
+// nil when there is no limit.
+func dumpOutput(outFile io.Writer, limit *int64) (io.Writer, *limitedWriter) {
+	if limit == nil {
+		return outFile, nil
+	}
+	lw := &limitedWriter{w: outFile, limit: *limit}
+	return lw, lw
+}
+
+// exceeded reports whether the limit was hit; safe on a nil writer.
+func (lw *limitedWriter) exceeded() bool {
+	return lw != nil && lw.hit
+}
+
+// err describes the exceeded limit.
+func (lw *limitedWriter) err() error {
+	return fmt.Errorf("%w: dump grew past %d bytes", errMaxSizeExceeded, lw.limit)
+}
@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// sourceSizeTimeout bounds the pg_database_size query run before a dump.
+const sourceSizeTimeout = 10 * time.Second
+
+// sourceDatabaseSize returns the on-disk size of the database being backed
+// up, as reported by pg_database_size. Compared with the dump size it shows
+// the compression ratio and how the database grows over time. psql is
+// started the way pg_dump is: same passfile, connect timeout, pg_env and
+// SSL handling, so it reaches the server wherever the dump can.
+func (s *Service) sourceDatabaseSize(psqlCmd string, dbConfig *models.DatabaseConfig) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sourceSizeTimeout)
+	defer cancel()
+
+	extraEnv, err := pgEnv(dbConfig)
+	if err != nil {
+		return 0, err
+	}
+	passfilePath, err := writePgPassFile(dbConfig)
+	if err != nil {
+		return 0, fmt.Errorf("prepare pgpass: %w", err)
+	}
+	defer os.Remove(passfilePath)
+	appName := pgAppName("size-check", dbConfig.Name)
+
+	args := []string{
+		"--host", dbConfig.Host,
+		"--port", fmt.Sprintf("%d", dbConfig.Port),
+		"--username", dbConfig.Username,
+		"--dbname", dbConfig.DBName,
+		"--no-password",
+		"--tuples-only",
+		"--no-align",
+		"--command", "SELECT pg_database_size(current_database());",
+	}
+	query := func(sslMode SSLMode) (string, string, error) {
+		cmd := exec.CommandContext(ctx, psqlCmd, args...)
+		cmd.Env = append(os.Environ(),
+			"PGPASSFILE="+passfilePath,
+			fmt.Sprintf("PGSSLMODE=%s", sslMode),
+			"PGAPPNAME="+appName,
+			fmt.Sprintf("PGCONNECT_TIMEOUT=%d", dbConfig.ConnectTimeout()),
+		)
+		cmd.Env = append(cmd.Env, extraEnv...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stdout.String(), stderr.String(), err
+	}
+
+	// Same order as the dump: a pinned sslmode is used as is, a host known
+	// to reject SSL goes straight to disable, anything else tries SSL first.
+	sslMode, pinned := pinnedSSLMode(dbConfig)
+	if !pinned {
+		sslMode = SSLModeRequire
+		if s.versionManager.SkipSSLAttempt(dbConfig.Host, dbConfig.Port) {
+			sslMode = SSLModeDisable
+		}
+	}
+	output, stderr, err := query(sslMode)
+	if err != nil && !pinned && sslMode == SSLModeRequire && strings.Contains(strings.ToLower(stderr), "ssl") {
+		log.Printf("Size check for %s failed with SSL, retrying without: %s", dbConfig.Name, strings.TrimSpace(stderr))
+		output, stderr, err = query(SSLModeDisable)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query database size: %v, stderr: %s", err, strings.TrimSpace(stderr))
+	}
+	return parseDatabaseSize(output)
+}
+
+// parseDatabaseSize reads the byte count psql printed for pg_database_size.
+func parseDatabaseSize(output string) (int64, error) {
+	size, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("unexpected database size %q", strings.TrimSpace(output))
+	}
+	return size, nil
+}
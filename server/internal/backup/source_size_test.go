@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+func TestParseDatabaseSize(t *testing.T) {
+	if got, err := parseDatabaseSize(" 7726127\n\n"); err != nil || got != 7726127 {
+		t.Errorf("parseDatabaseSize = %d, %v; want 7726127", got, err)
+	}
+	for _, in := range []string{"", "  \n", "-1", "7.3 MB", "1\n2"} {
+		if _, err := parseDatabaseSize(in); err == nil {
+			t.Errorf("parseDatabaseSize(%q) succeeded, want error", in)
+		}
+	}
+}
+
+// TestSourceDatabaseSizeEnv checks the size query gets the same connection
+// environment as pg_dump and falls back to sslmode=disable like it does.
+func TestSourceDatabaseSizeEnv(t *testing.T) {
+	t.Parallel()
+
+	// The fake psql logs its environment, refuses SSL and answers
+	// without it.
+	dir := t.TempDir()
+	envLog := filepath.Join(dir, "env.log")
+	psql := filepath.Join(dir, "psql")
+	script := "#!/bin/sh\nenv >> " + envLog + "\necho '--' >> " + envLog + "\n" +
+		"if [ \"$PGSSLMODE\" = require ]; then echo 'psql: error: server does not support SSL, but SSL was required' >&2; exit 2; fi\n" +
+		"echo 7726127\n"
+	if err := os.WriteFile(psql, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewService(nil)
+	cfg := &models.DatabaseConfig{
+		Name: "app", Host: "db.internal", Port: 5432, Username: "app", DBName: "app", Password: "secret",
+		ConnectTimeoutSeconds: 7,
+		PgEnv:                 map[string]string{"PGOPTIONS": "-c search_path=app"},
+	}
+	size, err := s.sourceDatabaseSize(psql, cfg)
+	if err != nil || size != 7726127 {
+		t.Fatalf("sourceDatabaseSize = %d, %v; want 7726127", size, err)
+	}
+
+	out, err := os.ReadFile(envLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := strings.Split(strings.TrimSuffix(string(out), "--\n"), "--\n")
+	if len(runs) != 2 {
+		t.Fatalf("psql ran %d times, want SSL then non-SSL", len(runs))
+	}
+	for _, want := range []string{"PGCONNECT_TIMEOUT=7", "PGOPTIONS=-c search_path=app", "PGPASSFILE="} {
+		if !strings.Contains(runs[0], want) {
+			t.Errorf("psql env is missing %q", want)
+		}
+	}
+	if !strings.Contains(runs[1], "PGSSLMODE=disable\n") {
+		t.Error("second attempt did not use PGSSLMODE=disable")
+	}
+}
@@ -26,6 +26,32 @@ type SSLConnector struct {
 	Username string
 	DBName   string
 	Password string
+	// AppName is sent as PGAPPNAME when set so the session is identifiable
+	// in pg_stat_activity.
+	AppName string
+}
+
+// appNamePrefix identifies DumpStation sessions in pg_stat_activity.
+const appNamePrefix = "dumpstation"
+
+// pgAppName builds the application_name for a session. Postgres truncates
+// the value to 63 bytes and replaces non-ASCII characters with '?', so the
+// config name is sanitised and clipped here to keep the result predictable.
+func pgAppName(purpose, configName string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return '_'
+		}
+		return r
+	}, configName)
+	appName := appNamePrefix + ":" + purpose
+	if name != "" {
+		appName += ":" + name
+	}
+	if len(appName) > 63 {
+		appName = appName[:63]
+	}
+	return appName
 }
 
 // NewSSLConnector creates a new SSL connector
@@ -83,6 +109,9 @@ func (sc *SSLConnector) executeCommand(ctx context.Context, cmdName string, args
 		"PGPASSFILE="+passfilePath,
 		fmt.Sprintf("PGSSLMODE=%s", sslMode),
 	)
+	if sc.AppName != "" {
+		cmd.Env = append(cmd.Env, "PGAPPNAME="+sc.AppName)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
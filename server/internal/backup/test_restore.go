@@ -0,0 +1,149 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/dbadmin"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// testRestoreDBPrefix names the throwaway databases test restores create,
+// so leftovers are easy to spot and clean up by hand.
+const testRestoreDBPrefix = "dumpstation_test_"
+
+// testRestoreAdminTimeout bounds each CREATE/DROP/ANALYZE round trip.
+const testRestoreAdminTimeout = 5 * time.Minute
+
+// StartTestRestore restores a backup into a freshly created database on
+// server (the source server when server.Host is empty), runs sanity
+// queries, and drops the database again. serverConnectionID names the saved
+// connection server came from, if any. The returned job is recorded before
+// the work starts in the background; its status and TestResult are filled
+// in when it finishes. server's password is only held in memory.
+func (s *Service) StartTestRestore(backupID uuid.UUID, server dbadmin.Options, serverConnectionID *uuid.UUID) (*models.RestoreJob, error) {
+	backup, dbConfig, err := s.loadRestoreSource(backupID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if server.Host == "" {
+		server = dbadmin.Options{
+			Host:     dbConfig.Host,
+			Port:     dbConfig.Port,
+			User:     dbConfig.Username,
+			Password: dbConfig.Password,
+		}
+	}
+	server.DBName = dbadmin.MaintenanceDB
+
+	req := &models.RestoreRequest{
+		TargetHost:     server.Host,
+		TargetPort:     server.Port,
+		TargetUser:     server.User,
+		TargetPassword: server.Password,
+		TargetDBName:   testRestoreDBPrefix + strings.ReplaceAll(uuid.NewString(), "-", "")[:12],
+		Test:           true,
+	}
+	job, err := s.repo.CreateRestoreJob(backupID, testRestoreJobRequest(req, serverConnectionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restore job: %w", err)
+	}
+
+	go s.runTestRestore(job, backup, dbConfig, req, server)
+	return job, nil
+}
+
+// testRestoreJobRequest is the copy of req stored on the restore job. It
+// drops the password, which runTestRestore gets from memory instead.
+func testRestoreJobRequest(req *models.RestoreRequest, serverConnectionID *uuid.UUID) *models.RestoreRequest {
+	persisted := *req
+	persisted.TargetPassword = ""
+	persisted.ServerConnectionID = serverConnectionID
+	return &persisted
+}
+
+func (s *Service) runTestRestore(job *models.RestoreJob, backup *models.Backup, dbConfig *models.DatabaseConfig, req *models.RestoreRequest, server dbadmin.Options) {
+	defer s.acquireRestoreSlot()()
+	start := time.Now()
+	result := &models.TestRestoreResult{DatabaseName: req.TargetDBName}
+
+	err := s.testRestoreInto(job, backup, dbConfig, req, server, result)
+	result.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		log.Printf("Test restore of backup %s failed: %v", backup.ID, err)
+	} else {
+		log.Printf("Test restore of backup %s passed: %d tables, ~%d rows", backup.ID, result.TableCount, result.TotalRows)
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	resultStr := string(resultJSON)
+	s.finishRestoreJob(job.ID, err, &resultStr)
+}
+
+// testRestoreInto creates the scratch database, restores into it, inspects
+// it and always drops it again.
+func (s *Service) testRestoreInto(job *models.RestoreJob, backup *models.Backup, dbConfig *models.DatabaseConfig, req *models.RestoreRequest, server dbadmin.Options, result *models.TestRestoreResult) error {
+	if err := s.checkHost(server.Host); err != nil {
+		return err
+	}
+	admin, err := dbadmin.New(server)
+	if err != nil {
+		return fmt.Errorf("connect to test restore server: %w", err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testRestoreAdminTimeout)
+	err = admin.CreateDatabase(ctx, req.TargetDBName, "")
+	cancel()
+	if err != nil {
+		return fmt.Errorf("create scratch database: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testRestoreAdminTimeout)
+		defer cancel()
+		if err := admin.DropDatabase(ctx, req.TargetDBName); err != nil {
+			log.Printf("⚠️  Failed to drop test restore database %s: %v", req.TargetDBName, err)
+			return
+		}
+		result.Dropped = true
+	}()
+
+	if err := s.runRestore(job, backup, dbConfig, req); err != nil {
+		return err
+	}
+
+	scratch, err := admin.WithDatabase(req.TargetDBName)
+	if err != nil {
+		return fmt.Errorf("connect to scratch database: %w", err)
+	}
+	defer scratch.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), testRestoreAdminTimeout)
+	defer cancel()
+	if err := scratch.Analyze(ctx); err != nil {
+		return fmt.Errorf("analyze scratch database: %w", err)
+	}
+	tables, err := scratch.ListTables(ctx)
+	if err != nil {
+		return fmt.Errorf("inspect scratch database: %w", err)
+	}
+
+	result.TableCount = len(tables)
+	for _, t := range tables {
+		rows := max(t.RowCount, 0)
+		result.TotalRows += rows
+		if result.LargestTable == "" || rows > result.LargestTableRows {
+			result.LargestTable = t.Schema + "." + t.Name
+			result.LargestTableRows = rows
+		}
+	}
+	if result.TableCount == 0 {
+		return fmt.Errorf("restore produced no tables")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestTestRestoreJobRequestDropsPassword checks that the restore job row of
+// a test restore never carries the decrypted server password.
+func TestTestRestoreJobRequestDropsPassword(t *testing.T) {
+	scID := uuid.New()
+	req := &models.RestoreRequest{TargetHost: "db.example.com", TargetUser: "postgres", TargetPassword: "secret", Test: true}
+
+	got := testRestoreJobRequest(req, &scID)
+
+	if got.TargetPassword != "" {
+		t.Errorf("persisted TargetPassword = %q, want empty", got.TargetPassword)
+	}
+	if got.ServerConnectionID == nil || *got.ServerConnectionID != scID {
+		t.Errorf("persisted ServerConnectionID = %v, want %s", got.ServerConnectionID, scID)
+	}
+	if req.TargetPassword != "secret" {
+		t.Error("testRestoreJobRequest cleared the in-memory password the restore needs")
+	}
+}
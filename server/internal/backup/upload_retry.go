@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// ErrNoRetainedUpload is returned by RetryUpload when the dump of a failed
+// upload is no longer on disk: retries are disabled, the window expired, the
+// server restarted, or another retry is already running.
+var ErrNoRetainedUpload = errors.New("no retained dump for this backup")
+
+// retainedUpload is a dump kept on disk after its upload failed.
+type retainedUpload struct {
+	artifact *dumpArtifact
+	expires  time.Time
+	timer    *time.Timer
+}
+
+// uploadRetries tracks retained dumps by backup ID. An entry is removed
+// while a retry owns it, so two retries of the same backup cannot race.
+type uploadRetries struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[uuid.UUID]*retainedUpload
+}
+
+// SetUploadRetryWindow sets how long a dump whose upload failed is kept for
+// RetryUpload. Zero disables retention.
+func (s *Service) SetUploadRetryWindow(d time.Duration) {
+	s.retries.mu.Lock()
+	defer s.retries.mu.Unlock()
+	s.retries.window = d
+}
+
+// HasRetainedUpload reports whether backupID's dump is available for retry.
+func (s *Service) HasRetainedUpload(backupID uuid.UUID) bool {
+	s.retries.mu.Lock()
+	defer s.retries.mu.Unlock()
+	_, ok := s.retries.entries[backupID]
+	return ok
+}
+
+// retainUpload moves the artifact out of the caller's temp file (which is
+// removed on return) and schedules its deletion at the end of the retry
+// window. It returns the expiry and false when retention is disabled or the
+// file could not be kept.
+func (s *Service) retainUpload(backupID uuid.UUID, artifact *dumpArtifact) (time.Time, bool) {
+	s.retries.mu.Lock()
+	window := s.retries.window
+	s.retries.mu.Unlock()
+	if window <= 0 {
+		return time.Time{}, false
+	}
+
+	f, err := os.CreateTemp("", "dumpstation-retry-*.bak")
+	if err != nil {
+		log.Printf("Failed to retain dump for upload retry: %v", err)
+		return time.Time{}, false
+	}
+	keptPath := f.Name()
+	f.Close()
+	if err := os.Rename(artifact.path, keptPath); err != nil {
+		os.Remove(keptPath)
+		log.Printf("Failed to retain dump for upload retry: %v", err)
+		return time.Time{}, false
+	}
+
+	kept := *artifact
+	kept.path = keptPath
+	expires := time.Now().Add(window)
+	s.keepRetained(backupID, &retainedUpload{artifact: &kept, expires: expires})
+	return expires, true
+}
+
+// keepRetained (re)registers entry until its expiry.
+func (s *Service) keepRetained(backupID uuid.UUID, entry *retainedUpload) {
+	remaining := time.Until(entry.expires)
+	if remaining <= 0 {
+		os.Remove(entry.artifact.path)
+		return
+	}
+
+	s.retries.mu.Lock()
+	defer s.retries.mu.Unlock()
+	entry.timer = time.AfterFunc(remaining, func() {
+		s.retries.mu.Lock()
+		current, ok := s.retries.entries[backupID]
+		if ok && current == entry {
+			delete(s.retries.entries, backupID)
+		}
+		s.retries.mu.Unlock()
+		if ok && current == entry {
+			os.Remove(entry.artifact.path)
+			log.Printf("Upload retry window expired for backup %s; dump removed", backupID)
+		}
+	})
+	s.retries.entries[backupID] = entry
+}
+
+// claimRetained removes and returns backupID's entry, or nil.
+func (s *Service) claimRetained(backupID uuid.UUID) *retainedUpload {
+	s.retries.mu.Lock()
+	defer s.retries.mu.Unlock()
+	entry, ok := s.retries.entries[backupID]
+	if !ok {
+		return nil
+	}
+	delete(s.retries.entries, backupID)
+	entry.timer.Stop()
+	return entry
+}
+
+// RetryUpload re-sends the retained dump of a backup whose upload failed,
+// without running pg_dump again. On success the backup completes exactly
+// as a normal run would; on failure the dump stays available until the
+// original window ends.
+func (s *Service) RetryUpload(backupID uuid.UUID) error {
+	entry := s.claimRetained(backupID)
+	if entry == nil {
+		return ErrNoRetainedUpload
+	}
+	artifact := entry.artifact
+
+	backup, err := s.repo.GetBackup(backupID)
+	if err != nil {
+		s.keepRetained(backupID, entry)
+		return fmt.Errorf("failed to get backup: %w", err)
+	}
+	var dbConfig *models.DatabaseConfig
+	if backup != nil {
+		if dbConfig, err = s.repo.GetDatabaseConfig(backup.DatabaseID); err != nil {
+			s.keepRetained(backupID, entry)
+			return fmt.Errorf("failed to get database config: %w", err)
+		}
+	}
+	if dbConfig == nil {
+		// The backup or its database is gone; nothing left to retry for.
+		os.Remove(artifact.path)
+		return ErrNoRetainedUpload
+	}
+
+	if err := s.repo.UpdateBackupStatus(backupID, models.BackupStatusRunning, nil, "", nil); err != nil {
+		log.Printf("Failed to update backup status to running: %v", err)
+	}
+	s.recordEvent(backupID, models.BackupEventStarted, "upload retry")
+	startTime := time.Now()
+
+	fail := func(errorMsg string) error {
+		s.keepRetained(backupID, entry)
+		if time.Now().Before(entry.expires) {
+			errorMsg += fmt.Sprintf("; dump kept for retry until %s", entry.expires.UTC().Format(time.RFC3339))
+		}
+		return s.handleBackupError(backupID, dbConfig, errorMsg)
+	}
+
+	storageConfig, err := s.repo.GetStorageConfig(artifact.storageID)
+	if err != nil {
+		return fail(fmt.Sprintf("failed to get storage config: %v", err))
+	}
+	if storageConfig == nil {
+		return fail("storage config no longer exists")
+	}
+	storageClient, err := storage.NewStorageClient(storageConfig)
+	if err != nil {
+		return fail(fmt.Sprintf("failed to create storage client: %v", err))
+	}
+	if err := s.uploadArtifact(s.runContext(), backupID, storageClient, storageConfig, artifact); err != nil {
+		return fail(fmt.Sprintf("failed to upload to %s (%s): %v", storageConfig.Name, storageConfig.Provider, err))
+	}
+
+	os.Remove(artifact.path)
+	s.completeBackup(backupID, dbConfig, storageClient, s.notifierFor(dbConfig), artifact, startTime)
+	return nil
+}
@@ -7,8 +7,11 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,29 +28,78 @@ type cachedVersion struct {
 	cachedAt  time.Time
 }
 
+// sslReprobeInterval is how long a host known to reject SSL is dumped
+// without trying SSL first. After that one run tries SSL again in case the
+// server gained support.
+const sslReprobeInterval = 24 * time.Hour
+
+// cachedSSLMode is the mode that last worked for a host and when SSL was
+// last actually attempted against it.
+type cachedSSLMode struct {
+	mode      SSLMode
+	checkedAt time.Time
+}
+
 // VersionManager handles PostgreSQL version detection and management.
 // All cache access is mediated by mu so concurrent scheduled backups are safe.
 type VersionManager struct {
 	mu           sync.RWMutex
 	versionCache map[string]cachedVersion
-	sslModeCache map[string]SSLMode
+	sslModeCache map[string]cachedSSLMode
+	binDirs      map[string]string // major version -> client bin directory
+	// onSSLMode, when set, is called after every SSL probe so the result
+	// can be persisted. It runs outside mu.
+	onSSLMode func(key string, mode SSLMode, checkedAt time.Time)
 }
 
 // NewVersionManager creates a new version manager
 func NewVersionManager() *VersionManager {
 	return &VersionManager{
 		versionCache: make(map[string]cachedVersion),
-		sslModeCache: make(map[string]SSLMode),
+		sslModeCache: make(map[string]cachedSSLMode),
 	}
 }
 
-// SetSSLMode records the SSL mode that worked for a host:port pair.
-// External callers must use this rather than touching the map directly.
+// SetSSLMode records the SSL mode that worked for a host:port pair after
+// SSL was attempted. External callers must use this rather than touching
+// the map directly.
 func (vm *VersionManager) SetSSLMode(host string, port int, mode SSLMode) {
-	cacheKey := fmt.Sprintf("%s:%d", host, port)
+	vm.storeSSLMode(fmt.Sprintf("%s:%d", host, port), mode, time.Now())
+}
+
+func (vm *VersionManager) storeSSLMode(cacheKey string, mode SSLMode, checkedAt time.Time) {
 	vm.mu.Lock()
-	vm.sslModeCache[cacheKey] = mode
+	vm.sslModeCache[cacheKey] = cachedSSLMode{mode: mode, checkedAt: checkedAt}
+	hook := vm.onSSLMode
 	vm.mu.Unlock()
+	if hook != nil {
+		hook(cacheKey, mode, checkedAt)
+	}
+}
+
+// LoadSSLMode seeds the cache from a persisted entry keyed "host:port"
+// without triggering the persistence hook.
+func (vm *VersionManager) LoadSSLMode(cacheKey string, mode SSLMode, checkedAt time.Time) {
+	vm.mu.Lock()
+	vm.sslModeCache[cacheKey] = cachedSSLMode{mode: mode, checkedAt: checkedAt}
+	vm.mu.Unlock()
+}
+
+// OnSSLModeChange registers fn to persist SSL probe results.
+func (vm *VersionManager) OnSSLModeChange(fn func(key string, mode SSLMode, checkedAt time.Time)) {
+	vm.mu.Lock()
+	vm.onSSLMode = fn
+	vm.mu.Unlock()
+}
+
+// SkipSSLAttempt reports whether host:port is known to reject SSL and was
+// probed recently enough that trying SSL again would only waste a
+// connection.
+func (vm *VersionManager) SkipSSLAttempt(host string, port int) bool {
+	vm.mu.RLock()
+	cached, ok := vm.sslModeCache[fmt.Sprintf("%s:%d", host, port)]
+	vm.mu.RUnlock()
+	return ok && cached.mode == SSLModeDisable && time.Since(cached.checkedAt) < sslReprobeInterval
 }
 
 // DetectPostgresVersion detects the PostgreSQL version of a database with SSL fallback
@@ -73,6 +125,7 @@ func (vm *VersionManager) DetectPostgresVersion(dbConfig *models.DatabaseConfig)
 		dbConfig.DBName,
 		dbConfig.Password,
 	)
+	connector.AppName = pgAppName("version-check", dbConfig.Name)
 
 	// Query PostgreSQL version with SSL fallback
 	args := []string{
@@ -93,11 +146,11 @@ func (vm *VersionManager) DetectPostgresVersion(dbConfig *models.DatabaseConfig)
 	versionOutput := output
 	majorVersion := vm.ParseMajorVersion(versionOutput)
 
-	// Cache the SSL mode that worked and the detected version under one lock acquisition
+	// Cache the detected version, then the SSL mode that worked
 	vm.mu.Lock()
-	vm.sslModeCache[cacheKey] = sslMode
 	vm.versionCache[cacheKey] = cachedVersion{value: majorVersion, cachedAt: time.Now()}
 	vm.mu.Unlock()
+	vm.storeSSLMode(cacheKey, sslMode, time.Now())
 
 	log.Printf("Detected PostgreSQL version for %s: %s (SSL mode: %s)", dbConfig.Name, majorVersion, sslMode)
 	return majorVersion, nil
@@ -127,108 +180,142 @@ func (vm *VersionManager) ParseMajorVersion(versionOutput string) string {
 
 // GetPgDumpVersion returns the pg_dump command with version-specific path if available
 func (vm *VersionManager) GetPgDumpVersion(postgresVersion string) string {
-	// Try to find version-specific pg_dump
-	// Common installation paths for PostgreSQL versions:
-	// /usr/lib/postgresql/14/bin/pg_dump
-	// /usr/local/pgsql/14/bin/pg_dump
-	// /opt/postgresql/14/bin/pg_dump
-	// etc.
-
-	// For "latest" or unknown, use the default pg_dump in PATH
-	if postgresVersion == "latest" || postgresVersion == "" {
-		return "pg_dump"
-	}
+	return vm.findBinary("pg_dump", postgresVersion)
+}
 
-	// Common paths to check (including Homebrew paths for macOS)
-	commonPaths := []string{
-		// Homebrew macOS paths
-		fmt.Sprintf("/opt/homebrew/opt/postgresql@%s/bin/pg_dump", postgresVersion),
-		fmt.Sprintf("/usr/local/opt/postgresql@%s/bin/pg_dump", postgresVersion),
-		// Linux paths
-		fmt.Sprintf("/usr/lib/postgresql/%s/bin/pg_dump", postgresVersion),
-		fmt.Sprintf("/usr/local/pgsql/%s/bin/pg_dump", postgresVersion),
-		fmt.Sprintf("/opt/postgresql/%s/bin/pg_dump", postgresVersion),
-		// macOS direct installation
-		fmt.Sprintf("/Library/PostgreSQL/%s/bin/pg_dump", postgresVersion),
-		// Windows
-		fmt.Sprintf("C:\\Program Files\\PostgreSQL\\%s\\bin\\pg_dump.exe", postgresVersion),
-	}
+// GetPgRestoreVersion returns the pg_restore command with version-specific path if available
+func (vm *VersionManager) GetPgRestoreVersion(postgresVersion string) string {
+	return vm.findBinary("pg_restore", postgresVersion)
+}
 
-	// Try each path
-	for _, path := range commonPaths {
-		if _, err := os.Stat(path); err == nil {
-			log.Printf("Found pg_dump at: %s", path)
-			return path
-		}
+// GetPsqlVersion returns the psql command with version-specific path if available
+func (vm *VersionManager) GetPsqlVersion(postgresVersion string) string {
+	return vm.findBinary("psql", postgresVersion)
+}
+
+// SetBinDirs installs an operator-supplied map of PostgreSQL major version
+// to the directory holding that version's client binaries (pg_dump,
+// pg_restore, psql). Configured directories win over the built-in list of
+// common install locations.
+func (vm *VersionManager) SetBinDirs(dirs map[string]string) {
+	copied := make(map[string]string, len(dirs))
+	for major, dir := range dirs {
+		copied[major] = dir
 	}
+	vm.mu.Lock()
+	vm.binDirs = copied
+	vm.mu.Unlock()
+}
 
-	// Fallback to PATH default
-	log.Printf("Could not find version-specific pg_dump for version %s, using default", postgresVersion)
-	return "pg_dump"
+// configuredBinDir returns the operator-configured bin directory for a
+// major version, if any.
+func (vm *VersionManager) configuredBinDir(major string) (string, bool) {
+	vm.mu.RLock()
+	defer vm.mu.RUnlock()
+	dir, ok := vm.binDirs[major]
+	return dir, ok
 }
 
-// GetPgRestoreVersion returns the pg_restore command with version-specific path if available
-func (vm *VersionManager) GetPgRestoreVersion(postgresVersion string) string {
+// findBinary resolves a PostgreSQL client tool for postgresVersion. Lookup
+// order: configured bin directory, common install locations, then the bare
+// tool name so exec falls back to PATH.
+func (vm *VersionManager) findBinary(tool, postgresVersion string) string {
+	// For "latest" or unknown, use the default binary in PATH
 	if postgresVersion == "latest" || postgresVersion == "" {
-		return "pg_restore"
+		return tool
+	}
+
+	if dir, ok := vm.configuredBinDir(postgresVersion); ok {
+		path := filepath.Join(dir, tool)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		log.Printf("Warning: configured %s for PostgreSQL %s not found at %s", tool, postgresVersion, path)
 	}
 
 	// Common paths to check (including Homebrew paths for macOS)
 	commonPaths := []string{
 		// Homebrew macOS paths
-		fmt.Sprintf("/opt/homebrew/opt/postgresql@%s/bin/pg_restore", postgresVersion),
-		fmt.Sprintf("/usr/local/opt/postgresql@%s/bin/pg_restore", postgresVersion),
+		fmt.Sprintf("/opt/homebrew/opt/postgresql@%s/bin/%s", postgresVersion, tool),
+		fmt.Sprintf("/usr/local/opt/postgresql@%s/bin/%s", postgresVersion, tool),
 		// Linux paths
-		fmt.Sprintf("/usr/lib/postgresql/%s/bin/pg_restore", postgresVersion),
-		fmt.Sprintf("/usr/local/pgsql/%s/bin/pg_restore", postgresVersion),
-		fmt.Sprintf("/opt/postgresql/%s/bin/pg_restore", postgresVersion),
+		fmt.Sprintf("/usr/lib/postgresql/%s/bin/%s", postgresVersion, tool),
+		fmt.Sprintf("/usr/local/pgsql/%s/bin/%s", postgresVersion, tool),
+		fmt.Sprintf("/opt/postgresql/%s/bin/%s", postgresVersion, tool),
 		// macOS direct installation
-		fmt.Sprintf("/Library/PostgreSQL/%s/bin/pg_restore", postgresVersion),
+		fmt.Sprintf("/Library/PostgreSQL/%s/bin/%s", postgresVersion, tool),
 		// Windows
-		fmt.Sprintf("C:\\Program Files\\PostgreSQL\\%s\\bin\\pg_restore.exe", postgresVersion),
+		fmt.Sprintf("C:\\Program Files\\PostgreSQL\\%s\\bin\\%s.exe", postgresVersion, tool),
 	}
 
 	for _, path := range commonPaths {
 		if _, err := os.Stat(path); err == nil {
-			log.Printf("Found pg_restore at: %s", path)
+			log.Printf("Found %s at: %s", tool, path)
 			return path
 		}
 	}
 
-	log.Printf("Could not find version-specific pg_restore for version %s, using default", postgresVersion)
-	return "pg_restore"
+	log.Printf("Could not find version-specific %s for version %s, using default", tool, postgresVersion)
+	return tool
 }
 
-// GetPsqlVersion returns the psql command with version-specific path if available
-func (vm *VersionManager) GetPsqlVersion(postgresVersion string) string {
-	if postgresVersion == "latest" || postgresVersion == "" {
-		return "psql"
-	}
+// KnownPostgresMajors lists the PostgreSQL major versions DumpStation
+// expects to back up. Diagnostics probe each of them so a missing client
+// shows up before a backup against that version fails.
+var KnownPostgresMajors = []string{"12", "13", "14", "15", "16", "17"}
+
+// BinaryCheck is the outcome of probing one PostgreSQL client binary with
+// `--version`.
+type BinaryCheck struct {
+	Major        string `json:"major"`
+	Tool         string `json:"tool"`
+	Path         string `json:"path"`
+	Version      string `json:"version,omitempty"`
+	PathFallback bool   `json:"path_fallback"` // No version-specific binary; resolved via PATH
+	Error        string `json:"error,omitempty"`
+}
 
-	// Common paths to check (including Homebrew paths for macOS)
-	commonPaths := []string{
-		// Homebrew macOS paths
-		fmt.Sprintf("/opt/homebrew/opt/postgresql@%s/bin/psql", postgresVersion),
-		fmt.Sprintf("/usr/local/opt/postgresql@%s/bin/psql", postgresVersion),
-		// Linux paths
-		fmt.Sprintf("/usr/lib/postgresql/%s/bin/psql", postgresVersion),
-		fmt.Sprintf("/usr/local/pgsql/%s/bin/psql", postgresVersion),
-		fmt.Sprintf("/opt/postgresql/%s/bin/psql", postgresVersion),
-		// macOS direct installation
-		fmt.Sprintf("/Library/PostgreSQL/%s/bin/psql", postgresVersion),
-		// Windows
-		fmt.Sprintf("C:\\Program Files\\PostgreSQL\\%s\\bin\\psql.exe", postgresVersion),
+// CheckBinaries probes pg_dump and psql for the default PATH install, every
+// configured major version, and any extra majors requested. It never
+// fails; unusable binaries are reported through BinaryCheck.Error.
+func (vm *VersionManager) CheckBinaries(extraMajors ...string) []BinaryCheck {
+	seen := map[string]bool{}
+	vm.mu.RLock()
+	for major := range vm.binDirs {
+		seen[major] = true
 	}
-
-	for _, path := range commonPaths {
-		if _, err := os.Stat(path); err == nil {
-			log.Printf("Found psql at: %s", path)
-			return path
+	vm.mu.RUnlock()
+	for _, major := range extraMajors {
+		seen[major] = true
+	}
+	delete(seen, "latest")
+	majors := make([]string, 0, len(seen))
+	for major := range seen {
+		majors = append(majors, major)
+	}
+	sort.Strings(majors)
+	majors = append([]string{"latest"}, majors...)
+
+	var checks []BinaryCheck
+	for _, major := range majors {
+		for _, tool := range []string{"pg_dump", "psql"} {
+			path := vm.findBinary(tool, major)
+			check := BinaryCheck{
+				Major:        major,
+				Tool:         tool,
+				Path:         path,
+				PathFallback: major != "latest" && path == tool,
+			}
+			out, err := vm.GetPgDumpVersionInfo(path)
+			if err != nil {
+				check.Error = err.Error()
+			} else {
+				check.Version = strings.TrimSpace(out)
+			}
+			checks = append(checks, check)
 		}
 	}
-
-	log.Printf("Could not find version-specific psql for version %s, using default", postgresVersion)
-	return "psql"
+	return checks
 }
 
 // IsCompatibleVersion checks if the pg_dump version is compatible with the database
@@ -247,6 +334,24 @@ func (vm *VersionManager) IsCompatibleVersion(pgDumpVersion string, dbVersion st
 	return dumpMajorInt >= dbMajorInt
 }
 
+// CheckRestoreCompatibility compares the PostgreSQL major a backup was taken
+// from with the restore target. Restoring into an older major is refused
+// because pg_dump output uses syntax and catalog features the older server
+// does not understand. Restoring into a newer major is supported and
+// returns an informational note. Unknown ("latest", "", "0") versions on
+// either side skip the check.
+func (vm *VersionManager) CheckRestoreCompatibility(sourceVersion, targetVersion string) (string, error) {
+	source, _ := strconv.Atoi(vm.ExtractMajorVersion(sourceVersion))
+	target, _ := strconv.Atoi(vm.ExtractMajorVersion(targetVersion))
+	if source == 0 || target == 0 || source == target {
+		return "", nil
+	}
+	if target < source {
+		return "", fmt.Errorf("backup was taken from PostgreSQL %d but the target server runs PostgreSQL %d; restoring into an older major version is not supported, restore into PostgreSQL %d or newer", source, target, source)
+	}
+	return fmt.Sprintf("restoring a PostgreSQL %d backup into PostgreSQL %d", source, target), nil
+}
+
 // ExtractMajorVersion extracts just the major version number
 func (vm *VersionManager) ExtractMajorVersion(version string) string {
 	re := regexp.MustCompile(`(\d+)`)
@@ -316,10 +421,10 @@ func (vm *VersionManager) GetDumpCompressionLevel(postgresVersion string) string
 func (vm *VersionManager) GetSSLModeForDatabase(host string, port int) SSLMode {
 	cacheKey := fmt.Sprintf("%s:%d", host, port)
 	vm.mu.RLock()
-	sslMode, exists := vm.sslModeCache[cacheKey]
+	cached, exists := vm.sslModeCache[cacheKey]
 	vm.mu.RUnlock()
 	if exists {
-		return sslMode
+		return cached.mode
 	}
 	// Default to require, but actual execution will fallback if needed
 	return SSLModeRequire
@@ -1,6 +1,8 @@
 package backup
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -100,3 +102,97 @@ func TestVersionManager_ParseMajorVersion(t *testing.T) {
 		})
 	}
 }
+
+// TestVersionManager_ConfiguredBinDir verifies an operator-supplied bin
+// directory wins over the built-in search and that a missing binary in it
+// still falls back to PATH.
+func TestVersionManager_ConfiguredBinDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pgDump := filepath.Join(dir, "pg_dump")
+	if err := os.WriteFile(pgDump, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	vm := NewVersionManager()
+	vm.SetBinDirs(map[string]string{"99": dir})
+
+	if got := vm.GetPgDumpVersion("99"); got != pgDump {
+		t.Errorf("GetPgDumpVersion(99) = %q, want %q", got, pgDump)
+	}
+	if got := vm.GetPsqlVersion("99"); got != "psql" {
+		t.Errorf("GetPsqlVersion(99) = %q, want PATH fallback %q", got, "psql")
+	}
+	if got := vm.GetPgDumpVersion("latest"); got != "pg_dump" {
+		t.Errorf("GetPgDumpVersion(latest) = %q, want %q", got, "pg_dump")
+	}
+}
+
+func TestVersionManager_CheckRestoreCompatibility(t *testing.T) {
+	t.Parallel()
+
+	vm := NewVersionManager()
+	tests := []struct {
+		name     string
+		source   string
+		target   string
+		wantErr  bool
+		wantNote bool
+	}{
+		{"same major", "15", "15", false, false},
+		{"newer target", "13", "16", false, true},
+		{"older target", "15", "13", true, false},
+		{"unknown source", "", "13", false, false},
+		{"latest source", "latest", "13", false, false},
+		{"unknown target", "15", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			note, err := vm.CheckRestoreCompatibility(tt.source, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (note != "") != tt.wantNote {
+				t.Errorf("note = %q, wantNote %v", note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestVersionManager_SkipSSLAttempt(t *testing.T) {
+	t.Parallel()
+
+	vm := NewVersionManager()
+	var persisted []SSLMode
+	vm.OnSSLModeChange(func(key string, mode SSLMode, _ time.Time) {
+		if key != "nossl:5432" {
+			t.Errorf("hook key = %q", key)
+		}
+		persisted = append(persisted, mode)
+	})
+
+	if vm.SkipSSLAttempt("nossl", 5432) {
+		t.Fatal("unknown host should try SSL")
+	}
+	vm.SetSSLMode("nossl", 5432, SSLModeDisable)
+	if !vm.SkipSSLAttempt("nossl", 5432) {
+		t.Fatal("host that just rejected SSL should skip the SSL attempt")
+	}
+	if len(persisted) != 1 || persisted[0] != SSLModeDisable {
+		t.Fatalf("persisted = %v, want [disable]", persisted)
+	}
+
+	// A stale entry (e.g. loaded after a restart) is re-probed.
+	vm.LoadSSLMode("stale:5432", SSLModeDisable, time.Now().Add(-sslReprobeInterval-time.Minute))
+	if vm.SkipSSLAttempt("stale", 5432) {
+		t.Fatal("entry older than the re-probe interval should try SSL again")
+	}
+
+	vm.SetSSLMode("nossl", 5432, SSLModeRequire)
+	if vm.SkipSSLAttempt("nossl", 5432) {
+		t.Fatal("host that accepted SSL should not skip it")
+	}
+}
@@ -1,9 +1,9 @@
 package cleanup
 
 import (
-	"log"
 	"time"
 
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/repository"
 )
 
@@ -28,8 +28,8 @@ func NewService(repo *repository.Repository, retention time.Duration) *Service {
 // Start begins the background cleanup process
 // It runs daily at 2 AM to clean up old activity logs
 func (s *Service) Start() error {
-	log.Println("[CLEANUP] Starting activity log cleanup service...")
-	log.Printf("[CLEANUP] Retention period: %.0f days", s.retention.Hours()/24)
+	logging.Infof("[CLEANUP] Starting activity log cleanup service...")
+	logging.Debugf("[CLEANUP] Retention period: %.0f days", s.retention.Hours()/24)
 
 	// Run initial cleanup on startup
 	go s.runCleanup()
@@ -42,7 +42,7 @@ func (s *Service) Start() error {
 	}
 	durationUntilNextRun := nextRun.Sub(now)
 
-	log.Printf("[CLEANUP] Next cleanup scheduled at: %v", nextRun.Format(time.RFC3339))
+	logging.Debugf("[CLEANUP] Next cleanup scheduled at: %v", nextRun.Format(time.RFC3339))
 
 	// Start a goroutine to run cleanup daily
 	go func() {
@@ -59,13 +59,13 @@ func (s *Service) Start() error {
 			case <-s.ticker.C:
 				s.runCleanup()
 			case <-s.stopChan:
-				log.Println("[CLEANUP] Stopping activity log cleanup service")
+				logging.Infof("[CLEANUP] Stopping activity log cleanup service")
 				return
 			}
 		}
 	}()
 
-	log.Println("[CLEANUP] ✅ Activity log cleanup service started")
+	logging.Infof("[CLEANUP] ✅ Activity log cleanup service started")
 	return nil
 }
 
@@ -78,26 +78,26 @@ func (s *Service) Stop() {
 
 // runCleanup performs the actual cleanup of old logs
 func (s *Service) runCleanup() {
-	log.Println("[CLEANUP] Running activity log cleanup...")
+	logging.Debugf("[CLEANUP] Running activity log cleanup...")
 
 	cutoffTime := time.Now().Add(-s.retention)
-	log.Printf("[CLEANUP] Deleting logs older than: %v", cutoffTime.Format(time.RFC3339))
+	logging.Debugf("[CLEANUP] Deleting logs older than: %v", cutoffTime.Format(time.RFC3339))
 
 	deleted, err := s.repo.DeleteOldActivityLogs(cutoffTime)
 	if err != nil {
-		log.Printf("[CLEANUP] ❌ Failed to delete old activity logs: %v", err)
+		logging.Errorf("[CLEANUP] ❌ Failed to delete old activity logs: %v", err)
 		return
 	}
 
 	if deleted > 0 {
-		log.Printf("[CLEANUP] ✅ Successfully deleted %d old activity log(s)", deleted)
+		logging.Infof("[CLEANUP] ✅ Successfully deleted %d old activity log(s)", deleted)
 	} else {
-		log.Println("[CLEANUP] No old activity logs to delete")
+		logging.Debugf("[CLEANUP] No old activity logs to delete")
 	}
 }
 
 // ForceCleanup allows manual triggering of cleanup (useful for testing or maintenance)
 func (s *Service) ForceCleanup() {
-	log.Println("[CLEANUP] Manual cleanup triggered")
+	logging.Infof("[CLEANUP] Manual cleanup triggered")
 	s.runCleanup()
 }
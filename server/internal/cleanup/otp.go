@@ -0,0 +1,76 @@
+package cleanup
+
+import (
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/repository"
+)
+
+// otpSweepInterval is how often expired OTP tokens are purged. A token row
+// is written on every login and download request, so this runs far more
+// often than the daily activity log cleanup.
+const otpSweepInterval = time.Hour
+
+// OTPService periodically deletes expired and used OTP tokens
+type OTPService struct {
+	repo      *repository.Repository
+	ticker    *time.Ticker
+	stopChan  chan bool
+	retention time.Duration
+}
+
+// NewOTPService creates a new OTP cleanup service
+// retention is how long after expiry (or use) a token is kept, e.g. 1 hour
+func NewOTPService(repo *repository.Repository, retention time.Duration) *OTPService {
+	return &OTPService{
+		repo:      repo,
+		retention: retention,
+		stopChan:  make(chan bool),
+	}
+}
+
+// Start runs a sweep immediately and then every otpSweepInterval
+func (s *OTPService) Start() error {
+	logging.Infof("[CLEANUP] Starting OTP token cleanup service (retention: %v)", s.retention)
+
+	go s.runCleanup()
+
+	s.ticker = time.NewTicker(otpSweepInterval)
+	go func() {
+		defer s.ticker.Stop()
+		for {
+			select {
+			case <-s.ticker.C:
+				s.runCleanup()
+			case <-s.stopChan:
+				logging.Infof("[CLEANUP] Stopping OTP token cleanup service")
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the OTP cleanup service
+func (s *OTPService) Stop() {
+	if s.ticker != nil {
+		s.stopChan <- true
+	}
+}
+
+// runCleanup deletes tokens that expired or were used before the cutoff
+func (s *OTPService) runCleanup() {
+	cutoffTime := time.Now().Add(-s.retention)
+
+	deleted, err := s.repo.DeleteExpiredOTPTokens(cutoffTime)
+	if err != nil {
+		logging.Errorf("[CLEANUP] ❌ Failed to delete expired OTP tokens: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		logging.Infof("[CLEANUP] ✅ Deleted %d expired OTP token(s)", deleted)
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 )
 
 // Config holds all application configuration
@@ -13,17 +17,33 @@ type Config struct {
 	Database  DatabaseConfig
 	JWT       JWTConfig
 	Discord   DiscordConfig
+	SMTP      SMTPConfig
 	GitHub    GitHubConfig
 	CORS      CORSConfig
 	Turnstile TurnstileConfig
 	Secret    SecretConfig
-	WebOrigin string // Frontend origin used for OAuth redirect (e.g. http://localhost:3000)
+	Postgres  PostgresToolsConfig
+	TwoFactor TwoFactorConfig
+	Scheduler SchedulerConfig
+	Storage   ObjectStorageConfig
+	Restore   RestoreConfig
+	Hosts     HostPolicyConfig
+	// Credentials tunes the advisory checks on stored credentials.
+	Credentials CredentialPolicyConfig
+	WebOrigin   string // Frontend origin used for OAuth redirect (e.g. http://localhost:3000)
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
 	Port string
 	Host string
+	// MaxBodyBytes caps JSON request bodies; larger ones are rejected with
+	// 413. StrictJSON rejects bodies with fields the endpoint doesn't know.
+	MaxBodyBytes int
+	StrictJSON   bool
+	// LogLevel drops log lines below it: debug adds the per-step auth and
+	// request logs, warn/error keep production logs to problems only.
+	LogLevel logging.Level
 }
 
 // DatabaseConfig holds database connection configuration
@@ -34,18 +54,48 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// Connection pool for the metadata database. A zero lifetime keeps
+	// connections open indefinitely.
+	MaxOpenConns           int
+	MaxIdleConns           int
+	ConnMaxLifetimeMinutes int
 }
 
-// JWTConfig holds JWT configuration
+// JWTConfig holds JWT configuration. HS256 with Secret is the default;
+// RS*/ES* algorithms sign with PrivateKeyFile under KeyID and also accept
+// tokens signed by RetiredKeyFiles, so keys can rotate without logging
+// everyone out.
 type JWTConfig struct {
-	Secret     string
-	Expiration int // in minutes
+	Secret          string
+	Expiration      int               // in minutes
+	Algorithm       string            // HS256 (default), RS256/384/512, ES256/384/512
+	KeyID           string            // kid of the active signing key
+	PrivateKeyFile  string            // PEM private key for RS*/ES*
+	RetiredKeyFiles map[string]string // kid -> PEM public key still accepted for verification
 }
 
 // DiscordConfig holds Discord configuration
 type DiscordConfig struct {
 	WebhookURL    string // Single webhook for both OTP and notifications
 	OTPExpiration int    // OTP expiration in minutes
+	OTPRetention  int    // Minutes expired/used OTP rows are kept before the sweep deletes them
+	MaxAttempts   int    // POST attempts per message before giving up
+	MaxRetryAfter int    // Longest 429 Retry-After (seconds) worth waiting for
+	QueueInterval int    // Minimum milliseconds between queued sends to one webhook
+	QueueSize     int    // Queued messages per webhook before new ones are dropped
+}
+
+// SMTPConfig holds the optional email channel used as a fallback for login
+// OTP delivery when Discord is unreachable. Enabled flips true only when
+// Host and To are both set.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string   // Defaults to Username when empty
+	To       []string // Comma-separated recipients for OTP mail
+	Enabled  bool     // Derived from non-empty Host and To
 }
 
 // GitHubConfig holds GitHub OAuth configuration for the single-user login.
@@ -56,6 +106,7 @@ type GitHubConfig struct {
 	ClientSecret string
 	AllowedLogin string // GitHub login (username) allowed to authenticate
 	RedirectURL  string // Backend callback URL, e.g. https://api.example.com/api/v1/auth/github/callback
+	StateSecret  string // HMAC key for the OAuth state cookie; a random per-process key is used when empty
 	Enabled      bool   // Derived from non-empty ClientID, ClientSecret, AllowedLogin
 }
 
@@ -91,12 +142,116 @@ type SecretConfig struct {
 	Key string
 }
 
+// PostgresToolsConfig controls which PostgreSQL client binaries are used
+// for backups and restores.
+type PostgresToolsConfig struct {
+	// BinDirs maps a major version ("15") to the directory holding that
+	// version's pg_dump, pg_restore and psql. Versions not listed fall back
+	// to the common install locations and then PATH.
+	BinDirs map[string]string
+}
+
+// TwoFactorConfig holds TOTP 2FA settings
+type TwoFactorConfig struct {
+	// BackupCodes is how many recovery codes are issued on enrolment and
+	// regeneration. Must be between MinBackupCodes and MaxBackupCodes.
+	BackupCodes int
+	// WebAuthnRPID enables passkeys as a second factor. It is the host
+	// name users reach DumpStation's web UI on; passkeys are bound to it.
+	// WebAuthnOrigins lists the exact origins allowed to use them and
+	// defaults to https:// plus the RP ID.
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+}
+
+// SchedulerConfig holds backup scheduling settings
+type SchedulerConfig struct {
+	// CronSeconds accepts an optional leading seconds field in schedules,
+	// e.g. "*/30 * * * * *" for every 30 seconds. Off by default so a
+	// six-field expression can't be mistaken for a minute-based one.
+	CronSeconds bool
+	// MaxConcurrent caps scheduled backups running at once; further
+	// triggers queue. 0 (default) is unlimited.
+	MaxConcurrent int
+	// FreshnessCheckMinutes is how often overdue backups are looked for;
+	// 0 disables the check. FreshnessGraceMinutes is how long past a
+	// missed trigger a database counts as stale.
+	FreshnessCheckMinutes int
+	FreshnessGraceMinutes int
+	// JitterMinutes delays each scheduled start by a random 0–N minutes
+	// unless the database sets its own jitter_minutes. 0 (default) is off.
+	JitterMinutes int
+}
+
+// ObjectStorageConfig holds settings applied to every backup bucket
+type ObjectStorageConfig struct {
+	// ObjectACL is the canned ACL set on S3 uploads: none (default, for
+	// buckets with ACLs disabled), private or bucket-owner-full-control.
+	ObjectACL string
+	// UploadRetryMinutes keeps a dump whose upload failed on local disk for
+	// this long so POST /backups/{id}/retry-upload can re-send it without
+	// re-dumping the source. 0 (default) discards it immediately.
+	UploadRetryMinutes int
+	// EnvVars lists the environment variables storage endpoints and regions
+	// may reference as ${NAME}. Empty (default) disables interpolation.
+	EnvVars []string
+	// HTTPTimeoutSeconds fails a storage request once its connection has
+	// moved no data for this long. 0 disables it.
+	HTTPTimeoutSeconds int
+	// HTTPProxy routes storage requests through this proxy URL. Empty
+	// (default) uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	HTTPProxy string
+	// KeyCollisionCheck checks that a backup's object key is free before
+	// uploading and renames the backup if not. On by default.
+	KeyCollisionCheck bool
+}
+
+// RestoreConfig holds settings for restores
+type RestoreConfig struct {
+	// TempDir is where restores download backups to. It is created with
+	// mode 0700. Empty uses dumpstation-restore in the system temp dir, or a
+	// fresh private directory if that path belongs to someone else.
+	TempDir string
+	// TempMaxAgeMinutes is the age past which a restore temp file found at
+	// startup is treated as left behind by a killed run and removed.
+	TempMaxAgeMinutes int
+	// MaxConcurrent caps restores running at once; further restores wait
+	// with status pending. 0 means unlimited.
+	MaxConcurrent int
+}
+
+// HostPolicyConfig restricts which hosts database configs and restore
+// targets may point at. Entries are hostnames, "*.domain" wildcards, IPs or
+// CIDRs; see utils.HostPolicy. Both empty (default) allows any host.
+type HostPolicyConfig struct {
+	Allow []string
+	Deny  []string
+}
+
+// CredentialPolicyConfig controls the warnings returned when storage keys
+// or database passwords look like example placeholders or are short. They
+// never reject input.
+type CredentialPolicyConfig struct {
+	// MinLength is the length below which a credential draws a warning;
+	// 0 disables the length check.
+	MinLength int
+}
+
+// Bounds for TWO_FACTOR_BACKUP_CODES.
+const (
+	MinBackupCodes = 5
+	MaxBackupCodes = 20
+)
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	cfg := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:         getEnv("SERVER_PORT", "8080"),
+			Host:         getEnv("SERVER_HOST", "0.0.0.0"),
+			MaxBodyBytes: getEnvAsInt("MAX_REQUEST_BODY_BYTES", 1<<20),
+			StrictJSON:   getEnvAsBool("JSON_DISALLOW_UNKNOWN_FIELDS", false),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -105,27 +260,49 @@ func Load() (*Config, error) {
 			Password: getEnv("DB_PASSWORD", ""),
 			DBName:   getEnv("DB_NAME", "backup_service"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+			MaxOpenConns:           getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:           getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetimeMinutes: getEnvAsInt("DB_CONN_MAX_LIFETIME_MINUTES", 30),
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", ""),
-			Expiration: getEnvAsInt("JWT_EXPIRATION_MINUTES", 30),
+			Secret:          getEnv("JWT_SECRET", ""),
+			Expiration:      getEnvAsInt("JWT_EXPIRATION_MINUTES", 30),
+			Algorithm:       strings.ToUpper(getEnv("JWT_ALGORITHM", "HS256")),
+			KeyID:           getEnv("JWT_KEY_ID", ""),
+			PrivateKeyFile:  getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			RetiredKeyFiles: getEnvAsMap("JWT_RETIRED_PUBLIC_KEYS"),
 		},
 		Discord: DiscordConfig{
 			WebhookURL:    getEnv("DISCORD_WEBHOOK_URL", ""),
 			OTPExpiration: getEnvAsInt("OTP_EXPIRATION_MINUTES", 5),
+			OTPRetention:  getEnvAsInt("OTP_RETENTION_MINUTES", 60),
+			MaxAttempts:   getEnvAsInt("DISCORD_MAX_ATTEMPTS", 3),
+			MaxRetryAfter: getEnvAsInt("DISCORD_MAX_RETRY_AFTER_SECONDS", 5),
+			QueueInterval: getEnvAsInt("DISCORD_QUEUE_INTERVAL_MS", 500),
+			QueueSize:     getEnvAsInt("DISCORD_QUEUE_SIZE", 100),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnvAsInt("SMTP_PORT", 587),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", ""),
+			To:       getEnvAsSlice("SMTP_TO", []string{}),
 		},
 		GitHub: GitHubConfig{
 			ClientID:     getEnv("GITHUB_CLIENT_ID", ""),
 			ClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
 			AllowedLogin: getEnv("GITHUB_ALLOWED_LOGIN", ""),
 			RedirectURL:  getEnv("GITHUB_REDIRECT_URL", ""),
+			StateSecret:  getEnv("GITHUB_OAUTH_STATE_SECRET", ""),
 		},
 		WebOrigin: getEnv("WEB_ORIGIN", ""),
 		CORS: CORSConfig{
 			AllowedOrigins:   getEnvAsSlice("CORS_ALLOWED_ORIGINS", []string{}),
 			AllowedMethods:   getEnvAsSlice("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"}),
-			AllowedHeaders:   getEnvAsSlice("CORS_ALLOWED_HEADERS", []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-2FA-Token"}),
-			ExposedHeaders:   getEnvAsSlice("CORS_EXPOSED_HEADERS", []string{}),
+			AllowedHeaders:   getEnvAsSlice("CORS_ALLOWED_HEADERS", []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "X-2FA-Token", "X-2FA-Code"}),
+			ExposedHeaders:   getEnvAsSlice("CORS_EXPOSED_HEADERS", []string{"X-Total-Count", "X-Limit", "X-Offset", "Link"}),
 			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", true),
 			MaxAge:           getEnvAsInt("CORS_MAX_AGE", 86400),
 			Debug:            getEnvAsBool("CORS_DEBUG", false),
@@ -139,11 +316,51 @@ func Load() (*Config, error) {
 		Secret: SecretConfig{
 			Key: getEnv("DUMPSTATION_SECRET_KEY", ""),
 		},
+		Postgres: PostgresToolsConfig{
+			BinDirs: getEnvAsMap("PG_BIN_DIRS"),
+		},
+		TwoFactor: TwoFactorConfig{
+			BackupCodes:     getEnvAsInt("TWO_FACTOR_BACKUP_CODES", 10),
+			WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", ""),
+			WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "DumpStation"),
+			WebAuthnOrigins: getEnvAsSlice("WEBAUTHN_ORIGINS", nil),
+		},
+		Scheduler: SchedulerConfig{
+			CronSeconds:           getEnvAsBool("SCHEDULER_CRON_SECONDS", false),
+			MaxConcurrent:         getEnvAsInt("SCHEDULER_MAX_CONCURRENT", 0),
+			FreshnessCheckMinutes: getEnvAsInt("SCHEDULER_FRESHNESS_CHECK_MINUTES", 5),
+			FreshnessGraceMinutes: getEnvAsInt("SCHEDULER_FRESHNESS_GRACE_MINUTES", 60),
+			JitterMinutes:         getEnvAsInt("SCHEDULER_JITTER_MINUTES", 0),
+		},
+		Storage: ObjectStorageConfig{
+			ObjectACL:          getEnv("STORAGE_OBJECT_ACL", "none"),
+			UploadRetryMinutes: getEnvAsInt("STORAGE_UPLOAD_RETRY_MINUTES", 0),
+			EnvVars:            getEnvAsSlice("STORAGE_ENV_VARS", []string{}),
+			HTTPTimeoutSeconds: getEnvAsInt("STORAGE_HTTP_TIMEOUT_SECONDS", 120),
+			HTTPProxy:          getEnv("STORAGE_HTTP_PROXY", ""),
+			KeyCollisionCheck:  getEnvAsBool("STORAGE_KEY_COLLISION_CHECK", true),
+		},
+		Restore: RestoreConfig{
+			TempDir:           getEnv("RESTORE_TEMP_DIR", ""),
+			TempMaxAgeMinutes: getEnvAsInt("RESTORE_TEMP_MAX_AGE_MINUTES", 120),
+			MaxConcurrent:     getEnvAsInt("RESTORE_MAX_CONCURRENT", 2),
+		},
+		Hosts: HostPolicyConfig{
+			Allow: getEnvAsSlice("DATABASE_HOST_ALLOWLIST", []string{}),
+			Deny:  getEnvAsSlice("DATABASE_HOST_DENYLIST", []string{}),
+		},
+		Credentials: CredentialPolicyConfig{
+			MinLength: getEnvAsInt("CREDENTIAL_MIN_LENGTH", 8),
+		},
 	}
 
 	// Validate required fields
-	if cfg.JWT.Secret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+	if cfg.JWT.Algorithm == "HS256" {
+		if cfg.JWT.Secret == "" {
+			return nil, fmt.Errorf("JWT_SECRET is required")
+		}
+	} else if cfg.JWT.PrivateKeyFile == "" || cfg.JWT.KeyID == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE and JWT_KEY_ID are required when JWT_ALGORITHM=%s", cfg.JWT.Algorithm)
 	}
 
 	if cfg.Database.Password == "" {
@@ -154,6 +371,87 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("DUMPSTATION_SECRET_KEY is required (generate with: openssl rand -base64 32)")
 	}
 
+	if cfg.Database.MaxOpenConns < 1 {
+		return nil, fmt.Errorf("DB_MAX_OPEN_CONNS must be at least 1")
+	}
+	if cfg.Database.MaxIdleConns < 0 || cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		return nil, fmt.Errorf("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS (%d)", cfg.Database.MaxOpenConns)
+	}
+	if cfg.Database.ConnMaxLifetimeMinutes < 0 {
+		return nil, fmt.Errorf("DB_CONN_MAX_LIFETIME_MINUTES must not be negative")
+	}
+
+	if cfg.Server.MaxBodyBytes < 1 {
+		return nil, fmt.Errorf("MAX_REQUEST_BODY_BYTES must be at least 1")
+	}
+
+	if cfg.TwoFactor.BackupCodes < MinBackupCodes || cfg.TwoFactor.BackupCodes > MaxBackupCodes {
+		return nil, fmt.Errorf("TWO_FACTOR_BACKUP_CODES must be between %d and %d", MinBackupCodes, MaxBackupCodes)
+	}
+	if strings.ContainsAny(cfg.TwoFactor.WebAuthnRPID, ":/") {
+		return nil, fmt.Errorf("WEBAUTHN_RP_ID must be a bare host name such as dumpstation.example.com")
+	}
+	for _, origin := range cfg.TwoFactor.WebAuthnOrigins {
+		if !strings.HasPrefix(origin, "https://") && !strings.HasPrefix(origin, "http://localhost") {
+			return nil, fmt.Errorf("WEBAUTHN_ORIGINS entry %q must be an https:// origin (or http://localhost for development)", origin)
+		}
+	}
+
+	level, err := logging.ParseLevel(getEnv("LOG_LEVEL", "info"))
+	if err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	cfg.Server.LogLevel = level
+
+	if cfg.Discord.OTPRetention < 0 {
+		return nil, fmt.Errorf("OTP_RETENTION_MINUTES must not be negative")
+	}
+
+	if cfg.Discord.QueueInterval < 0 || cfg.Discord.QueueSize < 1 {
+		return nil, fmt.Errorf("DISCORD_QUEUE_INTERVAL_MS must not be negative and DISCORD_QUEUE_SIZE must be at least 1")
+	}
+
+	if _, err := utils.NewHostPolicy(cfg.Hosts.Allow, cfg.Hosts.Deny); err != nil {
+		return nil, fmt.Errorf("DATABASE_HOST_ALLOWLIST/DATABASE_HOST_DENYLIST: %w", err)
+	}
+
+	if cfg.Scheduler.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("SCHEDULER_MAX_CONCURRENT must not be negative")
+	}
+
+	if cfg.Scheduler.FreshnessCheckMinutes < 0 {
+		return nil, fmt.Errorf("SCHEDULER_FRESHNESS_CHECK_MINUTES must not be negative")
+	}
+
+	if cfg.Scheduler.FreshnessGraceMinutes < 1 {
+		return nil, fmt.Errorf("SCHEDULER_FRESHNESS_GRACE_MINUTES must be at least 1")
+	}
+
+	if cfg.Scheduler.JitterMinutes < 0 || cfg.Scheduler.JitterMinutes > models.MaxJitterMinutes {
+		return nil, fmt.Errorf("SCHEDULER_JITTER_MINUTES must be between 0 and %d", models.MaxJitterMinutes)
+	}
+
+	if cfg.Storage.UploadRetryMinutes < 0 {
+		return nil, fmt.Errorf("STORAGE_UPLOAD_RETRY_MINUTES must not be negative")
+	}
+
+	if cfg.Storage.HTTPTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("STORAGE_HTTP_TIMEOUT_SECONDS must not be negative")
+	}
+
+	if cfg.Restore.TempMaxAgeMinutes < 0 {
+		return nil, fmt.Errorf("RESTORE_TEMP_MAX_AGE_MINUTES must not be negative")
+	}
+	if cfg.Restore.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("RESTORE_MAX_CONCURRENT must not be negative")
+	}
+
+	if cfg.Credentials.MinLength < 0 {
+		return nil, fmt.Errorf("CREDENTIAL_MIN_LENGTH must not be negative")
+	}
+
+	cfg.SMTP.Enabled = cfg.SMTP.Host != "" && len(cfg.SMTP.To) > 0
+
 	// Enable GitHub OAuth only when fully configured. We allow partial config
 	// (e.g. missing redirect URL) to silently disable the feature rather than
 	// crash the server, so Discord-OTP deployments keep working untouched.
@@ -245,3 +543,18 @@ func getEnvAsSlice(key string, defaultValue []string) []string {
 	}
 	return result
 }
+
+// getEnvAsMap retrieves an environment variable as a map of comma-separated
+// key=value pairs. Malformed pairs are skipped.
+func getEnvAsMap(key string) map[string]string {
+	result := map[string]string{}
+	for _, part := range getEnvAsSlice(key, nil) {
+		k, v, ok := strings.Cut(part, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
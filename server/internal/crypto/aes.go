@@ -91,3 +91,13 @@ func (c *Cipher) Decrypt(ciphertext string) (string, error) {
 	}
 	return string(plain), nil
 }
+
+// ReEncrypt decrypts ciphertext with from and re-seals the plaintext with
+// to. Used by key rotation; the plaintext never leaves this function.
+func ReEncrypt(from, to *Cipher, ciphertext string) (string, error) {
+	plain, err := from.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return to.Encrypt(plain)
+}
@@ -106,3 +106,30 @@ func TestDecryptRejectsMalformed(t *testing.T) {
 		}
 	}
 }
+
+func TestReEncryptMovesCiphertextToNewKey(t *testing.T) {
+	oldC := newTestCipher(t)
+	newC := newTestCipher(t)
+	ct, err := oldC.Encrypt("rotate-me")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := ReEncrypt(oldC, newC, ct)
+	if err != nil {
+		t.Fatalf("ReEncrypt: %v", err)
+	}
+	got, err := newC.Decrypt(rotated)
+	if err != nil {
+		t.Fatalf("Decrypt with new key: %v", err)
+	}
+	if got != "rotate-me" {
+		t.Fatalf("round-trip mismatch: got %q", got)
+	}
+	if _, err := oldC.Decrypt(rotated); err == nil {
+		t.Fatal("expected rotated ciphertext to be unreadable with the old key")
+	}
+	if _, err := ReEncrypt(newC, oldC, ct); err == nil {
+		t.Fatal("expected ReEncrypt to fail when the source key is wrong")
+	}
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"time"
 
+	"github.com/monzim/db_proxy/v1/internal/config"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -15,9 +17,10 @@ type DB struct {
 	*gorm.DB
 }
 
-// New creates a new GORM database connection
-func New(dsn string) (*DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+// New creates a new GORM database connection using cfg's DSN and pool
+// settings.
+func New(cfg *config.DatabaseConfig) (*DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.GetDSN()), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent), // Silent in production, change to logger.Info for debugging
 	})
 	if err != nil {
@@ -31,8 +34,12 @@ func New(dsn string) (*DB, error) {
 	}
 
 	// Set connection pool settings
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(5)
+	lifetime := time.Duration(cfg.ConnMaxLifetimeMinutes) * time.Minute
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(lifetime)
+	logging.Debugf("Database pool: max_open=%d max_idle=%d conn_max_lifetime=%s",
+		cfg.MaxOpenConns, cfg.MaxIdleConns, lifetime)
 
 	// Test connection
 	if err := sqlDB.Ping(); err != nil {
@@ -44,7 +51,7 @@ func New(dsn string) (*DB, error) {
 
 // AutoMigrate runs GORM auto-migration for all models
 func (db *DB) AutoMigrate() error {
-	log.Println("Running GORM auto-migration...")
+	logging.Debugf("Running GORM auto-migration...")
 
 	err := db.DB.AutoMigrate(
 		&models.User{},
@@ -52,7 +59,12 @@ func (db *DB) AutoMigrate() error {
 		&models.StorageConfig{},
 		&models.NotificationConfig{},
 		&models.DatabaseConfig{},
+		&models.NotificationState{},
 		&models.Backup{},
+		&models.BackupEvent{},
+		&models.BackupLog{},
+		&models.BackupShare{},
+		&models.BackupUpload{},
 		&models.RestoreJob{},
 		&models.ActivityLog{},
 		&models.Label{},
@@ -60,6 +72,9 @@ func (db *DB) AutoMigrate() error {
 		&models.StorageLabel{},
 		&models.NotificationLabel{},
 		&models.ServerConnection{},
+		&models.MaintenanceState{},
+		&models.SSLModeCacheEntry{},
+		&models.WebAuthnCredential{},
 	)
 
 	if err != nil {
@@ -71,7 +86,7 @@ func (db *DB) AutoMigrate() error {
 	// prevents GitHub-only signups from succeeding. Drop the constraint
 	// idempotently so both auth flows can coexist.
 	if err := db.DB.Exec(`ALTER TABLE users ALTER COLUMN discord_user_id DROP NOT NULL`).Error; err != nil {
-		log.Printf("warning: could not drop NOT NULL on users.discord_user_id (likely already nullable): %v", err)
+		logging.Warnf("warning: could not drop NOT NULL on users.discord_user_id (likely already nullable): %v", err)
 	}
 
 	// Some AutoMigrate paths abort silently when a new column would be
@@ -95,11 +110,33 @@ func (db *DB) AutoMigrate() error {
 	}
 	for _, m := range manualColumns {
 		if err := db.DB.Exec(m.stmt).Error; err != nil {
-			log.Printf("warning: schema patch for %s failed (likely already applied): %v", m.hint, err)
+			logging.Warnf("warning: schema patch for %s failed (likely already applied): %v", m.hint, err)
 		}
 	}
 
-	log.Println("Auto-migration completed successfully")
+	// Storage config names are unique per user, ignoring case. Rename any
+	// duplicates left from before that was enforced, keeping the oldest
+	// as is and suffixing the rest, then add the index.
+	if err := db.DB.Exec(`
+		UPDATE storage_configs s SET name = s.name || ' (' || d.rn || ')'
+		FROM (
+			SELECT id, row_number() OVER (PARTITION BY user_id, lower(name) ORDER BY created_at, id) AS rn
+			FROM storage_configs
+		) d
+		WHERE s.id = d.id AND d.rn > 1`).Error; err != nil {
+		logging.Warnf("warning: could not rename duplicate storage config names: %v", err)
+	}
+	if err := db.DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_storage_configs_user_lower_name ON storage_configs (user_id, lower(name))`).Error; err != nil {
+		logging.Warnf("warning: could not add the unique storage config name index: %v", err)
+	}
+
+	// Test restores used to copy the decrypted server-connection password
+	// into restore_jobs. Scrub any left over from before that was fixed.
+	if err := db.DB.Exec(`UPDATE restore_jobs SET target_password = NULL WHERE is_test AND target_password IS NOT NULL`).Error; err != nil {
+		logging.Warnf("warning: could not clear test restore passwords: %v", err)
+	}
+
+	logging.Infof("Auto-migration completed successfully")
 	return nil
 }
 
@@ -1,13 +1,13 @@
 package database
 
 import (
-	"log"
 	"math/rand"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"github.com/monzim/db_proxy/v1/internal/repository"
 )
@@ -36,11 +36,11 @@ func isProductionEnv() bool {
 // SeedDemoData seeds demo data for the demo account
 func SeedDemoData(repo *repository.Repository) error {
 	if isProductionEnv() {
-		log.Println("[DEMO] ⏭️  Skipping demo seed (APP_ENV=production). Set FORCE_DEMO_SEED=true to override.")
+		logging.Warnf("[DEMO] ⏭️  Skipping demo seed (APP_ENV=production). Set FORCE_DEMO_SEED=true to override.")
 		return nil
 	}
 
-	log.Println("[DEMO] 🔧 Seeding demo data...")
+	logging.Infof("[DEMO] 🔧 Seeding demo data...")
 
 	// Create or get demo user
 	demoUser, err := repo.SeedDemoUser(DemoUsername, DemoEmail)
@@ -48,7 +48,7 @@ func SeedDemoData(repo *repository.Repository) error {
 		return err
 	}
 
-	log.Printf("[DEMO] ✅ Demo user ready: %s (%s)", demoUser.DiscordUsername, demoUser.Email)
+	logging.Debugf("[DEMO] ✅ Demo user ready: %s (%s)", demoUser.DiscordUsername, demoUser.Email)
 
 	// Check if demo data already exists (by checking storage configs)
 	storageConfigs, err := repo.ListStorageConfigsByUser(demoUser.ID, false)
@@ -59,7 +59,7 @@ func SeedDemoData(repo *repository.Repository) error {
 	// If demo storage configs already exist, skip seeding
 	for _, sc := range storageConfigs {
 		if sc.Name == "Demo S3 Bucket" || sc.Name == "Demo R2 Storage" {
-			log.Println("[DEMO] ✅ Demo data already exists, skipping seed")
+			logging.Infof("[DEMO] ✅ Demo data already exists, skipping seed")
 			return nil
 		}
 	}
@@ -67,17 +67,17 @@ func SeedDemoData(repo *repository.Repository) error {
 	// Seed storage configurations
 	storages, err := seedDemoStorages(repo, demoUser.ID)
 	if err != nil {
-		log.Printf("[DEMO] ⚠️  Failed to seed storage configs: %v", err)
+		logging.Errorf("[DEMO] ⚠️  Failed to seed storage configs: %v", err)
 	} else {
-		log.Printf("[DEMO] ✅ Created %d demo storage configurations", len(storages))
+		logging.Debugf("[DEMO] ✅ Created %d demo storage configurations", len(storages))
 	}
 
 	// Seed notification configurations
 	notifications, err := seedDemoNotifications(repo, demoUser.ID)
 	if err != nil {
-		log.Printf("[DEMO] ⚠️  Failed to seed notification configs: %v", err)
+		logging.Errorf("[DEMO] ⚠️  Failed to seed notification configs: %v", err)
 	} else {
-		log.Printf("[DEMO] ✅ Created %d demo notification configurations", len(notifications))
+		logging.Debugf("[DEMO] ✅ Created %d demo notification configurations", len(notifications))
 	}
 
 	// Seed database configurations (need storage IDs)
@@ -89,30 +89,30 @@ func SeedDemoData(repo *repository.Repository) error {
 
 		databases, err := seedDemoDatabases(repo, demoUser.ID, storages[0].ID, notificationID)
 		if err != nil {
-			log.Printf("[DEMO] ⚠️  Failed to seed database configs: %v", err)
+			logging.Errorf("[DEMO] ⚠️  Failed to seed database configs: %v", err)
 		} else {
-			log.Printf("[DEMO] ✅ Created %d demo database configurations", len(databases))
+			logging.Debugf("[DEMO] ✅ Created %d demo database configurations", len(databases))
 
 			// Seed backup history for each database
 			for _, db := range databases {
 				err := seedDemoBackups(repo, db.ID)
 				if err != nil {
-					log.Printf("[DEMO] ⚠️  Failed to seed backups for %s: %v", db.Name, err)
+					logging.Errorf("[DEMO] ⚠️  Failed to seed backups for %s: %v", db.Name, err)
 				}
 			}
-			log.Println("[DEMO] ✅ Created demo backup history")
+			logging.Debugf("[DEMO] ✅ Created demo backup history")
 		}
 	}
 
 	// Seed activity logs
 	err = seedDemoActivityLogs(repo, demoUser.ID)
 	if err != nil {
-		log.Printf("[DEMO] ⚠️  Failed to seed activity logs: %v", err)
+		logging.Errorf("[DEMO] ⚠️  Failed to seed activity logs: %v", err)
 	} else {
-		log.Println("[DEMO] ✅ Created demo activity logs")
+		logging.Debugf("[DEMO] ✅ Created demo activity logs")
 	}
 
-	log.Println("[DEMO] ✅ Demo data seeding completed")
+	logging.Infof("[DEMO] ✅ Demo data seeding completed")
 	return nil
 }
 
@@ -263,7 +263,7 @@ func seedDemoBackups(repo *repository.Repository, databaseID uuid.UUID) error {
 		timestamp := now.Add(-time.Duration(b.daysAgo) * 24 * time.Hour)
 		timestamp = timestamp.Add(-time.Duration(rand.Intn(4)) * time.Hour) // Add some randomness
 
-		backup, err := repo.CreateBackup(databaseID, models.BackupStatusPending)
+		backup, err := repo.CreateBackup(databaseID, models.BackupStatusPending, models.BackupTriggerSchedule, nil)
 		if err != nil {
 			return err
 		}
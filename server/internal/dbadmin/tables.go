@@ -222,3 +222,12 @@ func (c *Client) TruncateTable(ctx context.Context, schema, name string) error {
 	}
 	return nil
 }
+
+// Analyze runs a database-wide ANALYZE so ListTables' reltuples estimates
+// are populated, e.g. right after a restore.
+func (c *Client) Analyze(ctx context.Context) error {
+	if _, err := c.db.ExecContext(ctx, "ANALYZE"); err != nil {
+		return classifyPgError(err)
+	}
+	return nil
+}
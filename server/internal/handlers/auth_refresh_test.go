@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestWithCurrentRolesDropsRevokedAdmin checks that a refresh issued after
+// admin was revoked no longer carries the admin claim.
+func TestWithCurrentRolesDropsRevokedAdmin(t *testing.T) {
+	id := uuid.New()
+	old := &auth.Claims{UserID: id, IsAdmin: true, SessionStartedAt: 42}
+
+	got := withCurrentRoles(old, &models.User{ID: id, IsAdmin: false})
+
+	if got.IsAdmin {
+		t.Error("refreshed claims kept a revoked admin role")
+	}
+	if got.SessionStartedAt != 42 || got.UserID != id {
+		t.Errorf("refreshed claims lost identity: %+v", got)
+	}
+	if !old.IsAdmin {
+		t.Error("withCurrentRoles modified the original claims")
+	}
+}
+
+// TestWithCurrentRolesDropsRevokedViewer is the viewer counterpart.
+func TestWithCurrentRolesDropsRevokedViewer(t *testing.T) {
+	old := &auth.Claims{UserID: uuid.New(), IsViewer: true}
+
+	got := withCurrentRoles(old, &models.User{ID: old.UserID})
+
+	if got.IsViewer {
+		t.Error("refreshed claims kept a revoked viewer role")
+	}
+}
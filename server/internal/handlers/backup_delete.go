@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// maxBulkDeleteBackups caps a single bulk-delete request. Each ID costs a
+// storage round trip, so an unbounded list could hold the request open for
+// minutes.
+const maxBulkDeleteBackups = 100
+
+// errBackupInProgress is returned when deleting a backup that is still
+// pending or running; its object may not exist yet and the pipeline would
+// recreate the row's state after we removed it.
+var errBackupInProgress = errors.New("backup is still in progress")
+
+// BulkDeleteBackupsRequest is the body of POST /backups/bulk-delete.
+type BulkDeleteBackupsRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+// BackupDeleteResult reports the outcome for one backup in a bulk delete.
+type BackupDeleteResult struct {
+	ID      uuid.UUID `json:"id"`
+	Deleted bool      `json:"deleted"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// BulkDeleteBackupsResponse is the shape of POST /backups/bulk-delete.
+type BulkDeleteBackupsResponse struct {
+	Deleted int                  `json:"deleted"`
+	Failed  int                  `json:"failed"`
+	Results []BackupDeleteResult `json:"results"`
+}
+
+// deleteBackup removes a backup's storage object and then its row. The
+// object goes first: if storage deletion fails the row is kept so the
+// object is never orphaned, and the caller can retry.
+func (h *Handler) deleteBackup(b *models.Backup) error {
+	if b.Status == models.BackupStatusPending || b.Status == models.BackupStatusRunning {
+		return errBackupInProgress
+	}
+
+	if b.StoragePath != "" {
+		storageConfig, err := h.repo.GetStorageConfig(b.Database.StorageID)
+		if err != nil || storageConfig == nil {
+			return fmt.Errorf("storage config unavailable: %v", err)
+		}
+		client, err := storage.NewStorageClient(storageConfig)
+		if err != nil {
+			return err
+		}
+		if err := client.DeleteFile(b.StoragePath); err != nil {
+			return err
+		}
+	}
+
+	if _, err := h.repo.DeleteBackupsByIDs([]uuid.UUID{b.ID}); err != nil {
+		// The object is already gone; flip the row so it no longer
+		// advertises a downloadable backup.
+		if markErr := h.repo.MarkBackupDeleted(b.ID); markErr != nil {
+			logError("Failed to mark backup deleted after row delete failed", markErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteBackup godoc
+// @Summary Delete a backup
+// @Description Delete a backup's storage object and its record. The record is kept if the storage object cannot be removed.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 204 "Backup deleted"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 409 {object} map[string]string "Backup still in progress"
+// @Failure 502 {object} map[string]string "Storage deletion failed"
+// @Router /backups/{id} [delete]
+func (h *Handler) DeleteBackup(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+
+	if err := h.deleteBackup(backup); err != nil {
+		if errors.Is(err, errBackupInProgress) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		logError("Failed to delete backup", err)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("failed to delete backup: %v", err))
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]string{
+		"database":     backup.Database.Name,
+		"storage_path": backup.StoragePath,
+	})
+	h.logActivity(userID, models.ActionBackupDeleted, models.LogLevelWarning,
+		"backup", &backup.ID, backup.Name,
+		fmt.Sprintf("Deleted backup %q of database %q", backup.Name, backup.Database.Name),
+		string(meta), getIPAddress(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BulkDeleteBackups godoc
+// @Summary Delete several backups
+// @Description Delete up to 100 backups by ID. Each backup is deleted independently; the response reports which succeeded and why the others failed.
+// @Tags Backups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BulkDeleteBackupsRequest true "Backup IDs"
+// @Success 200 {object} BulkDeleteBackupsResponse "Per-backup results"
+// @Failure 400 {object} map[string]string "Invalid request body"
+// @Router /backups/bulk-delete [post]
+func (h *Handler) BulkDeleteBackups(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	var req BulkDeleteBackupsRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "ids is required")
+		return
+	}
+	if len(req.IDs) > maxBulkDeleteBackups {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d backups can be deleted per request", maxBulkDeleteBackups))
+		return
+	}
+
+	resp := BulkDeleteBackupsResponse{Results: make([]BackupDeleteResult, 0, len(req.IDs))}
+	seen := make(map[uuid.UUID]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		result := BackupDeleteResult{ID: id}
+		backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+		switch {
+		case err != nil:
+			result.Error = "failed to get backup"
+		case backup == nil:
+			result.Error = "backup not found"
+		default:
+			if err := h.deleteBackup(backup); err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Deleted = true
+			}
+		}
+
+		if result.Deleted {
+			resp.Deleted++
+		} else {
+			resp.Failed++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	meta, _ := json.Marshal(resp)
+	level := models.LogLevelWarning
+	if resp.Failed > 0 {
+		level = models.LogLevelError
+	}
+	h.logActivity(userID, models.ActionBackupDeleted, level,
+		"backup", nil, "",
+		fmt.Sprintf("Bulk deleted %d backup(s), %d failed", resp.Deleted, resp.Failed),
+		string(meta), getIPAddress(r))
+
+	writeJSON(w, http.StatusOK, resp)
+}
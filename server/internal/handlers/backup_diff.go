@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/backup"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// maxSchemaDiffBytes caps each uncompressed schema dump read for a diff.
+// Schema-only dumps are normally well under a megabyte.
+const maxSchemaDiffBytes = 32 << 20
+
+// schemaDiffTimeout bounds fetching both dumps from storage.
+const schemaDiffTimeout = 2 * time.Minute
+
+var errSchemaDumpTooLarge = errors.New("schema dump too large to diff")
+
+// BackupDiffResponse is the shape of GET /backups/{id}/diff.
+type BackupDiffResponse struct {
+	BackupID  uuid.UUID `json:"backup_id"`
+	AgainstID uuid.UUID `json:"against_id"`
+	*backup.SchemaDiff
+}
+
+// DiffBackups godoc
+// @Summary Compare two schema-only backups
+// @Description Lists database objects added, removed or changed in backup {id} relative to backup {against}. Both backups must be successful schema-only backups owned by the caller.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Param against query string true "Backup ID (UUID) to compare against"
+// @Success 200 {object} BackupDiffResponse
+// @Failure 400 {object} map[string]string "Invalid ID, or a backup is not a schema-only dump"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 502 {object} map[string]string "Failed to read a backup from storage"
+// @Router /backups/{id}/diff [get]
+func (h *Handler) DiffBackups(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+	againstID, err := parseUUID(r.URL.Query().Get("against"))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid against ID")
+		return
+	}
+
+	var pair [2]*models.Backup
+	for i, bid := range []uuid.UUID{againstID, id} {
+		b, err := h.repo.GetBackupByUser(bid, *userID, isAdmin)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to get backup")
+			return
+		}
+		if b == nil {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+			return
+		}
+		if b.Status != models.BackupStatusSuccess || b.StoragePath == "" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("backup %s has no stored dump", b.ID))
+			return
+		}
+		if !b.SchemaOnly || b.DumpFormat != models.DumpFormatPlain {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("backup %s is not a schema-only backup", b.ID))
+			return
+		}
+		pair[i] = b
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), schemaDiffTimeout)
+	defer cancel()
+
+	var dumps [2][]byte
+	for i, b := range pair {
+		dumps[i], err = h.readSchemaDump(ctx, b)
+		if errors.Is(err, errSchemaDumpTooLarge) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			logError("Failed to read schema dump", err)
+			writeError(w, http.StatusBadGateway, "failed to read backup from storage")
+			return
+		}
+	}
+
+	diff, err := backup.DiffSchemaDumps(bytes.NewReader(dumps[0]), bytes.NewReader(dumps[1]))
+	if err != nil {
+		logError("Failed to diff schema dumps", err)
+		writeError(w, http.StatusInternalServerError, "failed to diff backups")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BackupDiffResponse{BackupID: id, AgainstID: againstID, SchemaDiff: diff})
+}
+
+// readSchemaDump fetches and, if needed, gunzips a plain-format dump.
+func (h *Handler) readSchemaDump(ctx context.Context, b *models.Backup) ([]byte, error) {
+	dbCfg, err := h.repo.GetDatabaseConfig(b.DatabaseID)
+	if err != nil {
+		return nil, err
+	}
+	if dbCfg == nil {
+		return nil, fmt.Errorf("database config %s not found", b.DatabaseID)
+	}
+	client, err := storage.NewStorageClient(&dbCfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	body, err := client.OpenObject(ctx, b.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var src io.Reader = body
+	if b.Compression == models.CompressionGzip {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, maxSchemaDiffBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSchemaDiffBytes {
+		return nil, errSchemaDumpTooLarge
+	}
+	return data, nil
+}
@@ -140,6 +140,9 @@ type DownloadOTPRequestResponse struct {
 type DownloadVerifyRequest struct {
 	OTPID uuid.UUID `json:"otp_id" validate:"required"`
 	Code  string    `json:"code" validate:"required"`
+	// Compress set to "gzip" streams an uncompressed backup through gzip
+	// on the fly instead of handing out a direct storage URL.
+	Compress string `json:"compress,omitempty" validate:"omitempty,oneof=gzip"`
 }
 
 // DownloadURLResponse is returned after a successful OTP verification.
@@ -171,7 +174,7 @@ func (h *Handler) RequestBackupDownloadOTP(w http.ResponseWriter, r *http.Reques
 
 	backupID, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid backup id")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid backup id")
 		return
 	}
 	backup, err := h.repo.GetBackupByUser(backupID, *userID, isAdmin)
@@ -180,7 +183,7 @@ func (h *Handler) RequestBackupDownloadOTP(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if backup == nil {
-		writeError(w, http.StatusNotFound, "backup not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
 		return
 	}
 	if backup.Status != models.BackupStatusSuccess || backup.StoragePath == "" {
@@ -260,7 +263,8 @@ func (h *Handler) RequestBackupDownloadOTP(w http.ResponseWriter, r *http.Reques
 // VerifyBackupDownloadOTP redeems a download OTP and returns a short-lived
 // S3/R2/MinIO presigned URL. The URL is delivered as JSON; the browser
 // follows it to download directly from storage so the API server never
-// proxies the bytes.
+// proxies the bytes — except when compress=gzip is requested for an
+// uncompressed backup, where the URL points at DownloadBackup instead.
 //
 // @Summary  Verify download OTP and get presigned URL
 // @Tags     Backups
@@ -281,13 +285,13 @@ func (h *Handler) VerifyBackupDownloadOTP(w http.ResponseWriter, r *http.Request
 
 	backupID, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid backup id")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid backup id")
 		return
 	}
 
 	var req DownloadVerifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid JSON body")
 		return
 	}
 	if verr, err := h.validator.Validate(&req); err != nil {
@@ -315,7 +319,7 @@ func (h *Handler) VerifyBackupDownloadOTP(w http.ResponseWriter, r *http.Request
 
 	backup, err := h.repo.GetBackupByUser(backupID, *userID, isAdmin)
 	if err != nil || backup == nil {
-		writeError(w, http.StatusNotFound, "backup not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
 		return
 	}
 
@@ -336,18 +340,17 @@ func (h *Handler) VerifyBackupDownloadOTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Pick a sensible download filename so the browser saves it nicely.
-	suggested := backup.Name
-	if backup.DumpFormat == models.DumpFormatCustom {
-		suggested += ".dump"
+	var url string
+	if req.Compress == models.CompressionGzip && !isStoredCompressed(backup) {
+		// Served by DownloadBackup, which proxies the bytes through gzip.
+		url = fmt.Sprintf("/api/v1/backups/%s/download?compress=gzip&ticket=%s",
+			backup.ID, h.signDownloadTicket(backup.ID, time.Now().Add(downloadOTPTTL)))
 	} else {
-		suggested += ".sql"
-	}
-
-	url, err := client.PresignDownload(backup.StoragePath, suggested, downloadOTPTTL)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to presign download URL")
-		return
+		url, err = client.PresignDownload(backup.StoragePath, downloadFilename(backup), downloadOTPTTL)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to presign download URL")
+			return
+		}
 	}
 
 	size := int64(0)
@@ -358,6 +361,7 @@ func (h *Handler) VerifyBackupDownloadOTP(w http.ResponseWriter, r *http.Request
 		"backup_id":  backup.ID,
 		"name":       backup.Name,
 		"size_bytes": size,
+		"compress":   req.Compress,
 	})
 	h.logActivity(userID, models.ActionBackupDownloaded, models.LogLevelInfo,
 		"backup", &backup.ID, backup.Name,
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+const (
+	// maxExportBackups and maxExportBytes cap one download-all archive.
+	// Larger histories are exported in several date ranges.
+	maxExportBackups       = 100
+	maxExportBytes   int64 = 20 << 30
+	// exportTimeout bounds the whole archive transfer.
+	exportTimeout = 2 * time.Hour
+)
+
+// ExportManifestEntry describes one backup in a download-all archive.
+type ExportManifestEntry struct {
+	BackupID        string    `json:"backup_id"`
+	File            string    `json:"file"`
+	StartedAt       time.Time `json:"started_at"`
+	SizeBytes       int64     `json:"size_bytes"`
+	DumpFormat      string    `json:"dump_format,omitempty"`
+	Compression     string    `json:"compression,omitempty"`
+	PostgresVersion string    `json:"postgres_version,omitempty"`
+	ChecksumSHA256  string    `json:"checksum_sha256,omitempty"`
+}
+
+// ExportManifest is written as manifest.json at the top of the archive.
+type ExportManifest struct {
+	DatabaseID   string                `json:"database_id"`
+	DatabaseName string                `json:"database_name"`
+	ExportedAt   time.Time             `json:"exported_at"`
+	Backups      []ExportManifestEntry `json:"backups"`
+}
+
+// DownloadAllBackups godoc
+// @Summary Download a database's backups as one archive
+// @Description Streams a .tar.gz holding manifest.json and every successful backup of the database (optionally limited to a started_at range), newest first. Objects are streamed from storage one by one, so nothing is buffered on the server. At most 100 backups and 20 GiB per archive; export larger histories in several ranges. Owner only; requires X-2FA-Code when 2FA is enabled, otherwise a login within the last 5 minutes.
+// @Tags Backups
+// @Produce application/gzip
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Param X-2FA-Code header string false "Current TOTP code (required when 2FA is enabled)"
+// @Param since query string false "Only backups started at or after this RFC 3339 time"
+// @Param until query string false "Only backups started before this RFC 3339 time"
+// @Success 200 {file} file "tar.gz archive"
+// @Failure 400 {object} models.APIError "Invalid ID or time range"
+// @Failure 403 {object} models.APIError "Re-authentication required"
+// @Failure 404 {object} models.APIError "Database config not found, or no backups in range"
+// @Failure 413 {object} models.APIError "Too many backups or bytes for one archive"
+// @Router /databases/{id}/backups/download-all [get]
+func (h *TwoFactorHandler) DownloadAllBackups(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+	since, err := parseOptionalTime(r.URL.Query().Get("since"))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, "since must be an RFC 3339 time")
+		return
+	}
+	until, err := parseOptionalTime(r.URL.Query().Get("until"))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, "until must be an RFC 3339 time")
+		return
+	}
+
+	// Owner only, like single downloads: admins don't bulk-export other
+	// users' data.
+	config, err := h.repo.GetDatabaseConfigByUser(id, *userID, false)
+	if err != nil {
+		logError("Failed to get database config", err)
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return
+	}
+
+	if !h.requireFreshAuth(w, r, *userID) {
+		return
+	}
+
+	// One extra row tells us the range holds more than the cap.
+	backups, err := h.repo.ListExportableBackups(config.ID, since, until, maxExportBackups+1)
+	if err != nil {
+		logError("Failed to list backups for export", err)
+		writeError(w, http.StatusInternalServerError, "failed to list backups")
+		return
+	}
+	if len(backups) == 0 {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "no successful backups in range")
+		return
+	}
+	if len(backups) > maxExportBackups {
+		writeErrorCode(w, http.StatusRequestEntityTooLarge, models.ErrCodeExportTooLarge,
+			fmt.Sprintf("more than %d backups in range; narrow it with since/until", maxExportBackups))
+		return
+	}
+	var recorded int64
+	for _, b := range backups {
+		if b.SizeBytes != nil {
+			recorded += *b.SizeBytes
+		}
+	}
+	if recorded > maxExportBytes {
+		writeErrorCode(w, http.StatusRequestEntityTooLarge, models.ErrCodeExportTooLarge,
+			fmt.Sprintf("backups in range total more than %d GiB; narrow it with since/until", maxExportBytes>>30))
+		return
+	}
+
+	client, err := storage.NewStorageClient(&config.Storage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to init storage client")
+		return
+	}
+
+	manifest := ExportManifest{
+		DatabaseID:   config.ID.String(),
+		DatabaseName: config.Name,
+		ExportedAt:   time.Now().UTC(),
+		Backups:      make([]ExportManifestEntry, 0, len(backups)),
+	}
+	for _, b := range backups {
+		entry := ExportManifestEntry{
+			BackupID:        b.ID.String(),
+			File:            exportEntryName(b),
+			StartedAt:       b.StartedAt,
+			DumpFormat:      string(b.DumpFormat),
+			Compression:     b.Compression,
+			PostgresVersion: b.PostgresVersion,
+			ChecksumSHA256:  b.ChecksumSHA256,
+		}
+		if b.SizeBytes != nil {
+			entry.SizeBytes = *b.SizeBytes
+		}
+		manifest.Backups = append(manifest.Backups, entry)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build manifest")
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]any{
+		"backups":    len(backups),
+		"size_bytes": recorded,
+	})
+	h.logActivity(userID, models.ActionBackupDownloaded, models.LogLevelInfo,
+		"database", &config.ID, config.Name,
+		fmt.Sprintf("All %d backups of %q downloaded as one archive", len(backups), config.Name),
+		string(meta), getIPAddress(r))
+
+	extendWriteDeadline(w, exportTimeout)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-backups.tar.gz"`, archiveName(config.Name)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are gone from here on: a failure can only cut the archive
+	// short, which tar and gzip readers report as truncated.
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+	// Most dumps are already compressed; favour speed over ratio.
+	zw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	tw := tar.NewWriter(zw)
+	if err := writeTarFile(tw, "manifest.json", manifest.ExportedAt, int64(len(manifestJSON)), bytes.NewReader(manifestJSON)); err != nil {
+		logError("Backup export interrupted", err)
+		return
+	}
+	for i, b := range backups {
+		if err := exportObject(ctx, tw, client, b.StoragePath, manifest.Backups[i].File, b.StartedAt); err != nil {
+			logError(fmt.Sprintf("Backup export of %s interrupted at backup %s", config.Name, b.ID), err)
+			return
+		}
+	}
+	if err := tw.Close(); err != nil {
+		logError("Backup export interrupted", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		logError("Backup export interrupted", err)
+	}
+}
+
+// exportObject streams one stored object into the archive as name.
+func exportObject(ctx context.Context, tw *tar.Writer, client *storage.StorageClient, objectKey, name string, modTime time.Time) error {
+	body, size, err := client.OpenObjectWithSize(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return writeTarFile(tw, name, modTime, size, body)
+}
+
+// writeTarFile adds a regular file of exactly size bytes read from body.
+func writeTarFile(tw *tar.Writer, name string, modTime time.Time, size int64, body io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, body)
+	return err
+}
+
+// archiveName turns a config name into a safe download filename stem.
+func archiveName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if strings.Trim(safe, "_.") == "" {
+		return "database"
+	}
+	return safe
+}
+
+// exportEntryName is b's path inside the export archive. Backup names are
+// only unique to the second, so the ID keeps two entries from colliding.
+func exportEntryName(b *models.Backup) string {
+	return "backups/" + b.ID.String() + "-" + downloadFilename(b)
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestExportEntryNameUnique checks that two backups sharing a name, as
+// happens when both start in the same second, get distinct archive paths.
+func TestExportEntryNameUnique(t *testing.T) {
+	a := &models.Backup{ID: uuid.New(), Name: "prod_20260102_030405", DumpFormat: models.DumpFormatCustom}
+	b := &models.Backup{ID: uuid.New(), Name: a.Name, DumpFormat: models.DumpFormatCustom}
+
+	nameA, nameB := exportEntryName(a), exportEntryName(b)
+	if nameA == nameB {
+		t.Fatalf("both backups export as %q", nameA)
+	}
+	want := "backups/" + a.ID.String() + "-prod_20260102_030405.dump"
+	if nameA != want {
+		t.Errorf("exportEntryName = %q, want %q", nameA, want)
+	}
+	if !strings.HasSuffix(nameB, "-"+downloadFilename(b)) {
+		t.Errorf("exportEntryName = %q, want it to end in the download filename", nameB)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// GetBackupLog godoc
+// @Summary Get a backup's pg_dump log
+// @Description Retrieve the tail of pg_dump's output for a backup, successful or failed, with passwords scrubbed. It holds pg_dump's verbose progress unless the database has no_verbose set, in which case only warnings and errors are kept. truncated is true when earlier output was dropped.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 200 {object} models.BackupLog "Captured pg_dump output"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found, or no output was captured"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /backups/{id}/log [get]
+func (h *Handler) GetBackupLog(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+
+	entry, err := h.repo.GetBackupLog(backup.ID)
+	if err != nil {
+		logError("Failed to get backup log", err)
+		writeError(w, http.StatusInternalServerError, "failed to get backup log")
+		return
+	}
+	if entry == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "no pg_dump output was captured for this backup")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+)
+
+// resendNotificationCooldown is how long a database's backup notifications
+// can't be re-sent again, so a retry loop can't flood a channel.
+const resendNotificationCooldown = time.Minute
+
+// ResendBackupNotification godoc
+// @Summary Re-send a backup's notification
+// @Description Send the success or failure notification of a finished backup again, e.g. after fixing a webhook that was broken during the original run. It goes through the database's notification config, or through the one given as notification_id. Re-sends are limited to one per database per minute.
+// @Tags Backups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Param body body models.ResendNotificationRequest false "Optional notification config to send through"
+// @Success 200 {object} map[string]interface{} "Notification sent"
+// @Failure 400 {object} map[string]string "Invalid ID or body, or no notification config to send through"
+// @Failure 404 {object} map[string]string "Backup or notification config not found"
+// @Failure 409 {object} map[string]string "Backup has not finished"
+// @Failure 429 {object} map[string]string "Notification re-sent too recently"
+// @Failure 502 {object} map[string]string "Notification delivery failed"
+// @Router /backups/{id}/notify [post]
+func (h *Handler) ResendBackupNotification(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	var req models.ResendNotificationRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+
+	b, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if b == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if b.Status != models.BackupStatusSuccess && b.Status != models.BackupStatusFailed {
+		writeErrorCode(w, http.StatusConflict, models.ErrCodeConflict, "only finished backups have a notification to re-send")
+		return
+	}
+
+	dbConfig, err := h.repo.GetDatabaseConfig(b.DatabaseID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if dbConfig == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return
+	}
+
+	// An explicit config must belong to the caller; the database's own was
+	// chosen by its owner, who already passed the backup ownership check.
+	var notifConfig *models.NotificationConfig
+	switch {
+	case req.NotificationID != nil:
+		notifConfig, err = h.repo.GetNotificationConfigByUser(*req.NotificationID, *userID, isAdmin)
+	case dbConfig.NotificationID != nil:
+		notifConfig, err = h.repo.GetNotificationConfig(*dbConfig.NotificationID)
+	default:
+		writeError(w, http.StatusBadRequest, "database has no notification config; pass notification_id")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get notification config")
+		return
+	}
+	if notifConfig == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotificationNotFound, "notification config not found")
+		return
+	}
+	notifier := notification.NotifierFromConfig(notifConfig)
+	channels := notification.Channels(notifier)
+	if len(channels) == 0 {
+		writeError(w, http.StatusBadRequest, "notification config has no channel set up")
+		return
+	}
+
+	decision, err := h.repo.ClaimNotification(dbConfig.ID, models.NotificationEventBackupResend, resendNotificationCooldown)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to check notification rate limit")
+		return
+	}
+	if !decision.Send {
+		w.Header().Set("Retry-After", strconv.Itoa(int(resendNotificationCooldown.Seconds())))
+		writeErrorCode(w, http.StatusTooManyRequests, models.ErrCodeRateLimited,
+			"a notification for this database was re-sent recently; try again in a minute")
+		return
+	}
+
+	if err := sendBackupNotification(notifier, dbConfig.Name, b); err != nil {
+		// The error can quote the webhook URL or bot token, so it only goes
+		// to the log. A failed send shouldn't use up the cooldown either.
+		logError(fmt.Sprintf("Failed to re-send notification for backup %s", b.ID), err)
+		if err := h.repo.ClearNotificationState(dbConfig.ID, models.NotificationEventBackupResend); err != nil {
+			logError("Failed to reset notification cooldown", err)
+		}
+		writeErrorCode(w, http.StatusBadGateway, models.ErrCodeUpstream, "notification delivery failed")
+		return
+	}
+
+	h.logActivity(userID, models.ActionBackupNotificationResent, models.LogLevelInfo,
+		"backup", &b.ID, b.Name,
+		fmt.Sprintf("Re-sent %s notification for backup %q via %q", b.Status, b.Name, notifConfig.Name),
+		fmt.Sprintf(`{"notification_id":"%s","channels":"%s"}`, notifConfig.ID, strings.Join(channels, ",")),
+		getIPAddress(r))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "notification sent",
+		"channels": channels,
+	})
+}
+
+// sendBackupNotification sends b's outcome the way the backup run did.
+func sendBackupNotification(notifier notification.Notifier, dbName string, b *models.Backup) error {
+	if b.Status == models.BackupStatusFailed {
+		msg := "unknown error"
+		if b.ErrorMessage != nil && *b.ErrorMessage != "" {
+			msg = *b.ErrorMessage
+		}
+		return notifier.SendBackupFailure(dbName, msg)
+	}
+	var size int64
+	if b.SizeBytes != nil {
+		size = *b.SizeBytes
+	}
+	duration := "unknown"
+	if b.CompletedAt != nil {
+		duration = b.CompletedAt.Sub(b.StartedAt).Round(time.Second).String()
+	}
+	return notifier.SendBackupSuccess(dbName, size, duration)
+}
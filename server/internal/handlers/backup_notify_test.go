@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+)
+
+// recordingNotifier captures the backup notification it was asked to send.
+type recordingNotifier struct {
+	notification.Notifier
+	success  bool
+	size     int64
+	duration string
+	errMsg   string
+}
+
+func (n *recordingNotifier) SendBackupSuccess(_ string, sizeBytes int64, duration string) error {
+	n.success, n.size, n.duration = true, sizeBytes, duration
+	return nil
+}
+
+func (n *recordingNotifier) SendBackupFailure(_, errorMsg string) error {
+	n.errMsg = errorMsg
+	return nil
+}
+
+func TestSendBackupNotification(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	completed := started.Add(90 * time.Second)
+	size := int64(2048)
+
+	var n recordingNotifier
+	ok := &models.Backup{Status: models.BackupStatusSuccess, SizeBytes: &size, StartedAt: started, CompletedAt: &completed}
+	if err := sendBackupNotification(&n, "prod", ok); err != nil {
+		t.Fatal(err)
+	}
+	if !n.success || n.size != size || n.duration != "1m30s" {
+		t.Errorf("success notification = %+v, want size %d and duration 1m30s", n, size)
+	}
+
+	n = recordingNotifier{}
+	failed := &models.Backup{Status: models.BackupStatusFailed, StartedAt: started}
+	if err := sendBackupNotification(&n, "prod", failed); err != nil {
+		t.Fatal(err)
+	}
+	if n.success || n.errMsg != "unknown error" {
+		t.Errorf("failure notification = %+v, want the unknown error message", n)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// PinBackup godoc
+// @Summary Pin a backup
+// @Description Exclude a backup from the rotation policy so it is kept until unpinned. Pinned backups do not count toward a count-based policy.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 200 {object} models.Backup "Pinned backup"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 409 {object} map[string]string "Backup has been deleted"
+// @Router /backups/{id}/pin [post]
+func (h *Handler) PinBackup(w http.ResponseWriter, r *http.Request) {
+	h.setBackupPinned(w, r, true)
+}
+
+// UnpinBackup godoc
+// @Summary Unpin a backup
+// @Description Return a pinned backup to the rotation policy. It may be removed by the next cleanup.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 200 {object} models.Backup "Unpinned backup"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Router /backups/{id}/pin [delete]
+func (h *Handler) UnpinBackup(w http.ResponseWriter, r *http.Request) {
+	h.setBackupPinned(w, r, false)
+}
+
+func (h *Handler) setBackupPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if pinned && backup.Status == models.BackupStatusDeleted {
+		writeErrorCode(w, http.StatusConflict, models.ErrCodeBackupDeleted, "backup has been deleted")
+		return
+	}
+
+	if backup.Pinned != pinned {
+		if err := h.repo.SetBackupPinned(backup.ID, pinned); err != nil {
+			logError("Failed to update backup pin", err)
+			writeError(w, http.StatusInternalServerError, "failed to update backup")
+			return
+		}
+		backup.Pinned = pinned
+
+		action, verb := models.ActionBackupPinned, "Pinned"
+		if !pinned {
+			action, verb = models.ActionBackupUnpinned, "Unpinned"
+		}
+		h.logActivity(userID, action, models.LogLevelInfo,
+			"backup", &backup.ID, backup.Name,
+			fmt.Sprintf("%s backup %q of database %q", verb, backup.Name, backup.Database.Name),
+			"", getIPAddress(r))
+	}
+
+	writeJSON(w, http.StatusOK, backup)
+}
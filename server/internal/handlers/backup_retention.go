@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// ManualBackupRequest is the optional body of POST /databases/{id}/backup.
+type ManualBackupRequest struct {
+	// RetainUntil keeps the backup out of rotation until this time.
+	RetainUntil *time.Time `json:"retain_until,omitempty" example:"2026-12-31T00:00:00Z"`
+}
+
+// UpdateBackupRequest is the body of PATCH /backups/{id}. A null or missing
+// retain_until clears the hold.
+type UpdateBackupRequest struct {
+	RetainUntil *time.Time `json:"retain_until" example:"2026-12-31T00:00:00Z"`
+}
+
+// validateRetainUntil rejects holds that would already have expired.
+func validateRetainUntil(t *time.Time) error {
+	if t != nil && !t.After(time.Now()) {
+		return errors.New("retain_until must be in the future")
+	}
+	return nil
+}
+
+// UpdateBackup godoc
+// @Summary Update a backup's retention hold
+// @Description Set or clear retain_until. Until that time the backup is skipped by the rotation policy and does not count toward it.
+// @Tags Backups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Param request body UpdateBackupRequest true "Retention hold"
+// @Success 200 {object} models.Backup "Updated backup"
+// @Failure 400 {object} map[string]string "Invalid ID or request body"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 409 {object} map[string]string "Backup has been deleted"
+// @Router /backups/{id} [patch]
+func (h *Handler) UpdateBackup(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	var req UpdateBackupRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+	if err := validateRetainUntil(req.RetainUntil); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if req.RetainUntil != nil && backup.Status == models.BackupStatusDeleted {
+		writeErrorCode(w, http.StatusConflict, models.ErrCodeBackupDeleted, "backup has been deleted")
+		return
+	}
+
+	if err := h.repo.SetBackupRetainUntil(backup.ID, req.RetainUntil); err != nil {
+		logError("Failed to update backup retention", err)
+		writeError(w, http.StatusInternalServerError, "failed to update backup")
+		return
+	}
+	backup.RetainUntil = req.RetainUntil
+
+	desc := fmt.Sprintf("Cleared retention hold on backup %q", backup.Name)
+	if req.RetainUntil != nil {
+		desc = fmt.Sprintf("Backup %q held until %s", backup.Name, req.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	h.logActivity(userID, models.ActionBackupRetentionUpdated, models.LogLevelInfo,
+		"backup", &backup.ID, backup.Name, desc, "", getIPAddress(r))
+
+	writeJSON(w, http.StatusOK, backup)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/backup"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// RetryBackupUpload godoc
+// @Summary Retry a failed backup upload
+// @Description Re-upload the dump of a backup whose upload failed, without running pg_dump again. Only available while the dump is still kept on disk (see STORAGE_UPLOAD_RETRY_MINUTES).
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 202 {object} models.Backup "Upload retry started"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 409 {object} map[string]string "Backup did not fail or its dump is no longer available"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
+// @Router /backups/{id}/retry-upload [post]
+func (h *Handler) RetryBackupUpload(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+	if h.rejectDuringMaintenance(w) {
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	b, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if b == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if b.Status != models.BackupStatusFailed {
+		writeError(w, http.StatusConflict, "only failed backups can be retried")
+		return
+	}
+	if !h.backupSvc.HasRetainedUpload(b.ID) {
+		writeError(w, http.StatusConflict, "dump is no longer available; trigger a new backup")
+		return
+	}
+
+	go func() {
+		if err := h.backupSvc.RetryUpload(b.ID); errors.Is(err, backup.ErrNoRetainedUpload) {
+			logInfo("Upload retry for backup %s skipped: %v", b.ID, err)
+		}
+		// Other errors are already logged and audited by the service.
+	}()
+
+	h.logActivity(userID, models.ActionBackupUploadRetried, models.LogLevelInfo,
+		"backup", &b.ID, b.Name, fmt.Sprintf("Upload retry started for backup %q", b.Name), "", getIPAddress(r))
+
+	b.Status = models.BackupStatusRunning
+	writeJSON(w, http.StatusAccepted, b)
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+const (
+	// shareTokenBytes is the entropy of a share link token (hex-encoded to
+	// 64 characters).
+	shareTokenBytes = 32
+	// defaultShareTTL applies when a share request gives no expiry.
+	defaultShareTTL = 24 * time.Hour
+)
+
+// BackupShareResponse is returned once when a share link is created. The
+// token is never stored or shown again; only its hash is kept.
+type BackupShareResponse struct {
+	ID           string    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Token        string    `json:"token" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	ShareURL     string    `json:"share_url" example:"/api/v1/share/9f86d0..."`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxDownloads *int      `json:"max_downloads,omitempty" example:"1"`
+}
+
+// hashShareToken returns the hex SHA-256 of a share token. Like trigger
+// tokens, it is high-entropy random data, so an unsalted hash suffices.
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateBackupShare godoc
+// @Summary Create a share link for a backup
+// @Description Create a link that lets anyone holding it download this backup without an account, e.g. a contractor. It expires after expires_in_hours (default 24, max 168) and, when max_downloads is set, after that many downloads. The token is only returned in this response. Not available to demo users.
+// @Tags Backups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Param body body models.BackupShareRequest false "Expiry and download limit"
+// @Success 201 {object} BackupShareResponse "New share link"
+// @Failure 400 {object} validator.ValidationErrorResponse "Invalid ID or body, or the backup can't be downloaded"
+// @Failure 403 {object} models.APIError "Demo users and viewers cannot share backups"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /backups/{id}/share [post]
+func (h *Handler) CreateBackupShare(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if denyWrite(w, r, "share backups") {
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	var req models.BackupShareRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+	if validationErr, err := h.validator.Validate(&req); validationErr != nil || err != nil {
+		if validationErr != nil {
+			writeValidationError(w, validationErr)
+			return
+		}
+		logError("Validation error", err)
+		writeError(w, http.StatusInternalServerError, "validation error")
+		return
+	}
+
+	b, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if b == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if b.Status != models.BackupStatusSuccess || b.StoragePath == "" {
+		writeError(w, http.StatusBadRequest, "only successful backups can be shared")
+		return
+	}
+
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		logError("generate share token", err)
+		writeError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	ttl := defaultShareTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	share := &models.BackupShare{
+		BackupID:     b.ID,
+		UserID:       *userID,
+		TokenHash:    hashShareToken(token),
+		ExpiresAt:    time.Now().Add(ttl),
+		MaxDownloads: req.MaxDownloads,
+	}
+	if err := h.repo.CreateBackupShare(share); err != nil {
+		logError("store share link", err)
+		writeError(w, http.StatusInternalServerError, "failed to store share link")
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]any{
+		"share_id":      share.ID,
+		"expires_at":    share.ExpiresAt,
+		"max_downloads": share.MaxDownloads,
+	})
+	h.logActivity(userID, models.ActionBackupShareCreated, models.LogLevelWarning,
+		"backup", &b.ID, b.Name,
+		fmt.Sprintf("Share link created for backup %q, expiring %s", b.Name, share.ExpiresAt.Format(time.RFC3339)),
+		string(meta), getIPAddress(r))
+
+	writeJSON(w, http.StatusCreated, BackupShareResponse{
+		ID:           share.ID.String(),
+		Token:        token,
+		ShareURL:     "/api/v1/share/" + token,
+		ExpiresAt:    share.ExpiresAt,
+		MaxDownloads: share.MaxDownloads,
+	})
+}
+
+// RevokeBackupShare godoc
+// @Summary Revoke a backup share link
+// @Description Stop a share link from serving further downloads. The link is identified by its token or by the id returned when it was created.
+// @Tags Backups
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Param token path string true "Share token or share ID"
+// @Success 204 "Share link revoked"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} models.APIError "Demo users and viewers cannot manage share links"
+// @Failure 404 {object} map[string]string "Backup or share link not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /backups/{id}/share/{token} [delete]
+func (h *Handler) RevokeBackupShare(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if denyWrite(w, r, "manage share links") {
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	vars := mux.Vars(r)
+	id, err := parseUUID(vars["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	b, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if b == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+
+	// Tokens are 64 hex characters, so they never parse as a share ID.
+	ref := vars["token"]
+	var share *models.BackupShare
+	if shareID, err := parseUUID(ref); err == nil {
+		share, err = h.repo.RevokeBackupShare(b.ID, &shareID, "")
+	} else {
+		share, err = h.repo.RevokeBackupShare(b.ID, nil, hashShareToken(ref))
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke share link")
+		return
+	}
+	if share == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "share link not found")
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]any{
+		"share_id":       share.ID,
+		"download_count": share.DownloadCount,
+	})
+	h.logActivity(userID, models.ActionBackupShareRevoked, models.LogLevelInfo,
+		"backup", &b.ID, b.Name,
+		fmt.Sprintf("Share link for backup %q revoked after %d download(s)", b.Name, share.DownloadCount),
+		string(meta), getIPAddress(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DownloadSharedBackup godoc
+// @Summary Download a shared backup
+// @Description Stream the backup a share link points at. No account is needed; the token in the URL is the credential. Each download counts against the link's limit.
+// @Tags Backups
+// @Produce application/octet-stream
+// @Param token path string true "Share token"
+// @Success 200 {file} file "Backup file"
+// @Failure 404 {object} map[string]string "Unknown, expired, revoked or used-up link"
+// @Failure 429 {object} map[string]string "Rate limited"
+// @Failure 502 {object} map[string]string "Backup could not be read from storage"
+// @Router /share/{token} [get]
+func (h *Handler) DownloadSharedBackup(w http.ResponseWriter, r *http.Request) {
+	ip := getIPAddress(r)
+
+	// Every way a link can fail gets the same answer, so the endpoint
+	// doesn't tell a guesser which tokens once existed.
+	notFound := func() {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "share link not found or no longer valid")
+	}
+
+	token := mux.Vars(r)["token"]
+	if len(token) != 2*shareTokenBytes {
+		notFound()
+		return
+	}
+	share, err := h.repo.GetBackupShareByTokenHash(hashShareToken(token))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get share link")
+		return
+	}
+	if share == nil || !share.Usable(time.Now()) {
+		logInfo("Rejected share link download from %s", ip)
+		notFound()
+		return
+	}
+
+	backup, err := h.repo.GetBackup(share.BackupID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil || backup.StoragePath == "" || backup.Status != models.BackupStatusSuccess {
+		notFound()
+		return
+	}
+	dbCfg, err := h.repo.GetDatabaseConfig(backup.DatabaseID)
+	if err != nil || dbCfg == nil {
+		writeError(w, http.StatusInternalServerError, "failed to load database config")
+		return
+	}
+	client, err := storage.NewStorageClient(&dbCfg.Storage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to init storage client")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), streamDownloadTimeout)
+	defer cancel()
+	body, err := client.OpenObject(ctx, backup.StoragePath)
+	if err != nil {
+		logError("Failed to open shared backup", err)
+		writeError(w, http.StatusBadGateway, "failed to read backup from storage")
+		return
+	}
+	defer body.Close()
+
+	// Count the download only once the object is readable, so a storage
+	// outage doesn't use up a one-time link.
+	claimed, err := h.repo.ClaimBackupShareDownload(share.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to record download")
+		return
+	}
+	if !claimed {
+		notFound()
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]any{
+		"share_id": share.ID,
+		"download": share.DownloadCount + 1,
+	})
+	h.logActivity(&share.UserID, models.ActionBackupShareDownloaded, models.LogLevelWarning,
+		"backup", &backup.ID, backup.Name,
+		fmt.Sprintf("Backup %q downloaded through a share link", backup.Name),
+		string(meta), ip)
+
+	serveSharedObject(w, body, downloadFilename(backup))
+}
+
+// serveSharedObject streams a shared backup's stored object as a download.
+// The server's WriteTimeout would cut it short, so the write deadline is
+// moved to streamDownloadTimeout first.
+func serveSharedObject(w http.ResponseWriter, body io.Reader, filename string) {
+	extendWriteDeadline(w, streamDownloadTimeout)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		logError("Shared backup stream interrupted", err)
+	}
+}
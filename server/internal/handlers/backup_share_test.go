@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+func TestBackupShareUsable(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	one := 1
+	revoked := now.Add(-time.Minute)
+
+	cases := []struct {
+		name  string
+		share models.BackupShare
+		want  bool
+	}{
+		{"fresh", models.BackupShare{ExpiresAt: now.Add(time.Hour)}, true},
+		{"expired", models.BackupShare{ExpiresAt: now}, false},
+		{"revoked", models.BackupShare{ExpiresAt: now.Add(time.Hour), RevokedAt: &revoked}, false},
+		{"download left", models.BackupShare{ExpiresAt: now.Add(time.Hour), MaxDownloads: &one}, true},
+		{"used up", models.BackupShare{ExpiresAt: now.Add(time.Hour), MaxDownloads: &one, DownloadCount: 1}, false},
+	}
+	for _, c := range cases {
+		if got := c.share.Usable(now); got != c.want {
+			t.Errorf("%s: Usable = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestHashShareToken(t *testing.T) {
+	a, b := hashShareToken("token-a"), hashShareToken("token-b")
+	if len(a) != 64 || a == b || a != hashShareToken("token-a") {
+		t.Errorf("hashShareToken: %q, %q", a, b)
+	}
+}
+
+// slowReader yields its chunks with a pause before each one after the first.
+type slowReader struct {
+	chunks []string
+	pause  time.Duration
+	n      int
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.n >= len(r.chunks) {
+		return 0, io.EOF
+	}
+	if r.n > 0 {
+		time.Sleep(r.pause)
+	}
+	n := copy(p, r.chunks[r.n])
+	r.n++
+	return n, nil
+}
+
+func TestServeSharedObjectOutlastsWriteTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &slowReader{chunks: []string{"-- dump start\n", "-- dump end\n"}, pause: 300 * time.Millisecond}
+		serveSharedObject(w, body, "prod.dump")
+	}))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if string(got) != "-- dump start\n-- dump end\n" {
+		t.Errorf("body = %q, want the whole object", got)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="prod.dump"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+}
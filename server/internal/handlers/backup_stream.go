@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// streamDownloadTimeout bounds a proxied download. It matches the storage
+// client's own download budget.
+const streamDownloadTimeout = 30 * time.Minute
+
+// newDownloadKey returns a random HMAC key for download tickets. It lives
+// only in memory, so a restart invalidates outstanding tickets; they are
+// five-minute credentials, so that is acceptable.
+func newDownloadKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("download ticket key: %v", err))
+	}
+	return key
+}
+
+// signDownloadTicket returns "<expiry-unix>.<mac>" binding a backup ID to an
+// expiry. It stands in for the presigned storage URL when the API has to
+// proxy the bytes itself.
+func (h *Handler) signDownloadTicket(backupID uuid.UUID, expiresAt time.Time) string {
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+	return exp + "." + base64.RawURLEncoding.EncodeToString(h.downloadTicketMAC(backupID, exp))
+}
+
+func (h *Handler) downloadTicketMAC(backupID uuid.UUID, exp string) []byte {
+	mac := hmac.New(sha256.New, h.downloadKey)
+	mac.Write([]byte("backup-download:" + backupID.String() + ":" + exp))
+	return mac.Sum(nil)
+}
+
+// verifyDownloadTicket reports whether ticket was signed for backupID and
+// has not expired.
+func (h *Handler) verifyDownloadTicket(backupID uuid.UUID, ticket string, now time.Time) bool {
+	exp, sig, ok := strings.Cut(ticket, ".")
+	if !ok {
+		return false
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > expUnix {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, h.downloadTicketMAC(backupID, exp))
+}
+
+// extendWriteDeadline lifts the server's WriteTimeout, which is far shorter
+// than a large download, for a response that streams a backup or waits on
+// slow work before answering: the write deadline becomes d from now. Call
+// it before writing the headers.
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d)); err != nil {
+		logError("Failed to extend write deadline", err)
+	}
+}
+
+// isStoredCompressed reports whether the stored object is already
+// compressed: gzipped plain dumps and pg_dump's custom format.
+func isStoredCompressed(b *models.Backup) bool {
+	return b.Compression != "" || b.DumpFormat == models.DumpFormatCustom
+}
+
+// downloadFilename is the name a browser should save the stored object as.
+func downloadFilename(b *models.Backup) string {
+	switch {
+	case b.DumpFormat == models.DumpFormatCustom:
+		return b.Name + ".dump"
+	case b.Compression == models.CompressionGzip:
+		return b.Name + ".sql.gz"
+	default:
+		return b.Name + ".sql"
+	}
+}
+
+// DownloadBackup godoc
+// @Summary Stream a backup through gzip
+// @Description Streams an uncompressed backup gzipped on the fly. The URL, including its short-lived ticket, is returned by the download OTP verify step when compress=gzip was requested; no bearer token is needed.
+// @Tags Backups
+// @Produce application/gzip
+// @Param id path string true "Backup ID (UUID)"
+// @Param ticket query string true "Signed download ticket"
+// @Param compress query string true "Must be gzip"
+// @Success 200 {file} file "Gzipped backup"
+// @Failure 400 {object} map[string]string "Invalid ID or compression"
+// @Failure 401 {object} map[string]string "Invalid or expired ticket"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Router /backups/{id}/download [get]
+func (h *Handler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+	if r.URL.Query().Get("compress") != models.CompressionGzip {
+		writeError(w, http.StatusBadRequest, "compress must be gzip")
+		return
+	}
+	if !h.verifyDownloadTicket(id, r.URL.Query().Get("ticket"), time.Now()) {
+		writeError(w, http.StatusUnauthorized, "invalid or expired download ticket")
+		return
+	}
+
+	backup, err := h.repo.GetBackup(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil || backup.StoragePath == "" || backup.Status != models.BackupStatusSuccess {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if isStoredCompressed(backup) {
+		writeError(w, http.StatusBadRequest, "backup is already compressed; use the direct download URL")
+		return
+	}
+
+	dbCfg, err := h.repo.GetDatabaseConfig(backup.DatabaseID)
+	if err != nil || dbCfg == nil {
+		writeError(w, http.StatusInternalServerError, "failed to load database config")
+		return
+	}
+	client, err := storage.NewStorageClient(&dbCfg.Storage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to init storage client")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), streamDownloadTimeout)
+	defer cancel()
+	body, err := client.OpenObject(ctx, backup.StoragePath)
+	if err != nil {
+		logError("Failed to open backup for streaming", err)
+		writeError(w, http.StatusBadGateway, "failed to read backup from storage")
+		return
+	}
+	defer body.Close()
+
+	// The response is a .gz file rather than Content-Encoding: gzip, so
+	// clients that don't decode transfer encodings still save a correctly
+	// named archive.
+	extendWriteDeadline(w, streamDownloadTimeout)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.gz"`, downloadFilename(backup)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	zw := gzip.NewWriter(w)
+	if _, err := io.Copy(zw, body); err != nil {
+		logError("Backup stream interrupted", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		logError("Backup stream interrupted", err)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowStreamServer serves two chunks with a pause longer than the server's
+// WriteTimeout between them, calling extendWriteDeadline first if extend.
+func slowStreamServer(t *testing.T, extend bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if extend {
+			extendWriteDeadline(w, 5*time.Second)
+		}
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "first;")
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "second")
+	}))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func readAll(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestExtendWriteDeadlineOutlastsWriteTimeout(t *testing.T) {
+	if got := readAll(t, slowStreamServer(t, true).URL); got != "first;second" {
+		t.Errorf("body = %q, want the whole stream", got)
+	}
+	// Without the extension the server's WriteTimeout cuts the stream,
+	// which is what this guards against.
+	if got := readAll(t, slowStreamServer(t, false).URL); strings.Contains(got, "second") {
+		t.Errorf("body = %q; the write timeout did not apply, so the test proves nothing", got)
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// triggerTokenBytes is the entropy of a trigger URL token (hex-encoded to 64
+// characters).
+const triggerTokenBytes = 32
+
+// TriggerTokenResponse is returned once when a trigger URL is created. The
+// token is never stored or shown again; only its hash is kept.
+type TriggerTokenResponse struct {
+	Token      string `json:"token" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	TriggerURL string `json:"trigger_url" example:"/api/v1/databases/550e8400-e29b-41d4-a716-446655440000/trigger?token=9f86d0..."`
+}
+
+// manualTriggerAudit is the activity log content for a manual trigger.
+type manualTriggerAudit struct {
+	// OnBehalf is set when the actor is not the database owner (an admin);
+	// the owner then gets their own entry with OwnerDescription.
+	OnBehalf         bool
+	Description      string
+	OwnerDescription string
+	Metadata         string
+}
+
+// describeManualTrigger builds the audit entries for actorID triggering a
+// backup of config.
+func describeManualTrigger(actorID uuid.UUID, config *models.DatabaseConfig) manualTriggerAudit {
+	if actorID == config.UserID {
+		return manualTriggerAudit{
+			Description: fmt.Sprintf("Manual backup triggered for database '%s'", config.Name),
+		}
+	}
+	meta, _ := json.Marshal(map[string]string{
+		"triggered_by_user_id": actorID.String(),
+		"owner_user_id":        config.UserID.String(),
+	})
+	return manualTriggerAudit{
+		OnBehalf:         true,
+		Description:      fmt.Sprintf("Manual backup triggered on behalf of the owner of database '%s'", config.Name),
+		OwnerDescription: fmt.Sprintf("An administrator triggered a backup of your database '%s'", config.Name),
+		Metadata:         string(meta),
+	}
+}
+
+// hashTriggerToken returns the hex SHA-256 of a trigger token. The token is
+// high-entropy random data, so an unsalted hash is sufficient.
+func hashTriggerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateTriggerToken godoc
+// @Summary Create or rotate a database's trigger URL
+// @Description Generate a secret token that lets external systems (e.g. CI) start a backup of this database without a login. Any previous token stops working. The token is only returned in this response.
+// @Tags Databases
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Success 201 {object} TriggerTokenResponse "New trigger token"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Database config not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /databases/{id}/trigger-token [post]
+func (h *Handler) CreateTriggerToken(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	config, err := h.repo.GetDatabaseConfigByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return
+	}
+
+	buf := make([]byte, triggerTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		logError("generate trigger token", err)
+		writeError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+	token := hex.EncodeToString(buf)
+	hash := hashTriggerToken(token)
+	if err := h.repo.SetDatabaseTriggerTokenHash(config.ID, &hash); err != nil {
+		logError("store trigger token", err)
+		writeError(w, http.StatusInternalServerError, "failed to store token")
+		return
+	}
+
+	h.logActivity(userID, models.ActionTriggerTokenCreated, models.LogLevelInfo,
+		"database", &config.ID, config.Name,
+		fmt.Sprintf("Trigger URL created for database '%s'", config.Name),
+		"", getIPAddress(r))
+
+	writeJSON(w, http.StatusCreated, TriggerTokenResponse{
+		Token:      token,
+		TriggerURL: fmt.Sprintf("/api/v1/databases/%s/trigger?token=%s", config.ID, token),
+	})
+}
+
+// RevokeTriggerToken godoc
+// @Summary Revoke a database's trigger URL
+// @Description Disable the trigger URL so the current token can no longer start backups
+// @Tags Databases
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Success 204 "Trigger URL revoked"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Database config not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /databases/{id}/trigger-token [delete]
+func (h *Handler) RevokeTriggerToken(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	config, err := h.repo.GetDatabaseConfigByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return
+	}
+
+	if err := h.repo.SetDatabaseTriggerTokenHash(config.ID, nil); err != nil {
+		logError("revoke trigger token", err)
+		writeError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+
+	h.logActivity(userID, models.ActionTriggerTokenRevoked, models.LogLevelInfo,
+		"database", &config.ID, config.Name,
+		fmt.Sprintf("Trigger URL revoked for database '%s'", config.Name),
+		"", getIPAddress(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TriggerBackupByToken godoc
+// @Summary Trigger a backup via the database's trigger URL
+// @Description Start a backup authenticated by the per-database trigger token instead of a JWT, so CI can call it with a plain curl. Rate-limited per source IP.
+// @Tags Backups
+// @Produce json
+// @Param id path string true "Database Config ID (UUID)"
+// @Param token query string true "Trigger token"
+// @Success 202 {object} models.Backup "Backup initiated successfully"
+// @Failure 401 {object} map[string]string "Missing or invalid token"
+// @Failure 409 {object} map[string]string "Outside the database's backup window"
+// @Failure 429 {object} map[string]string "Rate limited"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
+// @Router /databases/{id}/trigger [post]
+func (h *Handler) TriggerBackupByToken(w http.ResponseWriter, r *http.Request) {
+	ip := getIPAddress(r)
+
+	// Unknown IDs and bad tokens get the same answer so the endpoint does
+	// not reveal which databases exist.
+	id, err := parseUUID(mux.Vars(r)["id"])
+	token := r.URL.Query().Get("token")
+	if err != nil || token == "" {
+		writeError(w, http.StatusUnauthorized, "invalid trigger token")
+		return
+	}
+
+	config, err := h.repo.GetDatabaseConfig(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if config == nil || config.TriggerTokenHash == nil ||
+		subtle.ConstantTimeCompare([]byte(hashTriggerToken(token)), []byte(*config.TriggerTokenHash)) != 1 {
+		logInfo("Rejected backup trigger for %s from %s", id, ip)
+		writeError(w, http.StatusUnauthorized, "invalid trigger token")
+		return
+	}
+
+	if h.rejectDuringMaintenance(w) {
+		return
+	}
+
+	if h.rejectOutsideBackupWindow(w, config) {
+		return
+	}
+
+	backup, _, err := h.startBackup(config, models.BackupTriggerWebhook, nil, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create backup")
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]string{"source": string(models.BackupTriggerWebhook)})
+	h.logActivity(&config.UserID, models.ActionBackupTriggered, models.LogLevelInfo,
+		"backup", &backup.ID, config.Name,
+		fmt.Sprintf("Backup triggered via trigger URL for database '%s' from %s", config.Name, ip),
+		string(meta), ip)
+
+	writeJSON(w, http.StatusAccepted, backup)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+func TestDescribeManualTrigger_Owner(t *testing.T) {
+	owner := uuid.New()
+	cfg := &models.DatabaseConfig{UserID: owner, Name: "orders"}
+
+	got := describeManualTrigger(owner, cfg)
+	if got.OnBehalf {
+		t.Fatal("owner trigger marked as on-behalf")
+	}
+	if got.Metadata != "" || got.OwnerDescription != "" {
+		t.Errorf("owner trigger carries on-behalf details: %+v", got)
+	}
+}
+
+// TestDescribeManualTrigger_AdminOnBehalf covers an admin triggering a
+// backup of another user's database: both IDs are recorded and the owner
+// gets a separate, readable entry.
+func TestDescribeManualTrigger_AdminOnBehalf(t *testing.T) {
+	owner, admin := uuid.New(), uuid.New()
+	cfg := &models.DatabaseConfig{UserID: owner, Name: "orders"}
+
+	got := describeManualTrigger(admin, cfg)
+	if !got.OnBehalf {
+		t.Fatal("admin trigger on another user's database not marked on-behalf")
+	}
+
+	var meta map[string]string
+	if err := json.Unmarshal([]byte(got.Metadata), &meta); err != nil {
+		t.Fatalf("metadata is not JSON: %v", err)
+	}
+	if meta["triggered_by_user_id"] != admin.String() {
+		t.Errorf("triggered_by_user_id = %q, want %s", meta["triggered_by_user_id"], admin)
+	}
+	if meta["owner_user_id"] != owner.String() {
+		t.Errorf("owner_user_id = %q, want %s", meta["owner_user_id"], owner)
+	}
+	if !strings.Contains(got.OwnerDescription, "orders") || !strings.Contains(got.OwnerDescription, "administrator") {
+		t.Errorf("owner description %q does not name the database and the admin", got.OwnerDescription)
+	}
+}
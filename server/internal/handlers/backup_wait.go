@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+const (
+	// defaultBackupWait and maxBackupWait bound how long backup-now-and-wait
+	// holds the request open.
+	defaultBackupWait = 15 * time.Minute
+	maxBackupWait     = time.Hour
+	// backupWaitSlack covers reading the final record and writing the
+	// response after the wait ends.
+	backupWaitSlack = 30 * time.Second
+)
+
+// TriggerBackupAndWait godoc
+// @Summary Trigger a backup and wait for it to finish
+// @Description Synchronous variant of POST /databases/{id}/backup for scripts, e.g. a pre-deploy backup. Starts a manual backup and holds the request until it ends, then returns the final record with 200; check its status for success or failure. If the backup is still running after timeout seconds (default 900, max 3600) it returns 504 with the in-progress record and the backup carries on; poll GET /backups/{id} from there.
+// @Tags Backups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Param timeout query int false "Seconds to wait (1-3600, default 900)"
+// @Param request body ManualBackupRequest false "Optional backup options"
+// @Success 200 {object} models.Backup "Backup finished; status is success or failed"
+// @Failure 400 {object} map[string]string "Invalid ID, timeout or request body"
+// @Failure 404 {object} map[string]string "Database config not found"
+// @Failure 409 {object} map[string]string "Outside the database's backup window"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
+// @Failure 504 {object} models.Backup "Backup still running after the timeout"
+// @Router /databases/{id}/backup-now-and-wait [post]
+func (h *Handler) TriggerBackupAndWait(w http.ResponseWriter, r *http.Request) {
+	wait, err := parseBackupWait(r.URL.Query().Get("timeout"))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	backup, done, ok := h.triggerManualBackup(w, r)
+	if !ok {
+		return
+	}
+
+	// The server's write timeout is far shorter than a backup.
+	extendWriteDeadline(w, wait+backupWaitSlack)
+
+	status, ok := waitForBackup(r.Context(), done, wait)
+	if !ok {
+		// Client gave up; the backup carries on.
+		return
+	}
+
+	final, err := h.repo.GetBackup(backup.ID)
+	if err != nil {
+		logError("Failed to reload backup after waiting", err)
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if final == nil {
+		// Deleted while we waited.
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	writeJSON(w, status, final)
+}
+
+// waitForBackup waits up to wait for done and returns the status to answer
+// with: 200 when the backup ended, 504 when it is still running. ok is
+// false when ctx ended first and nothing should be written.
+func waitForBackup(ctx context.Context, done <-chan struct{}, wait time.Duration) (status int, ok bool) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return http.StatusOK, true
+	case <-timer.C:
+		return http.StatusGatewayTimeout, true
+	case <-ctx.Done():
+		return 0, false
+	}
+}
+
+// parseBackupWait reads the timeout query parameter in seconds.
+func parseBackupWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultBackupWait, nil
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil || secs < 1 || time.Duration(secs)*time.Second > maxBackupWait {
+		return 0, fmt.Errorf("timeout must be between 1 and %d seconds", int(maxBackupWait.Seconds()))
+	}
+	return time.Duration(secs) * time.Second, nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseBackupWait(t *testing.T) {
+	valid := map[string]time.Duration{
+		"":     defaultBackupWait,
+		"1":    time.Second,
+		"3600": time.Hour,
+	}
+	for in, want := range valid {
+		got, err := parseBackupWait(in)
+		if err != nil || got != want {
+			t.Errorf("parseBackupWait(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"0", "-5", "3601", "10m", "abc"} {
+		if _, err := parseBackupWait(in); err == nil {
+			t.Errorf("parseBackupWait(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestWaitForBackup(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	if status, ok := waitForBackup(context.Background(), done, time.Minute); !ok || status != http.StatusOK {
+		t.Errorf("finished backup: status %d, ok %v; want 200, true", status, ok)
+	}
+
+	start := time.Now()
+	if status, ok := waitForBackup(context.Background(), make(chan struct{}), 50*time.Millisecond); !ok || status != http.StatusGatewayTimeout {
+		t.Errorf("running backup: status %d, ok %v; want 504, true", status, ok)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout of 50ms took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := waitForBackup(ctx, make(chan struct{}), time.Minute); ok {
+		t.Error("cancelled request: ok = true, want false so nothing is written")
+	}
+}
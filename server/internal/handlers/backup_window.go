@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// rejectOutsideBackupWindow answers 409 and returns true when config has a
+// backup window that doesn't include now. Scheduled runs are skipped by the
+// backup service instead; this makes manual and trigger-URL starts fail
+// loudly rather than creating a backup that is immediately skipped.
+func (h *Handler) rejectOutsideBackupWindow(w http.ResponseWriter, config *models.DatabaseConfig) bool {
+	if config.InBackupWindow(time.Now()) {
+		return false
+	}
+	logInfo("Refused backup for %s: outside backup window %s", config.Name, config.BackupWindow)
+	writeErrorCode(w, http.StatusConflict, models.ErrCodeOutsideBackupWindow,
+		fmt.Sprintf("backups for this database may only start between %s (server time)", config.BackupWindow))
+	return true
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+	"github.com/monzim/db_proxy/v1/internal/repository"
+)
+
+// maxImportBodyBytes caps a POST /import document, which may carry
+// MaxImportItems configs.
+const maxImportBodyBytes = 4 << 20
+
+// importPlan is a checked ConfigDocument: the configs to create, with IDs
+// chosen up front so databases can point at storage and notification
+// configs from the same document, and a result per item.
+type importPlan struct {
+	storage       []*models.StorageConfig
+	notifications []*models.NotificationConfig
+	databases     []*models.DatabaseConfig
+	results       []models.ImportItemResult
+	ids           []uuid.UUID // the ID each result's config gets, in order
+	invalid       bool
+}
+
+// ImportConfigs godoc
+// @Summary Import storage, notification and database configs
+// @Description Create the configs described by a document like the one GET /export returns, owned by the caller. Databases refer to storage and notification configs by name: ones in the same document, or ones the caller already has. Every item is validated first; if any is invalid nothing is created and the response lists the errors per item. Otherwise all configs are created in one transaction.
+// @Tags Import/Export
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.ConfigDocument true "Configs to create"
+// @Success 201 {object} models.ImportResponse "All configs created"
+// @Failure 400 {object} models.ImportResponse "One or more items are invalid; nothing was created"
+// @Failure 409 {object} models.APIError "A storage config name was taken during the import"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /import [post]
+func (h *Handler) ImportConfigs(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if denyWrite(w, r, "import configurations") {
+		return
+	}
+
+	// Unknown fields are always rejected here: a misspelt key would
+	// otherwise import a config silently missing that setting.
+	var doc models.ConfigDocument
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
+		return
+	}
+	if doc.Version != models.ConfigDocumentVersion {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported document version %d; expected %d", doc.Version, models.ConfigDocumentVersion))
+		return
+	}
+	if n := len(doc.Storage) + len(doc.Notifications) + len(doc.Databases); n == 0 || n > models.MaxImportItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("a document must hold between 1 and %d configs", models.MaxImportItems))
+		return
+	}
+
+	existingStorage, err := h.repo.ListStorageConfigsByUser(*userID, false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list storage configs")
+		return
+	}
+	existingNotifications, err := h.repo.ListNotificationConfigsByUser(*userID, false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list notification configs")
+		return
+	}
+
+	plan := h.planImport(*userID, &doc, existingStorage, existingNotifications)
+	if plan.invalid {
+		writeJSON(w, http.StatusBadRequest, models.ImportResponse{Results: plan.results})
+		return
+	}
+
+	err = h.repo.ImportConfigs(plan.storage, plan.notifications, plan.databases)
+	var conflict *repository.ConflictError
+	if errors.As(err, &conflict) {
+		writeErrorCode(w, http.StatusConflict, models.ErrCodeConflict,
+			fmt.Sprintf("storage config name %q was taken during the import; nothing was created", conflict.Value))
+		return
+	}
+	if err != nil {
+		logError("Failed to import configs", err)
+		writeError(w, http.StatusInternalServerError, "failed to import configs; nothing was created")
+		return
+	}
+
+	for i := range plan.results {
+		plan.results[i].Status = models.ImportStatusCreated
+		plan.results[i].ID = &plan.ids[i]
+	}
+	ip := getIPAddress(r)
+	for _, s := range plan.storage {
+		h.logActivity(userID, models.ActionStorageCreated, models.LogLevelSuccess,
+			"storage", &s.ID, s.Name,
+			fmt.Sprintf("Storage configuration '%s' (%s) imported", s.Name, s.Provider), "", ip)
+	}
+	for _, n := range plan.notifications {
+		h.logActivity(userID, models.ActionNotificationCreated, models.LogLevelSuccess,
+			"notification", &n.ID, n.Name,
+			fmt.Sprintf("Notification config %q imported", n.Name), "", ip)
+	}
+	// The configs are committed; a database that can't be scheduled keeps
+	// its scheduler error for the UI, as a failed reschedule does.
+	offset := len(plan.storage) + len(plan.notifications)
+	for i, d := range plan.databases {
+		if err := h.scheduler.AddJob(d); err != nil {
+			plan.results[offset+i].Errors = append(plan.results[offset+i].Errors, "created, but scheduling failed: "+err.Error())
+		}
+		h.logActivity(userID, models.ActionDatabaseCreated, models.LogLevelSuccess,
+			"database", &d.ID, d.Name,
+			fmt.Sprintf("Database configuration '%s' imported with schedule: %s", d.Name, d.Schedule), "", ip)
+	}
+
+	writeJSON(w, http.StatusCreated, models.ImportResponse{Created: true, Results: plan.results})
+}
+
+// planImport checks every item of doc and resolves database references
+// against the document's configs first, then the user's existing ones.
+// Results are in document order: storage, notifications, databases.
+func (h *Handler) planImport(userID uuid.UUID, doc *models.ConfigDocument, existingStorage []*models.StorageConfig, existingNotifications []*models.NotificationConfig) *importPlan {
+	plan := &importPlan{}
+	addResult := func(kind string, index int, name string, id uuid.UUID, errs []string) {
+		res := models.ImportItemResult{Kind: kind, Index: index, Name: name, Status: models.ImportStatusSkipped, Errors: errs}
+		if len(errs) > 0 {
+			res.Status = models.ImportStatusInvalid
+			plan.invalid = true
+		}
+		plan.results = append(plan.results, res)
+		plan.ids = append(plan.ids, id)
+	}
+
+	storageIDs := make(map[string]uuid.UUID)
+	existingStorageIDs := make(map[string]uuid.UUID, len(existingStorage))
+	for _, s := range existingStorage {
+		existingStorageIDs[s.Name] = s.ID
+	}
+	for i := range doc.Storage {
+		input := &doc.Storage[i]
+		errs := h.validationMessages(input)
+		if _, ok := existingStorageIDs[input.Name]; ok {
+			errs = append(errs, fmt.Sprintf("a storage config named %q already exists", input.Name))
+		}
+		if _, ok := storageIDs[input.Name]; ok {
+			errs = append(errs, fmt.Sprintf("storage name %q appears more than once in the document", input.Name))
+		}
+		config := input.ToModel(userID)
+		config.ID = uuid.New()
+		storageIDs[input.Name] = config.ID
+		plan.storage = append(plan.storage, config)
+		addResult("storage", i, input.Name, config.ID, errs)
+	}
+
+	// Notification names aren't unique, so a reference to an existing one
+	// must match exactly one config.
+	notificationIDs := make(map[string]uuid.UUID)
+	existingNotificationIDs := make(map[string][]uuid.UUID, len(existingNotifications))
+	for _, n := range existingNotifications {
+		existingNotificationIDs[n.Name] = append(existingNotificationIDs[n.Name], n.ID)
+	}
+	for i := range doc.Notifications {
+		input := &doc.Notifications[i]
+		errs := h.validationMessages(input)
+		if input.DiscordWebhookURL != "" {
+			if err := notification.ValidateDiscordWebhookURL(input.DiscordWebhookURL); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if input.DiscordWebhookURL == "" && (input.TelegramBotToken == "" || input.TelegramChatID == "") {
+			errs = append(errs, "provide a Discord webhook URL, a Telegram bot_token+chat_id, or both")
+		}
+		if _, ok := notificationIDs[input.Name]; ok {
+			errs = append(errs, fmt.Sprintf("notification name %q appears more than once in the document", input.Name))
+		}
+		config := input.ToModel(userID)
+		config.ID = uuid.New()
+		notificationIDs[input.Name] = config.ID
+		plan.notifications = append(plan.notifications, config)
+		addResult("notification", i, input.Name, config.ID, errs)
+	}
+
+	for i := range doc.Databases {
+		item := &doc.Databases[i]
+		var errs []string
+
+		item.StorageID = uuid.Nil
+		if id, ok := storageIDs[item.Storage]; ok {
+			item.StorageID = id
+		} else if id, ok := existingStorageIDs[item.Storage]; ok {
+			item.StorageID = id
+		} else if item.Storage == "" {
+			errs = append(errs, "storage is required")
+		} else {
+			errs = append(errs, fmt.Sprintf("storage %q not found in the document or your storage configs", item.Storage))
+		}
+
+		item.NotificationID = nil
+		if item.Notification != "" {
+			if id, ok := notificationIDs[item.Notification]; ok {
+				item.NotificationID = &id
+			} else if ids := existingNotificationIDs[item.Notification]; len(ids) == 1 {
+				item.NotificationID = &ids[0]
+			} else if len(ids) > 1 {
+				errs = append(errs, fmt.Sprintf("notification %q matches %d of your notification configs; rename one first", item.Notification, len(ids)))
+			} else {
+				errs = append(errs, fmt.Sprintf("notification %q not found in the document or your notification configs", item.Notification))
+			}
+		}
+
+		// An unresolved storage is already reported; don't repeat it as
+		// "storage_id is required".
+		if item.StorageID == uuid.Nil {
+			item.StorageID = uuid.New()
+		}
+		errs = append(errs, h.validationMessages(&item.DatabaseConfigInput)...)
+		if err := item.RotationPolicy.Validate(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if err := item.ValidateBlobs(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if err := h.hostPolicy.Check(item.Host); err != nil {
+			errs = append(errs, err.Error())
+		}
+
+		config := item.ToModel(userID)
+		config.ID = uuid.New()
+		plan.databases = append(plan.databases, config)
+		addResult("database", i, item.Name, config.ID, errs)
+	}
+
+	return plan
+}
+
+// validationMessages runs the validator on v and returns its errors as
+// "field: message" strings, or nil when v is valid.
+func (h *Handler) validationMessages(v interface{}) []string {
+	resp, err := h.validator.Validate(v)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if resp == nil {
+		return nil
+	}
+	msgs := make([]string, 0, len(resp.Errors))
+	for _, e := range resp.Errors {
+		msgs = append(msgs, e.Field+": "+e.Message)
+	}
+	return msgs
+}
+
+// ExportConfigs godoc
+// @Summary Export storage, notification and database configs
+// @Description Return the caller's configs as a document POST /import accepts, for keeping a copy of the setup or moving it to another instance. Connection details are unmasked, but passwords, access and secret keys, webhook URLs and bot tokens are left empty. Requires X-2FA-Code when 2FA is enabled, otherwise a login within the last 5 minutes.
+// @Tags Import/Export
+// @Produce json
+// @Security BearerAuth
+// @Param X-2FA-Code header string false "Current TOTP code, required when 2FA is enabled"
+// @Success 200 {object} models.ConfigDocument "Configs without secrets"
+// @Failure 403 {object} models.APIError "Re-authentication required"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /export [get]
+func (h *TwoFactorHandler) ExportConfigs(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if !h.requireFreshAuth(w, r, *userID) {
+		return
+	}
+
+	storages, err := h.repo.ListStorageConfigsByUser(*userID, false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list storage configs")
+		return
+	}
+	notifications, err := h.repo.ListNotificationConfigsByUser(*userID, false)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list notification configs")
+		return
+	}
+	databases, err := h.repo.ListDatabaseConfigsByUser(*userID, false, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list database configs")
+		return
+	}
+
+	doc := exportDocument(storages, notifications, databases)
+	h.logActivity(userID, models.ActionConfigsExported, models.LogLevelWarning,
+		"user", userID, "",
+		fmt.Sprintf("Exported %d storage, %d notification and %d database configs", len(doc.Storage), len(doc.Notifications), len(doc.Databases)),
+		"", getIPAddress(r))
+
+	w.Header().Set("Content-Disposition", `attachment; filename="dumpstation-configs.json"`)
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// exportDocument converts configs into a ConfigDocument without their
+// secrets. Databases name their storage and notification configs.
+func exportDocument(storages []*models.StorageConfig, notifications []*models.NotificationConfig, databases []*models.DatabaseConfig) models.ConfigDocument {
+	doc := models.ConfigDocument{
+		Version:       models.ConfigDocumentVersion,
+		Storage:       make([]models.StorageConfigInput, 0, len(storages)),
+		Notifications: make([]models.NotificationConfigInput, 0, len(notifications)),
+		Databases:     make([]models.ImportDatabaseConfig, 0, len(databases)),
+	}
+	storageNames := make(map[uuid.UUID]string, len(storages))
+	for _, s := range storages {
+		storageNames[s.ID] = s.Name
+		doc.Storage = append(doc.Storage, models.StorageConfigInput{
+			Name:     s.Name,
+			Provider: s.Provider,
+			Bucket:   s.Bucket,
+			Region:   s.Region,
+			Endpoint: s.Endpoint,
+		})
+	}
+	notificationNames := make(map[uuid.UUID]string, len(notifications))
+	for _, n := range notifications {
+		notificationNames[n.ID] = n.Name
+		doc.Notifications = append(doc.Notifications, models.NotificationConfigInput{
+			Name:            n.Name,
+			TelegramChatID:  n.TelegramChatID,
+			CooldownMinutes: n.CooldownMinutes,
+			SuccessTemplate: n.SuccessTemplate,
+			FailureTemplate: n.FailureTemplate,
+		})
+	}
+	for _, d := range databases {
+		item := models.ImportDatabaseConfig{
+			DatabaseConfigInput: models.DatabaseConfigInput{
+				Name:                   d.Name,
+				Host:                   d.Host,
+				Port:                   d.Port,
+				DBName:                 d.DBName,
+				Username:               d.Username,
+				Schedule:               d.Schedule,
+				StorageID:              d.StorageID,
+				NotificationID:         d.NotificationID,
+				RotationPolicy:         d.GetRotationPolicy(),
+				MaxSizeBytes:           d.MaxSizeBytes,
+				SchemaOnly:             d.SchemaOnly,
+				FilenameTemplate:       d.FilenameTemplate,
+				NoOwner:                d.NoOwner,
+				NoPrivileges:           d.NoPrivileges,
+				NoVerbose:              d.NoVerbose,
+				Blobs:                  d.Blobs,
+				ConnectTimeoutSeconds:  d.ConnectTimeoutSeconds,
+				LockWaitTimeoutSeconds: d.LockWaitTimeoutSeconds,
+				BackupWindow:           d.BackupWindow,
+				ExtraArgs:              d.ExtraArgs,
+				PgEnv:                  d.PgEnv,
+				JitterMinutes:          d.JitterMinutes,
+			},
+			Storage: storageNames[d.StorageID],
+		}
+		if item.Storage == "" {
+			item.Storage = d.Storage.Name
+		}
+		if d.NotificationID != nil {
+			item.Notification = notificationNames[*d.NotificationID]
+			if item.Notification == "" && d.Notification != nil {
+				item.Notification = d.Notification.Name
+			}
+		}
+		doc.Databases = append(doc.Databases, item)
+	}
+	return doc
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/validator"
+)
+
+func importDatabase(name, storage, notification string) models.ImportDatabaseConfig {
+	return models.ImportDatabaseConfig{
+		DatabaseConfigInput: models.DatabaseConfigInput{
+			Name:           name,
+			Host:           "db.example.com",
+			Port:           5432,
+			DBName:         "app",
+			Username:       "backup",
+			Password:       "secret",
+			Schedule:       "0 2 * * *",
+			RotationPolicy: models.RotationPolicy{Type: models.RotationPolicyDays, Value: 30},
+		},
+		Storage:      storage,
+		Notification: notification,
+	}
+}
+
+func TestPlanImportResolvesReferences(t *testing.T) {
+	h := &Handler{validator: validator.New()}
+	userID := uuid.New()
+	existing := &models.StorageConfig{ID: uuid.New(), Name: "old bucket"}
+	existingNotif := &models.NotificationConfig{ID: uuid.New(), Name: "ops"}
+
+	doc := &models.ConfigDocument{
+		Version: models.ConfigDocumentVersion,
+		Storage: []models.StorageConfigInput{{
+			Name: "new bucket", Provider: models.StorageProviderS3, Bucket: "b", AccessKey: "a", SecretKey: "s",
+		}},
+		Databases: []models.ImportDatabaseConfig{
+			importDatabase("one", "new bucket", ""),
+			importDatabase("two", "old bucket", "ops"),
+		},
+	}
+	plan := h.planImport(userID, doc, []*models.StorageConfig{existing}, []*models.NotificationConfig{existingNotif})
+	if plan.invalid {
+		t.Fatalf("plan invalid: %+v", plan.results)
+	}
+	if got := plan.databases[0].StorageID; got != plan.storage[0].ID {
+		t.Errorf("database one storage = %s, want the imported storage %s", got, plan.storage[0].ID)
+	}
+	if got := plan.databases[1].StorageID; got != existing.ID {
+		t.Errorf("database two storage = %s, want the existing storage %s", got, existing.ID)
+	}
+	if got := plan.databases[1].NotificationID; got == nil || *got != existingNotif.ID {
+		t.Errorf("database two notification = %v, want %s", got, existingNotif.ID)
+	}
+	for _, c := range plan.databases {
+		if c.UserID != userID || !c.Enabled {
+			t.Errorf("database %s: user %s enabled %v", c.Name, c.UserID, c.Enabled)
+		}
+	}
+	if len(plan.results) != 3 || plan.results[0].Kind != "storage" || plan.results[2].Name != "two" {
+		t.Errorf("results = %+v", plan.results)
+	}
+}
+
+func TestPlanImportReportsEveryInvalidItem(t *testing.T) {
+	h := &Handler{validator: validator.New()}
+	noPassword := importDatabase("no password", "missing", "")
+	noPassword.Password = ""
+
+	doc := &models.ConfigDocument{
+		Version: models.ConfigDocumentVersion,
+		Storage: []models.StorageConfigInput{
+			{Name: "taken", Provider: models.StorageProviderR2, Bucket: "b", AccessKey: "a", SecretKey: "s"},
+		},
+		Notifications: []models.NotificationConfigInput{{Name: "no channel"}},
+		Databases: []models.ImportDatabaseConfig{
+			noPassword,
+			importDatabase("fine", "taken", ""),
+		},
+	}
+	existing := []*models.StorageConfig{{ID: uuid.New(), Name: "taken"}}
+	plan := h.planImport(uuid.New(), doc, existing, nil)
+	if !plan.invalid {
+		t.Fatal("plan with invalid items was not marked invalid")
+	}
+
+	want := []struct {
+		status string
+		errs   []string
+	}{
+		{models.ImportStatusInvalid, []string{"already exists"}},
+		{models.ImportStatusInvalid, []string{"Discord webhook URL"}},
+		{models.ImportStatusInvalid, []string{`storage "missing" not found`, "Password is required"}},
+		{models.ImportStatusSkipped, nil},
+	}
+	for i, w := range want {
+		res := plan.results[i]
+		if res.Status != w.status {
+			t.Errorf("result %d (%s) status = %s, want %s", i, res.Name, res.Status, w.status)
+		}
+		joined := strings.Join(res.Errors, "; ")
+		for _, e := range w.errs {
+			if !strings.Contains(joined, e) {
+				t.Errorf("result %d errors %q do not mention %q", i, joined, e)
+			}
+		}
+		if w.errs == nil && len(res.Errors) > 0 {
+			t.Errorf("result %d errors = %q, want none", i, joined)
+		}
+	}
+}
+
+func TestExportDocumentOmitsSecrets(t *testing.T) {
+	storage := &models.StorageConfig{ID: uuid.New(), Name: "bucket", Provider: models.StorageProviderS3, Bucket: "b", AccessKey: "AKIA", SecretKey: "shh"}
+	notif := &models.NotificationConfig{ID: uuid.New(), Name: "ops", DiscordWebhookURL: "https://discord.com/api/webhooks/1/x", TelegramBotToken: "123:abc", TelegramChatID: "-100"}
+	db := &models.DatabaseConfig{ID: uuid.New(), Name: "prod", Host: "db", Port: 5432, Password: "pw", StorageID: storage.ID, NotificationID: &notif.ID}
+	db.SetRotationPolicy(models.RotationPolicy{Type: models.RotationPolicyCount, Value: 7})
+
+	doc := exportDocument([]*models.StorageConfig{storage}, []*models.NotificationConfig{notif}, []*models.DatabaseConfig{db})
+	if doc.Version != models.ConfigDocumentVersion {
+		t.Errorf("version = %d", doc.Version)
+	}
+	if s := doc.Storage[0]; s.AccessKey != "" || s.SecretKey != "" || s.Bucket != "b" {
+		t.Errorf("storage = %+v", s)
+	}
+	if n := doc.Notifications[0]; n.DiscordWebhookURL != "" || n.TelegramBotToken != "" || n.TelegramChatID != "-100" {
+		t.Errorf("notification = %+v", n)
+	}
+	d := doc.Databases[0]
+	if d.Password != "" || d.Storage != "bucket" || d.Notification != "ops" || d.RotationPolicy.Value != 7 {
+		t.Errorf("database = %+v", d)
+	}
+}
+
+// TestImportAcceptsStatementTimeoutAlias checks documents exported before
+// statement_timeout_seconds became lock_wait_timeout_seconds still import
+// their lock wait timeout, and that the new key wins when both are set.
+func TestImportAcceptsStatementTimeoutAlias(t *testing.T) {
+	for doc, want := range map[string]int{
+		`{"statement_timeout_seconds": 30}`:                                  30,
+		`{"lock_wait_timeout_seconds": 45}`:                                  45,
+		`{"statement_timeout_seconds": 30, "lock_wait_timeout_seconds": 45}`: 45,
+	} {
+		var in models.DatabaseConfigInput
+		if err := json.Unmarshal([]byte(doc), &in); err != nil {
+			t.Fatal(err)
+		}
+		if got := in.ToModel(uuid.New()).LockWaitTimeoutSeconds; got != want {
+			t.Errorf("%s: lock wait timeout = %d, want %d", doc, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// credentialMinLength is the configured CREDENTIAL_MIN_LENGTH.
+func (h *Handler) credentialMinLength() int {
+	if h.cfg == nil {
+		return 0
+	}
+	return h.cfg.Credentials.MinLength
+}
+
+// storageCredentialWarnings flags example placeholders and short keys in a
+// storage config. The config is saved regardless; real keys can look odd.
+func (h *Handler) storageCredentialWarnings(input *models.StorageConfigInput) []string {
+	minLength := h.credentialMinLength()
+	warnings := utils.CredentialWarnings("access_key", input.AccessKey, minLength)
+	return append(warnings, utils.CredentialWarnings("secret_key", input.SecretKey, minLength)...)
+}
+
+// databaseCredentialWarnings flags an example placeholder or short
+// password in a database config.
+func (h *Handler) databaseCredentialWarnings(input *models.DatabaseConfigInput) []string {
+	return utils.CredentialWarnings("password", input.Password, h.credentialMinLength())
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -85,8 +84,8 @@ func (h *Handler) CreateServerConnection(w http.ResponseWriter, r *http.Request)
 	}
 
 	var input models.ServerConnectionInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 	if validationErr, err := h.validator.Validate(&input); validationErr != nil || err != nil {
@@ -101,6 +100,9 @@ func (h *Handler) CreateServerConnection(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "password is required when creating a server connection")
 		return
 	}
+	if h.rejectDisallowedHost(w, input.Host) {
+		return
+	}
 
 	// Verify credentials before persisting — fail fast rather than store
 	// secrets we can't actually use.
@@ -164,13 +166,13 @@ func (h *Handler) UpdateServerConnection(w http.ResponseWriter, r *http.Request)
 	}
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid id")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid id")
 		return
 	}
 
 	var input models.ServerConnectionInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 	if validationErr, err := h.validator.Validate(&input); validationErr != nil || err != nil {
@@ -181,6 +183,9 @@ func (h *Handler) UpdateServerConnection(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, "validation error")
 		return
 	}
+	if h.rejectDisallowedHost(w, input.Host) {
+		return
+	}
 
 	var ciphertext string
 	if input.Password != "" {
@@ -199,7 +204,7 @@ func (h *Handler) UpdateServerConnection(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if sc == nil {
-		writeError(w, http.StatusNotFound, "server connection not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeServerConnectionNotFound, "server connection not found")
 		return
 	}
 
@@ -227,7 +232,7 @@ func (h *Handler) DeleteServerConnection(w http.ResponseWriter, r *http.Request)
 	}
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid id")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid id")
 		return
 	}
 
@@ -236,7 +241,7 @@ func (h *Handler) DeleteServerConnection(w http.ResponseWriter, r *http.Request)
 
 	if err := h.repo.DeleteServerConnectionByUser(id, *userID, getIsAdminFromContext(r)); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			writeError(w, http.StatusNotFound, "server connection not found")
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeServerConnectionNotFound, "server connection not found")
 			return
 		}
 		logError("delete server connection", err)
@@ -274,6 +279,10 @@ func (h *Handler) TestServerConnection(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	// Rows saved before the policy was tightened must not be reachable.
+	if h.rejectDisallowedHost(w, sc.Host) {
+		return
+	}
 	plain, err := h.cipher.Decrypt(sc.Password)
 	if err != nil {
 		logError("decrypt server password", err)
@@ -317,14 +326,17 @@ func (h *Handler) TestServerConnection(w http.ResponseWriter, r *http.Request) {
 // @Router /server-connections/test [post]
 func (h *Handler) TestServerConnectionAdHoc(w http.ResponseWriter, r *http.Request) {
 	var input models.ServerConnectionInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 	if input.Host == "" || input.Port == 0 || input.Username == "" || input.Password == "" {
 		writeError(w, http.StatusBadRequest, "host, port, user, and password are all required to test")
 		return
 	}
+	if h.rejectDisallowedHost(w, input.Host) {
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), adminRequestTimeout)
 	defer cancel()
@@ -390,8 +402,8 @@ func (h *Handler) ListServerDatabases(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) CreateServerDatabase(w http.ResponseWriter, r *http.Request) {
 	userID := getUserIDFromContext(r)
 	var input models.ServerCreateDatabaseInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 	if validationErr, err := h.validator.Validate(&input); validationErr != nil || err != nil {
@@ -669,8 +681,8 @@ func (h *Handler) ListServerUsers(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) CreateServerUser(w http.ResponseWriter, r *http.Request) {
 	userID := getUserIDFromContext(r)
 	var input models.ServerCreateUserInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 	if validationErr, err := h.validator.Validate(&input); validationErr != nil || err != nil {
@@ -760,8 +772,8 @@ func (h *Handler) GrantServerRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var input models.ServerGrantInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 	if validationErr, err := h.validator.Validate(&input); validationErr != nil || err != nil {
@@ -814,7 +826,7 @@ func (h *Handler) requireServerConnection(w http.ResponseWriter, r *http.Request
 	}
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid id")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid id")
 		return nil, false
 	}
 	sc, err := h.repo.GetServerConnectionByUser(id, *userID, getIsAdminFromContext(r))
@@ -824,7 +836,7 @@ func (h *Handler) requireServerConnection(w http.ResponseWriter, r *http.Request
 		return nil, false
 	}
 	if sc == nil {
-		writeError(w, http.StatusNotFound, "server connection not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeServerConnectionNotFound, "server connection not found")
 		return nil, false
 	}
 	return sc, true
@@ -838,6 +850,9 @@ func (h *Handler) openClient(w http.ResponseWriter, r *http.Request, dbname stri
 	if !ok {
 		return nil, nil, false
 	}
+	if h.rejectDisallowedHost(w, sc.Host) {
+		return nil, nil, false
+	}
 	plain, err := h.cipher.Decrypt(sc.Password)
 	if err != nil {
 		logError("decrypt server password", err)
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// DefaultMaxBodyBytes caps JSON request bodies until SetJSONLimits is
+// called.
+const DefaultMaxBodyBytes = 1 << 20
+
+var (
+	maxBodyBytes          atomic.Int64
+	disallowUnknownFields atomic.Bool
+)
+
+func init() {
+	maxBodyBytes.Store(DefaultMaxBodyBytes)
+}
+
+// SetJSONLimits sets the largest JSON body decodeJSON accepts and whether
+// fields the target struct doesn't declare are rejected rather than
+// ignored. maxBytes <= 0 means DefaultMaxBodyBytes.
+func SetJSONLimits(maxBytes int64, strict bool) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	maxBodyBytes.Store(maxBytes)
+	disallowUnknownFields.Store(strict)
+}
+
+// decodeJSON decodes r's body into v under the configured size limit.
+// Report failures with writeDecodeError.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return decodeJSONLimit(w, r, v, maxBodyBytes.Load())
+}
+
+// decodeJSONLimit is decodeJSON with an explicit size limit, for the few
+// endpoints that legitimately take larger bodies.
+func decodeJSONLimit(w http.ResponseWriter, r *http.Request, v any, limit int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	if disallowUnknownFields.Load() {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// decodeOptionalJSON decodes r's body into v, treating an empty body as
+// "no options".
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := decodeJSON(w, r, v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// writeDecodeError answers a decodeJSON failure: 413 for an oversized body,
+// 400 naming the field for an unknown one, otherwise 400 with msg.
+func writeDecodeError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeErrorCode(w, http.StatusRequestEntityTooLarge, models.ErrCodeBodyTooLarge,
+			fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+		return
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidBody, "unknown field "+field)
+		return
+	}
+	writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidBody, msg)
+}
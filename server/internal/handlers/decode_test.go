@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	t.Cleanup(func() { SetJSONLimits(DefaultMaxBodyBytes, false) })
+
+	type body struct {
+		Name string `json:"name"`
+	}
+	decode := func(payload string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		var v body
+		if err := decodeJSON(w, r, &v); err != nil {
+			writeDecodeError(w, err, "invalid request body")
+		}
+		return w
+	}
+
+	SetJSONLimits(32, false)
+	if w := decode(`{"name":"orders","extra":1}`); w.Code != http.StatusOK {
+		t.Errorf("unknown field with lenient decoding: status %d, want 200", w.Code)
+	}
+	if w := decode(`{"name":"` + strings.Repeat("x", 64) + `"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", w.Code)
+	}
+	if w := decode(`{"name":`); w.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: status %d, want 400", w.Code)
+	}
+
+	SetJSONLimits(32, true)
+	w := decode(`{"name":"orders","extra":1}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `unknown field \"extra\"`) {
+		t.Errorf("unknown field with strict decoding: status %d, body %s", w.Code, w.Body.String())
+	}
+}
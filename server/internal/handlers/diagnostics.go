@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/backup"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// ─────────────────────── Admin diagnostics ───────────────────────
+
+// DiagnosticsResponse is the shape of /admin/diagnostics. It is meant to be
+// pasted into support tickets, so it carries no secrets.
+type DiagnosticsResponse struct {
+	GeneratedAt time.Time             `json:"generated_at"`
+	GoVersion   string                `json:"go_version"`
+	OS          string                `json:"os"`
+	Arch        string                `json:"arch"`
+	PgTools     []backup.BinaryCheck  `json:"pg_tools"`
+	TempDir     TempDirDiagnostics    `json:"temp_dir"`
+	Storage     []StorageReachability `json:"storage"`
+}
+
+// TempDirDiagnostics reports free space where dumps are staged before upload.
+type TempDirDiagnostics struct {
+	Path       string `json:"path"`
+	FreeBytes  uint64 `json:"free_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+	Error      string `json:"error,omitempty"`
+}
+
+// StorageReachability is the result of a HeadBucket probe against one
+// storage configuration.
+type StorageReachability struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	Reachable bool      `json:"reachable"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// GetDiagnostics reports the host's PostgreSQL client versions, Go runtime,
+// temp-dir free space and storage backend reachability in one call.
+//
+// @Summary  Host diagnostics
+// @Description Probes pg_dump/psql for every known PostgreSQL major, reports the Go runtime, temp-dir disk space and whether each storage backend is reachable. Admin only.
+// @Tags     Admin
+// @Security BearerAuth
+// @Produce  json
+// @Success  200 {object} DiagnosticsResponse
+// @Failure  403 {object} map[string]string "Admin access required"
+// @Failure  500 {object} map[string]string "Internal server error"
+// @Router   /admin/diagnostics [get]
+func (h *Handler) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	configs, err := h.repo.ListStorageConfigs()
+	if err != nil {
+		logError("Failed to list storage configs for diagnostics", err)
+		writeError(w, http.StatusInternalServerError, "failed to list storage configs")
+		return
+	}
+
+	resp := DiagnosticsResponse{
+		GeneratedAt: time.Now().UTC(),
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		TempDir:     TempDirDiagnostics{Path: os.TempDir()},
+		Storage:     make([]StorageReachability, len(configs)),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp.PgTools = h.backupSvc.CheckPgToolchain(backup.KnownPostgresMajors...)
+	}()
+
+	// Probe backends concurrently; each probe is bounded by the storage
+	// client's metadata timeout.
+	for i, cfg := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := StorageReachability{ID: cfg.ID, Name: cfg.Name, Provider: string(cfg.Provider)}
+			start := time.Now()
+			client, err := storage.NewStorageClient(cfg)
+			if err == nil {
+				err = client.Ping()
+			}
+			result.LatencyMs = time.Since(start).Milliseconds()
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Reachable = true
+			}
+			resp.Storage[i] = result
+		}()
+	}
+
+	free, total, err := diskSpace(resp.TempDir.Path)
+	if err != nil {
+		resp.TempDir.Error = err.Error()
+	} else {
+		resp.TempDir.FreeBytes = free
+		resp.TempDir.TotalBytes = total
+	}
+
+	wg.Wait()
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package handlers
+
+import "errors"
+
+// diskSpace is not implemented on Windows; diagnostics report the error.
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk space check not supported on windows")
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,8 +21,9 @@ import (
 
 // githubStateCookie is the name of the short-lived cookie that holds the
 // random state value during the OAuth round trip. We HMAC the state with
-// the JWT secret so we don't need server-side state storage and a stolen
-// cookie cannot be replayed without also knowing the server secret.
+// the handler's state key (GITHUB_OAUTH_STATE_SECRET or a random
+// per-process key) so we don't need server-side state storage and a forged
+// cookie cannot pass without also knowing that key.
 const githubStateCookie = "ds_gh_oauth_state"
 
 // githubStateTTL bounds how long an OAuth round trip can pause for. Five
@@ -77,7 +79,11 @@ func (h *Handler) GitHubLogin(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "failed to mint state")
 		return
 	}
-	signedState := signState(state, h.cfg.JWT.Secret)
+	signedState, err := signState(state, h.githubStateKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to sign state")
+		return
+	}
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     githubStateCookie,
@@ -127,7 +133,7 @@ func (h *Handler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 		h.redirectGitHubError(w, r, "missing_state_cookie")
 		return
 	}
-	if !verifyState(gotState, cookie.Value, h.cfg.JWT.Secret) {
+	if !verifyState(gotState, cookie.Value, h.githubStateKey) {
 		h.redirectGitHubError(w, r, "state_mismatch")
 		return
 	}
@@ -174,7 +180,7 @@ func (h *Handler) GitHubCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, expiresAt, err := h.jwtMgr.GenerateToken(user.ID, user.DiscordUserID, user.IsAdmin)
+	token, expiresAt, err := h.jwtMgr.GenerateToken(user.ID, user.DiscordUserID, user.IsAdmin, user.IsViewer)
 	if err != nil {
 		log.Printf("github oauth: jwt sign failed: %v", err)
 		h.redirectGitHubError(w, r, "token_failed")
@@ -303,13 +309,20 @@ func newRandomState() (string, error) {
 // stolen state cookie can't be replayed against a different deployment.
 // The cookie value carries "state.hmac"; the URL parameter carries the
 // state half only.
-func signState(state, secret string) string {
-	mac := hmac.New(sha256.New, []byte(secret))
+// errEmptyStateKey guards against signing the state with a zero-length key,
+// which would let anyone forge the cookie.
+var errEmptyStateKey = errors.New("github oauth state key is empty")
+
+func signState(state string, key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", errEmptyStateKey
+	}
+	mac := hmac.New(sha256.New, key)
 	_, _ = mac.Write([]byte(state))
-	return state + "." + hex.EncodeToString(mac.Sum(nil))
+	return state + "." + hex.EncodeToString(mac.Sum(nil)), nil
 }
 
-func verifyState(rawState, cookieValue, secret string) bool {
+func verifyState(rawState, cookieValue string, key []byte) bool {
 	parts := strings.SplitN(cookieValue, ".", 2)
 	if len(parts) != 2 {
 		return false
@@ -317,7 +330,10 @@ func verifyState(rawState, cookieValue, secret string) bool {
 	if parts[0] != rawState {
 		return false
 	}
-	expected := signState(rawState, secret)
+	expected, err := signState(rawState, key)
+	if err != nil {
+		return false
+	}
 	// Constant-time compare on the full "state.hmac" string is fine because
 	// the state half is already known to match.
 	return hmac.Equal([]byte(expected), []byte(cookieValue))
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestSignStateRejectsEmptyKey(t *testing.T) {
+	if _, err := signState("abc", nil); !errors.Is(err, errEmptyStateKey) {
+		t.Fatalf("signState with empty key: err = %v, want errEmptyStateKey", err)
+	}
+	// A cookie minted with an empty key must not verify either.
+	mac := hmac.New(sha256.New, nil)
+	_, _ = mac.Write([]byte("abc"))
+	forged := "abc." + hex.EncodeToString(mac.Sum(nil))
+	if verifyState("abc", forged, nil) {
+		t.Fatal("verifyState accepted a cookie with an empty key")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	signed, err := signState("abc", key)
+	if err != nil {
+		t.Fatalf("signState: %v", err)
+	}
+	if !verifyState("abc", signed, key) {
+		t.Fatal("verifyState rejected its own signature")
+	}
+	if verifyState("abd", signed, key) {
+		t.Fatal("verifyState accepted a different state")
+	}
+	if verifyState("abc", signed, []byte("another-key")) {
+		t.Fatal("verifyState accepted a signature from another key")
+	}
+}
+
+func TestNewHandlerGitHubStateKey(t *testing.T) {
+	h := New(nil, nil, nil, nil, nil, 0, false, "", 0, nil, nil)
+	if len(h.githubStateKey) == 0 {
+		t.Fatal("handler without config has an empty GitHub state key")
+	}
+}
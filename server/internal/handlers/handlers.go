@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,12 +19,15 @@ import (
 	"github.com/monzim/db_proxy/v1/internal/backup"
 	"github.com/monzim/db_proxy/v1/internal/config"
 	"github.com/monzim/db_proxy/v1/internal/crypto"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/middleware"
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"github.com/monzim/db_proxy/v1/internal/notification"
 	"github.com/monzim/db_proxy/v1/internal/repository"
 	"github.com/monzim/db_proxy/v1/internal/scheduler"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 	"github.com/monzim/db_proxy/v1/internal/validator"
+	"gorm.io/gorm"
 )
 
 // Handler holds all dependencies for HTTP handlers
@@ -29,6 +37,7 @@ type Handler struct {
 	backupSvc        *backup.Service
 	scheduler        *scheduler.Scheduler
 	notifier         *notification.DiscordNotifier
+	otpFallback      *notification.EmailNotifier // Secondary OTP channel; nil unless SMTP is configured
 	otpExpiry        time.Duration
 	validator        *validator.Validator
 	turnstileEnabled bool
@@ -36,6 +45,9 @@ type Handler struct {
 	turnstileTimeout int
 	cipher           *crypto.Cipher
 	cfg              *config.Config
+	downloadKey      []byte // Per-process HMAC key for streamed-download tickets
+	githubStateKey   []byte // HMAC key for the GitHub OAuth state cookie
+	hostPolicy       *utils.HostPolicy
 }
 
 // New creates a new handler instance
@@ -43,19 +55,43 @@ func New(repo *repository.Repository, jwtMgr *auth.JWTManager, backupSvc *backup
 	scheduler *scheduler.Scheduler, notifier *notification.DiscordNotifier, otpExpiry time.Duration,
 	turnstileEnabled bool, turnstileSecret string, turnstileTimeout int,
 	cipher *crypto.Cipher, cfg *config.Config) *Handler {
+	var otpFallback *notification.EmailNotifier
+	var hostPolicy *utils.HostPolicy
+	cronSeconds := false
+	// The state cookie only has to survive one login round trip, so a random
+	// per-process key is fine when no dedicated secret is configured. It must
+	// never be the JWT secret, which is empty under RS256/ES256.
+	githubStateKey := newDownloadKey()
+	if cfg != nil {
+		cronSeconds = cfg.Scheduler.CronSeconds
+		if cfg.GitHub.StateSecret != "" {
+			githubStateKey = []byte(cfg.GitHub.StateSecret)
+		}
+		// Patterns were validated by config.Load.
+		hostPolicy, _ = utils.NewHostPolicy(cfg.Hosts.Allow, cfg.Hosts.Deny)
+	}
+	if cfg != nil && cfg.SMTP.Enabled {
+		otpFallback = notification.NewEmailNotifier(cfg.SMTP.Host, cfg.SMTP.Port,
+			cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From, cfg.SMTP.To)
+		otpFallback.SetOTPExpiry(otpExpiry)
+	}
 	return &Handler{
 		repo:             repo,
 		jwtMgr:           jwtMgr,
 		backupSvc:        backupSvc,
 		scheduler:        scheduler,
 		notifier:         notifier,
+		otpFallback:      otpFallback,
 		otpExpiry:        otpExpiry,
-		validator:        validator.New(),
+		validator:        validator.NewWithCronSeconds(cronSeconds),
 		turnstileEnabled: turnstileEnabled,
 		turnstileSecret:  turnstileSecret,
 		turnstileTimeout: turnstileTimeout,
 		cipher:           cipher,
 		cfg:              cfg,
+		downloadKey:      newDownloadKey(),
+		githubStateKey:   githubStateKey,
+		hostPolicy:       hostPolicy,
 	}
 }
 
@@ -63,7 +99,7 @@ func New(repo *repository.Repository, jwtMgr *auth.JWTManager, backupSvc *backup
 
 // Login godoc
 // @Summary Request OTP for authentication
-// @Description Sends a one-time password to the configured Discord webhook for authentication. Single-user system - user must provide valid username or email.
+// @Description Sends a one-time password to the user's own OTP destination if set, otherwise the configured Discord webhook, falling back to email (SMTP) when Discord delivery fails. Single-user system - user must provide valid username or email.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -72,14 +108,15 @@ func New(repo *repository.Repository, jwtMgr *auth.JWTManager, backupSvc *backup
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 401 {object} map[string]string "Invalid credentials"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "OTP delivery delayed by Discord rate limiting"
 // @Router /auth/login [post]
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
-	logInfo("Login request received")
+	logDebug("Login request received")
 
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid request body in login", err)
-		writeError(w, http.StatusBadRequest, "invalid request body: username or email is required")
+		writeDecodeError(w, err, "invalid request body: username or email is required")
 		return
 	}
 
@@ -98,17 +135,17 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		logInfo("Verifying Turnstile token for username/email: %s", req.Username)
+		logDebug("Verifying Turnstile token for username/email: %s", req.Username)
 		clientIP := auth.GetIPAddress(r)
 		if err := auth.VerifyTurnstileToken(h.turnstileSecret, req.TurnstileToken, clientIP, h.turnstileTimeout); err != nil {
 			logError("Turnstile verification failed", err)
 			writeError(w, http.StatusBadRequest, "security verification failed")
 			return
 		}
-		logInfo("✅ Turnstile verification successful")
+		logDebug("✅ Turnstile verification successful")
 	}
 
-	logInfo("Processing login for username/email: %s", req.Username)
+	logDebug("Processing login for username/email: %s", req.Username)
 
 	// Get the single system user by username or email
 	user, err := h.repo.GetUserByUsernameOrEmail(req.Username)
@@ -120,12 +157,12 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Single-user system: user must already exist (no auto-creation)
 	if user == nil {
-		log.Printf("[AUTH] ❌ Invalid login attempt - user not found: %s", req.Username)
+		logging.Warnf("[AUTH] ❌ Invalid login attempt - user not found: %s", req.Username)
 		writeError(w, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
 
-	logInfo("✅ User authenticated: %s (ID: %s)", user.DiscordUsername, user.ID)
+	logDebug("✅ User authenticated: %s (ID: %s)", user.DiscordUsername, user.ID)
 
 	// Generate OTP
 	otp, err := auth.GenerateOTP()
@@ -135,7 +172,7 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logInfo("✅ OTP generated for user: %s", user.DiscordUsername)
+	logDebug("✅ OTP generated for user: %s", user.DiscordUsername)
 
 	// Store OTP
 	expiresAt := time.Now().Add(h.otpExpiry)
@@ -145,27 +182,148 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logInfo("✅ OTP stored in database (expires at: %v)", expiresAt)
+	logDebug("✅ OTP stored in database (expires at: %v)", expiresAt)
 
-	// Send OTP via Discord webhook
-	if h.notifier != nil {
-		logInfo("Sending OTP to Discord webhook...")
-		if err := h.notifier.SendOTP(otp); err != nil {
-			logError("Failed to send OTP to Discord", err)
+	// Send OTP to the user's own destination or the Discord webhook,
+	// falling back to email when configured
+	message := "OTP sent to Discord webhook"
+	if h.notifier != nil || h.otpFallback != nil || h.userOTPNotifier(user) != nil {
+		channel, err := h.sendLoginOTP(user, otp)
+		if err != nil {
+			var rateLimited *notification.RateLimitError
+			if errors.As(err, &rateLimited) {
+				if rateLimited.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateLimited.RetryAfter.Seconds()))))
+				}
+				writeError(w, http.StatusServiceUnavailable, "notification delivery delayed: Discord is rate limiting, please try again shortly")
+				return
+			}
 			writeError(w, http.StatusInternalServerError, "failed to send OTP")
 			return
 		}
-		logInfo("✅ OTP sent to Discord webhook successfully")
+		logDebug("✅ OTP sent via %s", channel)
+		switch channel {
+		case "email":
+			message = "OTP sent by email (Discord delivery failed)"
+		case "personal_discord":
+			message = "OTP sent to your Discord webhook"
+		case "personal_email":
+			message = "OTP sent to your email"
+		}
+
+		h.logActivity(&user.ID, models.ActionLogin, models.LogLevelInfo,
+			"user", &user.ID, user.DiscordUsername,
+			fmt.Sprintf("Login OTP for %s delivered via %s", user.DiscordUsername, channel),
+			fmt.Sprintf(`{"otp_channel":"%s"}`, channel), getIPAddress(r))
 	} else {
-		log.Printf("[WARNING] ⚠️  Discord notifier not configured, OTP not sent: %s", otp)
+		logging.Warnf("[WARNING] ⚠️  No OTP channel configured, OTP for %s was not sent", user.DiscordUsername)
+		logDebug("Undelivered OTP for %s: %s", user.DiscordUsername, otp)
 	}
 
-	logInfo("✅ Login successful for user: %s", user.DiscordUsername)
+	logDebug("✅ Login successful for user: %s", user.DiscordUsername)
 	writeJSON(w, http.StatusOK, map[string]string{
-		"message": "OTP sent to Discord webhook",
+		"message": message,
 	})
 }
 
+// userOTPNotifier builds a notifier for the user's own OTP destination, or
+// returns nil when the user has none (or email is chosen but the server
+// has no SMTP relay).
+func (h *Handler) userOTPNotifier(user *models.User) notification.Notifier {
+	switch user.OTPChannel {
+	case models.OTPChannelDiscord:
+		if user.OTPDiscordWebhookURL == "" {
+			return nil
+		}
+		dn := notification.NewDiscordNotifier(user.OTPDiscordWebhookURL, "")
+		if h.cfg != nil {
+			dn.SetRetryPolicy(h.cfg.Discord.MaxAttempts, time.Duration(h.cfg.Discord.MaxRetryAfter)*time.Second)
+		}
+		return dn
+	case models.OTPChannelEmail:
+		if user.OTPEmail == "" || h.cfg == nil || h.cfg.SMTP.Host == "" {
+			return nil
+		}
+		en := notification.NewEmailNotifier(h.cfg.SMTP.Host, h.cfg.SMTP.Port,
+			h.cfg.SMTP.Username, h.cfg.SMTP.Password, h.cfg.SMTP.From, []string{user.OTPEmail})
+		en.SetOTPExpiry(h.otpExpiry)
+		return en
+	}
+	return nil
+}
+
+// sendLoginOTP delivers a login OTP to the user's own destination when one
+// is set, otherwise (or if that fails) through the server-wide Discord
+// webhook and, failing that, SMTP. It returns the name of the channel that
+// accepted the code. When every channel fails the errors are joined so
+// callers can still detect a Discord RateLimitError.
+func (h *Handler) sendLoginOTP(user *models.User, otp string) (string, error) {
+	if personal := h.userOTPNotifier(user); personal != nil {
+		err := personal.SendOTP(otp)
+		if err == nil {
+			return "personal_" + string(user.OTPChannel), nil
+		}
+		logError(fmt.Sprintf("Failed to send OTP to personal %s destination", user.OTPChannel), err)
+		if h.notifier == nil && h.otpFallback == nil {
+			return "", err
+		}
+		logDebug("Falling back to the server-wide OTP channel...")
+	}
+
+	var primaryErr error
+	if h.notifier != nil {
+		primaryErr = h.notifier.SendOTP(otp)
+		if primaryErr == nil {
+			return "discord", nil
+		}
+		logError("Failed to send OTP to Discord", primaryErr)
+	}
+
+	if h.otpFallback == nil {
+		return "", primaryErr
+	}
+	if h.notifier != nil {
+		logDebug("Falling back to email for OTP delivery...")
+	}
+	if err := h.otpFallback.SendOTP(otp); err != nil {
+		logError("Failed to send OTP via email", err)
+		return "", errors.Join(primaryErr, err)
+	}
+	return "email", nil
+}
+
+// sendSecurityAlert delivers an account security alert the way
+// sendLoginOTP delivers codes: to the user's own destination first, then
+// the server-wide Discord webhook and, failing that, SMTP. An alert about
+// a compromised OTP channel is most useful where the user reads their codes.
+func (h *Handler) sendSecurityAlert(user *models.User, msg string) error {
+	if personal := h.userOTPNotifier(user); personal != nil {
+		err := personal.SendMessage(msg)
+		if err == nil {
+			return nil
+		}
+		logError(fmt.Sprintf("Failed to send security alert to personal %s destination", user.OTPChannel), err)
+		if h.notifier == nil && h.otpFallback == nil {
+			return err
+		}
+	}
+
+	var primaryErr error
+	if h.notifier != nil {
+		primaryErr = h.notifier.SendMessage(msg)
+		if primaryErr == nil {
+			return nil
+		}
+	}
+	if h.otpFallback == nil {
+		return primaryErr
+	}
+	if err := h.otpFallback.SendMessage(msg); err != nil {
+		return errors.Join(primaryErr, err)
+	}
+	return nil
+}
+
 // Verify godoc
 // @Summary Verify OTP and get JWT token
 // @Description Verifies the OTP code received via Discord and returns a JWT token for API authentication. If 2FA is enabled, returns a temporary token that must be verified with a TOTP code.
@@ -179,12 +337,12 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/verify [post]
 func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
-	logInfo("OTP verification request received")
+	logDebug("OTP verification request received")
 
 	var req models.VerifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid request body in verify", err)
-		writeError(w, http.StatusBadRequest, "invalid request body")
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 
@@ -195,7 +353,7 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logInfo("Verifying OTP for username/email: %s", req.Username)
+	logDebug("Verifying OTP for username/email: %s", req.Username)
 
 	// Get user by username or email
 	user, err := h.repo.GetUserByUsernameOrEmail(req.Username)
@@ -205,12 +363,12 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if user == nil {
-		log.Printf("[AUTH] ❌ User not found: %s", req.Username)
+		logging.Warnf("[AUTH] ❌ User not found: %s", req.Username)
 		writeError(w, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
 
-	logInfo("User found: %s (ID: %s), verifying OTP...", user.DiscordUsername, user.ID)
+	logDebug("User found: %s (ID: %s), verifying OTP...", user.DiscordUsername, user.ID)
 
 	// Verify OTP. The repository tracks failed attempts per token and locks
 	// after the threshold; surface a 429 with Retry-After when locked so
@@ -231,7 +389,7 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !verifyResult.OK {
-		log.Printf("[AUTH] ❌ Invalid or expired OTP for user: %s", user.DiscordUsername)
+		logging.Warnf("[AUTH] ❌ Invalid or expired OTP for user: %s", user.DiscordUsername)
 		// Audit failed OTP attempts so the activity log surfaces brute-force
 		// attempts even when the per-token failure counter hasn't locked yet.
 		h.logActivity(&user.ID, models.ActionLogin, models.LogLevelWarning,
@@ -242,11 +400,17 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logInfo("✅ OTP verified successfully for user: %s", user.DiscordUsername)
+	logDebug("✅ OTP verified successfully for user: %s", user.DiscordUsername)
 
-	// Check if 2FA is enabled for this user
-	if user.TwoFactorEnabled {
-		logInfo("2FA is enabled for user: %s, generating 2FA token...", user.DiscordUsername)
+	// Check if 2FA is enabled for this user (TOTP, passkeys, or both)
+	methods, err := h.twoFactorMethods(user)
+	if err != nil {
+		logError(fmt.Sprintf("Failed to check 2FA methods for user: %s", user.DiscordUsername), err)
+		writeError(w, http.StatusInternalServerError, "failed to check 2FA status")
+		return
+	}
+	if len(methods) > 0 {
+		logDebug("2FA is enabled for user: %s, generating 2FA token...", user.DiscordUsername)
 
 		// Generate a temporary 2FA token
 		twoFAToken, expiresAt, err := h.jwtMgr.Generate2FAToken(user.ID, user.DiscordUserID, user.IsAdmin)
@@ -256,7 +420,7 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		logInfo("✅ 2FA token generated for user: %s (expires: %v)", user.DiscordUsername, expiresAt)
+		logDebug("✅ 2FA token generated for user: %s (expires: %v)", user.DiscordUsername, expiresAt)
 
 		// Log that 2FA is required
 		h.logActivity(&user.ID, models.ActionLogin, models.LogLevelInfo,
@@ -268,19 +432,20 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 			Requires2FA:        true,
 			TwoFactorToken:     twoFAToken,
 			TwoFactorExpiresAt: expiresAt,
+			TwoFactorMethods:   methods,
 		})
 		return
 	}
 
 	// No 2FA - generate full access token
-	token, expiresAt, err := h.jwtMgr.GenerateToken(user.ID, user.DiscordUserID, user.IsAdmin)
+	token, expiresAt, err := h.jwtMgr.GenerateToken(user.ID, user.DiscordUserID, user.IsAdmin, user.IsViewer)
 	if err != nil {
 		logError(fmt.Sprintf("Failed to generate JWT for user: %s", req.Username), err)
 		writeError(w, http.StatusInternalServerError, "failed to generate token")
 		return
 	}
 
-	logInfo("✅ JWT token generated for user: %s (expires: %v)", req.Username, expiresAt)
+	logDebug("✅ JWT token generated for user: %s (expires: %v)", req.Username, expiresAt)
 
 	// Log the successful login
 	h.logActivity(&user.ID, models.ActionLogin, models.LogLevelSuccess,
@@ -304,7 +469,7 @@ func (h *Handler) Verify(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/demo-login [post]
 func (h *Handler) DemoLogin(w http.ResponseWriter, r *http.Request) {
-	logInfo("Demo login request received")
+	logDebug("Demo login request received")
 
 	// Get the demo user
 	user, err := h.repo.GetDemoUser()
@@ -320,7 +485,7 @@ func (h *Handler) DemoLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logInfo("✅ Demo user found: %s (ID: %s)", user.DiscordUsername, user.ID)
+	logDebug("✅ Demo user found: %s (ID: %s)", user.DiscordUsername, user.ID)
 
 	// Generate demo token (bypasses OTP and 2FA)
 	token, expiresAt, err := h.jwtMgr.GenerateDemoToken(user.ID, user.DiscordUserID)
@@ -330,7 +495,7 @@ func (h *Handler) DemoLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logInfo("✅ Demo JWT token generated (expires: %v)", expiresAt)
+	logDebug("✅ Demo JWT token generated (expires: %v)", expiresAt)
 
 	// Demo-user activity is intentionally NOT logged: the demo account is a
 	// public preview and its actions would just create audit-log noise. The
@@ -348,6 +513,7 @@ func (h *Handler) DemoLogin(w http.ResponseWriter, r *http.Request) {
 // Refresh godoc
 // @Summary  Refresh JWT session
 // @Description Issues a new JWT with a fresh expiration while preserving the original session start.
+// @Description Roles are reloaded from the database, so role changes take effect at the next refresh.
 // @Description Refuses if the absolute session lifetime (SessionAbsoluteMax) has been exceeded.
 // @Tags     Authentication
 // @Security BearerAuth
@@ -363,7 +529,21 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, expiresAt, sessionExpiresAt, err := h.jwtMgr.RefreshToken(claims)
+	// Roles come from the database, not the old token, so a revoked admin
+	// or viewer loses access at the next refresh instead of keeping it all
+	// session.
+	user, err := h.repo.GetUserByID(claims.UserID)
+	if err != nil {
+		logError("Failed to load user for refresh", err)
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "user no longer exists")
+		return
+	}
+
+	token, expiresAt, sessionExpiresAt, err := h.jwtMgr.RefreshToken(withCurrentRoles(claims, user))
 	if err != nil {
 		if err == auth.ErrSessionExpired {
 			writeError(w, http.StatusUnauthorized, err.Error())
@@ -387,6 +567,14 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// withCurrentRoles returns a copy of claims carrying user's current roles.
+func withCurrentRoles(claims *auth.Claims, user *models.User) *auth.Claims {
+	refreshed := *claims
+	refreshed.IsAdmin = user.IsAdmin
+	refreshed.IsViewer = user.IsViewer
+	return &refreshed
+}
+
 // Logout godoc
 // @Summary  Log out the current session
 // @Description Best-effort logout: emits an audit-log entry. JWTs are stateless,
@@ -413,14 +601,32 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 // @Description Returns the health status of the service
 // @Tags Health
 // @Produce json
-// @Success 200 {object} map[string]string "Service is healthy"
+// @Success 200 {object} map[string]interface{} "Service is healthy; maintenance reports whether backups are paused"
 // @Router /health [get]
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status": "healthy",
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":      "healthy",
+		"maintenance": h.scheduler.InMaintenance(),
 	})
 }
 
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens. Only served when an asymmetric JWT algorithm (RS256/ES256) is configured.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} auth.JWKS "Active and retired verification keys"
+// @Failure 404 {object} map[string]string "Tokens are HMAC-signed; no public keys"
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if !h.jwtMgr.IsAsymmetric() {
+		writeError(w, http.StatusNotFound, "JWKS is only available with asymmetric JWT signing")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.jwtMgr.JWKS())
+}
+
 // Storage handlers
 
 // ListStorageConfigs godoc
@@ -460,6 +666,7 @@ func (h *Handler) ListStorageConfigs(w http.ResponseWriter, r *http.Request) {
 // @Param body body models.StorageConfigInput true "Storage configuration"
 // @Success 201 {object} models.StorageConfigResponse "Created storage configuration with masked sensitive data"
 // @Failure 400 {object} validator.ValidationErrorResponse "Bad request"
+// @Failure 409 {object} models.APIError "A storage config with this name already exists"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /storage [post]
 func (h *Handler) CreateStorageConfig(w http.ResponseWriter, r *http.Request) {
@@ -469,16 +676,15 @@ func (h *Handler) CreateStorageConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Demo users cannot create resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot create storage configurations")
+	// Demo and viewer accounts cannot create resources
+	if denyWrite(w, r, "create storage configurations") {
 		return
 	}
 
 	var input models.StorageConfigInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := decodeJSON(w, r, &input); err != nil {
 		logError("Invalid JSON in storage config request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
 		return
 	}
 
@@ -494,6 +700,9 @@ func (h *Handler) CreateStorageConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	config, err := h.repo.CreateStorageConfig(*userID, &input)
+	if writeConflict(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create storage config")
 		return
@@ -506,7 +715,9 @@ func (h *Handler) CreateStorageConfig(w http.ResponseWriter, r *http.Request) {
 		"", getIPAddress(r))
 
 	// Return response DTO with masked sensitive data
-	writeJSON(w, http.StatusCreated, config.ToResponse())
+	resp := config.ToResponse()
+	resp.Warnings = h.storageCredentialWarnings(&input)
+	writeJSON(w, http.StatusCreated, resp)
 }
 
 // GetStorageConfig godoc
@@ -531,7 +742,7 @@ func (h *Handler) GetStorageConfig(w http.ResponseWriter, r *http.Request) {
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -541,7 +752,7 @@ func (h *Handler) GetStorageConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "storage config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeStorageNotFound, "storage config not found")
 		return
 	}
 
@@ -561,6 +772,7 @@ func (h *Handler) GetStorageConfig(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} models.StorageConfigResponse "Updated storage configuration with masked sensitive data"
 // @Failure 400 {object} validator.ValidationErrorResponse "Bad request"
 // @Failure 404 {object} map[string]string "Storage config not found"
+// @Failure 409 {object} models.APIError "Another storage config has this name"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /storage/{id} [put]
 func (h *Handler) UpdateStorageConfig(w http.ResponseWriter, r *http.Request) {
@@ -571,22 +783,21 @@ func (h *Handler) UpdateStorageConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot update resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot update storage configurations")
+	// Demo and viewer accounts cannot update resources
+	if denyWrite(w, r, "update storage configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
 	var input models.StorageConfigInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := decodeJSON(w, r, &input); err != nil {
 		logError("Invalid JSON in storage config update request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
 		return
 	}
 
@@ -602,12 +813,15 @@ func (h *Handler) UpdateStorageConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	config, err := h.repo.UpdateStorageConfigByUser(id, *userID, isAdmin, &input)
+	if writeConflict(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to update storage config")
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "storage config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeStorageNotFound, "storage config not found")
 		return
 	}
 
@@ -618,7 +832,9 @@ func (h *Handler) UpdateStorageConfig(w http.ResponseWriter, r *http.Request) {
 		"", getIPAddress(r))
 
 	// Return response DTO with masked sensitive data
-	writeJSON(w, http.StatusOK, config.ToResponse())
+	resp := config.ToResponse()
+	resp.Warnings = h.storageCredentialWarnings(&input)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // DeleteStorageConfig godoc
@@ -641,15 +857,14 @@ func (h *Handler) DeleteStorageConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot delete resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot delete storage configurations")
+	// Demo and viewer accounts cannot delete resources
+	if denyWrite(w, r, "delete storage configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -660,7 +875,7 @@ func (h *Handler) DeleteStorageConfig(w http.ResponseWriter, r *http.Request) {
 		configName = config.Name
 	} else {
 		// Config not found or not authorized
-		writeError(w, http.StatusNotFound, "storage config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeStorageNotFound, "storage config not found")
 		return
 	}
 
@@ -726,16 +941,15 @@ func (h *Handler) CreateNotificationConfig(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Demo users cannot create resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot create notification configurations")
+	// Demo and viewer accounts cannot create resources
+	if denyWrite(w, r, "create notification configurations") {
 		return
 	}
 
 	var input models.NotificationConfigInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := decodeJSON(w, r, &input); err != nil {
 		logError("Invalid JSON in notification config request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
 		return
 	}
 
@@ -806,7 +1020,7 @@ func (h *Handler) GetNotificationConfig(w http.ResponseWriter, r *http.Request)
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -816,7 +1030,7 @@ func (h *Handler) GetNotificationConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "notification config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotificationNotFound, "notification config not found")
 		return
 	}
 
@@ -846,22 +1060,21 @@ func (h *Handler) UpdateNotificationConfig(w http.ResponseWriter, r *http.Reques
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot update resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot update notification configurations")
+	// Demo and viewer accounts cannot update resources
+	if denyWrite(w, r, "update notification configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
 	var input models.NotificationConfigInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := decodeJSON(w, r, &input); err != nil {
 		logError("Invalid JSON in notification config update request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
 		return
 	}
 
@@ -888,7 +1101,7 @@ func (h *Handler) UpdateNotificationConfig(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "notification config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotificationNotFound, "notification config not found")
 		return
 	}
 
@@ -916,15 +1129,14 @@ func (h *Handler) DeleteNotificationConfig(w http.ResponseWriter, r *http.Reques
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot delete resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot delete notification configurations")
+	// Demo and viewer accounts cannot delete resources
+	if denyWrite(w, r, "delete notification configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -952,11 +1164,15 @@ func (h *Handler) DeleteNotificationConfig(w http.ResponseWriter, r *http.Reques
 
 // ListDatabaseConfigs godoc
 // @Summary List all database configurations
-// @Description Get a list of all configured PostgreSQL databases for backup. Connection details are masked for security.
+// @Description Get a list of all configured PostgreSQL databases for backup. Connection details are masked for security. stale is true when a scheduled backup is overdue: the schedule fired after the last successful backup and nothing succeeded within the freshness grace period.
 // @Tags Databases
 // @Produce json
 // @Security BearerAuth
+// @Param search query string false "Case-insensitive match on name or host"
+// @Param enabled query bool false "Filter by enabled flag"
+// @Param paused query bool false "Filter by paused flag"
 // @Success 200 {array} models.DatabaseConfigResponse "List of database configurations with masked sensitive data"
+// @Failure 400 {object} map[string]string "Invalid filter"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /databases [get]
 func (h *Handler) ListDatabaseConfigs(w http.ResponseWriter, r *http.Request) {
@@ -967,13 +1183,29 @@ func (h *Handler) ListDatabaseConfigs(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	configs, err := h.repo.ListDatabaseConfigsByUser(*userID, isAdmin)
+	query := r.URL.Query()
+	params := &models.DatabaseConfigListParams{Search: strings.TrimSpace(query.Get("search"))}
+	var err error
+	if params.Enabled, err = parseOptionalBool(query.Get("enabled")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid enabled filter: must be true or false")
+		return
+	}
+	if params.Paused, err = parseOptionalBool(query.Get("paused")); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid paused filter: must be true or false")
+		return
+	}
+
+	configs, err := h.repo.ListDatabaseConfigsByUser(*userID, isAdmin, params)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list database configs")
 		return
 	}
 	// Convert to response DTOs with masked sensitive data
 	responses := models.DatabaseConfigsToResponse(configs)
+	now := time.Now()
+	for i, config := range configs {
+		_, responses[i].Stale = h.scheduler.Overdue(config, now)
+	}
 	writeJSON(w, http.StatusOK, responses)
 }
 
@@ -986,7 +1218,7 @@ func (h *Handler) ListDatabaseConfigs(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param body body models.DatabaseConfigInput true "Database configuration"
 // @Success 201 {object} models.DatabaseConfig "Created database configuration"
-// @Failure 400 {object} validator.ValidationErrorResponse "Bad request"
+// @Failure 400 {object} validator.ValidationErrorResponse "Bad request, or host not allowed by the server's host policy"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /databases [post]
 func (h *Handler) CreateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
@@ -996,16 +1228,15 @@ func (h *Handler) CreateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Demo users cannot create resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot create database configurations")
+	// Demo and viewer accounts cannot create resources
+	if denyWrite(w, r, "create database configurations") {
 		return
 	}
 
 	var input models.DatabaseConfigInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := decodeJSON(w, r, &input); err != nil {
 		logError("Invalid JSON in database config request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
 		return
 	}
 
@@ -1020,6 +1251,15 @@ func (h *Handler) CreateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := input.ValidateBlobs(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if h.rejectDisallowedHost(w, input.Host) {
+		return
+	}
+
 	config, err := h.repo.CreateDatabaseConfig(*userID, &input)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create database config")
@@ -1039,7 +1279,9 @@ func (h *Handler) CreateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		"", getIPAddress(r))
 
 	// Return response DTO with masked sensitive data
-	writeJSON(w, http.StatusCreated, config.ToResponse())
+	resp := config.ToResponse()
+	resp.Warnings = h.databaseCredentialWarnings(&input)
+	writeJSON(w, http.StatusCreated, resp)
 }
 
 // GetDatabaseConfig godoc
@@ -1064,7 +1306,7 @@ func (h *Handler) GetDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1074,12 +1316,14 @@ func (h *Handler) GetDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "database config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
 		return
 	}
 
 	// Return response DTO with masked sensitive data
-	writeJSON(w, http.StatusOK, config.ToResponse())
+	resp := config.ToResponse()
+	_, resp.Stale = h.scheduler.Overdue(config, time.Now())
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // UpdateDatabaseConfig godoc
@@ -1092,7 +1336,7 @@ func (h *Handler) GetDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 // @Param id path string true "Database Config ID (UUID)"
 // @Param body body models.DatabaseConfigInput true "Updated database configuration"
 // @Success 200 {object} models.DatabaseConfig "Updated database configuration"
-// @Failure 400 {object} validator.ValidationErrorResponse "Bad request"
+// @Failure 400 {object} validator.ValidationErrorResponse "Bad request, or host not allowed by the server's host policy"
 // @Failure 404 {object} map[string]string "Database config not found"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /databases/{id} [put]
@@ -1104,22 +1348,21 @@ func (h *Handler) UpdateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot update resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot update database configurations")
+	// Demo and viewer accounts cannot update resources
+	if denyWrite(w, r, "update database configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
 	var input models.DatabaseConfigInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := decodeJSON(w, r, &input); err != nil {
 		logError("Invalid JSON in database config update request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
 		return
 	}
 
@@ -1134,13 +1377,22 @@ func (h *Handler) UpdateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := input.ValidateBlobs(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if h.rejectDisallowedHost(w, input.Host) {
+		return
+	}
+
 	config, err := h.repo.UpdateDatabaseConfigByUser(id, *userID, isAdmin, &input)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to update database config")
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "database config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
 		return
 	}
 
@@ -1157,7 +1409,9 @@ func (h *Handler) UpdateDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		"", getIPAddress(r))
 
 	// Return response DTO with masked sensitive data
-	writeJSON(w, http.StatusOK, config.ToResponse())
+	resp := config.ToResponse()
+	resp.Warnings = h.databaseCredentialWarnings(&input)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // DeleteDatabaseConfig godoc
@@ -1179,15 +1433,14 @@ func (h *Handler) DeleteDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot delete resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot delete database configurations")
+	// Demo and viewer accounts cannot delete resources
+	if denyWrite(w, r, "delete database configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1198,7 +1451,7 @@ func (h *Handler) DeleteDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		configName = config.Name
 	} else {
 		// Config not found or not authorized
-		writeError(w, http.StatusNotFound, "database config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
 		return
 	}
 
@@ -1239,15 +1492,14 @@ func (h *Handler) PauseDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot pause resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot pause database configurations")
+	// Demo and viewer accounts cannot pause resources
+	if denyWrite(w, r, "pause database configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1258,7 +1510,7 @@ func (h *Handler) PauseDatabaseConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "database config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
 		return
 	}
 
@@ -1306,15 +1558,14 @@ func (h *Handler) UnpauseDatabaseConfig(w http.ResponseWriter, r *http.Request)
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot unpause resources
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot unpause database configurations")
+	// Demo and viewer accounts cannot unpause resources
+	if denyWrite(w, r, "unpause database configurations") {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1325,7 +1576,7 @@ func (h *Handler) UnpauseDatabaseConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "database config not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
 		return
 	}
 
@@ -1359,80 +1610,159 @@ func (h *Handler) UnpauseDatabaseConfig(w http.ResponseWriter, r *http.Request)
 
 // TriggerManualBackup godoc
 // @Summary Trigger a manual backup
-// @Description Manually trigger a backup for a specific database configuration
+// @Description Manually trigger a backup for a specific database configuration. An optional retain_until keeps the backup out of rotation until that time.
 // @Tags Backups
+// @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Database Config ID (UUID)"
+// @Param request body ManualBackupRequest false "Optional backup options"
 // @Success 202 {object} models.Backup "Backup initiated successfully"
-// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 400 {object} map[string]string "Invalid ID or request body"
 // @Failure 404 {object} map[string]string "Database config not found"
+// @Failure 409 {object} map[string]string "Outside the database's backup window"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
 // @Router /databases/{id}/backup [post]
 func (h *Handler) TriggerManualBackup(w http.ResponseWriter, r *http.Request) {
+	backup, _, ok := h.triggerManualBackup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusAccepted, backup)
+}
+
+// triggerManualBackup validates and starts a manual backup for the
+// request's database. On failure it has written the error response and
+// returns false; done is closed when the backup finishes.
+func (h *Handler) triggerManualBackup(w http.ResponseWriter, r *http.Request) (*models.Backup, <-chan struct{}, bool) {
 	userID := getUserIDFromContext(r)
 	if userID == nil {
 		writeError(w, http.StatusUnauthorized, "unauthorized")
-		return
+		return nil, nil, false
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot trigger backups
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot trigger backups")
-		return
+	// Demo and viewer accounts cannot trigger backups
+	if denyWrite(w, r, "trigger backups") {
+		return nil, nil, false
+	}
+	if h.rejectDuringMaintenance(w) {
+		return nil, nil, false
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
-		return
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return nil, nil, false
+	}
+
+	var req ManualBackupRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return nil, nil, false
+	}
+	if err := validateRetainUntil(req.RetainUntil); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
 	}
 
 	config, err := h.repo.GetDatabaseConfigByUser(id, *userID, isAdmin)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to get database config")
-		return
+		return nil, nil, false
 	}
 	if config == nil {
-		writeError(w, http.StatusNotFound, "database config not found")
-		return
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return nil, nil, false
+	}
+
+	if h.rejectOutsideBackupWindow(w, config) {
+		return nil, nil, false
 	}
 
-	// Create backup record
-	backup, err := h.repo.CreateBackup(config.ID, models.BackupStatusPending)
+	backup, done, err := h.startBackup(config, models.BackupTriggerManual, userID, req.RetainUntil)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create backup")
-		return
+		return nil, nil, false
 	}
 
-	// Log backup trigger
+	// Log backup trigger. An admin acting on another user's database is
+	// recorded for both the admin and the owner.
+	audit := describeManualTrigger(*userID, config)
 	h.logActivity(userID, models.ActionBackupTriggered, models.LogLevelInfo,
-		"backup", &backup.ID, config.Name,
-		fmt.Sprintf("Manual backup triggered for database '%s'", config.Name),
-		"", getIPAddress(r))
+		"backup", &backup.ID, config.Name, audit.Description, audit.Metadata, getIPAddress(r))
+	if audit.OnBehalf {
+		h.logActivity(&config.UserID, models.ActionBackupTriggered, models.LogLevelInfo,
+			"backup", &backup.ID, config.Name, audit.OwnerDescription, audit.Metadata, getIPAddress(r))
+	}
+
+	return backup, done, true
+}
 
-	// Execute backup asynchronously, passing the backup ID to reuse the record
+// startBackup creates the backup record and runs the backup asynchronously,
+// passing the backup ID so the service reuses the record. The returned
+// channel is closed when the run ends. Notifications go to the database's
+// own channel, so an admin triggering someone else's backup still alerts
+// the owner.
+func (h *Handler) startBackup(config *models.DatabaseConfig, trigger models.BackupTrigger, userID *uuid.UUID, retainUntil *time.Time) (*models.Backup, <-chan struct{}, error) {
+	backup, err := h.createBackupRecord(config, trigger, userID, retainUntil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		if err := h.backupSvc.ExecuteBackupWithID(config, backup.ID); err != nil {
 			// Error is already logged in ExecuteBackupWithID
 		}
 	}()
 
-	writeJSON(w, http.StatusAccepted, backup)
+	return backup, done, nil
+}
+
+// createBackupRecord creates the pending backup record for a run of config
+// started by userID (nil for trigger URLs).
+func (h *Handler) createBackupRecord(config *models.DatabaseConfig, trigger models.BackupTrigger, userID *uuid.UUID, retainUntil *time.Time) (*models.Backup, error) {
+	backup, err := h.repo.CreateBackup(config.ID, models.BackupStatusPending, trigger, userID)
+	if err != nil {
+		return nil, err
+	}
+	if retainUntil != nil {
+		if err := h.repo.SetBackupRetainUntil(backup.ID, retainUntil); err != nil {
+			return nil, err
+		}
+		backup.RetainUntil = retainUntil
+	}
+	if userID != nil && *userID != config.UserID {
+		owner := config.UserID
+		if err := h.repo.SetBackupOnBehalfOf(backup.ID, owner); err != nil {
+			logError("record backup owner", err)
+		} else {
+			backup.OnBehalfOfUserID = &owner
+		}
+	}
+	return backup, nil
 }
 
 // Backup handlers
 
 // ListBackupsByDatabase godoc
 // @Summary List backups for a database
-// @Description Get a list of all backup history for a specific database configuration
+// @Description Get the backup history for a specific database configuration, newest first. The number of matches is returned in X-Total-Count. Without limit every match is returned.
 // @Tags Backups
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Database Config ID (UUID)"
+// @Param triggered_by query string false "Filter by trigger (schedule, manual, webhook, cli)"
+// @Param limit query int false "Page size (1-500)"
+// @Param offset query int false "Number of matches to skip"
 // @Success 200 {array} models.Backup "List of backups"
-// @Failure 400 {object} map[string]string "Invalid ID"
+// @Header 200 {integer} X-Total-Count "Matches before paging"
+// @Header 200 {integer} X-Limit "Page size (0 = unpaged)"
+// @Header 200 {integer} X-Offset "Matches skipped"
+// @Failure 400 {object} map[string]string "Invalid ID or filter"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /databases/{id}/backups [get]
 func (h *Handler) ListBackupsByDatabase(w http.ResponseWriter, r *http.Request) {
@@ -1445,26 +1775,47 @@ func (h *Handler) ListBackupsByDatabase(w http.ResponseWriter, r *http.Request)
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	params, err := parseBackupListParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	backups, err := h.repo.ListBackupsByDatabaseByUser(id, *userID, isAdmin)
+	backups, total, err := h.repo.ListBackupsByDatabaseByUser(id, *userID, isAdmin, params)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list backups")
 		return
 	}
 
+	setPaginationHeaders(w, r, total, params.Limit, params.Offset, nil)
 	writeJSON(w, http.StatusOK, backups)
 }
 
 // ListBackups godoc
 // @Summary List all backups
-// @Description Retrieve a list of all backups across all databases
+// @Description Retrieve backups across all databases, newest first, filtered in the database. The number of matches is returned in X-Total-Count. Without limit every match is returned.
 // @Tags Backups
 // @Produce json
 // @Security BearerAuth
+// @Param triggered_by query string false "Filter by trigger (schedule, manual, webhook, cli)"
+// @Param database_id query string false "Only backups of this database (UUID)"
+// @Param status query string false "Filter by status (pending, running, success, failed, deleted)"
+// @Param since query string false "Only backups started at or after this RFC 3339 time"
+// @Param until query string false "Only backups started before this RFC 3339 time"
+// @Param min_size query int false "Minimum size in bytes"
+// @Param max_size query int false "Maximum size in bytes"
+// @Param label_id query string false "Only backups of databases with this label (UUID)"
+// @Param limit query int false "Page size (1-500)"
+// @Param offset query int false "Number of matches to skip"
 // @Success 200 {array} models.Backup "List of all backups"
+// @Header 200 {integer} X-Total-Count "Matches before paging"
+// @Header 200 {integer} X-Limit "Page size (0 = unpaged)"
+// @Header 200 {integer} X-Offset "Matches skipped"
+// @Failure 400 {object} map[string]string "Invalid filter"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /backups [get]
 func (h *Handler) ListBackups(w http.ResponseWriter, r *http.Request) {
@@ -1475,18 +1826,25 @@ func (h *Handler) ListBackups(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	backups, err := h.repo.ListAllBackupsByUser(*userID, isAdmin)
+	params, err := parseBackupListParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	backups, total, err := h.repo.ListAllBackupsByUser(*userID, isAdmin, params)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list backups")
 		return
 	}
 
+	setPaginationHeaders(w, r, total, params.Limit, params.Offset, nil)
 	writeJSON(w, http.StatusOK, backups)
 }
 
 // GetBackup godoc
 // @Summary Get a backup by ID
-// @Description Retrieve details of a specific backup including status, size, and storage path
+// @Description Retrieve details of a specific backup including status, size, storage path, and the provider and (masked) bucket it was stored in
 // @Tags Backups
 // @Produce json
 // @Security BearerAuth
@@ -1506,7 +1864,7 @@ func (h *Handler) GetBackup(w http.ResponseWriter, r *http.Request) {
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1516,26 +1874,118 @@ func (h *Handler) GetBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if backup == nil {
-		writeError(w, http.StatusNotFound, "backup not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
 		return
 	}
+	backup.ResolveLocation()
 
 	writeJSON(w, http.StatusOK, backup)
 }
 
+// GetBackupEvents godoc
+// @Summary Get a backup's timeline
+// @Description Retrieve the timestamped phases (dump, upload, cleanup) recorded while the backup ran, oldest first
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 200 {array} models.BackupEvent "Backup timeline"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /backups/{id}/events [get]
+func (h *Handler) GetBackupEvents(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+
+	events, err := h.repo.ListBackupEvents(backup.ID)
+	if err != nil {
+		logError("Failed to list backup events", err)
+		writeError(w, http.StatusInternalServerError, "failed to list backup events")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// GetBackupProgress godoc
+// @Summary Get a backup's transfer progress
+// @Description Report bytes transferred for the backup's running upload, or for the download of a restore from it. active is false when nothing is transferring. updated_at is when bytes last moved, so a stale value points at a stall.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Success 200 {object} models.BackupProgress "Transfer progress"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /backups/{id}/progress [get]
+func (h *Handler) GetBackupProgress(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.backupSvc.Progress(backup.ID))
+}
+
+// restoreDryRunTimeout bounds downloading a backup and listing its contents
+// for a dry-run restore.
+const restoreDryRunTimeout = 10 * time.Minute
+
 // RestoreBackup godoc
 // @Summary Restore a backup
-// @Description Restore a PostgreSQL database from a backup. Can restore to the original database or a different target.
+// @Description Restore a PostgreSQL database from a backup. Can restore to the original database, a different target, or the database of another config you own (target_database_id). Set tables to restore only those tables from a custom-format backup. Set dry_run to validate a custom-format backup and get its table of contents without touching any database.
 // @Tags Backups
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Backup ID (UUID)"
 // @Param body body models.RestoreRequest false "Restore configuration (optional for custom target)"
+// @Success 200 {object} backup.RestoreDryRun "Dry run result"
 // @Success 202 {object} models.RestoreJob "Restore job created successfully"
-// @Failure 400 {object} map[string]string "Invalid ID or request body"
-// @Failure 404 {object} map[string]string "Backup not found"
+// @Failure 400 {object} map[string]string "Invalid ID or request body, target host not allowed, or target host without target password"
+// @Failure 404 {object} map[string]string "Backup or target database config not found"
 // @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
 // @Router /backups/{id}/restore [post]
 func (h *Handler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 	userID := getUserIDFromContext(r)
@@ -1545,15 +1995,17 @@ func (h *Handler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 	}
 	isAdmin := getIsAdminFromContext(r)
 
-	// Demo users cannot restore backups
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot restore backups")
+	// Demo and viewer accounts cannot restore backups
+	if denyWrite(w, r, "restore backups") {
+		return
+	}
+	if h.rejectDuringMaintenance(w) {
 		return
 	}
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1564,24 +2016,84 @@ func (h *Handler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if backup == nil {
-		writeError(w, http.StatusNotFound, "backup not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
 		return
 	}
 
 	var req models.RestoreRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid JSON in restore request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body: "+err.Error())
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
+		return
+	}
+	if err := req.ValidateTargetDatabase(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.ApplyTargetURL(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid target_url: "+err.Error())
+		return
+	}
+	// Unset target fields default to the source database, or to the
+	// target config when one is named. The user must be able to see it.
+	targetDefaults := &backup.Database
+	if req.TargetDatabaseID != nil {
+		targetDB, err := h.repo.GetDatabaseConfigByUser(*req.TargetDatabaseID, *userID, isAdmin)
+		if err != nil {
+			logError("Failed to get restore target database config", err)
+			writeError(w, http.StatusInternalServerError, "failed to get target database config")
+			return
+		}
+		if targetDB == nil {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "target database config not found")
+			return
+		}
+		targetDefaults = targetDB
+	}
+	if err := req.ValidateTargetCredentials(targetDefaults); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.rejectDisallowedHost(w, cmp.Or(req.TargetHost, targetDefaults.Host)) {
+		return
+	}
+	if err := req.ValidateTables(backup.DumpFormat); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.DryRun {
+		if backup.DumpFormat != models.DumpFormatCustom {
+			writeError(w, http.StatusBadRequest, "dry runs require a custom-format backup")
+			return
+		}
+		// Downloading the dump outlasts the server's WriteTimeout.
+		extendWriteDeadline(w, restoreDryRunTimeout)
+		ctx, cancel := context.WithTimeout(r.Context(), restoreDryRunTimeout)
+		defer cancel()
+		result, err := h.backupSvc.DryRunRestore(ctx, backup.ID, &req)
+		if err != nil {
+			logError("Restore dry run failed", err)
+			writeError(w, http.StatusInternalServerError, "failed to validate backup: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
 		return
 	}
 
 	// Audit: someone (real user, demo is blocked above) asked us to restore.
 	// The backup service will emit started/completed/failed entries on its
 	// own as the job progresses.
+	target := utils.MaskConnectionTarget(
+		cmp.Or(req.TargetUser, targetDefaults.Username),
+		cmp.Or(req.TargetHost, targetDefaults.Host),
+		cmp.Or(req.TargetPort, targetDefaults.Port),
+		cmp.Or(req.TargetDBName, targetDefaults.DBName))
+	triggeredMeta, _ := json.Marshal(map[string]string{"target": target})
 	h.logActivity(userID, models.ActionRestoreTriggered, models.LogLevelInfo,
 		"backup", &backup.ID, backup.Name,
-		fmt.Sprintf("Restore triggered for backup %q", backup.Name),
-		"", getIPAddress(r))
+		fmt.Sprintf("Restore triggered for backup %q into %s", backup.Name, target),
+		string(triggeredMeta), getIPAddress(r))
 
 	// Execute restore asynchronously
 	go func() {
@@ -1611,8 +2123,14 @@ func (h *Handler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
 // @Param start_date query string false "Filter by start date (RFC3339 format)"
 // @Param end_date query string false "Filter by end date (RFC3339 format)"
 // @Param limit query int false "Number of records to return (default: 50)"
-// @Param offset query int false "Number of records to skip (default: 0)"
-// @Success 200 {object} map[string]interface{} "Activity logs with pagination info"
+// @Param offset query int false "Number of records to skip (default: 0). Ignored when cursor is set"
+// @Param cursor query string false "Return logs after this next_cursor from a previous page"
+// @Param include_metadata query bool false "Include the metadata field (default: true)"
+// @Success 200 {object} map[string]interface{} "Activity logs with pagination info and next_cursor"
+// @Header 200 {integer} X-Total-Count "Total matching logs"
+// @Header 200 {integer} X-Limit "Requested page size (0 means the default)"
+// @Header 200 {integer} X-Offset "Offset of this page"
+// @Header 200 {string} Link "rel=\"next\" URL of the next page, when there is one"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /logs [get]
@@ -1676,6 +2194,23 @@ func (h *Handler) ListActivityLogs(w http.ResponseWriter, r *http.Request) {
 		fmt.Sscanf(offsetStr, "%d", &params.Offset)
 	}
 
+	includeMetadata, err := parseOptionalBool(query.Get("include_metadata"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid include_metadata: must be true or false")
+		return
+	}
+	params.ExcludeMetadata = includeMetadata != nil && !*includeMetadata
+
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := models.ParseActivityLogCursor(cursorStr)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		params.Cursor = cursor
+		params.Offset = 0
+	}
+
 	// Retrieve logs filtered by user
 	logs, total, err := h.repo.ListActivityLogsByUser(*userID, isAdmin, params)
 	if err != nil {
@@ -1683,12 +2218,26 @@ func (h *Handler) ListActivityLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return logs with pagination info
+	// A full page may have more behind it; hand out the position of its
+	// last entry. A short page is the end.
+	pageSize := params.Limit
+	if pageSize <= 0 {
+		pageSize = models.DefaultActivityLogLimit
+	}
+	var nextCursor *string
+	if n := len(logs); n > 0 && n == pageSize {
+		c := models.ActivityLogCursor{CreatedAt: logs[n-1].CreatedAt, ID: logs[n-1].ID}.Encode()
+		nextCursor = &c
+	}
+
+	// Return logs with pagination info, mirrored in headers
+	setPaginationHeaders(w, r, total, params.Limit, params.Offset, nextCursor)
 	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"logs":   logs,
-		"total":  total,
-		"limit":  params.Limit,
-		"offset": params.Offset,
+		"logs":        logs,
+		"total":       total,
+		"limit":       params.Limit,
+		"offset":      params.Offset,
+		"next_cursor": nextCursor,
 	})
 }
 
@@ -1714,7 +2263,7 @@ func (h *Handler) GetActivityLog(w http.ResponseWriter, r *http.Request) {
 
 	id, err := parseUUID(mux.Vars(r)["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
 		return
 	}
 
@@ -1724,7 +2273,7 @@ func (h *Handler) GetActivityLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if log == nil {
-		writeError(w, http.StatusNotFound, "activity log not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeActivityLogNotFound, "activity log not found")
 		return
 	}
 
@@ -1759,6 +2308,20 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, stats)
 }
 
+// GetSchedulerStatus godoc
+// @Summary Get scheduler status
+// @Description Live counts of scheduled backups queued for a free slot, running, and finished in the last hour, plus the configured concurrency limit (0 = unlimited). Read from the scheduler's memory; manual backups are not included. Restores running and queued under their own limit are reported alongside.
+// @Tags Statistics
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SchedulerStatus "Scheduler status"
+// @Router /scheduler/status [get]
+func (h *Handler) GetSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	status := h.scheduler.Status()
+	status.RestoresRunning, status.RestoresQueued, status.MaxConcurrentRestores = h.backupSvc.RestoreStatus()
+	writeJSON(w, http.StatusOK, status)
+}
+
 // Helper functions
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -1769,12 +2332,29 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
+// writeError writes an APIError carrying the generic code for status.
 func writeError(w http.ResponseWriter, status int, message string) {
-	log.Printf("[ERROR] ❌ HTTP %d: %s", status, message)
+	writeErrorCode(w, status, models.ErrorCodeForStatus(status), message)
+}
+
+// writeConflict writes a 409 naming the duplicated field when err is a
+// *repository.ConflictError, and reports whether it did.
+func writeConflict(w http.ResponseWriter, err error) bool {
+	var conflict *repository.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	writeErrorCode(w, http.StatusConflict, models.ErrCodeConflict, conflict.Error())
+	return true
+}
+
+// writeErrorCode writes an APIError with a specific machine-readable code.
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	logging.Warnf("[ERROR] ❌ HTTP %d %s: %s", status, code, message)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(models.APIError{
-		Code:    http.StatusText(status),
+		Code:    code,
 		Message: message,
 	}); err != nil {
 		log.Printf("[HANDLER] ❌ Error encoding error response: %v", err)
@@ -1782,7 +2362,7 @@ func writeError(w http.ResponseWriter, status int, message string) {
 }
 
 func writeValidationError(w http.ResponseWriter, validationErr *validator.ValidationErrorResponse) {
-	log.Printf("[VALIDATION] ❌ %s", validationErr.Message)
+	logging.Warnf("[VALIDATION] ❌ %s", validationErr.Message)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusBadRequest)
 	if err := json.NewEncoder(w).Encode(validationErr); err != nil {
@@ -1791,11 +2371,17 @@ func writeValidationError(w http.ResponseWriter, validationErr *validator.Valida
 }
 
 func logError(context string, err error) {
-	log.Printf("[ERROR] ❌ %s: %v", context, err)
+	logging.Errorf("[ERROR] ❌ %s: %v", context, err)
 }
 
 func logInfo(format string, args ...interface{}) {
-	log.Printf("[INFO] ℹ️  "+format, args...)
+	logging.Infof("[INFO] ℹ️  "+format, args...)
+}
+
+// logDebug is for per-step detail, such as each stage of the login flow,
+// that production deployments should not have to read.
+func logDebug(format string, args ...interface{}) {
+	logging.Debugf("[DEBUG] 🔍 "+format, args...)
 }
 
 func parseUUID(s string) (uuid.UUID, error) {
@@ -1806,6 +2392,93 @@ func parseUUID(s string) (uuid.UUID, error) {
 	return id, err
 }
 
+// parseOptionalBool parses an optional boolean query value. An empty string
+// yields nil so the filter is not applied.
+func parseOptionalBool(raw string) (*bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// parseOptionalTime parses an optional RFC 3339 query value. An empty
+// string yields nil.
+func parseOptionalTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseBackupListParams reads the backup list filters and paging from the
+// query string.
+func parseBackupListParams(r *http.Request) (*models.BackupListParams, error) {
+	query := r.URL.Query()
+	params := &models.BackupListParams{}
+	if raw := query.Get("triggered_by"); raw != "" {
+		trigger := models.BackupTrigger(raw)
+		if !trigger.Valid() {
+			return nil, fmt.Errorf("invalid triggered_by filter: must be schedule, manual, webhook or cli")
+		}
+		params.TriggeredBy = &trigger
+	}
+	if raw := query.Get("status"); raw != "" {
+		status := models.BackupStatus(raw)
+		if !status.Valid() {
+			return nil, fmt.Errorf("invalid status filter: must be pending, running, success, failed or deleted")
+		}
+		params.Status = &status
+	}
+	for name, dst := range map[string]**uuid.UUID{"database_id": &params.DatabaseID, "label_id": &params.LabelID} {
+		if raw := query.Get(name); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s filter: must be a UUID", name)
+			}
+			*dst = &id
+		}
+	}
+	var err error
+	if params.Since, err = parseOptionalTime(query.Get("since")); err != nil {
+		return nil, fmt.Errorf("invalid since filter: must be an RFC 3339 time")
+	}
+	if params.Until, err = parseOptionalTime(query.Get("until")); err != nil {
+		return nil, fmt.Errorf("invalid until filter: must be an RFC 3339 time")
+	}
+	for name, dst := range map[string]**int64{"min_size": &params.MinSizeBytes, "max_size": &params.MaxSizeBytes} {
+		if raw := query.Get(name); raw != "" {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s filter: must be a non-negative number of bytes", name)
+			}
+			*dst = &n
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > models.MaxBackupListLimit {
+			return nil, fmt.Errorf("invalid limit: must be between 1 and %d", models.MaxBackupListLimit)
+		}
+		params.Limit = n
+	}
+	if raw := query.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset: must be a non-negative number")
+		}
+		params.Offset = n
+	}
+	return params, nil
+}
+
 // getUserIDFromContext extracts user ID from request context
 func getUserIDFromContext(r *http.Request) *uuid.UUID {
 	// The middleware stores auth.Claims with key middleware.UserContextKey
@@ -1818,11 +2491,14 @@ func getUserIDFromContext(r *http.Request) *uuid.UUID {
 	return nil
 }
 
-// getIsAdminFromContext extracts admin status from request context
+// getIsAdminFromContext reports whether the request has admin scope, i.e.
+// sees every user's data. Viewers get it for reads only; their writes are
+// refused before reaching a handler, and a misrouted one must not act with
+// admin scope.
 func getIsAdminFromContext(r *http.Request) bool {
 	if claims := r.Context().Value(middleware.UserContextKey); claims != nil {
 		if authClaims, ok := claims.(*auth.Claims); ok {
-			return authClaims.IsAdmin
+			return authClaims.IsAdmin || (authClaims.IsViewer && middleware.IsReadMethod(r.Method))
 		}
 	}
 	return false
@@ -1838,6 +2514,31 @@ func isDemoUserFromContext(r *http.Request) bool {
 	return false
 }
 
+// isViewerFromContext checks if the current user is a read-only viewer
+func isViewerFromContext(r *http.Request) bool {
+	if claims := r.Context().Value(middleware.UserContextKey); claims != nil {
+		if authClaims, ok := claims.(*auth.Claims); ok {
+			return authClaims.IsViewer
+		}
+	}
+	return false
+}
+
+// denyWrite is the write-guard shared by mutating handlers. Demo and viewer
+// accounts are read-only: it writes a 403 naming the action (e.g. "create
+// labels") and returns true for them.
+func denyWrite(w http.ResponseWriter, r *http.Request, action string) bool {
+	switch {
+	case isDemoUserFromContext(r):
+		writeErrorCode(w, http.StatusForbidden, models.ErrCodeDemoForbidden, "demo users cannot "+action)
+	case isViewerFromContext(r):
+		writeErrorCode(w, http.StatusForbidden, models.ErrCodeViewerReadOnly, "viewers cannot "+action)
+	default:
+		return false
+	}
+	return true
+}
+
 // getIPAddress extracts the client IP using the shared auth helper, which
 // honors TRUST_PROXY_HEADERS and validates header values to block spoofing
 // and log injection. Wrapped here so existing call sites compile unchanged.
@@ -1897,6 +2598,7 @@ func (h *Handler) ListLabels(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {object} map[string]string "Bad request or validation error"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden (demo user)"
+// @Failure 409 {object} models.APIError "A label with this name already exists"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security BearerAuth
 // @Router /labels [post]
@@ -1907,14 +2609,13 @@ func (h *Handler) CreateLabel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot create labels")
+	if denyWrite(w, r, "create labels") {
 		return
 	}
 
 	var input models.LabelInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
 		return
 	}
 
@@ -1929,6 +2630,9 @@ func (h *Handler) CreateLabel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	label, err := h.repo.CreateLabel(*userID, &input)
+	if writeConflict(w, err) {
+		return
+	}
 	if err != nil {
 		logError("Failed to create label", err)
 		if err.Error() == "label limit reached: maximum 50 labels per user" {
@@ -1974,7 +2678,7 @@ func (h *Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid label ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
 		return
 	}
 
@@ -1985,7 +2689,7 @@ func (h *Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if label == nil {
-		writeError(w, http.StatusNotFound, "label not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeLabelNotFound, "label not found")
 		return
 	}
 
@@ -2005,7 +2709,7 @@ func (h *Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	writeError(w, http.StatusNotFound, "label not found")
+	writeErrorCode(w, http.StatusNotFound, models.ErrCodeLabelNotFound, "label not found")
 }
 
 // UpdateLabel godoc
@@ -2021,6 +2725,7 @@ func (h *Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden (demo user)"
 // @Failure 404 {object} map[string]string "Label not found"
+// @Failure 409 {object} models.APIError "Another label has this name"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security BearerAuth
 // @Router /labels/{id} [put]
@@ -2031,8 +2736,7 @@ func (h *Handler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot update labels")
+	if denyWrite(w, r, "update labels") {
 		return
 	}
 
@@ -2040,13 +2744,13 @@ func (h *Handler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid label ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
 		return
 	}
 
 	var input models.LabelInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
 		return
 	}
 
@@ -2061,10 +2765,13 @@ func (h *Handler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	label, err := h.repo.UpdateLabel(id, *userID, isAdmin, &input)
+	if writeConflict(w, err) {
+		return
+	}
 	if err != nil {
 		logError("Failed to update label", err)
-		if err == fmt.Errorf("record not found") {
-			writeError(w, http.StatusNotFound, "label not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeLabelNotFound, "label not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "failed to update label")
@@ -2110,8 +2817,7 @@ func (h *Handler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot delete labels")
+	if denyWrite(w, r, "delete labels") {
 		return
 	}
 
@@ -2119,7 +2825,7 @@ func (h *Handler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid label ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
 		return
 	}
 
@@ -2134,7 +2840,7 @@ func (h *Handler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logError("Failed to delete label", err)
 		if err == fmt.Errorf("record not found") {
-			writeError(w, http.StatusNotFound, "label not found")
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeLabelNotFound, "label not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "failed to delete label")
@@ -2175,8 +2881,7 @@ func (h *Handler) AssignLabelsToDatabase(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot assign labels")
+	if denyWrite(w, r, "assign labels") {
 		return
 	}
 
@@ -2184,13 +2889,13 @@ func (h *Handler) AssignLabelsToDatabase(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	dbID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid database ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid database ID")
 		return
 	}
 
 	var input models.AssignLabelsInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
 		return
 	}
 
@@ -2251,8 +2956,7 @@ func (h *Handler) RemoveLabelFromDatabase(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot remove labels")
+	if denyWrite(w, r, "remove labels") {
 		return
 	}
 
@@ -2260,22 +2964,31 @@ func (h *Handler) RemoveLabelFromDatabase(w http.ResponseWriter, r *http.Request
 	vars := mux.Vars(r)
 	dbID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid database ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid database ID")
 		return
 	}
 	labelID, err := uuid.Parse(vars["labelId"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid label ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
 		return
 	}
 
 	err = h.repo.RemoveLabelFromDatabase(dbID, labelID, *userID, isAdmin)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "database or label not found")
+			return
+		}
 		logError("Failed to remove label from database", err)
 		writeError(w, http.StatusInternalServerError, "failed to remove label")
 		return
 	}
 
+	if entity, err := h.repo.GetDatabaseWithLabels(dbID, *userID, isAdmin); err == nil && entity != nil {
+		h.logActivity(userID, models.ActionDatabaseUpdated, models.LogLevelSuccess, "database", &entity.ID, entity.Name,
+			fmt.Sprintf("Removed a label from database '%s'", entity.Name), "", getIPAddress(r))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -2306,8 +3019,7 @@ func (h *Handler) AssignLabelsToStorage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot assign labels")
+	if denyWrite(w, r, "assign labels") {
 		return
 	}
 
@@ -2315,13 +3027,13 @@ func (h *Handler) AssignLabelsToStorage(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	storageID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid storage ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid storage ID")
 		return
 	}
 
 	var input models.AssignLabelsInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
 		return
 	}
 
@@ -2382,8 +3094,7 @@ func (h *Handler) RemoveLabelFromStorage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot remove labels")
+	if denyWrite(w, r, "remove labels") {
 		return
 	}
 
@@ -2391,22 +3102,31 @@ func (h *Handler) RemoveLabelFromStorage(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	storageID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid storage ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid storage ID")
 		return
 	}
 	labelID, err := uuid.Parse(vars["labelId"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid label ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
 		return
 	}
 
 	err = h.repo.RemoveLabelFromStorage(storageID, labelID, *userID, isAdmin)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "storage or label not found")
+			return
+		}
 		logError("Failed to remove label from storage", err)
 		writeError(w, http.StatusInternalServerError, "failed to remove label")
 		return
 	}
 
+	if entity, err := h.repo.GetStorageWithLabels(storageID, *userID, isAdmin); err == nil && entity != nil {
+		h.logActivity(userID, models.ActionStorageUpdated, models.LogLevelSuccess, "storage", &entity.ID, entity.Name,
+			fmt.Sprintf("Removed a label from storage '%s'", entity.Name), "", getIPAddress(r))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -2437,8 +3157,7 @@ func (h *Handler) AssignLabelsToNotification(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot assign labels")
+	if denyWrite(w, r, "assign labels") {
 		return
 	}
 
@@ -2446,13 +3165,13 @@ func (h *Handler) AssignLabelsToNotification(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	notifID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid notification ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid notification ID")
 		return
 	}
 
 	var input models.AssignLabelsInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON")
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
 		return
 	}
 
@@ -2513,8 +3232,7 @@ func (h *Handler) RemoveLabelFromNotification(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot remove labels")
+	if denyWrite(w, r, "remove labels") {
 		return
 	}
 
@@ -2522,21 +3240,30 @@ func (h *Handler) RemoveLabelFromNotification(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	notifID, err := uuid.Parse(vars["id"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid notification ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid notification ID")
 		return
 	}
 	labelID, err := uuid.Parse(vars["labelId"])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid label ID")
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
 		return
 	}
 
 	err = h.repo.RemoveLabelFromNotification(notifID, labelID, *userID, isAdmin)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "notification or label not found")
+			return
+		}
 		logError("Failed to remove label from notification", err)
 		writeError(w, http.StatusInternalServerError, "failed to remove label")
 		return
 	}
 
+	if entity, err := h.repo.GetNotificationWithLabels(notifID, *userID, isAdmin); err == nil && entity != nil {
+		h.logActivity(userID, models.ActionNotificationUpdated, models.LogLevelSuccess, "notification", &entity.ID, entity.Name,
+			fmt.Sprintf("Removed a label from notification '%s'", entity.Name), "", getIPAddress(r))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
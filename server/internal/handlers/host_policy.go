@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// rejectDisallowedHost answers 400 and returns true when host is outside the
+// configured DATABASE_HOST_ALLOWLIST/DATABASE_HOST_DENYLIST. Handlers call
+// it before connecting to a user-supplied host; the backup service checks
+// the host again when a run starts.
+func (h *Handler) rejectDisallowedHost(w http.ResponseWriter, host string) bool {
+	if err := h.hostPolicy.Check(host); err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeHostNotAllowed, err.Error())
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// TestAdHocServerTestHonoursHostPolicy checks that the "test before saving"
+// probe cannot be used to reach a host the policy denies.
+func TestAdHocServerTestHonoursHostPolicy(t *testing.T) {
+	policy, err := utils.NewHostPolicy(nil, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("NewHostPolicy: %v", err)
+	}
+	h := &Handler{hostPolicy: policy}
+	body := `{"host":"10.1.2.3","port":5432,"user":"postgres","password":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/server-connections/test", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.TestServerConnectionAdHoc(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "host policy") {
+		t.Errorf("body = %s, want a host policy error", rec.Body.String())
+	}
+}
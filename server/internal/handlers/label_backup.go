@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// LabelBackupStarted is one backup started by a label-wide trigger.
+type LabelBackupStarted struct {
+	DatabaseID   string `json:"database_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	DatabaseName string `json:"database_name" example:"orders"`
+	BackupID     string `json:"backup_id" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"`
+}
+
+// LabelBackupSkipped is a labelled database that was not backed up.
+type LabelBackupSkipped struct {
+	DatabaseID   string `json:"database_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	DatabaseName string `json:"database_name" example:"analytics"`
+	Reason       string `json:"reason" example:"outside backup window 01:00-05:00"`
+}
+
+// LabelBackupResponse lists the backups a label-wide trigger created.
+type LabelBackupResponse struct {
+	LabelID string               `json:"label_id" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	Started []LabelBackupStarted `json:"started"`
+	Skipped []LabelBackupSkipped `json:"skipped"`
+}
+
+// TriggerLabelBackup godoc
+// @Summary Back up every database with a label
+// @Description Start a manual backup of each database carrying the label, e.g. for a coordinated pre-release snapshot. The backup records are created immediately; the runs share SCHEDULER_MAX_CONCURRENT with scheduled backups and wait for a free slot. Databases outside their backup window are skipped and listed.
+// @Tags Labels
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Label ID"
+// @Success 202 {object} LabelBackupResponse "Backups initiated"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} map[string]string "Demo or viewer account"
+// @Failure 404 {object} map[string]string "Label not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
+// @Router /labels/{id}/backup [post]
+func (h *Handler) TriggerLabelBackup(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	if denyWrite(w, r, "trigger backups") {
+		return
+	}
+	if h.rejectDuringMaintenance(w) {
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid label ID")
+		return
+	}
+
+	label, err := h.repo.GetLabel(id, *userID, isAdmin)
+	if err != nil {
+		logError("Failed to get label", err)
+		writeError(w, http.StatusInternalServerError, "failed to get label")
+		return
+	}
+	if label == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeLabelNotFound, "label not found")
+		return
+	}
+
+	configs, err := h.repo.ListDatabasesByLabel(label.ID, *userID, isAdmin)
+	if err != nil {
+		logError("Failed to list databases by label", err)
+		writeError(w, http.StatusInternalServerError, "failed to list databases")
+		return
+	}
+
+	resp := LabelBackupResponse{
+		LabelID: label.ID.String(),
+		Started: []LabelBackupStarted{},
+		Skipped: []LabelBackupSkipped{},
+	}
+	now := time.Now()
+	for _, config := range configs {
+		if !config.InBackupWindow(now) {
+			resp.Skipped = append(resp.Skipped, LabelBackupSkipped{
+				DatabaseID:   config.ID.String(),
+				DatabaseName: config.Name,
+				Reason:       fmt.Sprintf("outside backup window %s", config.BackupWindow),
+			})
+			continue
+		}
+
+		backup, err := h.createBackupRecord(config, models.BackupTriggerManual, userID, nil)
+		if err != nil {
+			logError(fmt.Sprintf("Failed to create backup for %s", config.Name), err)
+			resp.Skipped = append(resp.Skipped, LabelBackupSkipped{
+				DatabaseID:   config.ID.String(),
+				DatabaseName: config.Name,
+				Reason:       "failed to create backup",
+			})
+			continue
+		}
+		go h.runQueuedBackup(config, backup.ID)
+
+		audit := describeManualTrigger(*userID, config)
+		fields := map[string]string{"label_id": label.ID.String(), "label": label.Name}
+		if audit.OnBehalf {
+			fields["owner_user_id"] = config.UserID.String()
+		}
+		meta, _ := json.Marshal(fields)
+		h.logActivity(userID, models.ActionBackupTriggered, models.LogLevelInfo,
+			"backup", &backup.ID, config.Name,
+			fmt.Sprintf("%s (label '%s')", audit.Description, label.Name), string(meta), getIPAddress(r))
+		if audit.OnBehalf {
+			h.logActivity(&config.UserID, models.ActionBackupTriggered, models.LogLevelInfo,
+				"backup", &backup.ID, config.Name, audit.OwnerDescription, audit.Metadata, getIPAddress(r))
+		}
+
+		resp.Started = append(resp.Started, LabelBackupStarted{
+			DatabaseID:   config.ID.String(),
+			DatabaseName: config.Name,
+			BackupID:     backup.ID.String(),
+		})
+	}
+
+	logInfo("Label backup for '%s': %d started, %d skipped", label.Name, len(resp.Started), len(resp.Skipped))
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// runQueuedBackup waits for a scheduler slot, then runs the backup whose
+// record was already created.
+func (h *Handler) runQueuedBackup(config *models.DatabaseConfig, backupID uuid.UUID) {
+	// Errors are already logged in ExecuteBackupWithID.
+	_ = h.scheduler.RunQueued(func() error {
+		return h.backupSvc.ExecuteBackupWithID(config, backupID)
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+)
+
+// TestSendLoginOTPKeepsRateLimit checks that when Discord is rate limiting
+// and the email fallback fails too, the joined error still carries the
+// RateLimitError Login turns into a 503 with Retry-After.
+func TestSendLoginOTPKeepsRateLimit(t *testing.T) {
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(discord.Close)
+
+	// A listener closed straight away leaves a port nothing answers on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	smtpPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	h := &Handler{
+		notifier:    notification.NewDiscordNotifier(discord.URL, ""),
+		otpFallback: notification.NewEmailNotifier("127.0.0.1", smtpPort, "", "", "dumpstation@example.com", []string{"ops@example.com"}),
+	}
+	channel, err := h.sendLoginOTP(&models.User{}, "123456")
+	if err == nil {
+		t.Fatalf("sendLoginOTP succeeded via %q, want both channels to fail", channel)
+	}
+	var rl *notification.RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter != 30*time.Second {
+		t.Errorf("error = %v, want a RateLimitError asking for 30s", err)
+	}
+	if !strings.Contains(err.Error(), "SMTP") {
+		t.Errorf("error = %v, want the email fallback to have been tried", err)
+	}
+}
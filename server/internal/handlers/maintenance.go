@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// MaintenanceRequest toggles the global maintenance switch.
+type MaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" validate:"required"`
+	Reason  string `json:"reason" validate:"max=500"`
+}
+
+// rejectDuringMaintenance answers 503 and returns true when maintenance mode
+// is on. Handlers that start backups or restores call it before doing any
+// work; reads are never gated.
+func (h *Handler) rejectDuringMaintenance(w http.ResponseWriter) bool {
+	if !h.scheduler.InMaintenance() {
+		return false
+	}
+	w.Header().Set("Retry-After", "300")
+	writeErrorCode(w, http.StatusServiceUnavailable, models.ErrCodeMaintenance, "maintenance mode is enabled; backups and restores are paused")
+	return true
+}
+
+// GetMaintenance godoc
+// @Summary  Get maintenance mode
+// @Description Returns whether global maintenance mode is enabled. Admin only.
+// @Tags     Admin
+// @Security BearerAuth
+// @Produce  json
+// @Success  200 {object} models.MaintenanceState
+// @Failure  403 {object} map[string]string "Admin access required"
+// @Failure  500 {object} map[string]string "Internal server error"
+// @Router   /admin/maintenance [get]
+func (h *Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	state, err := h.repo.GetMaintenanceState()
+	if err != nil {
+		logError("Failed to get maintenance state", err)
+		writeError(w, http.StatusInternalServerError, "failed to get maintenance state")
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// SetMaintenance godoc
+// @Summary  Enable or disable maintenance mode
+// @Description While enabled, scheduled backups are skipped and manual, trigger-URL and restore requests return 503. Scheduled jobs are kept and resume when disabled. The state survives restarts. Admin only.
+// @Tags     Admin
+// @Security BearerAuth
+// @Accept   json
+// @Produce  json
+// @Param    request body MaintenanceRequest true "Maintenance switch"
+// @Success  200 {object} models.MaintenanceState
+// @Failure  400 {object} map[string]string "Invalid request body"
+// @Failure  403 {object} map[string]string "Admin access required"
+// @Failure  500 {object} map[string]string "Internal server error"
+// @Router   /admin/maintenance [put]
+func (h *Handler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input MaintenanceRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
+		return
+	}
+	if validationErr, err := h.validator.Validate(&input); validationErr != nil {
+		writeValidationError(w, validationErr)
+		return
+	} else if err != nil {
+		logError("Validation error", err)
+		writeError(w, http.StatusInternalServerError, "validation failed")
+		return
+	}
+
+	// Persist first so a restart never disagrees with what the API reported.
+	state, err := h.repo.SetMaintenanceState(*input.Enabled, input.Reason, userID)
+	if err != nil {
+		logError("Failed to set maintenance state", err)
+		writeError(w, http.StatusInternalServerError, "failed to set maintenance state")
+		return
+	}
+	h.scheduler.SetMaintenance(state.Enabled)
+
+	action, desc := models.ActionMaintenanceDisabled, "Maintenance mode disabled; backups resumed"
+	if state.Enabled {
+		action, desc = models.ActionMaintenanceEnabled, "Maintenance mode enabled; backups paused"
+		if state.Reason != "" {
+			desc = fmt.Sprintf("%s (%s)", desc, state.Reason)
+		}
+	}
+	h.logActivity(userID, action, models.LogLevelWarning, "system", nil, "System", desc, "", getIPAddress(r))
+
+	writeJSON(w, http.StatusOK, state)
+}
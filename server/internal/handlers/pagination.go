@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// setPaginationHeaders mirrors a page's total, limit and offset into
+// X-Total-Count, X-Limit and X-Offset for clients and data grids that read
+// pagination from headers rather than the body. When nextCursor is set the
+// next page is also linked as rel="next", with the request's filters kept.
+// Browsers can read these because they are in the default
+// CORS_EXPOSED_HEADERS.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, total int64, limit, offset int, nextCursor *string) {
+	h := w.Header()
+	h.Set("X-Total-Count", strconv.FormatInt(total, 10))
+	h.Set("X-Limit", strconv.Itoa(limit))
+	h.Set("X-Offset", strconv.Itoa(offset))
+	if nextCursor != nil {
+		next := *r.URL
+		q := next.Query()
+		q.Set("cursor", *nextCursor)
+		q.Del("offset")
+		next.RawQuery = q.Encode()
+		h.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetPaginationHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/v1/logs?level=error&offset=50&limit=50", nil)
+	w := httptest.NewRecorder()
+	next := "abc"
+	setPaginationHeaders(w, r, 120, 50, 50, &next)
+
+	want := map[string]string{
+		"X-Total-Count": "120",
+		"X-Limit":       "50",
+		"X-Offset":      "50",
+		"Link":          `</api/v1/logs?cursor=abc&level=error&limit=50>; rel="next"`,
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	setPaginationHeaders(w, r, 120, 50, 100, nil)
+	if got := w.Header().Get("Link"); got != "" {
+		t.Errorf("Link on last page = %q, want none", got)
+	}
+}
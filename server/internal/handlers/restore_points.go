@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// ListRestorePoints godoc
+// @Summary List restore points for a database
+// @Description Successful backups that can be restored, newest first, with size, age, format and whether a checksum was recorded. Failed, running and deleted backups are never listed.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Success 200 {array} models.RestorePoint "Restore points"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Database config not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /databases/{id}/restore-points [get]
+func (h *Handler) ListRestorePoints(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	config, err := h.repo.GetDatabaseConfigByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return
+	}
+
+	backups, err := h.repo.ListRestorePoints(config.ID)
+	if err != nil {
+		logError("Failed to list restore points", err)
+		writeError(w, http.StatusInternalServerError, "failed to list restore points")
+		return
+	}
+
+	now := time.Now()
+	points := make([]models.RestorePoint, 0, len(backups))
+	for _, b := range backups {
+		points = append(points, b.ToRestorePoint(now))
+	}
+	writeJSON(w, http.StatusOK, points)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/middleware"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// revealFreshLogin is how recent a login must be for a user without 2FA to
+// see unmasked connection details. Token refreshes don't count.
+const revealFreshLogin = 5 * time.Minute
+
+// requireFreshAuth gates reveal endpoints. Users with 2FA must send a
+// current TOTP code in X-2FA-Code; users without it must have logged in
+// within revealFreshLogin. It writes the error response and returns false
+// when the check fails.
+func (h *TwoFactorHandler) requireFreshAuth(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	user, err := h.repo.GetUserByID(userID)
+	if err != nil {
+		logError("Failed to get user for reveal", err)
+		writeError(w, http.StatusInternalServerError, "failed to get user")
+		return false
+	}
+	if user == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
+		return false
+	}
+
+	if user.TwoFactorEnabled {
+		code := r.Header.Get("X-2FA-Code")
+		if code == "" {
+			writeErrorCode(w, http.StatusForbidden, models.ErrCodeReauthRequired, "a current 2FA code is required in the X-2FA-Code header")
+			return false
+		}
+		valid, err := h.totpMgr.ValidateCodeWithWindow(user.TwoFactorSecret, code)
+		if err != nil || !valid {
+			log.Printf("[SECURITY] Reveal rejected: invalid 2FA code for user %s", userID)
+			writeErrorCode(w, http.StatusForbidden, models.ErrCodeReauthRequired, "invalid verification code")
+			return false
+		}
+		return true
+	}
+
+	claims, _ := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if claims == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+	loggedInAt := time.Unix(claims.SessionStartedAt, 0)
+	if claims.SessionStartedAt == 0 && claims.IssuedAt != nil {
+		loggedInAt = claims.IssuedAt.Time
+	}
+	if time.Since(loggedInAt) > revealFreshLogin {
+		writeErrorCode(w, http.StatusForbidden, models.ErrCodeReauthRequired,
+			fmt.Sprintf("log in again (within %s) or enable 2FA to reveal connection details", revealFreshLogin))
+		return false
+	}
+	return true
+}
+
+// logReveal records a reveal in the activity log and the server log.
+func (h *TwoFactorHandler) logReveal(r *http.Request, userID *uuid.UUID, entityType string, id uuid.UUID, name string) {
+	desc := fmt.Sprintf("Unmasked connection details of %s '%s' revealed", entityType, name)
+	log.Printf("[SECURITY] %s to %s from %s", desc, userID, getIPAddress(r))
+	meta, _ := json.Marshal(map[string]string{"entity_type": entityType})
+	h.logActivity(userID, models.ActionConfigRevealed, models.LogLevelWarning,
+		entityType, &id, name, desc, string(meta), getIPAddress(r))
+}
+
+// RevealStorageConfig godoc
+// @Summary      Reveal a storage config's connection details
+// @Description  Returns bucket, region and endpoint unmasked so the owner can verify them. Keys are never returned. Requires X-2FA-Code when 2FA is enabled, otherwise a login within the last 5 minutes.
+// @Tags         Storage
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path    string  true   "Storage ID"
+// @Param        X-2FA-Code header  string  false  "Current TOTP code (required when 2FA is enabled)"
+// @Success      200  {object}  models.StorageRevealResponse
+// @Failure      400  {object}  map[string]string "Invalid ID"
+// @Failure      403  {object}  map[string]string "Re-authentication required"
+// @Failure      404  {object}  map[string]string "Storage not found"
+// @Router       /storage/{id}/reveal [get]
+func (h *TwoFactorHandler) RevealStorageConfig(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	// Owner only: admins don't get to read other users' endpoints.
+	config, err := h.repo.GetStorageConfigByUser(id, *userID, false)
+	if err != nil {
+		logError("Failed to get storage config", err)
+		writeError(w, http.StatusInternalServerError, "failed to get storage config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeStorageNotFound, "storage config not found")
+		return
+	}
+
+	if !h.requireFreshAuth(w, r, *userID) {
+		return
+	}
+
+	h.logReveal(r, userID, "storage", config.ID, config.Name)
+	writeJSON(w, http.StatusOK, models.StorageRevealResponse{
+		ID:       config.ID,
+		Name:     config.Name,
+		Provider: config.Provider,
+		Bucket:   config.Bucket,
+		Region:   config.Region,
+		Endpoint: config.Endpoint,
+	})
+}
+
+// RevealDatabaseConfig godoc
+// @Summary      Reveal a database config's connection details
+// @Description  Returns host, port, database name and username unmasked so the owner can verify them. The password is never returned. Requires X-2FA-Code when 2FA is enabled, otherwise a login within the last 5 minutes.
+// @Tags         Databases
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path    string  true   "Database ID"
+// @Param        X-2FA-Code header  string  false  "Current TOTP code (required when 2FA is enabled)"
+// @Success      200  {object}  models.DatabaseRevealResponse
+// @Failure      400  {object}  map[string]string "Invalid ID"
+// @Failure      403  {object}  map[string]string "Re-authentication required"
+// @Failure      404  {object}  map[string]string "Database not found"
+// @Router       /databases/{id}/reveal [get]
+func (h *TwoFactorHandler) RevealDatabaseConfig(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	config, err := h.repo.GetDatabaseConfigByUser(id, *userID, false)
+	if err != nil {
+		logError("Failed to get database config", err)
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+		return
+	}
+
+	if !h.requireFreshAuth(w, r, *userID) {
+		return
+	}
+
+	h.logReveal(r, userID, "database", config.ID, config.Name)
+	writeJSON(w, http.StatusOK, models.DatabaseRevealResponse{
+		ID:       config.ID,
+		Name:     config.Name,
+		Host:     config.Host,
+		Port:     config.Port,
+		DBName:   config.DBName,
+		Username: config.Username,
+	})
+}
@@ -23,6 +23,9 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	r.Use(middleware.NewCORSMiddleware(&cfg.CORS))
 	r.Use(middleware.Logger)
 
+	// JWKS lives at the well-known root path, outside /api/v1
+	r.HandleFunc("/.well-known/jwks.json", h.JWKS).Methods("GET", "OPTIONS")
+
 	// API v1 routes
 	api := r.PathPrefix("/api/v1").Subrouter()
 
@@ -46,10 +49,25 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	authPublic.HandleFunc("/auth/github/login", h.GitHubLogin).Methods("GET", "OPTIONS")
 	authPublic.HandleFunc("/auth/github/callback", h.GitHubCallback).Methods("GET", "OPTIONS")
 
+	// Per-database trigger URL for CI — authenticated by its own token, not a
+	// JWT, so it shares the per-IP limit with the other public endpoints.
+	authPublic.HandleFunc("/databases/{id}/trigger", h.TriggerBackupByToken).Methods("POST", "OPTIONS")
+
+	// Streamed (gzip-on-the-fly) backup downloads. Browsers follow this URL
+	// without a bearer token; the signed ticket from the OTP verify step is
+	// the credential.
+	api.HandleFunc("/backups/{id}/download", h.DownloadBackup).Methods("GET", "OPTIONS")
+
+	// Backup share links — the token is the credential, so downloads share
+	// the per-IP limit with the other public endpoints.
+	authPublic.HandleFunc("/share/{token}", h.DownloadSharedBackup).Methods("GET", "OPTIONS")
+
 	// 2FA verification route (uses X-2FA-Token header, not regular auth)
 	if totpMgr != nil {
 		tfaHandler := NewTwoFactorHandler(h, totpMgr)
 		authPublic.HandleFunc("/auth/2fa/verify", tfaHandler.Verify2FA).Methods("POST", "OPTIONS")
+		authPublic.HandleFunc("/auth/2fa/webauthn/begin", tfaHandler.BeginPasskeyLogin).Methods("POST", "OPTIONS")
+		authPublic.HandleFunc("/auth/2fa/webauthn/verify", tfaHandler.VerifyPasskeyLogin).Methods("POST", "OPTIONS")
 	}
 
 	// Protected routes (authentication required)
@@ -65,6 +83,7 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	// Storage routes - GET allowed for demo, POST/PUT/DELETE blocked
 	protected.HandleFunc("/storage", h.ListStorageConfigs).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/storage/{id}", h.GetStorageConfig).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/storage/{id}/usage", h.GetStorageUsage).Methods("GET", "OPTIONS")
 
 	// Notification routes - GET allowed for demo
 	protected.HandleFunc("/notifications", h.ListNotificationConfigs).Methods("GET", "OPTIONS")
@@ -74,13 +93,20 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	protected.HandleFunc("/databases", h.ListDatabaseConfigs).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/databases/{id}", h.GetDatabaseConfig).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/databases/{id}/backups", h.ListBackupsByDatabase).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/databases/{id}/restore-points", h.ListRestorePoints).Methods("GET", "OPTIONS")
 
 	// Backup routes - GET allowed for demo
 	protected.HandleFunc("/backups", h.ListBackups).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/backups/{id}", h.GetBackup).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/backups/{id}/events", h.GetBackupEvents).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/backups/{id}/log", h.GetBackupLog).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/backups/{id}/progress", h.GetBackupProgress).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/backups/{id}/diff", h.DiffBackups).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/restore-jobs/{id}", h.GetRestoreJob).Methods("GET", "OPTIONS")
 
 	// Stats routes - GET allowed for demo
 	protected.HandleFunc("/stats", h.GetStats).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/scheduler/status", h.GetSchedulerStatus).Methods("GET", "OPTIONS")
 
 	// Activity Log routes - GET allowed for demo
 	protected.HandleFunc("/logs", h.ListActivityLogs).Methods("GET", "OPTIONS")
@@ -95,6 +121,7 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	// User profile routes - GET allowed for demo
 	protected.HandleFunc("/users/me", h.GetUserProfile).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/users/me/avatar", h.GetUserAvatar).Methods("GET", "OPTIONS")
+	protected.HandleFunc("/users/me/otp-destination", h.GetOTPDestination).Methods("GET", "OPTIONS")
 
 	// Backup download OTP-gated flow — GET count visible to demo so the
 	// settings UI doesn't 401; the destructive purge + download endpoints
@@ -105,15 +132,17 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	protected.HandleFunc("/labels", h.ListLabels).Methods("GET", "OPTIONS")
 	protected.HandleFunc("/labels/{id}", h.GetLabel).Methods("GET", "OPTIONS")
 
-	// Demo-restricted routes (write operations blocked for demo accounts)
+	// Demo-restricted routes (write operations blocked for demo and viewer accounts)
 	demoRestricted := api.PathPrefix("").Subrouter()
 	demoRestricted.Use(middleware.AuthMiddleware(jwtMgr))
+	demoRestricted.Use(middleware.ViewerReadOnlyMiddleware)
 	demoRestricted.Use(middleware.DemoRestrictionMiddleware)
 
 	// Storage write operations - blocked for demo
 	demoRestricted.HandleFunc("/storage", h.CreateStorageConfig).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/storage/{id}", h.UpdateStorageConfig).Methods("PUT", "OPTIONS")
 	demoRestricted.HandleFunc("/storage/{id}", h.DeleteStorageConfig).Methods("DELETE", "OPTIONS")
+	demoRestricted.HandleFunc("/storage/{id}/test", h.TestStorageConfig).Methods("POST", "OPTIONS")
 
 	// Notification write operations - blocked for demo
 	demoRestricted.HandleFunc("/notifications", h.CreateNotificationConfig).Methods("POST", "OPTIONS")
@@ -126,11 +155,27 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	demoRestricted.HandleFunc("/databases/{id}", h.DeleteDatabaseConfig).Methods("DELETE", "OPTIONS")
 	demoRestricted.HandleFunc("/databases/{id}/pause", h.PauseDatabaseConfig).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/databases/{id}/unpause", h.UnpauseDatabaseConfig).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/databases/{id}/snooze", h.SnoozeDatabaseConfig).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/databases/{id}/snooze", h.UnsnoozeDatabaseConfig).Methods("DELETE", "OPTIONS")
 	demoRestricted.HandleFunc("/databases/{id}/backup", h.TriggerManualBackup).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/databases/{id}/backup-now-and-wait", h.TriggerBackupAndWait).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/databases/{id}/trigger-token", h.CreateTriggerToken).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/databases/{id}/trigger-token", h.RevokeTriggerToken).Methods("DELETE", "OPTIONS")
+
+	// Bulk import of storage, notification and database configs
+	demoRestricted.HandleFunc("/import", h.ImportConfigs).Methods("POST", "OPTIONS")
 
 	// Backup write operations - blocked for demo
 	demoRestricted.HandleFunc("/backups/{id}/restore", h.RestoreBackup).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}/test-restore", h.TestRestoreBackup).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/backups/failed", h.PurgeFailedBackups).Methods("DELETE", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/bulk-delete", h.BulkDeleteBackups).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}", h.DeleteBackup).Methods("DELETE", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}", h.UpdateBackup).Methods("PATCH", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}/pin", h.PinBackup).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}/pin", h.UnpinBackup).Methods("DELETE", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}/retry-upload", h.RetryBackupUpload).Methods("POST", "OPTIONS")
+	demoRestricted.HandleFunc("/backups/{id}/notify", h.ResendBackupNotification).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/backups/{id}/download/request-otp", h.RequestBackupDownloadOTP).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/backups/{id}/download/verify", h.VerifyBackupDownloadOTP).Methods("POST", "OPTIONS")
 
@@ -143,6 +188,7 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	demoRestricted.HandleFunc("/labels", h.CreateLabel).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/labels/{id}", h.UpdateLabel).Methods("PUT", "OPTIONS")
 	demoRestricted.HandleFunc("/labels/{id}", h.DeleteLabel).Methods("DELETE", "OPTIONS")
+	demoRestricted.HandleFunc("/labels/{id}/backup", h.TriggerLabelBackup).Methods("POST", "OPTIONS")
 
 	// Database label assignment - blocked for demo
 	demoRestricted.HandleFunc("/databases/{id}/labels", h.AssignLabelsToDatabase).Methods("POST", "OPTIONS")
@@ -178,11 +224,19 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 	demoRestricted.HandleFunc("/server-connections/{id}/databases/{dbname}/grants", h.GrantServerRole).Methods("POST", "OPTIONS")
 	demoRestricted.HandleFunc("/server-connections/{id}/databases/{dbname}/tables/{schema}/{table}/truncate", h.TruncateServerTable).Methods("POST", "OPTIONS")
 
-	// Demo-blocked routes (completely blocked for demo accounts - 2FA management)
+	// Demo-blocked routes (completely blocked for demo accounts - 2FA management).
+	// Viewers may still secure their own account here.
 	demoBlocked := api.PathPrefix("").Subrouter()
 	demoBlocked.Use(middleware.AuthMiddleware(jwtMgr))
 	demoBlocked.Use(middleware.DemoBlockMiddleware)
 
+	// Login OTP destination - changing where auth codes go is blocked for demo
+	demoBlocked.HandleFunc("/users/me/otp-destination", h.UpdateOTPDestination).Methods("PUT", "OPTIONS")
+
+	// Share links hand a backup to people without an account; never for demo
+	demoBlocked.HandleFunc("/backups/{id}/share", h.CreateBackupShare).Methods("POST", "OPTIONS")
+	demoBlocked.HandleFunc("/backups/{id}/share/{token}", h.RevokeBackupShare).Methods("DELETE", "OPTIONS")
+
 	// 2FA management routes (protected - require full authentication, blocked for demo)
 	if totpMgr != nil {
 		tfaHandler := NewTwoFactorHandler(h, totpMgr)
@@ -192,8 +246,30 @@ func SetupRoutesWithTOTP(h *Handler, jwtMgr *auth.JWTManager, cfg *config.Config
 		demoBlocked.HandleFunc("/auth/2fa/backup-codes", tfaHandler.RegenerateBackupCodes).Methods("POST", "OPTIONS")
 		// 2FA status is read-only, so it goes to protected (allowed for demo to view)
 		protected.HandleFunc("/auth/2fa/status", tfaHandler.Get2FAStatus).Methods("GET", "OPTIONS")
+		// Passkeys (WebAuthn) as a second factor; 503 unless WEBAUTHN_RP_ID is set
+		protected.HandleFunc("/auth/webauthn/credentials", tfaHandler.ListPasskeys).Methods("GET", "OPTIONS")
+		demoBlocked.HandleFunc("/auth/webauthn/register/begin", tfaHandler.BeginPasskeyRegistration).Methods("POST", "OPTIONS")
+		demoBlocked.HandleFunc("/auth/webauthn/register", tfaHandler.FinishPasskeyRegistration).Methods("POST", "OPTIONS")
+		demoBlocked.HandleFunc("/auth/webauthn/credentials/{id}", tfaHandler.DeletePasskey).Methods("DELETE", "OPTIONS")
+		// Unmasked connection details need a fresh login or 2FA code
+		demoBlocked.HandleFunc("/storage/{id}/reveal", tfaHandler.RevealStorageConfig).Methods("GET", "OPTIONS")
+		demoBlocked.HandleFunc("/databases/{id}/reveal", tfaHandler.RevealDatabaseConfig).Methods("GET", "OPTIONS")
+		demoBlocked.HandleFunc("/databases/{id}/backups/download-all", tfaHandler.DownloadAllBackups).Methods("GET", "OPTIONS")
+		// Config export unmasks connection details, so it is gated the same way
+		demoBlocked.HandleFunc("/export", tfaHandler.ExportConfigs).Methods("GET", "OPTIONS")
 	}
 
+	// Admin-only routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AuthMiddleware(jwtMgr))
+	admin.Use(middleware.AdminOnlyMiddleware)
+	admin.Use(middleware.ViewerReadOnlyMiddleware)
+	admin.HandleFunc("/diagnostics", h.GetDiagnostics).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/maintenance", h.GetMaintenance).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/maintenance", h.SetMaintenance).Methods("PUT", "OPTIONS")
+	admin.HandleFunc("/users", h.ListUsers).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/users/{id}/role", h.SetUserRole).Methods("PUT", "OPTIONS")
+
 	// Swagger documentation (public, no auth required)
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
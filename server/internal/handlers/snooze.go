@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxSnooze bounds how far ahead a snooze may reach. Anything longer is a
+// pause in disguise and should use /pause, which shows up as such.
+const maxSnooze = 30 * 24 * time.Hour
+
+// SnoozeDatabaseConfig godoc
+// @Summary Snooze scheduled backups
+// @Description Skip scheduled backups of a database until the given time without pausing it or changing its schedule. The snooze clears itself once the time has passed. Manual and trigger-URL backups are not affected.
+// @Tags Databases
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Param until query string true "RFC 3339 time to skip runs until, at most 30 days ahead" example(2026-01-02T06:00:00Z)
+// @Success 200 {object} models.DatabaseConfigResponse "Database configuration snoozed"
+// @Failure 400 {object} models.APIError "Invalid ID or until"
+// @Failure 404 {object} models.APIError "Database config not found"
+// @Failure 500 {object} models.APIError "Internal server error"
+// @Router /databases/{id}/snooze [post]
+func (h *Handler) SnoozeDatabaseConfig(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	until, err := time.Parse(time.RFC3339, r.URL.Query().Get("until"))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, "until must be an RFC 3339 time, e.g. 2026-01-02T06:00:00Z")
+		return
+	}
+	now := time.Now()
+	if !until.After(now) {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, "until must be in the future")
+		return
+	}
+	if until.Sub(now) > maxSnooze {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, "until must be at most 30 days ahead; pause the database instead")
+		return
+	}
+
+	h.setSnooze(w, r, *userID, isAdmin, id, &until)
+}
+
+// UnsnoozeDatabaseConfig godoc
+// @Summary Cancel a snooze
+// @Description Clear a database's snooze so its next scheduled backup runs as normal.
+// @Tags Databases
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Database Config ID (UUID)"
+// @Success 200 {object} models.DatabaseConfigResponse "Snooze cleared"
+// @Failure 400 {object} models.APIError "Invalid ID"
+// @Failure 404 {object} models.APIError "Database config not found"
+// @Failure 500 {object} models.APIError "Internal server error"
+// @Router /databases/{id}/snooze [delete]
+func (h *Handler) UnsnoozeDatabaseConfig(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	h.setSnooze(w, r, *userID, isAdmin, id, nil)
+}
+
+// setSnooze stores the snooze (nil clears it), refreshes the scheduler job
+// so it sees the new time, and responds with the updated config.
+func (h *Handler) setSnooze(w http.ResponseWriter, r *http.Request, userID uuid.UUID, isAdmin bool, id uuid.UUID, until *time.Time) {
+	if err := h.repo.SetDatabaseSnoozeByUser(id, userID, isAdmin, until); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeDatabaseNotFound, "database config not found")
+			return
+		}
+		logError(fmt.Sprintf("Failed to snooze database config %s", id), err)
+		writeError(w, http.StatusInternalServerError, "failed to snooze database config")
+		return
+	}
+
+	config, err := h.repo.GetDatabaseConfigByUser(id, userID, isAdmin)
+	if err != nil || config == nil {
+		writeError(w, http.StatusInternalServerError, "failed to get database config")
+		return
+	}
+	// Paused or disabled configs have no job; UpdateJob leaves them alone and
+	// picks the snooze up again on unpause.
+	if err := h.scheduler.UpdateJob(config); err != nil {
+		logError(fmt.Sprintf("Failed to reschedule %s after snooze change", config.Name), err)
+	}
+
+	desc := fmt.Sprintf("Scheduled backups of '%s' resumed", config.Name)
+	if until != nil {
+		desc = fmt.Sprintf("Scheduled backups of '%s' snoozed until %s", config.Name, until.UTC().Format(time.RFC3339))
+	}
+	logInfo("%s", desc)
+	h.logActivity(&userID, models.ActionDatabaseSnoozed, models.LogLevelInfo,
+		"database", &config.ID, config.Name, desc, "", getIPAddress(r))
+
+	writeJSON(w, http.StatusOK, config.ToResponse())
+}
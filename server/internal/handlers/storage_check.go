@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// StorageTestResult is the shape of POST /storage/{id}/test.
+type StorageTestResult struct {
+	ID        uuid.UUID `json:"id"`
+	Reachable bool      `json:"reachable"`
+	LatencyMs int64     `json:"latency_ms"`
+	// Region is the bucket's actual region (S3 only).
+	Region         string `json:"region,omitempty" example:"eu-west-1"`
+	RegionMismatch bool   `json:"region_mismatch"`
+	Error          string `json:"error,omitempty"`
+}
+
+// TestStorageConfig godoc
+// @Summary Test a storage configuration
+// @Description Checks that the bucket is reachable with the stored credentials and, for AWS S3, that the bucket's region matches the configured region. A region mismatch is a common cause of failed uploads.
+// @Tags Storage
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Storage Config ID (UUID)"
+// @Success 200 {object} StorageTestResult "Test result; check reachable and region_mismatch"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Storage config not found"
+// @Router /storage/{id}/test [post]
+func (h *Handler) TestStorageConfig(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	config, err := h.repo.GetStorageConfigByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get storage config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeStorageNotFound, "storage config not found")
+		return
+	}
+
+	result := StorageTestResult{ID: config.ID}
+	start := time.Now()
+	client, err := storage.NewStorageClient(config)
+	if err == nil {
+		err = client.Ping()
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	result.Reachable = true
+
+	result.Region, err = client.CheckRegion()
+	if err != nil {
+		result.RegionMismatch = errors.Is(err, storage.ErrRegionMismatch)
+		result.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, result)
+}
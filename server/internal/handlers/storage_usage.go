@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/storage"
+)
+
+// storageUsageListTimeout bounds the bucket listing for ?live=true.
+const storageUsageListTimeout = 2 * time.Minute
+
+// StorageUsage is the shape of GET /storage/{id}/usage. BackupCount and
+// TotalBytes come from DumpStation's own records; Bucket is only filled in
+// when the bucket was listed.
+type StorageUsage struct {
+	ID          uuid.UUID           `json:"id"`
+	BackupCount int64               `json:"backup_count" example:"42"`
+	TotalBytes  int64               `json:"total_bytes" example:"10737418240"`
+	Bucket      *StorageBucketUsage `json:"bucket,omitempty"`
+}
+
+// StorageBucketUsage is what the provider reports under the backup prefix.
+// It differs from the recorded totals when objects were added or removed
+// outside DumpStation.
+type StorageBucketUsage struct {
+	ObjectCount int64  `json:"object_count" example:"43"`
+	TotalBytes  int64  `json:"total_bytes" example:"10737418240"`
+	Error       string `json:"error,omitempty"`
+}
+
+// GetStorageUsage godoc
+// @Summary Get storage usage
+// @Description Returns how many successful backups a storage config holds and their total size, from DumpStation's records. With live=true the bucket's backup prefix is also listed for an authoritative count, which can be slow on large buckets.
+// @Tags Storage
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Storage Config ID (UUID)"
+// @Param live query bool false "Also list the bucket (default: false)"
+// @Success 200 {object} StorageUsage
+// @Failure 400 {object} map[string]string "Invalid ID or live flag"
+// @Failure 404 {object} map[string]string "Storage config not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /storage/{id}/usage [get]
+func (h *Handler) GetStorageUsage(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+	live, err := parseOptionalBool(r.URL.Query().Get("live"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid live flag: must be true or false")
+		return
+	}
+
+	config, err := h.repo.GetStorageConfigByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get storage config")
+		return
+	}
+	if config == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeStorageNotFound, "storage config not found")
+		return
+	}
+
+	usage := StorageUsage{ID: config.ID}
+	usage.BackupCount, usage.TotalBytes, err = h.repo.StorageBackupUsage(config.ID)
+	if err != nil {
+		logError("Failed to get storage usage", err)
+		writeError(w, http.StatusInternalServerError, "failed to get storage usage")
+		return
+	}
+
+	if live != nil && *live {
+		// Listing failures are reported alongside the recorded totals
+		// rather than failing the request, like TestStorageConfig.
+		usage.Bucket = &StorageBucketUsage{}
+		client, err := storage.NewStorageClient(config)
+		if err == nil {
+			// Listing a large bucket outlasts the server's WriteTimeout.
+			extendWriteDeadline(w, storageUsageListTimeout)
+			ctx, cancel := context.WithTimeout(r.Context(), storageUsageListTimeout)
+			defer cancel()
+			usage.Bucket.ObjectCount, usage.Bucket.TotalBytes, err = client.PrefixUsage(ctx, storage.BackupKeyPrefix)
+		}
+		if err != nil {
+			usage.Bucket.Error = err.Error()
+		}
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/dbadmin"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestRestoreRequest optionally picks the server the throwaway database is
+// created on. Without it the backup's source server is used, which needs
+// the database user to have CREATEDB.
+type TestRestoreRequest struct {
+	ServerConnectionID *uuid.UUID `json:"server_connection_id,omitempty"`
+}
+
+// TestRestoreBackup godoc
+// @Summary Test-restore a backup
+// @Description Restores the backup into an automatically created scratch database, runs sanity checks (table count, row estimates) and drops the database again. No existing database is touched. Runs in the background; poll the returned restore job for the result.
+// @Tags Backups
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Backup ID (UUID)"
+// @Param request body TestRestoreRequest false "Optional server to restore on"
+// @Success 202 {object} models.RestoreJobResponse
+// @Failure 400 {object} map[string]string "Invalid ID or body, or backup has no stored dump"
+// @Failure 404 {object} map[string]string "Backup or server connection not found"
+// @Failure 503 {object} map[string]string "Maintenance mode is enabled"
+// @Router /backups/{id}/test-restore [post]
+func (h *Handler) TestRestoreBackup(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	if denyWrite(w, r, "restore backups") {
+		return
+	}
+	if h.rejectDuringMaintenance(w) {
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	var input TestRestoreRequest
+	if err := decodeOptionalJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON in request body: "+err.Error())
+		return
+	}
+
+	backup, err := h.repo.GetBackupByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get backup")
+		return
+	}
+	if backup == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeBackupNotFound, "backup not found")
+		return
+	}
+	if backup.Status != models.BackupStatusSuccess || backup.StoragePath == "" {
+		writeError(w, http.StatusBadRequest, "backup has no stored dump to restore")
+		return
+	}
+
+	var server dbadmin.Options
+	target := "the source server"
+	if input.ServerConnectionID != nil {
+		sc, err := h.repo.GetServerConnectionByUser(*input.ServerConnectionID, *userID, isAdmin)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to get server connection")
+			return
+		}
+		if sc == nil {
+			writeErrorCode(w, http.StatusNotFound, models.ErrCodeServerConnectionNotFound, "server connection not found")
+			return
+		}
+		if h.rejectDisallowedHost(w, sc.Host) {
+			return
+		}
+		plain, err := h.cipher.Decrypt(sc.Password)
+		if err != nil {
+			logError("decrypt server password", err)
+			writeError(w, http.StatusInternalServerError, "failed to decrypt stored password")
+			return
+		}
+		server = dbadmin.Options{Host: sc.Host, Port: sc.Port, User: sc.Username, Password: plain, SSLMode: sc.SSLMode}
+		target = fmt.Sprintf("server %q", sc.Name)
+	}
+
+	job, err := h.backupSvc.StartTestRestore(backup.ID, server, input.ServerConnectionID)
+	if err != nil {
+		logError("Failed to start test restore", err)
+		writeError(w, http.StatusInternalServerError, "failed to start test restore")
+		return
+	}
+
+	meta, _ := json.Marshal(map[string]string{"restore_job_id": job.ID.String(), "test": "true"})
+	h.logActivity(userID, models.ActionRestoreTriggered, models.LogLevelInfo,
+		"backup", &backup.ID, backup.Name,
+		fmt.Sprintf("Test restore triggered for backup %q on %s", backup.Name, target),
+		string(meta), getIPAddress(r))
+
+	writeJSON(w, http.StatusAccepted, job.ToResponse())
+}
+
+// GetRestoreJob godoc
+// @Summary Get a restore job
+// @Description Returns a restore job's status and, for test restores, the sanity-check results. Connection details are masked.
+// @Tags Backups
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Restore job ID (UUID)"
+// @Success 200 {object} models.RestoreJobResponse
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 404 {object} map[string]string "Restore job not found"
+// @Router /restore-jobs/{id} [get]
+func (h *Handler) GetRestoreJob(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	isAdmin := getIsAdminFromContext(r)
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	job, err := h.repo.GetRestoreJobByUser(id, *userID, isAdmin)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get restore job")
+		return
+	}
+	if job == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeRestoreJobNotFound, "restore job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job.ToResponse())
+}
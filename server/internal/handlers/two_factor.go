@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
+	"gorm.io/gorm"
 )
 
 // pending2FATTL bounds how long a Setup2FA-issued secret stays valid before
@@ -19,15 +23,21 @@ const pending2FATTL = 10 * time.Minute
 // TwoFactorHandler handles all 2FA related operations
 type TwoFactorHandler struct {
 	*Handler
-	totpMgr *auth.TOTPManager
+	totpMgr  *auth.TOTPManager
+	webauthn *auth.WebAuthnManager // nil when WEBAUTHN_RP_ID is unset
 }
 
 // NewTwoFactorHandler creates a new 2FA handler
 func NewTwoFactorHandler(h *Handler, totpMgr *auth.TOTPManager) *TwoFactorHandler {
-	return &TwoFactorHandler{
+	tfa := &TwoFactorHandler{
 		Handler: h,
 		totpMgr: totpMgr,
 	}
+	if h.cfg != nil {
+		tfa.webauthn = auth.NewWebAuthnManager(h.cfg.TwoFactor.WebAuthnRPID,
+			h.cfg.TwoFactor.WebAuthnRPName, h.cfg.TwoFactor.WebAuthnOrigins)
+	}
+	return tfa
 }
 
 // Setup2FA godoc
@@ -42,7 +52,7 @@ func NewTwoFactorHandler(h *Handler, totpMgr *auth.TOTPManager) *TwoFactorHandle
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/2fa/setup [post]
 func (h *TwoFactorHandler) Setup2FA(w http.ResponseWriter, r *http.Request) {
-	logInfo("2FA setup request received")
+	logDebug("2FA setup request received")
 
 	// Get user from context
 	userID := getUserIDFromContext(r)
@@ -105,6 +115,7 @@ func (h *TwoFactorHandler) Setup2FA(w http.ResponseWriter, r *http.Request) {
 		QRCodeDataURL: setupResult.QRCodeDataURL,
 		Issuer:        setupResult.Issuer,
 		AccountName:   setupResult.AccountName,
+		BackupCodes:   h.totpMgr.BackupCodeCount(),
 	})
 }
 
@@ -122,7 +133,7 @@ func (h *TwoFactorHandler) Setup2FA(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/2fa/verify-setup [post]
 func (h *TwoFactorHandler) VerifySetup2FA(w http.ResponseWriter, r *http.Request) {
-	logInfo("2FA setup verification request received")
+	logDebug("2FA setup verification request received")
 
 	// Get user from context
 	userID := getUserIDFromContext(r)
@@ -132,9 +143,9 @@ func (h *TwoFactorHandler) VerifySetup2FA(w http.ResponseWriter, r *http.Request
 	}
 
 	var req models.TwoFactorVerifySetupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid request body in 2FA verify setup", err)
-		writeError(w, http.StatusBadRequest, "invalid request body")
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 
@@ -171,7 +182,7 @@ func (h *TwoFactorHandler) VerifySetup2FA(w http.ResponseWriter, r *http.Request
 	// Verify the TOTP code against the PENDING secret.
 	valid, err := h.totpMgr.ValidateCodeWithWindow(user.PendingTwoFactorSecret, req.Code)
 	if err != nil || !valid {
-		log.Printf("[2FA] ❌ Invalid TOTP code for user: %s", user.DiscordUsername)
+		logging.Warnf("[2FA] ❌ Invalid TOTP code for user: %s", user.DiscordUsername)
 
 		// Log failed verification attempt
 		h.logActivity(userID, models.Action2FAFailed, models.LogLevelWarning,
@@ -184,7 +195,7 @@ func (h *TwoFactorHandler) VerifySetup2FA(w http.ResponseWriter, r *http.Request
 	}
 
 	// Generate backup codes
-	backupResult, err := h.totpMgr.GenerateBackupCodes(10)
+	backupResult, err := h.totpMgr.GenerateBackupCodes(h.totpMgr.BackupCodeCount())
 	if err != nil {
 		logError("Failed to generate backup codes", err)
 		writeError(w, http.StatusInternalServerError, "failed to generate backup codes")
@@ -226,7 +237,7 @@ func (h *TwoFactorHandler) VerifySetup2FA(w http.ResponseWriter, r *http.Request
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/2fa/verify [post]
 func (h *TwoFactorHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
-	logInfo("2FA verification request received")
+	logDebug("2FA verification request received")
 
 	// Get 2FA token from header
 	tokenString := r.Header.Get("X-2FA-Token")
@@ -244,9 +255,9 @@ func (h *TwoFactorHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.TwoFactorVerifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid request body in 2FA verify", err)
-		writeError(w, http.StatusBadRequest, "invalid request body")
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 
@@ -268,6 +279,13 @@ func (h *TwoFactorHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse to even evaluate the code while locked out, otherwise the
+	// lockout would only slow down — not stop — a brute-force run.
+	if user.TwoFactorLockedUntil != nil && user.TwoFactorLockedUntil.After(time.Now()) {
+		writeTwoFactorLocked(w, *user.TwoFactorLockedUntil)
+		return
+	}
+
 	// Try TOTP code first
 	valid := false
 	isBackupCode := false
@@ -299,7 +317,7 @@ func (h *TwoFactorHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !valid {
-		log.Printf("[2FA] ❌ Invalid 2FA code for user: %s", user.DiscordUsername)
+		logging.Warnf("[2FA] ❌ Invalid 2FA code for user: %s", user.DiscordUsername)
 
 		// Log failed verification
 		h.logActivity(&claims.UserID, models.Action2FAFailed, models.LogLevelError,
@@ -307,12 +325,25 @@ func (h *TwoFactorHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 			fmt.Sprintf("2FA verification failed for user %s - invalid code", user.DiscordUsername),
 			"", getIPAddress(r))
 
+		failure, err := h.repo.RecordUser2FAFailure(claims.UserID)
+		if err != nil {
+			logError("Failed to record 2FA failure", err)
+		} else if failure.JustLocked {
+			h.on2FALocked(user, *failure.LockedUntil, getIPAddress(r))
+			writeTwoFactorLocked(w, *failure.LockedUntil)
+			return
+		}
+
 		writeError(w, http.StatusBadRequest, "invalid verification code")
 		return
 	}
 
+	if err := h.repo.ResetUser2FAFailures(claims.UserID); err != nil {
+		logError("Failed to reset 2FA failure counter", err)
+	}
+
 	// Generate full access token
-	token, expiresAt, err := h.jwtMgr.GenerateToken(claims.UserID, claims.DiscordUserID, user.IsAdmin)
+	token, expiresAt, err := h.jwtMgr.GenerateToken(claims.UserID, claims.DiscordUserID, user.IsAdmin, user.IsViewer)
 	if err != nil {
 		logError("Failed to generate token after 2FA", err)
 		writeError(w, http.StatusInternalServerError, "failed to generate token")
@@ -337,21 +368,58 @@ func (h *TwoFactorHandler) Verify2FA(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// writeTwoFactorLocked responds 429 with Retry-After for a locked account.
+func writeTwoFactorLocked(w http.ResponseWriter, lockedUntil time.Time) {
+	retryAfter := int(time.Until(lockedUntil).Seconds())
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeError(w, http.StatusTooManyRequests, "too many failed 2FA attempts; please try again later")
+}
+
+// on2FALocked audits a new 2FA lockout and alerts the account owner, since
+// a run of bad codes after a correct OTP means the first factor is likely
+// compromised.
+func (h *TwoFactorHandler) on2FALocked(user *models.User, lockedUntil time.Time, ip string) {
+	logging.Warnf("[2FA] 🔒 2FA locked for user %s until %s", user.DiscordUsername, lockedUntil.Format(time.RFC3339))
+
+	meta, _ := json.Marshal(map[string]any{
+		"locked_until": lockedUntil,
+		"max_attempts": models.TwoFactorMaxFailedAttempts,
+	})
+	h.logActivity(&user.ID, models.Action2FALocked, models.LogLevelError,
+		"user", &user.ID, user.DiscordUsername,
+		fmt.Sprintf("2FA locked for user %s after %d failed attempts", user.DiscordUsername, models.TwoFactorMaxFailedAttempts),
+		string(meta), ip)
+
+	if h.notifier == nil && h.otpFallback == nil && h.userOTPNotifier(user) == nil {
+		return
+	}
+	msg := fmt.Sprintf("🔒 **Security alert: 2FA locked**\n%d wrong 2FA codes were entered for **%s** after a successful login OTP. 2FA verification is blocked until %s.\nSource IP: %s\nIf this wasn't you, your login OTP channel may be compromised.",
+		models.TwoFactorMaxFailedAttempts, user.DiscordUsername, lockedUntil.UTC().Format(time.RFC1123), ip)
+	go func() {
+		if err := h.sendSecurityAlert(user, msg); err != nil {
+			logError("Failed to send 2FA lockout notification", err)
+		}
+	}()
+}
+
 // Disable2FA godoc
 // @Summary Disable 2FA
-// @Description Disable two-factor authentication (requires current TOTP code for verification)
+// @Description Disable two-factor authentication. Confirm with a current TOTP code, or with an unused backup code if the authenticator is lost (the backup code is consumed).
 // @Tags Two-Factor Authentication
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param body body models.TwoFactorDisableRequest true "Current TOTP code to confirm"
+// @Param body body models.TwoFactorDisableRequest true "Current TOTP code or backup code to confirm"
 // @Success 200 {object} map[string]string "2FA disabled successfully"
 // @Failure 400 {object} map[string]string "Invalid code or 2FA not enabled"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/2fa/disable [post]
 func (h *TwoFactorHandler) Disable2FA(w http.ResponseWriter, r *http.Request) {
-	logInfo("2FA disable request received")
+	logDebug("2FA disable request received")
 
 	// Get user from context
 	userID := getUserIDFromContext(r)
@@ -361,9 +429,9 @@ func (h *TwoFactorHandler) Disable2FA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.TwoFactorDisableRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid request body in 2FA disable", err)
-		writeError(w, http.StatusBadRequest, "invalid request body")
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 
@@ -385,10 +453,29 @@ func (h *TwoFactorHandler) Disable2FA(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify the TOTP code
-	valid, err := h.totpMgr.ValidateCodeWithWindow(user.TwoFactorSecret, req.Code)
-	if err != nil || !valid {
-		log.Printf("[2FA] ❌ Invalid TOTP code for 2FA disable - user: %s", user.DiscordUsername)
+	// Accept a TOTP code first, then fall back to a backup code so a user who
+	// lost their authenticator can still turn 2FA off.
+	method := "totp"
+	valid := false
+	if len(req.Code) == 8 {
+		valid, _ = h.totpMgr.ValidateCodeWithWindow(user.TwoFactorSecret, req.Code)
+	}
+	if !valid {
+		if index, found := h.totpMgr.ValidateBackupCode(req.Code, user.TwoFactorBackupCodes); found {
+			// Spend the code before disabling so it stays single-use even
+			// if the disable below fails.
+			newCodes := auth.RemoveBackupCode(user.TwoFactorBackupCodes, index)
+			if err := h.repo.UpdateUser2FABackupCodes(*userID, newCodes); err != nil {
+				logError("Failed to consume backup code for 2FA disable", err)
+				writeError(w, http.StatusInternalServerError, "failed to disable 2FA")
+				return
+			}
+			valid = true
+			method = "backup_code"
+		}
+	}
+	if !valid {
+		logging.Warnf("[2FA] ❌ Invalid code for 2FA disable - user: %s", user.DiscordUsername)
 
 		h.logActivity(userID, models.Action2FAFailed, models.LogLevelWarning,
 			"user", userID, user.DiscordUsername,
@@ -407,12 +494,13 @@ func (h *TwoFactorHandler) Disable2FA(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log 2FA disabled
+	disableMeta, _ := json.Marshal(map[string]string{"method": method})
 	h.logActivity(userID, models.Action2FADisabled, models.LogLevelInfo,
 		"user", userID, user.DiscordUsername,
-		fmt.Sprintf("2FA disabled for user %s", user.DiscordUsername),
-		"", getIPAddress(r))
+		fmt.Sprintf("2FA disabled for user %s (confirmed with %s)", user.DiscordUsername, strings.ReplaceAll(method, "_", " ")),
+		string(disableMeta), getIPAddress(r))
 
-	logInfo("✅ 2FA disabled for user: %s", user.DiscordUsername)
+	logInfo("✅ 2FA disabled for user: %s (via %s)", user.DiscordUsername, method)
 
 	writeJSON(w, http.StatusOK, map[string]string{
 		"message": "2FA has been disabled successfully",
@@ -427,6 +515,7 @@ func (h *TwoFactorHandler) Disable2FA(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Success 200 {object} models.TwoFactorStatusResponse "2FA status"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/2fa/status [get]
 func (h *TwoFactorHandler) Get2FAStatus(w http.ResponseWriter, r *http.Request) {
@@ -438,6 +527,10 @@ func (h *TwoFactorHandler) Get2FAStatus(w http.ResponseWriter, r *http.Request)
 	}
 
 	enabled, backupCodesCount, verifiedAt, err := h.repo.GetUser2FAStatus(*userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
+		return
+	}
 	if err != nil {
 		logError("Failed to get 2FA status", err)
 		writeError(w, http.StatusInternalServerError, "failed to get 2FA status")
@@ -448,6 +541,7 @@ func (h *TwoFactorHandler) Get2FAStatus(w http.ResponseWriter, r *http.Request)
 		Enabled:          enabled,
 		VerifiedAt:       verifiedAt,
 		BackupCodesCount: backupCodesCount,
+		Passkeys:         h.passkeyCount(*userID),
 	})
 }
 
@@ -465,7 +559,7 @@ func (h *TwoFactorHandler) Get2FAStatus(w http.ResponseWriter, r *http.Request)
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /auth/2fa/backup-codes [post]
 func (h *TwoFactorHandler) RegenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
-	logInfo("Backup codes regeneration request received")
+	logDebug("Backup codes regeneration request received")
 
 	// Get user from context
 	userID := getUserIDFromContext(r)
@@ -475,9 +569,9 @@ func (h *TwoFactorHandler) RegenerateBackupCodes(w http.ResponseWriter, r *http.
 	}
 
 	var req models.TwoFactorDisableRequest // Reusing same struct as it has the same fields
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(w, r, &req); err != nil {
 		logError("Invalid request body in backup codes regeneration", err)
-		writeError(w, http.StatusBadRequest, "invalid request body")
+		writeDecodeError(w, err, "invalid request body")
 		return
 	}
 
@@ -502,13 +596,13 @@ func (h *TwoFactorHandler) RegenerateBackupCodes(w http.ResponseWriter, r *http.
 	// Verify the TOTP code
 	valid, err := h.totpMgr.ValidateCodeWithWindow(user.TwoFactorSecret, req.Code)
 	if err != nil || !valid {
-		log.Printf("[2FA] ❌ Invalid TOTP code for backup codes regeneration - user: %s", user.DiscordUsername)
+		logging.Warnf("[2FA] ❌ Invalid TOTP code for backup codes regeneration - user: %s", user.DiscordUsername)
 		writeError(w, http.StatusBadRequest, "invalid verification code")
 		return
 	}
 
 	// Generate new backup codes
-	backupResult, err := h.totpMgr.GenerateBackupCodes(10)
+	backupResult, err := h.totpMgr.GenerateBackupCodes(h.totpMgr.BackupCodeCount())
 	if err != nil {
 		logError("Failed to generate new backup codes", err)
 		writeError(w, http.StatusInternalServerError, "failed to generate backup codes")
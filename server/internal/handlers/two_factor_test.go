@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+)
+
+// webhookServer counts the messages posted to it and answers with status.
+func webhookServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// TestSendSecurityAlert checks that security alerts go to the user's own
+// OTP destination first and reach the server-wide webhook only when that
+// destination fails.
+func TestSendSecurityAlert(t *testing.T) {
+	personal, personalHits := webhookServer(t, http.StatusNoContent)
+	shared, sharedHits := webhookServer(t, http.StatusNoContent)
+	h := &Handler{notifier: notification.NewDiscordNotifier(shared.URL, "")}
+	user := &models.User{OTPChannel: models.OTPChannelDiscord, OTPDiscordWebhookURL: personal.URL}
+
+	if err := h.sendSecurityAlert(user, "2FA locked"); err != nil {
+		t.Fatalf("sendSecurityAlert: %v", err)
+	}
+	if personalHits.Load() != 1 || sharedHits.Load() != 0 {
+		t.Errorf("personal/shared hits = %d/%d, want 1/0", personalHits.Load(), sharedHits.Load())
+	}
+
+	broken, brokenHits := webhookServer(t, http.StatusNotFound)
+	user.OTPDiscordWebhookURL = broken.URL
+	if err := h.sendSecurityAlert(user, "2FA locked"); err != nil {
+		t.Fatalf("sendSecurityAlert with a broken personal webhook: %v", err)
+	}
+	if brokenHits.Load() == 0 || sharedHits.Load() != 1 {
+		t.Errorf("broken/shared hits = %d/%d, want the alert to fall back to the shared webhook", brokenHits.Load(), sharedHits.Load())
+	}
+}
@@ -2,18 +2,24 @@ package handlers
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 )
 
 // MaxAvatarSize is the maximum allowed size for avatar uploads (2MB)
 const MaxAvatarSize = 2 * 1024 * 1024
 
+// avatarJSONMaxBytes fits a MaxAvatarSize image as a base64 data URL.
+const avatarJSONMaxBytes = MaxAvatarSize/3*4 + 4096
+
 // AllowedImageTypes are the allowed MIME types for avatar uploads
 var AllowedImageTypes = map[string]string{
 	"image/jpeg": ".jpg",
@@ -53,7 +59,7 @@ func (h *Handler) GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if user == nil {
-		writeError(w, http.StatusNotFound, "user not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
 		return
 	}
 
@@ -86,7 +92,7 @@ func (h *Handler) GetUserAvatar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if user == nil {
-		writeError(w, http.StatusNotFound, "user not found")
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
 		return
 	}
 
@@ -125,16 +131,16 @@ func (h *Handler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Demo users cannot upload avatars
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot upload avatars")
+	// Demo and viewer accounts cannot upload avatars
+	if denyWrite(w, r, "upload avatars") {
 		return
 	}
 
+	// The image arrives base64-encoded, a third larger than MaxAvatarSize.
 	var req AvatarUploadRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSONLimit(w, r, &req, max(maxBodyBytes.Load(), avatarJSONMaxBytes)); err != nil {
 		logError("Invalid JSON in avatar upload request", err)
-		writeError(w, http.StatusBadRequest, "invalid JSON in request body")
+		writeDecodeError(w, err, "invalid JSON in request body")
 		return
 	}
 
@@ -212,9 +218,8 @@ func (h *Handler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Demo users cannot delete avatars
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot delete avatars")
+	// Demo and viewer accounts cannot delete avatars
+	if denyWrite(w, r, "delete avatars") {
 		return
 	}
 
@@ -259,9 +264,8 @@ func (h *Handler) UploadAvatarMultipart(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Demo users cannot upload avatars
-	if isDemoUserFromContext(r) {
-		writeError(w, http.StatusForbidden, "demo users cannot upload avatars")
+	// Demo and viewer accounts cannot upload avatars
+	if denyWrite(w, r, "upload avatars") {
 		return
 	}
 
@@ -330,3 +334,141 @@ func (h *Handler) UploadAvatarMultipart(w http.ResponseWriter, r *http.Request)
 
 // Unused import placeholder to avoid compile errors when not all are needed
 var _ = time.Now
+
+// GetOTPDestination godoc
+// @Summary Get login OTP destination
+// @Description Returns where the current user's login OTPs are delivered. An empty channel means the server-wide Discord webhook.
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.OTPDestinationResponse "OTP destination with masked webhook"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /users/me/otp-destination [get]
+func (h *Handler) GetOTPDestination(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	user, err := h.repo.GetUserByID(*userID)
+	if err != nil {
+		logError("Failed to get user for OTP destination", err)
+		writeError(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	if user == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.otpDestinationResponse(user.OTPChannel, user.OTPDiscordWebhookURL, user.OTPEmail))
+}
+
+// UpdateOTPDestination godoc
+// @Summary Set login OTP destination
+// @Description Sets where the current user's login OTPs are delivered: their own Discord webhook or email address. A confirmation message is sent first and the change is rejected if it cannot be delivered, so a typo cannot lock the user out. Send an empty channel to revert to the server-wide webhook.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.OTPDestinationInput true "OTP destination"
+// @Success 200 {object} models.OTPDestinationResponse "Updated OTP destination"
+// @Failure 400 {object} map[string]string "Invalid destination or delivery failed"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /users/me/otp-destination [put]
+func (h *Handler) UpdateOTPDestination(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var input models.OTPDestinationInput
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+	if validationErr, err := h.validator.Validate(&input); validationErr != nil || err != nil {
+		if validationErr != nil {
+			writeValidationError(w, validationErr)
+			return
+		}
+		logError("Validation error", err)
+		writeError(w, http.StatusInternalServerError, "validation error")
+		return
+	}
+
+	switch input.Channel {
+	case models.OTPChannelDiscord:
+		// Same SSRF guard as notification configs: the confirmation message
+		// below would otherwise POST to any https host, including private ones.
+		if err := notification.ValidateDiscordWebhookURL(input.DiscordWebhookURL); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case models.OTPChannelEmail:
+		if input.Email == "" {
+			writeError(w, http.StatusBadRequest, "email is required for the email channel")
+			return
+		}
+		if h.cfg == nil || h.cfg.SMTP.Host == "" {
+			writeError(w, http.StatusBadRequest, "email delivery is not configured on this server")
+			return
+		}
+	}
+
+	user, err := h.repo.GetUserByID(*userID)
+	if err != nil || user == nil {
+		logError("Failed to get user for OTP destination", err)
+		writeError(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+
+	// Prove the destination works before switching to it.
+	if input.Channel != models.OTPChannelDefault {
+		candidate := *user
+		candidate.OTPChannel = input.Channel
+		candidate.OTPDiscordWebhookURL = input.DiscordWebhookURL
+		candidate.OTPEmail = input.Email
+		if n := h.userOTPNotifier(&candidate); n != nil {
+			if err := n.SendMessage("✅ DumpStation login codes will now be delivered here."); err != nil {
+				logError("OTP destination confirmation failed", err)
+				writeError(w, http.StatusBadRequest, "could not deliver a confirmation message to the new destination; it was not saved")
+				return
+			}
+		}
+	}
+
+	if err := h.repo.UpdateUserOTPDestination(*userID, input.Channel, input.DiscordWebhookURL, input.Email); err != nil {
+		logError("Failed to update OTP destination", err)
+		writeError(w, http.StatusInternalServerError, "failed to update OTP destination")
+		return
+	}
+
+	channel := string(input.Channel)
+	if channel == "" {
+		channel = "default"
+	}
+	h.logActivity(userID, models.ActionOTPDestinationUpdated, models.LogLevelInfo,
+		"user", userID, user.DiscordUsername,
+		fmt.Sprintf("Login OTP destination changed to %s", channel),
+		fmt.Sprintf(`{"channel":"%s"}`, channel), getIPAddress(r))
+
+	writeJSON(w, http.StatusOK, h.otpDestinationResponse(input.Channel, input.DiscordWebhookURL, input.Email))
+}
+
+// otpDestinationResponse masks the webhook for display.
+func (h *Handler) otpDestinationResponse(channel models.OTPChannel, webhookURL, email string) models.OTPDestinationResponse {
+	resp := models.OTPDestinationResponse{
+		Channel:        channel,
+		Email:          email,
+		EmailAvailable: h.cfg != nil && h.cfg.SMTP.Host != "",
+	}
+	if webhookURL != "" {
+		resp.DiscordWebhookURL = utils.MaskWebhookURL(webhookURL)
+	}
+	return resp
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/middleware"
+)
+
+// TestUpdateOTPDestinationRejectsNonDiscordWebhook checks that a personal
+// OTP webhook goes through the same Discord allow-list as notification
+// configs, so the confirmation POST cannot be aimed at an arbitrary host.
+func TestUpdateOTPDestinationRejectsNonDiscordWebhook(t *testing.T) {
+	h := New(nil, nil, nil, nil, nil, 0, false, "", 0, nil, nil)
+	body := `{"channel":"discord","discord_webhook_url":"https://example.com/api/webhooks/1/abc"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/me/otp-destination", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, &auth.Claims{UserID: uuid.New()}))
+	rec := httptest.NewRecorder()
+
+	h.UpdateOTPDestination(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d (body %s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "not a recognized Discord domain") {
+		t.Errorf("body = %s, want the Discord domain error", rec.Body.String())
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/repository"
+)
+
+// ListUsers godoc
+// @Summary  List users
+// @Description Returns every user with their admin, viewer and demo flags. Admin or viewer.
+// @Tags     Admin
+// @Security BearerAuth
+// @Produce  json
+// @Success  200 {array} models.UserProfileResponse
+// @Failure  403 {object} map[string]string "Admin access required"
+// @Failure  500 {object} map[string]string "Internal server error"
+// @Router   /admin/users [get]
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.repo.ListUsers()
+	if err != nil {
+		logError("Failed to list users", err)
+		writeError(w, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	out := make([]*models.UserProfileResponse, 0, len(users))
+	for _, u := range users {
+		out = append(out, u.ToProfileResponse())
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// SetUserRole godoc
+// @Summary  Grant or revoke admin or viewer
+// @Description Sets a user's admin and/or viewer flag; omitted fields keep their value. Viewers see everything an admin sees but cannot change anything. A user cannot be both, the last admin cannot be demoted and demo users cannot be promoted. The change applies to the user's next login; tokens already issued keep their old role until they expire. Admin only.
+// @Tags     Admin
+// @Security BearerAuth
+// @Accept   json
+// @Produce  json
+// @Param    id path string true "User ID (UUID)"
+// @Param    request body models.UserRoleRequest true "New role"
+// @Success  200 {object} models.UserProfileResponse
+// @Failure  400 {object} map[string]string "Invalid ID or body"
+// @Failure  403 {object} map[string]string "Admin access required"
+// @Failure  404 {object} map[string]string "User not found"
+// @Failure  409 {object} map[string]string "Last admin, demo user, or both admin and viewer"
+// @Router   /admin/users/{id}/role [put]
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+
+	var input models.UserRoleRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		writeDecodeError(w, err, "invalid JSON")
+		return
+	}
+	if input.IsAdmin == nil && input.IsViewer == nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, "is_admin or is_viewer is required")
+		return
+	}
+
+	before, err := h.repo.GetUserByID(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	if before == nil {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
+		return
+	}
+
+	user, err := h.repo.SetUserRole(id, input.IsAdmin, input.IsViewer)
+	switch {
+	case errors.Is(err, repository.ErrLastAdmin), errors.Is(err, repository.ErrDemoPromotion), errors.Is(err, repository.ErrRoleConflict):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		logError("Failed to set user role", err)
+		writeError(w, http.StatusInternalServerError, "failed to set user role")
+		return
+	case user == nil:
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeUserNotFound, "user not found")
+		return
+	}
+
+	name := userDisplayName(user)
+	var changes []string
+	if before.IsAdmin != user.IsAdmin {
+		changes = append(changes, roleChange("Admin", name, user.IsAdmin))
+	}
+	if before.IsViewer != user.IsViewer {
+		changes = append(changes, roleChange("Viewer", name, user.IsViewer))
+	}
+	if len(changes) > 0 {
+		desc := strings.Join(changes, "; ")
+		log.Printf("[SECURITY] %s by %s", desc, userID)
+		meta, _ := json.Marshal(map[string]any{"user_id": user.ID, "is_admin": user.IsAdmin, "is_viewer": user.IsViewer})
+		h.logActivity(userID, models.ActionUserRoleChanged, models.LogLevelWarning,
+			"user", &user.ID, name, desc, string(meta), getIPAddress(r))
+		if h.notifier != nil {
+			if err := h.notifier.SendMessage("🛡️ **Role change**: " + desc); err != nil {
+				logError("Failed to send role change notification", err)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, user.ToProfileResponse())
+}
+
+// roleChange describes one flag flip, e.g. "Viewer granted to alice".
+func roleChange(role, name string, granted bool) string {
+	if granted {
+		return fmt.Sprintf("%s granted to %s", role, name)
+	}
+	return fmt.Sprintf("%s revoked from %s", role, name)
+}
+
+// userDisplayName picks the most recognisable identifier a user has.
+func userDisplayName(u *models.User) string {
+	switch {
+	case u.GitHubLogin != "":
+		return u.GitHubLogin
+	case u.DiscordUsername != "":
+		return u.DiscordUsername
+	case u.Email != "":
+		return u.Email
+	default:
+		return u.ID.String()
+	}
+}
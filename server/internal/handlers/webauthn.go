@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// maxPasskeys bounds the passkeys one user may register.
+const maxPasskeys = 10
+
+// requireWebAuthn answers 503 and returns false when passkeys are not
+// configured (WEBAUTHN_RP_ID is empty).
+func (h *TwoFactorHandler) requireWebAuthn(w http.ResponseWriter) bool {
+	if h.webauthn == nil {
+		writeError(w, http.StatusServiceUnavailable, "passkeys are not enabled on this deployment")
+		return false
+	}
+	return true
+}
+
+// decodeWebAuthnField decodes a base64url value from the browser, with or
+// without padding.
+func decodeWebAuthnField(name, value string) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("%s must be base64url", name)
+	}
+	return raw, nil
+}
+
+// BeginPasskeyRegistration godoc
+// @Summary Start registering a passkey
+// @Description Returns the options for navigator.credentials.create. Send the result to POST /auth/webauthn/register within 5 minutes. Registering a passkey makes a second factor required at login. Requires X-2FA-Code when TOTP is enabled, otherwise a login within the last 5 minutes.
+// @Tags Two-Factor Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param X-2FA-Code header string false "Current TOTP code (required when TOTP is enabled)"
+// @Success 200 {object} auth.PublicKeyCredentialCreationOptions "Credential creation options"
+// @Failure 400 {object} map[string]string "Passkey limit reached"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Re-authentication required"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Passkeys are not enabled"
+// @Router /auth/webauthn/register/begin [post]
+func (h *TwoFactorHandler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if !h.requireWebAuthn(w) {
+		return
+	}
+	// A stolen session must not be able to plant its own second factor.
+	if !h.requireFreshAuth(w, r, *userID) {
+		return
+	}
+
+	user, err := h.repo.GetUserByID(*userID)
+	if err != nil || user == nil {
+		logError("Failed to get user for passkey registration", err)
+		writeError(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	creds, err := h.repo.ListWebAuthnCredentials(*userID)
+	if err != nil {
+		logError("Failed to list passkeys", err)
+		writeError(w, http.StatusInternalServerError, "failed to list passkeys")
+		return
+	}
+	if len(creds) >= maxPasskeys {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d passkeys can be registered", maxPasskeys))
+		return
+	}
+
+	challenge, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		logError("Failed to generate passkey challenge", err)
+		writeError(w, http.StatusInternalServerError, "failed to start registration")
+		return
+	}
+	if err := h.repo.SetPendingWebAuthnChallenge(*userID, challenge, auth.WebAuthnChallengeTTL); err != nil {
+		logError("Failed to store passkey challenge", err)
+		writeError(w, http.StatusInternalServerError, "failed to start registration")
+		return
+	}
+
+	name := user.DiscordUsername
+	if name == "" {
+		name = user.Email
+	}
+	existing := make([][]byte, len(creds))
+	for i, c := range creds {
+		existing[i] = c.CredentialID
+	}
+	handle := user.ID
+	writeJSON(w, http.StatusOK, h.webauthn.CreationOptions(challenge, handle[:], name, existing))
+}
+
+// FinishPasskeyRegistration godoc
+// @Summary Register a passkey
+// @Description Verifies the navigator.credentials.create result against the challenge from /auth/webauthn/register/begin and stores the passkey. From the next login a second factor is required; TOTP, if enabled, keeps working.
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.WebAuthnRegisterRequest true "Authenticator response (base64url fields)"
+// @Success 201 {object} models.WebAuthnCredential "Registered passkey"
+// @Failure 400 {object} map[string]string "Invalid or expired registration"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Passkey already registered"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Passkeys are not enabled"
+// @Router /auth/webauthn/register [post]
+func (h *TwoFactorHandler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if !h.requireWebAuthn(w) {
+		return
+	}
+
+	var req models.WebAuthnRegisterRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+	if verr, err := h.validator.Validate(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if verr != nil {
+		writeJSON(w, http.StatusBadRequest, verr)
+		return
+	}
+	clientData, err := decodeWebAuthnField("client_data_json", req.ClientDataJSON)
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, err.Error())
+		return
+	}
+	attestation, err := decodeWebAuthnField("attestation_object", req.AttestationObject)
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	challenge, err := h.repo.ConsumeWebAuthnChallenge(*userID)
+	if err != nil {
+		logError("Failed to load passkey challenge", err)
+		writeError(w, http.StatusInternalServerError, "failed to verify registration")
+		return
+	}
+	if challenge == "" {
+		writeError(w, http.StatusBadRequest, "no passkey registration in progress or it expired; start again")
+		return
+	}
+
+	verified, err := h.webauthn.VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		logging.Warnf("[2FA] ❌ Passkey registration rejected for user %s: %v", *userID, err)
+		writeError(w, http.StatusBadRequest, "passkey registration failed: "+err.Error())
+		return
+	}
+
+	cred := &models.WebAuthnCredential{
+		UserID:       *userID,
+		Name:         strings.TrimSpace(req.Name),
+		CredentialID: verified.ID,
+		PublicKey:    verified.PublicKey,
+		SignCount:    int64(verified.SignCount),
+	}
+	if err := h.repo.CreateWebAuthnCredential(cred); err != nil {
+		if writeConflict(w, err) {
+			return
+		}
+		logError("Failed to store passkey", err)
+		writeError(w, http.StatusInternalServerError, "failed to store passkey")
+		return
+	}
+
+	h.logActivity(userID, models.ActionPasskeyRegistered, models.LogLevelSuccess,
+		"user", userID, cred.Name,
+		fmt.Sprintf("Passkey '%s' registered", cred.Name),
+		"", getIPAddress(r))
+
+	writeJSON(w, http.StatusCreated, cred)
+}
+
+// ListPasskeys godoc
+// @Summary List registered passkeys
+// @Description Returns the authenticated user's passkeys with their names and last use
+// @Tags Two-Factor Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.WebAuthnCredential "Passkeys"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/webauthn/credentials [get]
+func (h *TwoFactorHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	creds, err := h.repo.ListWebAuthnCredentials(*userID)
+	if err != nil {
+		logError("Failed to list passkeys", err)
+		writeError(w, http.StatusInternalServerError, "failed to list passkeys")
+		return
+	}
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// DeletePasskey godoc
+// @Summary Remove a passkey
+// @Description Deletes one of the authenticated user's passkeys. Requires X-2FA-Code when TOTP is enabled, otherwise a login within the last 5 minutes.
+// @Tags Two-Factor Authentication
+// @Security BearerAuth
+// @Param id path string true "Passkey ID (UUID)"
+// @Param X-2FA-Code header string false "Current TOTP code (required when TOTP is enabled)"
+// @Success 204 "Passkey removed"
+// @Failure 400 {object} map[string]string "Invalid ID"
+// @Failure 403 {object} map[string]string "Re-authentication required"
+// @Failure 404 {object} map[string]string "Passkey not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/webauthn/credentials/{id} [delete]
+func (h *TwoFactorHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseUUID(mux.Vars(r)["id"])
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, models.ErrCodeInvalidID, "invalid ID")
+		return
+	}
+	if !h.requireFreshAuth(w, r, *userID) {
+		return
+	}
+
+	found, err := h.repo.DeleteWebAuthnCredential(*userID, id)
+	if err != nil {
+		logError("Failed to delete passkey", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete passkey")
+		return
+	}
+	if !found {
+		writeErrorCode(w, http.StatusNotFound, models.ErrCodeNotFound, "passkey not found")
+		return
+	}
+
+	h.logActivity(userID, models.ActionPasskeyRemoved, models.LogLevelWarning,
+		"user", userID, "",
+		"Passkey removed", "", getIPAddress(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// twoFactorTokenClaims validates the X-2FA-Token of the login in progress.
+func (h *TwoFactorHandler) twoFactorTokenClaims(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	tokenString := r.Header.Get("X-2FA-Token")
+	if tokenString == "" {
+		writeError(w, http.StatusUnauthorized, "missing 2FA token")
+		return nil, false
+	}
+	claims, err := h.jwtMgr.Validate2FAToken(tokenString)
+	if err != nil {
+		logError("Invalid 2FA token", err)
+		writeError(w, http.StatusUnauthorized, "invalid or expired 2FA token")
+		return nil, false
+	}
+	return claims, true
+}
+
+// BeginPasskeyLogin godoc
+// @Summary Start the passkey second factor
+// @Description Returns the options for navigator.credentials.get, limited to the user's passkeys. Use when the login response lists "webauthn" in two_factor_methods.
+// @Tags Two-Factor Authentication
+// @Produce json
+// @Param X-2FA-Token header string true "Temporary 2FA token from login"
+// @Success 200 {object} auth.PublicKeyCredentialRequestOptions "Credential request options"
+// @Failure 400 {object} map[string]string "No passkeys registered"
+// @Failure 401 {object} map[string]string "Invalid or expired 2FA token"
+// @Failure 429 {object} map[string]string "2FA temporarily locked"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Passkeys are not enabled"
+// @Router /auth/2fa/webauthn/begin [post]
+func (h *TwoFactorHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.twoFactorTokenClaims(w, r)
+	if !ok || !h.requireWebAuthn(w) {
+		return
+	}
+
+	user, err := h.repo.GetUserByID(claims.UserID)
+	if err != nil || user == nil {
+		logError("Failed to get user for passkey login", err)
+		writeError(w, http.StatusUnauthorized, "user not found")
+		return
+	}
+	if user.TwoFactorLockedUntil != nil && user.TwoFactorLockedUntil.After(time.Now()) {
+		writeTwoFactorLocked(w, *user.TwoFactorLockedUntil)
+		return
+	}
+
+	creds, err := h.repo.ListWebAuthnCredentials(claims.UserID)
+	if err != nil {
+		logError("Failed to list passkeys", err)
+		writeError(w, http.StatusInternalServerError, "failed to list passkeys")
+		return
+	}
+	if len(creds) == 0 {
+		writeError(w, http.StatusBadRequest, "no passkeys are registered for this user")
+		return
+	}
+
+	challenge, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		logError("Failed to generate passkey challenge", err)
+		writeError(w, http.StatusInternalServerError, "failed to start passkey login")
+		return
+	}
+	if err := h.repo.SetPendingWebAuthnChallenge(claims.UserID, challenge, auth.WebAuthnChallengeTTL); err != nil {
+		logError("Failed to store passkey challenge", err)
+		writeError(w, http.StatusInternalServerError, "failed to start passkey login")
+		return
+	}
+
+	allowed := make([][]byte, len(creds))
+	for i, c := range creds {
+		allowed[i] = c.CredentialID
+	}
+	writeJSON(w, http.StatusOK, h.webauthn.RequestOptions(challenge, allowed))
+}
+
+// VerifyPasskeyLogin godoc
+// @Summary Complete login with a passkey
+// @Description Verifies the navigator.credentials.get result against the challenge from /auth/2fa/webauthn/begin and returns a full access token. Failures count towards the same lockout as TOTP codes.
+// @Tags Two-Factor Authentication
+// @Accept json
+// @Produce json
+// @Param X-2FA-Token header string true "Temporary 2FA token from login"
+// @Param body body models.WebAuthnAssertionRequest true "Authenticator response (base64url fields)"
+// @Success 200 {object} models.AuthResponse "Full access JWT token"
+// @Failure 400 {object} map[string]string "Invalid or expired assertion"
+// @Failure 401 {object} map[string]string "Invalid or expired 2FA token"
+// @Failure 429 {object} map[string]string "2FA temporarily locked"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 503 {object} map[string]string "Passkeys are not enabled"
+// @Router /auth/2fa/webauthn/verify [post]
+func (h *TwoFactorHandler) VerifyPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.twoFactorTokenClaims(w, r)
+	if !ok || !h.requireWebAuthn(w) {
+		return
+	}
+
+	var req models.WebAuthnAssertionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err, "invalid request body")
+		return
+	}
+	var fields [4][]byte
+	for i, f := range []struct{ name, value string }{
+		{"id", req.CredentialID},
+		{"client_data_json", req.ClientDataJSON},
+		{"authenticator_data", req.AuthenticatorData},
+		{"signature", req.Signature},
+	} {
+		raw, err := decodeWebAuthnField(f.name, f.value)
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, models.ErrCodeBadRequest, err.Error())
+			return
+		}
+		fields[i] = raw
+	}
+	credID, clientData, authData, signature := fields[0], fields[1], fields[2], fields[3]
+
+	user, err := h.repo.GetUserByID(claims.UserID)
+	if err != nil || user == nil {
+		logError("Failed to get user for passkey login", err)
+		writeError(w, http.StatusUnauthorized, "user not found")
+		return
+	}
+	if user.TwoFactorLockedUntil != nil && user.TwoFactorLockedUntil.After(time.Now()) {
+		writeTwoFactorLocked(w, *user.TwoFactorLockedUntil)
+		return
+	}
+
+	challenge, err := h.repo.ConsumeWebAuthnChallenge(claims.UserID)
+	if err != nil {
+		logError("Failed to load passkey challenge", err)
+		writeError(w, http.StatusInternalServerError, "failed to verify passkey")
+		return
+	}
+	if challenge == "" {
+		writeError(w, http.StatusBadRequest, "no passkey login in progress or it expired; start again")
+		return
+	}
+
+	cred, err := h.repo.GetWebAuthnCredential(claims.UserID, credID)
+	if err != nil {
+		logError("Failed to get passkey", err)
+		writeError(w, http.StatusInternalServerError, "failed to verify passkey")
+		return
+	}
+	var signCount uint32
+	if cred == nil {
+		err = errors.New("unknown credential")
+	} else {
+		signCount, err = h.webauthn.VerifyAssertion(challenge, cred.CredentialID, cred.PublicKey, uint32(cred.SignCount), clientData, authData, signature)
+	}
+	if err != nil {
+		logging.Warnf("[2FA] ❌ Passkey login rejected for user %s: %v", user.DiscordUsername, err)
+		h.logActivity(&claims.UserID, models.Action2FAFailed, models.LogLevelError,
+			"user", &claims.UserID, user.DiscordUsername,
+			fmt.Sprintf("2FA verification failed for user %s - passkey rejected: %v", user.DiscordUsername, err),
+			"", getIPAddress(r))
+
+		failure, ferr := h.repo.RecordUser2FAFailure(claims.UserID)
+		if ferr != nil {
+			logError("Failed to record 2FA failure", ferr)
+		} else if failure.JustLocked {
+			h.on2FALocked(user, *failure.LockedUntil, getIPAddress(r))
+			writeTwoFactorLocked(w, *failure.LockedUntil)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "passkey verification failed")
+		return
+	}
+
+	if err := h.repo.RecordWebAuthnUse(cred.ID, signCount); err != nil {
+		logError("Failed to record passkey use", err)
+	}
+	if err := h.repo.ResetUser2FAFailures(claims.UserID); err != nil {
+		logError("Failed to reset 2FA failure counter", err)
+	}
+
+	token, expiresAt, err := h.jwtMgr.GenerateToken(claims.UserID, claims.DiscordUserID, user.IsAdmin, user.IsViewer)
+	if err != nil {
+		logError("Failed to generate token after passkey login", err)
+		writeError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+
+	h.logActivity(&claims.UserID, models.Action2FAVerified, models.LogLevelSuccess,
+		"user", &claims.UserID, user.DiscordUsername,
+		fmt.Sprintf("2FA verified successfully for user %s using passkey '%s'", user.DiscordUsername, cred.Name),
+		"", getIPAddress(r))
+	logInfo("✅ 2FA verified for user: %s (method: passkey)", user.DiscordUsername)
+
+	writeJSON(w, http.StatusOK, models.AuthResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// twoFactorMethods lists the second factors user can complete login with;
+// empty means none is required.
+func (h *Handler) twoFactorMethods(user *models.User) ([]string, error) {
+	var methods []string
+	if user.TwoFactorEnabled {
+		methods = append(methods, models.TwoFactorMethodTOTP)
+	}
+	passkeys, err := h.repo.CountWebAuthnCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	// Passkeys registered before WEBAUTHN_RP_ID was removed still count:
+	// dropping the setting must not silently turn 2FA off.
+	if passkeys > 0 {
+		methods = append(methods, models.TwoFactorMethodWebAuthn)
+	}
+	return methods, nil
+}
+
+// passkeyCount is the number of passkeys userID has, for status responses.
+func (h *TwoFactorHandler) passkeyCount(userID uuid.UUID) int {
+	n, err := h.repo.CountWebAuthnCredentials(userID)
+	if err != nil {
+		logError("Failed to count passkeys", err)
+		return 0
+	}
+	return int(n)
+}
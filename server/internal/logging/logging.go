@@ -0,0 +1,96 @@
+// Package logging gates the server's log output by severity. Lines are still
+// written through the standard log package, so the existing prefixes and
+// log.SetFlags settings keep applying.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log severity. Messages below the configured level are dropped.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// String returns the level's name as accepted by ParseLevel.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel parses debug, info, warn (or warning) and error, ignoring case.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// SetLevel sets the minimum level that is written. The default is info.
+func SetLevel(l Level) {
+	current.Store(int32(l))
+}
+
+// Enabled reports whether messages at l are written. Use it to skip
+// building expensive log arguments.
+func Enabled(l Level) bool {
+	return int32(l) >= current.Load()
+}
+
+// Debugf logs per-step detail that is only useful while developing or
+// diagnosing a problem. Secrets such as OTPs may only be logged here.
+func Debugf(format string, args ...interface{}) {
+	logf(LevelDebug, format, args...)
+}
+
+// Infof logs routine operational messages.
+func Infof(format string, args ...interface{}) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warnf logs conditions an operator should look at.
+func Warnf(format string, args ...interface{}) {
+	logf(LevelWarn, format, args...)
+}
+
+// Errorf logs failures.
+func Errorf(format string, args ...interface{}) {
+	logf(LevelError, format, args...)
+}
+
+func logf(l Level, format string, args ...interface{}) {
+	if !Enabled(l) {
+		return
+	}
+	log.Output(3, fmt.Sprintf(format, args...))
+}
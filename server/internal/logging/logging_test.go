@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Level
+		wantErr bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"", LevelInfo, false},
+		{"warn", LevelWarn, false},
+		{"Warning", LevelWarn, false},
+		{" error ", LevelError, false},
+		{"verbose", LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLevelGating(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		SetLevel(LevelInfo)
+	}()
+
+	SetLevel(LevelWarn)
+	Debugf("otp %s", "123456")
+	Infof("started")
+	Warnf("careful")
+	Errorf("failed")
+
+	out := buf.String()
+	if strings.Contains(out, "123456") || strings.Contains(out, "started") {
+		t.Errorf("messages below warn were written: %q", out)
+	}
+	if !strings.Contains(out, "careful") || !strings.Contains(out, "failed") {
+		t.Errorf("warn/error messages missing: %q", out)
+	}
+
+	buf.Reset()
+	SetLevel(LevelDebug)
+	Debugf("step")
+	if !strings.Contains(buf.String(), "step") {
+		t.Errorf("debug message missing at debug level: %q", buf.String())
+	}
+}
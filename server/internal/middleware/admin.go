@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// AdminOnlyMiddleware restricts a route to admin accounts. Viewers pass for
+// reads; pair it with ViewerReadOnlyMiddleware for their writes. It must
+// run after AuthMiddleware so the claims are already in the context.
+func AdminOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Allow OPTIONS for CORS preflight
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authClaims, ok := r.Context().Value(UserContextKey).(*auth.Claims)
+		if !ok || authClaims == nil || !(authClaims.IsAdmin || (authClaims.IsViewer && IsReadMethod(r.Method))) {
+			writeError(w, http.StatusForbidden, models.ErrCodeAdminRequired, "admin access required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -3,12 +3,12 @@ package middleware
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
 )
 
@@ -22,8 +22,8 @@ func AuthMiddleware(jwtManager *auth.JWTManager) func(http.Handler) http.Handler
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				log.Printf("[AUTH] ❌ Missing authorization header - %s %s", r.Method, r.URL.Path)
-				writeError(w, http.StatusUnauthorized, "missing authorization header")
+				logging.Warnf("[AUTH] ❌ Missing authorization header - %s %s", r.Method, r.URL.Path)
+				writeError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "missing authorization header")
 				return
 			}
 
@@ -31,8 +31,8 @@ func AuthMiddleware(jwtManager *auth.JWTManager) func(http.Handler) http.Handler
 			parts := strings.Split(authHeader, " ")
 			// log the parts for debugging
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				log.Printf("[AUTH] ❌ Invalid authorization header format - %s %s", r.Method, r.URL.Path)
-				writeError(w, http.StatusUnauthorized, "invalid authorization header format")
+				logging.Warnf("[AUTH] ❌ Invalid authorization header format - %s %s", r.Method, r.URL.Path)
+				writeError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "invalid authorization header format")
 				return
 			}
 
@@ -41,12 +41,12 @@ func AuthMiddleware(jwtManager *auth.JWTManager) func(http.Handler) http.Handler
 			// Validate token
 			claims, err := jwtManager.ValidateToken(token)
 			if err != nil {
-				log.Printf("[AUTH] ❌ Invalid or expired token - %s %s - Error: %v", r.Method, r.URL.Path, err)
-				writeError(w, http.StatusUnauthorized, "invalid or expired token")
+				logging.Warnf("[AUTH] ❌ Invalid or expired token - %s %s - Error: %v", r.Method, r.URL.Path, err)
+				writeError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "invalid or expired token")
 				return
 			}
 
-			log.Printf("[AUTH] ✅ Token validated - User: %s - %s %s", claims.UserID, r.Method, r.URL.Path)
+			logging.Debugf("[AUTH] ✅ Token validated - User: %s - %s %s", claims.UserID, r.Method, r.URL.Path)
 
 			// Add claims to request context
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
@@ -67,6 +67,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// extend the write deadline for long-running handlers.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func (rw *responseWriter) Write(b []byte) (int, error) {
 	if rw.statusCode == 0 {
 		rw.statusCode = http.StatusOK
@@ -85,7 +91,8 @@ func Logger(next http.Handler) http.Handler {
 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 		// Log incoming request
-		log.Printf("[REQUEST] ➡️  %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		path := redactPath(r.URL.Path)
+		logging.Debugf("[REQUEST] ➡️  %s %s from %s", r.Method, path, r.RemoteAddr)
 
 		// Process request
 		next.ServeHTTP(wrapped, r)
@@ -101,22 +108,34 @@ func Logger(next http.Handler) http.Handler {
 			statusEmoji = "❌"
 		}
 
-		log.Printf("[RESPONSE] ⬅️  %s %s %d %s - %v - %d bytes",
+		logging.Infof("[RESPONSE] ⬅️  %s %s %d %s - %v - %d bytes",
 			statusEmoji,
 			r.Method,
 			wrapped.statusCode,
-			r.URL.Path,
+			path,
 			duration,
 			wrapped.written,
 		)
 	})
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
+// redactPath hides the token in share-link paths (/share/<token>), which
+// would otherwise let anyone with log access download the backup.
+func redactPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1] == "share" && segments[i] != "" {
+			segments[i] = "[redacted]"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(models.APIError{
-		Code:    http.StatusText(status),
+		Code:    code,
 		Message: message,
 	})
 }
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/logging"
+)
+
+// TestLoggerRedactsShareToken checks that neither request log line carries
+// a share-link token.
+func TestLoggerRedactsShareToken(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	logging.SetLevel(logging.LevelDebug)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		logging.SetLevel(logging.LevelInfo)
+	})
+
+	const token = "s3cr3t-share-token"
+	handler := Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/share/"+token, nil))
+
+	out := buf.String()
+	if strings.Contains(out, token) {
+		t.Fatalf("log output contains the share token:\n%s", out)
+	}
+	if got := strings.Count(out, "/api/v1/share/[redacted]"); got != 2 {
+		t.Errorf("redacted path appears %d times, want 2:\n%s", got, out)
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/share/abc":                 "/api/v1/share/[redacted]",
+		"/api/v1/backups/123/share/abc":     "/api/v1/backups/123/share/[redacted]",
+		"/api/v1/backups/123/share":         "/api/v1/backups/123/share",
+		"/api/v1/databases/123/backups/456": "/api/v1/databases/123/backups/456",
+	}
+	for in, want := range tests {
+		if got := redactPath(in); got != want {
+			t.Errorf("redactPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
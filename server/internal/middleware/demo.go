@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/models"
 )
 
 // DemoRestrictionMiddleware blocks write operations for demo accounts
@@ -32,7 +33,7 @@ func DemoRestrictionMiddleware(next http.Handler) http.Handler {
 
 		// Check if this is a demo account
 		if authClaims.IsDemo {
-			writeError(w, http.StatusForbidden, "demo accounts cannot perform write operations")
+			writeError(w, http.StatusForbidden, models.ErrCodeDemoForbidden, "demo accounts cannot perform write operations")
 			return
 		}
 
@@ -65,7 +66,7 @@ func DemoBlockMiddleware(next http.Handler) http.Handler {
 
 		// Block demo accounts from accessing this endpoint
 		if authClaims.IsDemo {
-			writeError(w, http.StatusForbidden, "this feature is not available for demo accounts")
+			writeError(w, http.StatusForbidden, models.ErrCodeDemoForbidden, "this feature is not available for demo accounts")
 			return
 		}
 
@@ -122,6 +122,8 @@ func writeRateLimitError(w http.ResponseWriter, msg string, retryAfterSeconds in
 	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusTooManyRequests)
+	// Rate-limit responses keep their original {"error", "code"} body;
+	// clients already parse it, so it does not follow APIError.
 	_ = json.NewEncoder(w).Encode(map[string]string{
 		"error": msg,
 		"code":  "rate_limited",
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWriteRateLimitErrorBody checks the 429 keeps its original
+// {"error", "code"} body next to the Retry-After header.
+func TestWriteRateLimitErrorBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeRateLimitError(rec, "too many requests, please retry later", 60)
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("status %d, Retry-After %q; want 429 and 60", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"error": "too many requests, please retry later", "code": "rate_limited"}
+	if len(body) != len(want) || body["error"] != want["error"] || body["code"] != want["code"] {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
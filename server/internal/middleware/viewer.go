@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/monzim/db_proxy/v1/internal/auth"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// IsReadMethod reports whether an HTTP method only reads. Viewers are
+// limited to these.
+func IsReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// ViewerReadOnlyMiddleware blocks write operations for viewer accounts.
+// Viewers see everything an admin sees but change nothing, so this is
+// applied to every subrouter that carries writes. It must run after
+// AuthMiddleware so the claims are already in the context.
+func ViewerReadOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsReadMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authClaims, ok := r.Context().Value(UserContextKey).(*auth.Claims)
+		if ok && authClaims != nil && authClaims.IsViewer {
+			writeError(w, http.StatusForbidden, models.ErrCodeViewerReadOnly, "viewer accounts are read-only")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
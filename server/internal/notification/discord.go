@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,10 +16,42 @@ import (
 
 // DiscordMessage represents a Discord webhook message
 type DiscordMessage struct {
-	Content  string `json:"content"`
-	Username string `json:"username,omitempty"`
+	Content  string         `json:"content,omitempty"`
+	Username string         `json:"username,omitempty"`
+	Embeds   []DiscordEmbed `json:"embeds,omitempty"`
 }
 
+// DiscordEmbed is a rich message card: a title, a coloured sidebar, inline
+// fields and a timestamp Discord renders in the reader's time zone.
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+// DiscordEmbedField is one name/value pair of an embed.
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Embed sidebar colours, keyed to the outcome.
+const (
+	embedColorSuccess = 0x2ECC71
+	embedColorFailure = 0xE74C3C
+)
+
+// Discord rejects embeds whose description or field values exceed these,
+// and plain messages whose content exceeds messageMaxContent.
+const (
+	embedMaxDescription = 4096
+	embedMaxFieldValue  = 1024
+	messageMaxContent   = 2000
+)
+
 const (
 	discordRequestTimeout = 10 * time.Second
 	discordMaxAttempts    = 3
@@ -31,10 +64,31 @@ const (
 // API call would block the calling goroutine forever.
 var httpClient = &http.Client{Timeout: discordRequestTimeout}
 
+// ErrRateLimited is matched (via errors.Is) by every error that gave up
+// because Discord kept answering 429. Callers use it to tell the user that
+// delivery is delayed rather than broken.
+var ErrRateLimited = errors.New("notification delivery rate limited")
+
+// RateLimitError is returned when Discord rate-limited the webhook and the
+// retry budget ran out, or when Discord asked us to wait longer than we are
+// willing to block for. RetryAfter is Discord's last requested delay (zero
+// when the header was missing).
+type RateLimitError struct {
+	RetryAfter time.Duration
+	err        error
+}
+
+func (e *RateLimitError) Error() string        { return e.err.Error() }
+func (e *RateLimitError) Unwrap() error        { return e.err }
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
 // DiscordNotifier handles Discord notifications
 type DiscordNotifier struct {
-	webhookURL string
-	username   string
+	webhookURL    string
+	username      string
+	maxAttempts   int
+	maxRetryAfter time.Duration
+	queue         *Queue
 }
 
 // NewDiscordNotifier creates a new Discord notifier
@@ -43,23 +97,81 @@ func NewDiscordNotifier(webhookURL, username string) *DiscordNotifier {
 		username = "PostgreSQL Backup Service"
 	}
 	return &DiscordNotifier{
-		webhookURL: webhookURL,
-		username:   username,
+		webhookURL:    webhookURL,
+		username:      username,
+		maxAttempts:   discordMaxAttempts,
+		maxRetryAfter: discordMaxBackoff,
+	}
+}
+
+// SetRetryPolicy overrides how many POST attempts a message gets and the
+// longest Retry-After (from a 429) the notifier will sleep for before
+// giving up with a RateLimitError. Non-positive values keep the defaults.
+func (dn *DiscordNotifier) SetRetryPolicy(maxAttempts int, maxRetryAfter time.Duration) {
+	if maxAttempts > 0 {
+		dn.maxAttempts = maxAttempts
+	}
+	if maxRetryAfter > 0 {
+		dn.maxRetryAfter = maxRetryAfter
 	}
 }
 
-// SendMessage sends a message to Discord webhook with bounded retry. 5xx
-// responses and network errors retry with exponential backoff; 429 honors
-// the Retry-After header when present. 4xx (other than 429) are permanent
-// failures and are not retried.
+// UseQueue routes SendMessage and the backup/restore notifications through
+// q instead of posting inline. OTP messages always bypass the queue because
+// login needs to know whether the code was delivered.
+func (dn *DiscordNotifier) UseQueue(q *Queue) {
+	dn.queue = q
+}
+
+// SendMessage sends a message to the Discord webhook. With a queue attached
+// the message is enqueued and nil returned unless the backlog is full;
+// otherwise it is posted inline (see send).
 func (dn *DiscordNotifier) SendMessage(message string) error {
+	return dn.dispatch(func() error { return dn.send(message) })
+}
+
+// SendEmbed posts embed, falling back to fallback as plain text when the
+// webhook rejects the embed (some Discord-compatible endpoints only accept
+// content). The fallback is cut to Discord's content limit, since it
+// carries the full error. It is queued like SendMessage.
+func (dn *DiscordNotifier) SendEmbed(embed DiscordEmbed, fallback string) error {
+	return dn.dispatch(func() error {
+		err := dn.post(DiscordMessage{Username: dn.username, Embeds: []DiscordEmbed{embed}})
+		if err == nil || errIsTransient(err) || errors.Is(err, ErrRateLimited) {
+			return err
+		}
+		log.Printf("Discord webhook rejected embed, sending plain text: %v", err)
+		return dn.send(truncateRunes(fallback, messageMaxContent))
+	})
+}
+
+// dispatch runs deliver through the queue when one is attached, or inline.
+func (dn *DiscordNotifier) dispatch(deliver func() error) error {
 	if dn.webhookURL == "" {
 		return nil // Notifications disabled
 	}
+	if dn.queue != nil {
+		err := dn.queue.Enqueue(dn.webhookURL, deliver)
+		if !errors.Is(err, ErrQueueClosed) {
+			return err
+		}
+	}
+	return deliver()
+}
 
-	payload := DiscordMessage{
-		Content:  message,
-		Username: dn.username,
+// send posts a plain-text message to the Discord webhook (see post).
+func (dn *DiscordNotifier) send(message string) error {
+	return dn.post(DiscordMessage{Content: message, Username: dn.username})
+}
+
+// post sends payload to the Discord webhook with bounded retry. 5xx
+// responses and network errors retry with exponential backoff; 429 waits
+// for the Retry-After Discord asked for, unless that exceeds the configured
+// ceiling, in which case it gives up straight away with a RateLimitError.
+// 4xx (other than 429) are permanent failures and are not retried.
+func (dn *DiscordNotifier) post(payload DiscordMessage) error {
+	if dn.webhookURL == "" {
+		return nil // Notifications disabled
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -67,8 +179,17 @@ func (dn *DiscordNotifier) SendMessage(message string) error {
 		return fmt.Errorf("failed to marshal Discord message: %w", err)
 	}
 
+	maxAttempts := dn.maxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = discordMaxAttempts
+	}
+	maxRetryAfter := dn.maxRetryAfter
+	if maxRetryAfter <= 0 {
+		maxRetryAfter = discordMaxBackoff
+	}
+
 	var lastErr error
-	for attempt := 1; attempt <= discordMaxAttempts; attempt++ {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		retryAfter, err := dn.postOnce(jsonData)
 		if err == nil {
 			return nil
@@ -79,19 +200,22 @@ func (dn *DiscordNotifier) SendMessage(message string) error {
 			return err
 		}
 
-		if attempt == discordMaxAttempts {
+		if attempt == maxAttempts {
 			break
 		}
 
 		wait := backoffDuration(attempt)
-		if retryAfter > 0 && retryAfter < discordMaxBackoff {
+		if errors.Is(err, ErrRateLimited) && retryAfter > 0 {
+			if retryAfter > maxRetryAfter {
+				return fmt.Errorf("Discord webhook asked to retry after %s (limit %s): %w", retryAfter, maxRetryAfter, err)
+			}
 			wait = retryAfter
 		}
-		log.Printf("Discord webhook attempt %d/%d failed: %v (retrying in %s)", attempt, discordMaxAttempts, err, wait)
+		log.Printf("Discord webhook attempt %d/%d failed: %v (retrying in %s)", attempt, maxAttempts, err, wait)
 		time.Sleep(wait)
 	}
 
-	return fmt.Errorf("Discord webhook failed after %d attempts: %w", discordMaxAttempts, lastErr)
+	return fmt.Errorf("Discord webhook failed after %d attempts: %w", maxAttempts, lastErr)
 }
 
 // postOnce performs a single POST. Returns (retryAfter, error). retryAfter
@@ -124,7 +248,11 @@ func (dn *DiscordNotifier) postOnce(payload []byte) (time.Duration, error) {
 	case resp.StatusCode >= 200 && resp.StatusCode < 300:
 		return 0, nil
 	case resp.StatusCode == http.StatusTooManyRequests:
-		return parseRetryAfter(resp.Header.Get("Retry-After")), transientErrorf("rate limited (429): %s", body)
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return retryAfter, &RateLimitError{
+			RetryAfter: retryAfter,
+			err:        transientErrorf("rate limited (429): %s", body),
+		}
 	case resp.StatusCode >= 500:
 		return 0, transientErrorf("server error %d: %s", resp.StatusCode, body)
 	default:
@@ -184,7 +312,7 @@ func (dn *DiscordNotifier) SendOTP(otp string) error {
 	message := fmt.Sprintf("🔐 **Login OTP Code**: `%s`\n⏰ This code expires in 5 minutes.\n\n_Requested at: %s_",
 		otp,
 		fmt.Sprintf("<t:%d:F>", time.Now().Unix()))
-	return dn.SendMessage(message)
+	return dn.send(message)
 }
 
 // SendDownloadOTP sends a backup-download OTP via Discord webhook.
@@ -192,32 +320,73 @@ func (dn *DiscordNotifier) SendDownloadOTP(otp, backupName string) error {
 	message := fmt.Sprintf("📥 **Backup Download Code**: `%s`\n📦 Backup: `%s`\n⏰ Expires in 5 minutes.\n\n_Requested at: %s_",
 		otp, backupName,
 		fmt.Sprintf("<t:%d:F>", time.Now().Unix()))
-	return dn.SendMessage(message)
+	return dn.send(message)
 }
 
 // SendBackupSuccess sends backup success notification
 func (dn *DiscordNotifier) SendBackupSuccess(dbName string, sizeBytes int64, duration string) error {
 	message := fmt.Sprintf("✅ **Backup Completed**\n📊 Database: `%s`\n💾 Size: %s\n⏱️ Duration: %s",
 		dbName, formatBytes(sizeBytes), duration)
-	return dn.SendMessage(message)
+	return dn.SendEmbed(newEmbed("✅ Backup Completed", "", embedColorSuccess,
+		embedField("Database", dbName),
+		embedField("Size", formatBytes(sizeBytes)),
+		embedField("Duration", duration),
+	), message)
 }
 
 // SendBackupFailure sends backup failure notification
 func (dn *DiscordNotifier) SendBackupFailure(dbName, errorMsg string) error {
 	message := fmt.Sprintf("❌ **Backup Failed**\n📊 Database: `%s`\n⚠️ Error: %s", dbName, errorMsg)
-	return dn.SendMessage(message)
+	return dn.SendEmbed(newEmbed("❌ Backup Failed", errorMsg, embedColorFailure,
+		embedField("Database", dbName),
+	), message)
 }
 
 // SendRestoreSuccess sends restore success notification
 func (dn *DiscordNotifier) SendRestoreSuccess(dbName, targetDB string) error {
 	message := fmt.Sprintf("✅ **Restore Completed**\n📊 Source: `%s`\n🎯 Target: `%s`", dbName, targetDB)
-	return dn.SendMessage(message)
+	return dn.SendEmbed(newEmbed("✅ Restore Completed", "", embedColorSuccess,
+		embedField("Source", dbName),
+		embedField("Target", targetDB),
+	), message)
 }
 
 // SendRestoreFailure sends restore failure notification
 func (dn *DiscordNotifier) SendRestoreFailure(dbName, errorMsg string) error {
 	message := fmt.Sprintf("❌ **Restore Failed**\n📊 Database: `%s`\n⚠️ Error: %s", dbName, errorMsg)
-	return dn.SendMessage(message)
+	return dn.SendEmbed(newEmbed("❌ Restore Failed", errorMsg, embedColorFailure,
+		embedField("Database", dbName),
+	), message)
+}
+
+// newEmbed builds an outcome embed stamped with the current time.
+// description is trimmed to Discord's limit.
+func newEmbed(title, description string, color int, fields ...DiscordEmbedField) DiscordEmbed {
+	return DiscordEmbed{
+		Title:       title,
+		Description: truncateRunes(description, embedMaxDescription),
+		Color:       color,
+		Fields:      fields,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// embedField is an inline field; Discord rejects empty values, so those
+// show as "-".
+func embedField(name, value string) DiscordEmbedField {
+	if value == "" {
+		value = "-"
+	}
+	return DiscordEmbedField{Name: name, Value: truncateRunes(value, embedMaxFieldValue), Inline: true}
+}
+
+// truncateRunes shortens s to at most max runes, marking the cut with "…".
+func truncateRunes(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
 }
 
 // formatBytes formats bytes to human-readable format
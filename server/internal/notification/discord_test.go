@@ -0,0 +1,136 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// TestDiscordNotifier_EmbedWithPlainFallback checks backup notifications
+// go out as coloured embeds, and that a webhook rejecting embeds still gets
+// the message as plain text.
+func TestDiscordNotifier_EmbedWithPlainFallback(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		payloads []DiscordMessage
+	)
+	rejectEmbeds := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg DiscordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, msg)
+		reject := rejectEmbeds && len(msg.Embeds) > 0
+		mu.Unlock()
+		if reject {
+			http.Error(w, `{"code":50035,"message":"Invalid Form Body"}`, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	// received snapshots payloads under the lock the server handler uses.
+	received := func() []DiscordMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]DiscordMessage(nil), payloads...)
+	}
+
+	dn := NewDiscordNotifier(srv.URL, "")
+	if err := dn.SendBackupSuccess("prod", 2048, "3s"); err != nil {
+		t.Fatalf("SendBackupSuccess: %v", err)
+	}
+	got := received()
+	if len(got) != 1 || len(got[0].Embeds) != 1 || got[0].Content != "" {
+		t.Fatalf("want one embed-only message, got %+v", got)
+	}
+	embed := got[0].Embeds[0]
+	if embed.Color != embedColorSuccess || embed.Timestamp == "" || len(embed.Fields) != 3 || embed.Fields[1].Value != "2.0 KB" {
+		t.Fatalf("unexpected success embed: %+v", embed)
+	}
+
+	mu.Lock()
+	payloads, rejectEmbeds = nil, true
+	mu.Unlock()
+	if err := dn.SendBackupFailure("prod", "connection refused"); err != nil {
+		t.Fatalf("SendBackupFailure: %v", err)
+	}
+	got = received()
+	if len(got) != 2 {
+		t.Fatalf("want embed attempt then plain fallback, got %d requests", len(got))
+	}
+	if got[0].Embeds[0].Color != embedColorFailure {
+		t.Fatalf("failure embed colour = %#x", got[0].Embeds[0].Color)
+	}
+	if len(got[1].Embeds) != 0 || got[1].Content == "" {
+		t.Fatalf("fallback should be plain text, got %+v", got[1])
+	}
+
+	// A long error must not push the fallback past Discord's content limit.
+	mu.Lock()
+	payloads = nil
+	mu.Unlock()
+	if err := dn.SendBackupFailure("prod", strings.Repeat("x", 5000)); err != nil {
+		t.Fatalf("SendBackupFailure with a long error: %v", err)
+	}
+	got = received()
+	if len(got) != 2 {
+		t.Fatalf("want embed attempt then plain fallback, got %d requests", len(got))
+	}
+	if n := utf8.RuneCountInString(got[1].Content); n > messageMaxContent {
+		t.Errorf("fallback is %d runes, want at most %d", n, messageMaxContent)
+	}
+}
+
+// TestDiscordNotifier_RateLimited checks a webhook that keeps answering 429
+// ends in a RateLimitError carrying Discord's Retry-After, that short waits
+// are retried up to the configured attempts, and that a wait over the
+// configured ceiling gives up at once.
+func TestDiscordNotifier_RateLimited(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	retryAfter := "0.01"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", retryAfter)
+		http.Error(w, `{"message":"You are being rate limited."}`, http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	dn := NewDiscordNotifier(srv.URL, "")
+	dn.SetRetryPolicy(2, time.Second)
+	err := dn.SendOTP("123456")
+	var rl *RateLimitError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rl) {
+		t.Fatalf("SendOTP error = %v, want a RateLimitError", err)
+	}
+	if rl.RetryAfter != 10*time.Millisecond {
+		t.Errorf("RetryAfter = %s, want 10ms", rl.RetryAfter)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("made %d requests, want 2 (SetRetryPolicy attempts)", n)
+	}
+
+	requests.Store(0)
+	retryAfter = "60"
+	start := time.Now()
+	err = dn.SendOTP("123456")
+	if !errors.As(err, &rl) || rl.RetryAfter != time.Minute {
+		t.Fatalf("SendOTP error = %v, want a RateLimitError asking for 1m", err)
+	}
+	if n := requests.Load(); n != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("made %d requests in %s, want one and no wait", n, time.Since(start))
+	}
+}
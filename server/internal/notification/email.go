@@ -0,0 +1,168 @@
+package notification
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// emailDialTimeout bounds the TCP connect + SMTP conversation. Like the chat
+// clients, a hung mail relay must never pin the login request goroutine.
+const emailDialTimeout = 10 * time.Second
+
+// defaultOTPExpiry is the login OTP lifetime quoted in emails until
+// SetOTPExpiry is called; it matches OTP_EXPIRATION_MINUTES's default.
+const defaultOTPExpiry = 5 * time.Minute
+
+// EmailNotifier delivers messages over SMTP. It is primarily used as the
+// fallback OTP channel when Discord is unreachable, so it deliberately
+// keeps no state beyond its connection settings and the OTP expiry it
+// quotes. An empty host or recipient list makes every call a silent no-op.
+type EmailNotifier struct {
+	host      string
+	port      int
+	username  string
+	password  string
+	from      string
+	to        []string
+	otpExpiry time.Duration
+}
+
+// NewEmailNotifier constructs an SMTP notifier. username/password may be
+// empty for relays that accept unauthenticated mail from this host.
+func NewEmailNotifier(host string, port int, username, password, from string, to []string) *EmailNotifier {
+	if from == "" {
+		from = username
+	}
+	return &EmailNotifier{
+		host:      host,
+		port:      port,
+		username:  username,
+		password:  password,
+		from:      from,
+		to:        to,
+		otpExpiry: defaultOTPExpiry,
+	}
+}
+
+// SetOTPExpiry sets how long login OTP emails say the code is valid for.
+// Pass the expiry the codes are actually stored with; non-positive values
+// keep the default.
+func (en *EmailNotifier) SetOTPExpiry(d time.Duration) {
+	if d > 0 {
+		en.otpExpiry = d
+	}
+}
+
+// SendMessage emails message as a plain-text body. The first line doubles
+// as the subject (with Discord/Telegram markdown stripped) so the inbox
+// preview is meaningful.
+func (en *EmailNotifier) SendMessage(message string) error {
+	if en.host == "" || len(en.to) == 0 {
+		return nil
+	}
+
+	subject, _, _ := strings.Cut(message, "\n")
+	subject = strings.NewReplacer("*", "", "`", "", "_", "").Replace(subject)
+	return en.send(strings.TrimSpace(subject), message)
+}
+
+// send performs a single SMTP delivery. STARTTLS is used whenever the server
+// advertises it; credentials are only sent over an encrypted connection.
+func (en *EmailNotifier) send(subject, body string) error {
+	addr := net.JoinHostPort(en.host, fmt.Sprintf("%d", en.port))
+	conn, err := net.DialTimeout("tcp", addr, emailDialTimeout)
+	if err != nil {
+		return fmt.Errorf("SMTP dial %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(emailDialTimeout))
+
+	client, err := smtp.NewClient(conn, en.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: en.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("SMTP STARTTLS: %w", err)
+		}
+	}
+	if en.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", en.username, en.password, en.host)); err != nil {
+			return fmt.Errorf("SMTP auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(en.from); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM: %w", err)
+	}
+	for _, rcpt := range en.to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("SMTP RCPT TO %s: %w", rcpt, err)
+		}
+	}
+
+	wc, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		en.from, strings.Join(en.to, ", "), subject, time.Now().UTC().Format(time.RFC1123Z),
+		strings.ReplaceAll(body, "\n", "\r\n"))
+	if _, err := wc.Write([]byte(msg)); err != nil {
+		wc.Close()
+		return fmt.Errorf("SMTP write: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("SMTP DATA close: %w", err)
+	}
+	return client.Quit()
+}
+
+// SendOTP emails a login OTP.
+func (en *EmailNotifier) SendOTP(otp string) error {
+	return en.SendMessage(fmt.Sprintf("DumpStation login code: %s\n\nThis code expires in %s.\nRequested at: %s",
+		otp, formatMinutes(en.otpExpiry), time.Now().UTC().Format(time.RFC3339)))
+}
+
+// formatMinutes renders d as a whole number of minutes, rounding up so a
+// code is never described as expiring sooner than it does.
+func formatMinutes(d time.Duration) string {
+	n := int((d + time.Minute - 1) / time.Minute)
+	if n == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", n)
+}
+
+// SendDownloadOTP emails a backup-download OTP. Download codes always live
+// for five minutes (downloadOTPTTL in the handlers), unlike login codes.
+func (en *EmailNotifier) SendDownloadOTP(otp, backupName string) error {
+	return en.SendMessage(fmt.Sprintf("DumpStation backup download code: %s\n\nBackup: %s\nThis code expires in 5 minutes.\nRequested at: %s",
+		otp, backupName, time.Now().UTC().Format(time.RFC3339)))
+}
+
+// SendBackupSuccess emails a backup success notification.
+func (en *EmailNotifier) SendBackupSuccess(dbName string, sizeBytes int64, duration string) error {
+	return en.SendMessage(fmt.Sprintf("Backup completed: %s\n\nSize: %s\nDuration: %s", dbName, formatBytes(sizeBytes), duration))
+}
+
+// SendBackupFailure emails a backup failure notification.
+func (en *EmailNotifier) SendBackupFailure(dbName, errorMsg string) error {
+	return en.SendMessage(fmt.Sprintf("Backup failed: %s\n\nError: %s", dbName, errorMsg))
+}
+
+// SendRestoreSuccess emails a restore success notification.
+func (en *EmailNotifier) SendRestoreSuccess(dbName, targetDB string) error {
+	return en.SendMessage(fmt.Sprintf("Restore completed: %s\n\nTarget: %s", dbName, targetDB))
+}
+
+// SendRestoreFailure emails a restore failure notification.
+func (en *EmailNotifier) SendRestoreFailure(dbName, errorMsg string) error {
+	return en.SendMessage(fmt.Sprintf("Restore failed: %s\n\nError: %s", dbName, errorMsg))
+}
@@ -0,0 +1,98 @@
+package notification
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one plain (no STARTTLS, no AUTH) SMTP session and sends
+// the DATA it received on the returned channel.
+func fakeSMTP(t *testing.T) (host string, port int, data <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				out <- body.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, out
+}
+
+// TestEmailNotifier_SendOTPQuotesExpiry checks the login code email states
+// the expiry the code is stored with rather than a fixed five minutes.
+func TestEmailNotifier_SendOTPQuotesExpiry(t *testing.T) {
+	t.Parallel()
+
+	host, port, data := fakeSMTP(t)
+	en := NewEmailNotifier(host, port, "", "", "dumpstation@example.com", []string{"ops@example.com"})
+	en.SetOTPExpiry(15 * time.Minute)
+	if err := en.SendOTP("424242"); err != nil {
+		t.Fatalf("SendOTP: %v", err)
+	}
+	select {
+	case body := <-data:
+		for _, want := range []string{"Subject: DumpStation login code: 424242", "This code expires in 15 minutes."} {
+			if !strings.Contains(body, want) {
+				t.Errorf("email lacks %q:\n%s", want, body)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no email received")
+	}
+}
+
+func TestFormatMinutes(t *testing.T) {
+	t.Parallel()
+
+	for d, want := range map[time.Duration]string{
+		time.Minute:      "1 minute",
+		5 * time.Minute:  "5 minutes",
+		90 * time.Second: "2 minutes",
+	} {
+		if got := formatMinutes(d); got != want {
+			t.Errorf("formatMinutes(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -25,7 +25,9 @@ type Notifier interface {
 
 // NotifierFromConfig builds a Notifier from a NotificationConfig row. When
 // the row carries both Discord and Telegram credentials the returned
-// notifier fans out to both. A nil config or an empty config returns a
+// notifier fans out to both. Discord messages go through the default queue
+// when one is set. Backup messages use the config's templates when it has
+// any. A nil config or an empty config returns a
 // no-op notifier so callers can dispatch unconditionally.
 func NotifierFromConfig(cfg *models.NotificationConfig) Notifier {
 	if cfg == nil {
@@ -33,7 +35,9 @@ func NotifierFromConfig(cfg *models.NotificationConfig) Notifier {
 	}
 	var parts []Notifier
 	if cfg.HasDiscord() {
-		parts = append(parts, NewDiscordNotifier(cfg.DiscordWebhookURL, ""))
+		dn := NewDiscordNotifier(cfg.DiscordWebhookURL, "")
+		dn.UseQueue(DefaultQueue())
+		parts = append(parts, dn)
 	}
 	if cfg.HasTelegram() {
 		parts = append(parts, NewTelegramNotifier(cfg.TelegramBotToken, cfg.TelegramChatID))
@@ -42,9 +46,9 @@ func NotifierFromConfig(cfg *models.NotificationConfig) Notifier {
 	case 0:
 		return noopNotifier{}
 	case 1:
-		return parts[0]
+		return withTemplates(parts[0], cfg.SuccessTemplate, cfg.FailureTemplate)
 	default:
-		return MultiNotifier(parts)
+		return withTemplates(MultiNotifier(parts), cfg.SuccessTemplate, cfg.FailureTemplate)
 	}
 }
 
@@ -140,6 +144,8 @@ func (m MultiNotifier) SendDownloadOTP(otp, backupName string) error {
 // to tell the user "code sent via Discord, Telegram".
 func Channels(n Notifier) []string {
 	switch v := n.(type) {
+	case templatedNotifier:
+		return Channels(v.Notifier)
 	case MultiNotifier:
 		out := make([]string, 0, len(v))
 		for _, child := range v {
@@ -150,6 +156,8 @@ func Channels(n Notifier) []string {
 		return []string{"discord"}
 	case *TelegramNotifier:
 		return []string{"telegram"}
+	case *EmailNotifier:
+		return []string{"email"}
 	default:
 		return nil
 	}
@@ -0,0 +1,146 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	queueDefaultInterval      = 500 * time.Millisecond
+	queueDefaultDepth         = 100
+	queueMaxRetryAfter        = time.Minute
+	queueMaxRateLimitAttempts = 5
+)
+
+var (
+	// ErrQueueFull is returned when a webhook's backlog is at capacity and
+	// the message was dropped.
+	ErrQueueFull = errors.New("notification queue full")
+	// ErrQueueClosed is returned by Enqueue after Close; callers fall back
+	// to sending inline.
+	ErrQueueClosed = errors.New("notification queue closed")
+)
+
+// Queue serializes outbound notifications per destination. Each key (a
+// webhook URL) gets its own worker that sends one message at a time and
+// waits at least interval between sends, so many databases sharing one
+// webhook cannot burst past Discord's rate limit. A 429 parks the worker
+// for the Retry-After Discord asked for (capped at a minute) and the same
+// message is tried again.
+type Queue struct {
+	interval time.Duration
+	depth    int
+
+	mu     sync.Mutex
+	lanes  map[string]chan func() error
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewQueue creates a queue. Non-positive interval or depth use defaults.
+func NewQueue(interval time.Duration, depth int) *Queue {
+	if interval <= 0 {
+		interval = queueDefaultInterval
+	}
+	if depth <= 0 {
+		depth = queueDefaultDepth
+	}
+	return &Queue{
+		interval: interval,
+		depth:    depth,
+		lanes:    make(map[string]chan func() error),
+	}
+}
+
+// Enqueue schedules send on key's worker and returns immediately. Delivery
+// errors are logged by the worker, not returned.
+func (q *Queue) Enqueue(key string, send func() error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	lane, ok := q.lanes[key]
+	if !ok {
+		lane = make(chan func() error, q.depth)
+		q.lanes[key] = lane
+		q.wg.Add(1)
+		go q.work(lane)
+	}
+	select {
+	case lane <- send:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) work(lane chan func() error) {
+	defer q.wg.Done()
+	for send := range lane {
+		for attempt := 1; ; attempt++ {
+			err := send()
+			if err == nil {
+				break
+			}
+			var rl *RateLimitError
+			if errors.As(err, &rl) && attempt < queueMaxRateLimitAttempts {
+				wait := rl.RetryAfter
+				if wait <= 0 {
+					wait = q.interval
+				}
+				if wait > queueMaxRetryAfter {
+					wait = queueMaxRetryAfter
+				}
+				log.Printf("Notification queue: rate limited, retrying in %s", wait)
+				time.Sleep(wait)
+				continue
+			}
+			log.Printf("Notification queue: delivery failed: %v", err)
+			break
+		}
+		time.Sleep(q.interval)
+	}
+}
+
+// Close stops accepting messages and waits for queued ones to be sent or
+// for ctx to end, whichever comes first.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		for _, lane := range q.lanes {
+			close(lane)
+		}
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var defaultQueue atomic.Pointer[Queue]
+
+// SetDefaultQueue installs the queue NotifierFromConfig attaches to the
+// Discord notifiers it builds. Pass nil to send inline again.
+func SetDefaultQueue(q *Queue) {
+	defaultQueue.Store(q)
+}
+
+// DefaultQueue returns the queue set by SetDefaultQueue, or nil.
+func DefaultQueue() *Queue {
+	return defaultQueue.Load()
+}
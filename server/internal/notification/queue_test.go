@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestQueue_PacesAndRetriesRateLimited checks queued messages to one webhook
+// are sent one at a time, at least interval apart, and that a 429 is retried
+// rather than dropped.
+func TestQueue_PacesAndRetriesRateLimited(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		if len(times) == 1 {
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	const interval = 30 * time.Millisecond
+	q := NewQueue(interval, 10)
+	dn := NewDiscordNotifier(srv.URL, "")
+	dn.SetRetryPolicy(1, time.Second)
+	dn.UseQueue(q)
+
+	for i := 0; i < 3; i++ {
+		if err := dn.SendMessage("hello"); err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := dn.SendMessage("after close"); err != nil {
+		t.Errorf("SendMessage after Close should fall back to inline: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 5 {
+		t.Fatalf("got %d requests, want 5 (one 429, three queued, one inline)", len(times))
+	}
+	for i := 2; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < interval {
+			t.Errorf("requests %d and %d were %s apart, want at least %s", i-1, i, gap, interval)
+		}
+	}
+}
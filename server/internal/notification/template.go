@@ -0,0 +1,61 @@
+package notification
+
+import (
+	"log"
+
+	"github.com/monzim/db_proxy/v1/internal/utils"
+)
+
+// templatedNotifier renders backup success and failure messages from a
+// notification config's templates and posts them as plain messages. A
+// missing template keeps the backend's default formatting for that event.
+type templatedNotifier struct {
+	Notifier
+	success string
+	failure string
+}
+
+// withTemplates wraps n when the config defines usable templates. A stored
+// template that no longer validates is ignored so a bad row can never
+// silence alerts.
+func withTemplates(n Notifier, success, failure string) Notifier {
+	if success != "" && utils.ValidateMessageTemplate(success) != nil {
+		log.Printf("Ignoring invalid backup success template; using the default message")
+		success = ""
+	}
+	if failure != "" && utils.ValidateMessageTemplate(failure) != nil {
+		log.Printf("Ignoring invalid backup failure template; using the default message")
+		failure = ""
+	}
+	if success == "" && failure == "" {
+		return n
+	}
+	return templatedNotifier{Notifier: n, success: success, failure: failure}
+}
+
+func (t templatedNotifier) SendBackupSuccess(dbName string, sizeBytes int64, duration string) error {
+	if t.success == "" {
+		return t.Notifier.SendBackupSuccess(dbName, sizeBytes, duration)
+	}
+	return t.Notifier.SendMessage(utils.RenderMessage(t.success, utils.MessageVars{
+		Database: dbName,
+		Size:     formatBytes(sizeBytes),
+		Duration: duration,
+		Status:   "success",
+	}))
+}
+
+func (t templatedNotifier) SendBackupFailure(dbName, errorMsg string) error {
+	if t.failure == "" {
+		return t.Notifier.SendBackupFailure(dbName, errorMsg)
+	}
+	// Error text (pg_dump stderr) can run to kilobytes. Trim it to what is
+	// left after the longest template, then cap the whole message at
+	// Discord's content limit, the smallest of the channels, in case the
+	// template repeats {error}.
+	return t.Notifier.SendMessage(truncateRunes(utils.RenderMessage(t.failure, utils.MessageVars{
+		Database: dbName,
+		Status:   "failed",
+		Error:    truncateRunes(errorMsg, messageMaxContent-utils.MaxMessageTemplateLength),
+	}), messageMaxContent))
+}
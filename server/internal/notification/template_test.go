@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// messageRecorder keeps the plain messages it was asked to send.
+type messageRecorder struct {
+	noopNotifier
+	messages []string
+}
+
+func (m *messageRecorder) SendMessage(msg string) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// TestTemplatedFailureCapsLongErrors checks that a multi-kilobyte error
+// still renders into a message Discord accepts, keeping the template's
+// own text.
+func TestTemplatedFailureCapsLongErrors(t *testing.T) {
+	rec := &messageRecorder{}
+	n := withTemplates(rec, "", "{database} failed: {error} -- on-call: #ops")
+
+	if err := n.SendBackupFailure("prod", strings.Repeat("pg_dump: error: ", 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.messages) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(rec.messages))
+	}
+	msg := rec.messages[0]
+	if n := utf8.RuneCountInString(msg); n > messageMaxContent {
+		t.Errorf("message is %d runes, want at most %d", n, messageMaxContent)
+	}
+	if !strings.HasPrefix(msg, "prod failed: ") || !strings.HasSuffix(msg, " -- on-call: #ops") {
+		t.Errorf("message lost the template text: %q…%q", msg[:20], msg[len(msg)-20:])
+	}
+}
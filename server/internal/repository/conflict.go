@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// pgUniqueViolation is PostgreSQL's SQLSTATE for a unique constraint
+// violation.
+const pgUniqueViolation = "23505"
+
+// ConflictError is returned when a create or update would duplicate a value
+// that must be unique. Field is the JSON name of the offending input.
+type ConflictError struct {
+	Field string
+	Value string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %q is already in use", e.Field, e.Value)
+}
+
+// conflictOr returns a *ConflictError for field when err is a unique
+// violation, and err unchanged otherwise.
+func conflictOr(err error, field, value string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return &ConflictError{Field: field, Value: value}
+	}
+	return err
+}
@@ -1,7 +1,12 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,6 +14,7 @@ import (
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"github.com/monzim/db_proxy/v1/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository handles all database operations using GORM
@@ -50,7 +56,7 @@ func (r *Repository) SeedSystemUser(username, email string) error {
 
 	result := r.db.Create(user)
 	if result.Error != nil {
-		return fmt.Errorf("failed to seed system user: %w", result.Error)
+		return fmt.Errorf("failed to seed system user: %w", conflictOr(result.Error, "email", email))
 	}
 
 	return nil
@@ -131,7 +137,7 @@ func (r *Repository) UpsertGitHubUser(githubID int64, login, email, avatarURL st
 			IsAdmin:      true,
 		}
 		if err := r.db.Create(&user).Error; err != nil {
-			return nil, fmt.Errorf("create github user: %w", err)
+			return nil, fmt.Errorf("create github user: %w", conflictOr(err, "email", email))
 		}
 		return &user, nil
 	}
@@ -321,21 +327,17 @@ func (r *Repository) VerifyOTP(userID uuid.UUID, otpCode string) (OTPVerifyResul
 
 // Storage operations
 
+// CreateStorageConfig stores a new storage config. Names are unique per
+// user; a duplicate returns a *ConflictError.
 func (r *Repository) CreateStorageConfig(userID uuid.UUID, input *models.StorageConfigInput) (*models.StorageConfig, error) {
-	storage := &models.StorageConfig{
-		UserID:    userID,
-		Name:      input.Name,
-		Provider:  input.Provider,
-		Bucket:    input.Bucket,
-		Region:    input.Region,
-		Endpoint:  input.Endpoint,
-		AccessKey: input.AccessKey,
-		SecretKey: input.SecretKey,
+	if err := r.checkStorageName(userID, input.Name, uuid.Nil); err != nil {
+		return nil, err
 	}
+	storage := input.ToModel(userID)
 
 	result := r.db.Create(storage)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to create storage config: %w", result.Error)
+		return nil, fmt.Errorf("failed to create storage config: %w", conflictOr(result.Error, "name", input.Name))
 	}
 
 	return storage, nil
@@ -410,6 +412,13 @@ func (r *Repository) UpdateStorageConfig(id uuid.UUID, input *models.StorageConf
 		}
 		return nil, fmt.Errorf("failed to find storage config: %w", err)
 	}
+	// Only a rename is checked, so a config that predates the unique
+	// index can still be edited without renaming it first.
+	if !strings.EqualFold(input.Name, storage.Name) {
+		if err := r.checkStorageName(storage.UserID, input.Name, storage.ID); err != nil {
+			return nil, err
+		}
+	}
 
 	// Update fields
 	storage.Name = input.Name
@@ -422,7 +431,7 @@ func (r *Repository) UpdateStorageConfig(id uuid.UUID, input *models.StorageConf
 
 	result := r.db.Save(&storage)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update storage config: %w", result.Error)
+		return nil, fmt.Errorf("failed to update storage config: %w", conflictOr(result.Error, "name", input.Name))
 	}
 
 	return &storage, nil
@@ -442,6 +451,13 @@ func (r *Repository) UpdateStorageConfigByUser(id uuid.UUID, userID uuid.UUID, i
 		}
 		return nil, fmt.Errorf("failed to find storage config: %w", err)
 	}
+	// Only a rename is checked, so a config that predates the unique
+	// index can still be edited without renaming it first.
+	if !strings.EqualFold(input.Name, storage.Name) {
+		if err := r.checkStorageName(storage.UserID, input.Name, storage.ID); err != nil {
+			return nil, err
+		}
+	}
 
 	// Update fields
 	storage.Name = input.Name
@@ -454,12 +470,28 @@ func (r *Repository) UpdateStorageConfigByUser(id uuid.UUID, userID uuid.UUID, i
 
 	result := r.db.Save(&storage)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update storage config: %w", result.Error)
+		return nil, fmt.Errorf("failed to update storage config: %w", conflictOr(result.Error, "name", input.Name))
 	}
 
 	return &storage, nil
 }
 
+// checkStorageName returns a *ConflictError when userID has another storage
+// config (not exceptID) called name, ignoring case. It gives a clean error
+// up front; idx_storage_configs_user_lower_name enforces it under races.
+func (r *Repository) checkStorageName(userID uuid.UUID, name string, exceptID uuid.UUID) error {
+	var count int64
+	if err := r.db.Model(&models.StorageConfig{}).
+		Where("user_id = ? AND lower(name) = lower(?) AND id <> ?", userID, name, exceptID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check storage config name: %w", err)
+	}
+	if count > 0 {
+		return &ConflictError{Field: "name", Value: name}
+	}
+	return nil
+}
+
 func (r *Repository) DeleteStorageConfig(id uuid.UUID) error {
 	result := r.db.Delete(&models.StorageConfig{}, "id = ?", id)
 
@@ -496,13 +528,7 @@ func (r *Repository) DeleteStorageConfigByUser(id uuid.UUID, userID uuid.UUID, i
 // Notification operations
 
 func (r *Repository) CreateNotificationConfig(userID uuid.UUID, input *models.NotificationConfigInput) (*models.NotificationConfig, error) {
-	notification := &models.NotificationConfig{
-		UserID:            userID,
-		Name:              input.Name,
-		DiscordWebhookURL: input.DiscordWebhookURL,
-		TelegramBotToken:  input.TelegramBotToken,
-		TelegramChatID:    input.TelegramChatID,
-	}
+	notification := input.ToModel(userID)
 
 	result := r.db.Create(notification)
 	if result.Error != nil {
@@ -586,6 +612,9 @@ func (r *Repository) UpdateNotificationConfig(id uuid.UUID, input *models.Notifi
 	notification.DiscordWebhookURL = input.DiscordWebhookURL
 	notification.TelegramBotToken = input.TelegramBotToken
 	notification.TelegramChatID = input.TelegramChatID
+	notification.CooldownMinutes = input.CooldownMinutes
+	notification.SuccessTemplate = input.SuccessTemplate
+	notification.FailureTemplate = input.FailureTemplate
 
 	result := r.db.Save(&notification)
 	if result.Error != nil {
@@ -614,6 +643,9 @@ func (r *Repository) UpdateNotificationConfigByUser(id uuid.UUID, userID uuid.UU
 	notification.DiscordWebhookURL = input.DiscordWebhookURL
 	notification.TelegramBotToken = input.TelegramBotToken
 	notification.TelegramChatID = input.TelegramChatID
+	notification.CooldownMinutes = input.CooldownMinutes
+	notification.SuccessTemplate = input.SuccessTemplate
+	notification.FailureTemplate = input.FailureTemplate
 
 	result := r.db.Save(&notification)
 	if result.Error != nil {
@@ -623,6 +655,108 @@ func (r *Repository) UpdateNotificationConfigByUser(id uuid.UUID, userID uuid.UU
 	return &notification, nil
 }
 
+// NotificationDecision is the outcome of ClaimNotification.
+type NotificationDecision struct {
+	Send bool
+	// Suppressed is how many alerts were swallowed since Since. A non-zero
+	// value on a Send decision means the caller should send a "still
+	// failing" reminder rather than the regular alert.
+	Suppressed int
+	Since      time.Time
+}
+
+// ClaimNotification decides whether an alert for (databaseID, event) may be
+// sent under the given cooldown and records the outcome. The row is locked
+// so concurrent failures of the same database cannot both send.
+func (r *Repository) ClaimNotification(databaseID uuid.UUID, event string, cooldown time.Duration) (NotificationDecision, error) {
+	var decision NotificationDecision
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		seed := &models.NotificationState{DatabaseID: databaseID, Event: event}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(seed).Error; err != nil {
+			return err
+		}
+
+		var state models.NotificationState
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&state, "database_id = ? AND event = ?", databaseID, event).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if now.Sub(state.LastSentAt) < cooldown {
+			return tx.Model(&state).Update("suppressed_count", gorm.Expr("suppressed_count + 1")).Error
+		}
+
+		decision = NotificationDecision{Send: true, Suppressed: state.SuppressedCount, Since: state.LastSentAt}
+		return tx.Model(&state).Updates(map[string]interface{}{
+			"last_sent_at":     now,
+			"suppressed_count": 0,
+		}).Error
+	})
+	if err != nil {
+		return NotificationDecision{}, fmt.Errorf("failed to claim notification: %w", err)
+	}
+	return decision, nil
+}
+
+// ClearNotificationState forgets the cooldown for (databaseID, event), so
+// the next failure after a recovery alerts immediately.
+func (r *Repository) ClearNotificationState(databaseID uuid.UUID, event string) error {
+	result := r.db.Where("database_id = ? AND event = ?", databaseID, event).Delete(&models.NotificationState{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to clear notification state: %w", result.Error)
+	}
+	return nil
+}
+
+// GetMaintenanceState returns the persisted maintenance switch. A missing
+// row means maintenance has never been enabled.
+func (r *Repository) GetMaintenanceState() (*models.MaintenanceState, error) {
+	var state models.MaintenanceState
+	result := r.db.First(&state, "id = ?", models.MaintenanceStateID)
+
+	if result.Error == gorm.ErrRecordNotFound {
+		return &models.MaintenanceState{ID: models.MaintenanceStateID}, nil
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get maintenance state: %w", result.Error)
+	}
+
+	return &state, nil
+}
+
+// SetMaintenanceState upserts the maintenance switch.
+func (r *Repository) SetMaintenanceState(enabled bool, reason string, updatedBy *uuid.UUID) (*models.MaintenanceState, error) {
+	state := &models.MaintenanceState{
+		ID:        models.MaintenanceStateID,
+		Enabled:   enabled,
+		Reason:    reason,
+		UpdatedBy: updatedBy,
+		UpdatedAt: time.Now(),
+	}
+	if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(state).Error; err != nil {
+		return nil, fmt.Errorf("failed to set maintenance state: %w", err)
+	}
+	return state, nil
+}
+
+// ListSSLModeCache returns every persisted per-server sslmode.
+func (r *Repository) ListSSLModeCache() ([]models.SSLModeCacheEntry, error) {
+	var entries []models.SSLModeCacheEntry
+	if err := r.db.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list SSL mode cache: %w", err)
+	}
+	return entries, nil
+}
+
+// SaveSSLModeCacheEntry upserts the sslmode recorded for a server.
+func (r *Repository) SaveSSLModeCacheEntry(entry *models.SSLModeCacheEntry) error {
+	if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to save SSL mode cache entry: %w", err)
+	}
+	return nil
+}
+
 func (r *Repository) DeleteNotificationConfig(id uuid.UUID) error {
 	result := r.db.Delete(&models.NotificationConfig{}, "id = ?", id)
 
@@ -663,22 +797,7 @@ func (r *Repository) CreateDatabaseConfig(userID uuid.UUID, input *models.Databa
 		return nil, fmt.Errorf("invalid rotation policy: %w", err)
 	}
 
-	dbConfig := &models.DatabaseConfig{
-		UserID:         userID,
-		Name:           input.Name,
-		Host:           input.Host,
-		Port:           input.Port,
-		DBName:         input.DBName,
-		Username:       input.Username,
-		Password:       input.Password,
-		Schedule:       input.Schedule,
-		StorageID:      input.StorageID,
-		NotificationID: input.NotificationID,
-		Enabled:        true,
-	}
-
-	// Set rotation policy
-	dbConfig.SetRotationPolicy(input.RotationPolicy)
+	dbConfig := input.ToModel(userID)
 
 	result := r.db.Create(dbConfig)
 	if result.Error != nil {
@@ -688,6 +807,36 @@ func (r *Repository) CreateDatabaseConfig(userID uuid.UUID, input *models.Databa
 	return dbConfig, nil
 }
 
+// ImportConfigs creates storage, notification and database configs in one
+// transaction, in that order, so a database can reference configs created
+// in the same call through IDs set beforehand. Either all are created or
+// none. Storage names are checked again inside the transaction; a clash
+// returns a *ConflictError.
+func (r *Repository) ImportConfigs(storages []*models.StorageConfig, notifications []*models.NotificationConfig, databases []*models.DatabaseConfig) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := &Repository{db: tx}
+		for _, storage := range storages {
+			if err := txRepo.checkStorageName(storage.UserID, storage.Name, uuid.Nil); err != nil {
+				return err
+			}
+			if err := tx.Create(storage).Error; err != nil {
+				return fmt.Errorf("failed to create storage config %q: %w", storage.Name, conflictOr(err, "name", storage.Name))
+			}
+		}
+		for _, notification := range notifications {
+			if err := tx.Create(notification).Error; err != nil {
+				return fmt.Errorf("failed to create notification config %q: %w", notification.Name, err)
+			}
+		}
+		for _, dbConfig := range databases {
+			if err := tx.Create(dbConfig).Error; err != nil {
+				return fmt.Errorf("failed to create database config %q: %w", dbConfig.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
 func (r *Repository) GetDatabaseConfig(id uuid.UUID) (*models.DatabaseConfig, error) {
 	var dbConfig models.DatabaseConfig
 	result := r.db.Preload("Storage").Preload("Notification").First(&dbConfig, "id = ?", id)
@@ -705,9 +854,11 @@ func (r *Repository) GetDatabaseConfig(id uuid.UUID) (*models.DatabaseConfig, er
 // GetDatabaseConfigByUser retrieves a database config only if it belongs to the user (or user is admin)
 func (r *Repository) GetDatabaseConfigByUser(id uuid.UUID, userID uuid.UUID, isAdmin bool) (*models.DatabaseConfig, error) {
 	var dbConfig models.DatabaseConfig
-	query := r.db.Preload("Storage").Preload("Notification").Preload("Labels").Where("id = ?", id)
+	query := r.db.Preload("Storage").Preload("Notification").Preload("Labels").
+		Scopes(withLastBackup).
+		Where("database_configs.id = ?", id)
 	if !isAdmin {
-		query = query.Where("user_id = ?", userID)
+		query = query.Where("database_configs.user_id = ?", userID)
 	}
 	result := query.First(&dbConfig)
 
@@ -721,6 +872,38 @@ func (r *Repository) GetDatabaseConfigByUser(id uuid.UUID, userID uuid.UUID, isA
 	return &dbConfig, nil
 }
 
+// withLastBackup fills DatabaseConfig's LastBackup* fields from the newest
+// backup of each database, and LastSuccessAt from the newest successful
+// one, in the same query. Filters combined with this scope must qualify
+// their columns with "database_configs.".
+func withLastBackup(db *gorm.DB) *gorm.DB {
+	return db.Select("database_configs.*, lb.id AS last_backup_id, lb.status AS last_backup_status, lb.started_at AS last_backup_at, ls.started_at AS last_success_at").
+		Joins(`LEFT JOIN LATERAL (
+			SELECT b.id, b.status, b.started_at FROM backups b
+			WHERE b.database_id = database_configs.id
+			ORDER BY b.started_at DESC LIMIT 1
+		) lb ON true`).
+		Joins(`LEFT JOIN LATERAL (
+			SELECT b.started_at FROM backups b
+			WHERE b.database_id = database_configs.id AND b.status = ?
+			ORDER BY b.started_at DESC LIMIT 1
+		) ls ON true`, models.BackupStatusSuccess)
+}
+
+// ListScheduledDatabaseConfigs lists enabled, unpaused database configs with
+// their notification config and LastSuccessAt, for the freshness check.
+func (r *Repository) ListScheduledDatabaseConfigs() ([]*models.DatabaseConfig, error) {
+	var configs []*models.DatabaseConfig
+	result := r.db.Preload("Notification").
+		Scopes(withLastBackup).
+		Where("database_configs.enabled = ? AND database_configs.paused = ?", true, false).
+		Find(&configs)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list scheduled database configs: %w", result.Error)
+	}
+	return configs, nil
+}
+
 func (r *Repository) ListDatabaseConfigs() ([]*models.DatabaseConfig, error) {
 	var configs []*models.DatabaseConfig
 	result := r.db.Preload("Storage").Preload("Notification").
@@ -733,12 +916,27 @@ func (r *Repository) ListDatabaseConfigs() ([]*models.DatabaseConfig, error) {
 	return configs, nil
 }
 
-// ListDatabaseConfigsByUser lists database configs for a specific user (or all if admin)
-func (r *Repository) ListDatabaseConfigsByUser(userID uuid.UUID, isAdmin bool) ([]*models.DatabaseConfig, error) {
+// ListDatabaseConfigsByUser lists database configs for a specific user (or all if admin).
+// params may be nil; search matches the stored (unmasked) name and host.
+func (r *Repository) ListDatabaseConfigsByUser(userID uuid.UUID, isAdmin bool, params *models.DatabaseConfigListParams) ([]*models.DatabaseConfig, error) {
 	var configs []*models.DatabaseConfig
-	query := r.db.Preload("Storage").Preload("Notification").Preload("Labels").Order("created_at DESC")
+	query := r.db.Preload("Storage").Preload("Notification").Preload("Labels").
+		Scopes(withLastBackup).
+		Order("database_configs.created_at DESC")
 	if !isAdmin {
-		query = query.Where("user_id = ?", userID)
+		query = query.Where("database_configs.user_id = ?", userID)
+	}
+	if params != nil {
+		if params.Search != "" {
+			pattern := "%" + escapeLike(params.Search) + "%"
+			query = query.Where("(database_configs.name ILIKE ? OR database_configs.host ILIKE ?)", pattern, pattern)
+		}
+		if params.Enabled != nil {
+			query = query.Where("database_configs.enabled = ?", *params.Enabled)
+		}
+		if params.Paused != nil {
+			query = query.Where("database_configs.paused = ?", *params.Paused)
+		}
 	}
 	result := query.Find(&configs)
 
@@ -773,6 +971,19 @@ func (r *Repository) UpdateDatabaseConfig(id uuid.UUID, input *models.DatabaseCo
 	dbConfig.Schedule = input.Schedule
 	dbConfig.StorageID = input.StorageID
 	dbConfig.NotificationID = input.NotificationID
+	dbConfig.MaxSizeBytes = input.MaxSizeBytes
+	dbConfig.SchemaOnly = input.SchemaOnly
+	dbConfig.FilenameTemplate = input.FilenameTemplate
+	dbConfig.NoOwner = input.NoOwner
+	dbConfig.NoPrivileges = input.NoPrivileges
+	dbConfig.NoVerbose = input.NoVerbose
+	dbConfig.Blobs = input.Blobs
+	dbConfig.ConnectTimeoutSeconds = input.ConnectTimeoutSeconds
+	dbConfig.LockWaitTimeoutSeconds = input.LockWaitTimeout()
+	dbConfig.BackupWindow = input.BackupWindow
+	dbConfig.ExtraArgs = input.ExtraArgs
+	dbConfig.PgEnv = input.PgEnv
+	dbConfig.JitterMinutes = input.JitterMinutes
 	dbConfig.SetRotationPolicy(input.RotationPolicy)
 
 	result := r.db.Save(&dbConfig)
@@ -812,6 +1023,19 @@ func (r *Repository) UpdateDatabaseConfigByUser(id uuid.UUID, userID uuid.UUID,
 	dbConfig.Schedule = input.Schedule
 	dbConfig.StorageID = input.StorageID
 	dbConfig.NotificationID = input.NotificationID
+	dbConfig.MaxSizeBytes = input.MaxSizeBytes
+	dbConfig.SchemaOnly = input.SchemaOnly
+	dbConfig.FilenameTemplate = input.FilenameTemplate
+	dbConfig.NoOwner = input.NoOwner
+	dbConfig.NoPrivileges = input.NoPrivileges
+	dbConfig.NoVerbose = input.NoVerbose
+	dbConfig.Blobs = input.Blobs
+	dbConfig.ConnectTimeoutSeconds = input.ConnectTimeoutSeconds
+	dbConfig.LockWaitTimeoutSeconds = input.LockWaitTimeout()
+	dbConfig.BackupWindow = input.BackupWindow
+	dbConfig.ExtraArgs = input.ExtraArgs
+	dbConfig.PgEnv = input.PgEnv
+	dbConfig.JitterMinutes = input.JitterMinutes
 	dbConfig.SetRotationPolicy(input.RotationPolicy)
 
 	result := r.db.Save(&dbConfig)
@@ -889,6 +1113,56 @@ func (r *Repository) PauseDatabaseConfigByUser(id uuid.UUID, userID uuid.UUID, i
 	return nil
 }
 
+// SetDatabaseSnoozeByUser sets or, with a nil until, clears the snooze on a
+// database config the user owns (or any, for admins).
+func (r *Repository) SetDatabaseSnoozeByUser(id uuid.UUID, userID uuid.UUID, isAdmin bool, until *time.Time) error {
+	query := r.db.Model(&models.DatabaseConfig{}).Where("id = ?", id)
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	result := query.Update("snoozed_until", until)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to snooze database config: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ClearExpiredSnooze clears a database's snooze once it has passed. A
+// snooze that was extended in the meantime is left alone.
+func (r *Repository) ClearExpiredSnooze(id uuid.UUID) error {
+	result := r.db.Model(&models.DatabaseConfig{}).
+		Where("id = ? AND snoozed_until <= ?", id, time.Now()).
+		Update("snoozed_until", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to clear snooze: %w", result.Error)
+	}
+	return nil
+}
+
+// SetSchedulerError records msg as the database's last scheduler error, or
+// clears it when msg is empty. Clearing is a no-op for databases without
+// one, so it is cheap to call after every successful run.
+func (r *Repository) SetSchedulerError(id uuid.UUID, msg string) error {
+	query := r.db.Model(&models.DatabaseConfig{}).Where("id = ?", id)
+	var result *gorm.DB
+	if msg == "" {
+		result = query.Where("scheduler_error IS NOT NULL").
+			Updates(map[string]any{"scheduler_error": nil, "scheduler_error_at": nil})
+	} else {
+		result = query.Updates(map[string]any{"scheduler_error": msg, "scheduler_error_at": time.Now()})
+	}
+	if result.Error != nil {
+		return fmt.Errorf("failed to set scheduler error: %w", result.Error)
+	}
+	return nil
+}
+
 // UnpauseDatabaseConfig resumes backup operations for a specific database config
 func (r *Repository) UnpauseDatabaseConfig(id uuid.UUID) error {
 	result := r.db.Model(&models.DatabaseConfig{}).Where("id = ?", id).Update("paused", false)
@@ -923,14 +1197,28 @@ func (r *Repository) UnpauseDatabaseConfigByUser(id uuid.UUID, userID uuid.UUID,
 	return nil
 }
 
+// SetDatabaseTriggerTokenHash stores the hash of a database's trigger URL
+// token. A nil hash disables the trigger URL.
+func (r *Repository) SetDatabaseTriggerTokenHash(id uuid.UUID, hash *string) error {
+	result := r.db.Model(&models.DatabaseConfig{}).Where("id = ?", id).Update("trigger_token_hash", hash)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update trigger token: %w", result.Error)
+	}
+	return nil
+}
+
 // Backup operations
 
-func (r *Repository) CreateBackup(databaseID uuid.UUID, status models.BackupStatus) (*models.Backup, error) {
+// CreateBackup inserts a backup row. triggeredByUserID is only meaningful for
+// manual triggers and should be nil for scheduled runs.
+func (r *Repository) CreateBackup(databaseID uuid.UUID, status models.BackupStatus, trigger models.BackupTrigger, triggeredByUserID *uuid.UUID) (*models.Backup, error) {
 	backup := &models.Backup{
-		Name:       utils.GenerateBackupName(),
-		DatabaseID: databaseID,
-		Status:     status,
-		StartedAt:  time.Now(),
+		Name:              utils.GenerateBackupName(),
+		DatabaseID:        databaseID,
+		Status:            status,
+		TriggeredBy:       trigger,
+		TriggeredByUserID: triggeredByUserID,
+		StartedAt:         time.Now(),
 	}
 
 	result := r.db.Create(backup)
@@ -962,6 +1250,115 @@ func (r *Repository) SetBackupDumpFormat(id uuid.UUID, format models.DumpFormat)
 	return result.Error
 }
 
+// SetBackupFileName records the resolved object name of a backup.
+func (r *Repository) SetBackupFileName(id uuid.UUID, fileName string) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("file_name", fileName)
+	return result.Error
+}
+
+// SetBackupChecksum records the SHA-256 of the stored object.
+func (r *Repository) SetBackupChecksum(id uuid.UUID, checksum string) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("checksum_sha256", checksum)
+	return result.Error
+}
+
+// SetBackupSSLMode records the sslmode the dump connected with.
+func (r *Repository) SetBackupSSLMode(id uuid.UUID, sslMode string) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("ssl_mode", sslMode)
+	return result.Error
+}
+
+// SetBackupSchemaOnly marks a backup as a DDL-only dump.
+func (r *Repository) SetBackupSchemaOnly(id uuid.UUID) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("schema_only", true)
+	return result.Error
+}
+
+// SetBackupPostgresVersion records the source server's major version so a
+// later restore can refuse an older target up front.
+func (r *Repository) SetBackupPostgresVersion(id uuid.UUID, version string) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("postgres_version", version)
+	return result.Error
+}
+
+// SetBackupSourceSize records the source database's on-disk size at dump
+// time.
+func (r *Repository) SetBackupSourceSize(id uuid.UUID, sizeBytes int64) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("source_size_bytes", sizeBytes)
+	return result.Error
+}
+
+// SetBackupCompression records how the stored object was compressed so the
+// restore path can decompress it before handing it to psql.
+func (r *Repository) SetBackupCompression(id uuid.UUID, compression string) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("compression", compression)
+	return result.Error
+}
+
+// SetBackupPinned pins or unpins a backup. Pinned backups are never removed
+// by the rotation policy.
+func (r *Repository) SetBackupPinned(id uuid.UUID, pinned bool) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("pinned", pinned)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update backup pin: %w", result.Error)
+	}
+	return nil
+}
+
+// SetBackupRetainUntil sets or clears (nil) a backup's retention hold.
+func (r *Repository) SetBackupRetainUntil(id uuid.UUID, retainUntil *time.Time) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("retain_until", retainUntil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update backup retention: %w", result.Error)
+	}
+	return nil
+}
+
+// SetBackupOnBehalfOf records the database owner on a backup an admin
+// triggered for them.
+func (r *Repository) SetBackupOnBehalfOf(id uuid.UUID, ownerID uuid.UUID) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("on_behalf_of_user_id", ownerID)
+	return result.Error
+}
+
+// SetBackupVersionWarning records a pg_dump/server version skew warning on
+// the backup row.
+func (r *Repository) SetBackupVersionWarning(id uuid.UUID, warning string) error {
+	result := r.db.Model(&models.Backup{}).Where("id = ?", id).Update("version_warning", warning)
+	return result.Error
+}
+
+// PreviousBackupHadVersionWarning reports whether the most recent backup of
+// databaseID other than excludeID carried a version warning. Used to send
+// the skew notification once per episode rather than on every run.
+func (r *Repository) PreviousBackupHadVersionWarning(databaseID, excludeID uuid.UUID) (bool, error) {
+	var prev models.Backup
+	result := r.db.Select("version_warning").
+		Where("database_id = ? AND id <> ?", databaseID, excludeID).
+		Order("started_at DESC").
+		Limit(1).
+		Find(&prev)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to load previous backup: %w", result.Error)
+	}
+	return prev.VersionWarning != nil && *prev.VersionWarning != "", nil
+}
+
+// PreviousBackupSSLMode returns the sslmode recorded on the most recent
+// other backup of the database, or "" when none has one.
+func (r *Repository) PreviousBackupSSLMode(databaseID, excludeID uuid.UUID) (string, error) {
+	var prev models.Backup
+	result := r.db.Select("ssl_mode").
+		Where("database_id = ? AND id <> ? AND ssl_mode <> ''", databaseID, excludeID).
+		Order("started_at DESC").
+		Limit(1).
+		Find(&prev)
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to load previous backup: %w", result.Error)
+	}
+	return prev.SSLMode, nil
+}
+
 // MarkBackupDeleted flips the row to the "deleted" status and clears the
 // storage path. Used by the rotation cleanup AFTER the storage object has
 // been removed, so the DB never advertises a backup whose bytes are gone.
@@ -990,7 +1387,9 @@ func (r *Repository) GetBackup(id uuid.UUID) (*models.Backup, error) {
 // GetBackupByUser retrieves a backup only if the associated database belongs to the user (or user is admin)
 func (r *Repository) GetBackupByUser(id uuid.UUID, userID uuid.UUID, isAdmin bool) (*models.Backup, error) {
 	var backup models.Backup
-	query := r.db.Preload("Database").
+	query := r.db.Preload("Database.Storage").Preload("Uploads", func(db *gorm.DB) *gorm.DB {
+		return db.Order("started_at ASC")
+	}).
 		Joins("JOIN database_configs ON backups.database_id = database_configs.id").
 		Where("backups.id = ?", id)
 	if !isAdmin {
@@ -1020,18 +1419,66 @@ func (r *Repository) ListBackupsByDatabase(databaseID uuid.UUID) ([]*models.Back
 	return backups, nil
 }
 
-// ListBackupsByDatabaseByUser lists backups for a database only if it belongs to the user (or user is admin)
-func (r *Repository) ListBackupsByDatabaseByUser(databaseID uuid.UUID, userID uuid.UUID, isAdmin bool) ([]*models.Backup, error) {
+// ListBackupsByDatabaseByUser lists backups for a database only if it
+// belongs to the user (or user is admin), together with the number of
+// matches before paging.
+func (r *Repository) ListBackupsByDatabaseByUser(databaseID uuid.UUID, userID uuid.UUID, isAdmin bool, params *models.BackupListParams) ([]*models.Backup, int64, error) {
 	var backups []*models.Backup
-	query := r.db.Joins("JOIN database_configs ON backups.database_id = database_configs.id").
+	query := r.db.Model(&models.Backup{}).
+		Joins("JOIN database_configs ON backups.database_id = database_configs.id").
 		Where("backups.database_id = ?", databaseID)
 	if !isAdmin {
 		query = query.Where("database_configs.user_id = ?", userID)
 	}
-	result := query.Order("backups.started_at DESC").Find(&backups)
+	query = applyBackupListParams(query, params)
 
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count backups: %w", err)
+	}
+
+	result := pageBackupList(query, params).
+		Order("backups.started_at DESC").Order("backups.id DESC").Find(&backups)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to list backups: %w", result.Error)
+		return nil, 0, fmt.Errorf("failed to list backups: %w", result.Error)
+	}
+
+	return backups, total, nil
+}
+
+// ListRestorePoints returns the database's successful, stored backups,
+// newest first, capped at models.MaxRestorePoints.
+func (r *Repository) ListRestorePoints(databaseID uuid.UUID) ([]*models.Backup, error) {
+	var backups []*models.Backup
+	result := r.db.
+		Where("database_id = ? AND status = ? AND storage_path <> ''", databaseID, models.BackupStatusSuccess).
+		Order("started_at DESC").
+		Limit(models.MaxRestorePoints).
+		Find(&backups)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list restore points: %w", result.Error)
+	}
+
+	return backups, nil
+}
+
+// ListExportableBackups returns a database's successful, stored backups
+// started in [since, until) (either bound may be nil), newest first, at most
+// limit rows.
+func (r *Repository) ListExportableBackups(databaseID uuid.UUID, since, until *time.Time, limit int) ([]*models.Backup, error) {
+	var backups []*models.Backup
+	query := r.db.Where("database_id = ? AND status = ? AND storage_path <> ''", databaseID, models.BackupStatusSuccess)
+	if since != nil {
+		query = query.Where("started_at >= ?", *since)
+	}
+	if until != nil {
+		query = query.Where("started_at < ?", *until)
+	}
+	result := query.Order("started_at DESC").Limit(limit).Find(&backups)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list backups for export: %w", result.Error)
 	}
 
 	return backups, nil
@@ -1049,21 +1496,78 @@ func (r *Repository) ListAllBackups() ([]*models.Backup, error) {
 	return backups, nil
 }
 
-// ListAllBackupsByUser lists all backups for a specific user (or all if admin)
-func (r *Repository) ListAllBackupsByUser(userID uuid.UUID, isAdmin bool) ([]*models.Backup, error) {
+// ListAllBackupsByUser returns the user's backups across all databases
+// (every user's for admins), newest first, filtered by params, together
+// with the number of matches before paging.
+func (r *Repository) ListAllBackupsByUser(userID uuid.UUID, isAdmin bool, params *models.BackupListParams) ([]*models.Backup, int64, error) {
 	var backups []*models.Backup
-	query := r.db.Preload("Database").
+	query := r.db.Model(&models.Backup{}).
 		Joins("JOIN database_configs ON backups.database_id = database_configs.id")
 	if !isAdmin {
 		query = query.Where("database_configs.user_id = ?", userID)
 	}
-	result := query.Order("backups.started_at DESC").Find(&backups)
+	query = applyBackupListParams(query, params)
 
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count backups: %w", err)
+	}
+
+	result := pageBackupList(query, params).
+		Order("backups.started_at DESC").Order("backups.id DESC").Find(&backups)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to list all backups: %w", result.Error)
+		return nil, 0, fmt.Errorf("failed to list all backups: %w", result.Error)
 	}
 
-	return backups, nil
+	return backups, total, nil
+}
+
+// applyBackupListParams adds the optional backup list filters to query.
+// The query must already join database_configs.
+func applyBackupListParams(query *gorm.DB, params *models.BackupListParams) *gorm.DB {
+	if params == nil {
+		return query
+	}
+	if params.TriggeredBy != nil {
+		query = query.Where("backups.triggered_by = ?", *params.TriggeredBy)
+	}
+	if params.DatabaseID != nil {
+		query = query.Where("backups.database_id = ?", *params.DatabaseID)
+	}
+	if params.Status != nil {
+		query = query.Where("backups.status = ?", *params.Status)
+	}
+	if params.Since != nil {
+		query = query.Where("backups.started_at >= ?", *params.Since)
+	}
+	if params.Until != nil {
+		query = query.Where("backups.started_at < ?", *params.Until)
+	}
+	if params.MinSizeBytes != nil {
+		query = query.Where("backups.size_bytes >= ?", *params.MinSizeBytes)
+	}
+	if params.MaxSizeBytes != nil {
+		query = query.Where("backups.size_bytes <= ?", *params.MaxSizeBytes)
+	}
+	if params.LabelID != nil {
+		query = query.Where("EXISTS (SELECT 1 FROM database_labels WHERE database_labels.database_id = database_configs.id AND database_labels.label_id = ?)", *params.LabelID)
+	}
+	return query
+}
+
+// pageBackupList applies params' limit and offset; a zero limit keeps
+// every row.
+func pageBackupList(query *gorm.DB, params *models.BackupListParams) *gorm.DB {
+	if params == nil {
+		return query
+	}
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit)
+	}
+	if params.Offset > 0 {
+		query = query.Offset(params.Offset)
+	}
+	return query
 }
 
 // ListFailedBackupsByUser returns every backup row owned by the user with
@@ -1101,6 +1605,24 @@ func (r *Repository) CountFailedBackupsByUser(userID uuid.UUID, isAdmin bool) (i
 	return count, nil
 }
 
+// StorageBackupUsage counts the successful backups of databases that use
+// storageID and sums their recorded sizes.
+func (r *Repository) StorageBackupUsage(storageID uuid.UUID) (count int64, totalBytes int64, err error) {
+	var row struct {
+		Count int64
+		Total int64
+	}
+	err = r.db.Model(&models.Backup{}).
+		Joins("JOIN database_configs ON backups.database_id = database_configs.id").
+		Where("database_configs.storage_id = ? AND backups.status = ?", storageID, models.BackupStatusSuccess).
+		Select("COUNT(*) AS count, COALESCE(SUM(backups.size_bytes), 0) AS total").
+		Scan(&row).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum storage usage: %w", err)
+	}
+	return row.Count, row.Total, nil
+}
+
 // DeleteBackupsByIDs bulk-deletes Backup rows by primary key.
 func (r *Repository) DeleteBackupsByIDs(ids []uuid.UUID) (int64, error) {
 	if len(ids) == 0 {
@@ -1113,6 +1635,170 @@ func (r *Repository) DeleteBackupsByIDs(ids []uuid.UUID) (int64, error) {
 	return res.RowsAffected, nil
 }
 
+// AddBackupEvent appends a phase marker to a backup's timeline.
+func (r *Repository) AddBackupEvent(backupID uuid.UUID, phase models.BackupEventPhase, message string) error {
+	event := &models.BackupEvent{
+		BackupID:   backupID,
+		Phase:      phase,
+		Message:    message,
+		OccurredAt: time.Now(),
+	}
+	if err := r.db.Create(event).Error; err != nil {
+		return fmt.Errorf("failed to add backup event: %w", err)
+	}
+	return nil
+}
+
+// ListBackupEvents returns a backup's timeline in chronological order.
+// Ownership is NOT checked here; callers resolve the backup through
+// GetBackupByUser first.
+func (r *Repository) ListBackupEvents(backupID uuid.UUID) ([]*models.BackupEvent, error) {
+	var events []*models.BackupEvent
+	if err := r.db.Where("backup_id = ?", backupID).
+		Order("occurred_at ASC").
+		Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list backup events: %w", err)
+	}
+	return events, nil
+}
+
+// SaveBackupLog stores the captured pg_dump output of a backup, replacing
+// any earlier one (an upload retry doesn't re-run pg_dump, so in practice
+// there is one per backup).
+func (r *Repository) SaveBackupLog(entry *models.BackupLog) error {
+	if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to save backup log: %w", err)
+	}
+	return nil
+}
+
+// GetBackupLog returns a backup's captured pg_dump output, or nil when none
+// was recorded. Ownership is NOT checked here; callers resolve the backup
+// through GetBackupByUser first.
+func (r *Repository) GetBackupLog(backupID uuid.UUID) (*models.BackupLog, error) {
+	var entry models.BackupLog
+	result := r.db.First(&entry, "backup_id = ?", backupID)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get backup log: %w", result.Error)
+	}
+	return &entry, nil
+}
+
+// CreateBackupShare stores a new share link.
+func (r *Repository) CreateBackupShare(share *models.BackupShare) error {
+	if err := r.db.Create(share).Error; err != nil {
+		return fmt.Errorf("failed to create backup share: %w", err)
+	}
+	return nil
+}
+
+// GetBackupShareByTokenHash returns the share link with tokenHash, or nil.
+// Revoked and expired links are returned too; check Usable.
+func (r *Repository) GetBackupShareByTokenHash(tokenHash string) (*models.BackupShare, error) {
+	var share models.BackupShare
+	result := r.db.First(&share, "token_hash = ?", tokenHash)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get backup share: %w", result.Error)
+	}
+	return &share, nil
+}
+
+// ClaimBackupShareDownload counts one download against a share link. It
+// returns false, without counting, when the link is revoked, expired or out
+// of downloads; the row lock keeps concurrent downloads within
+// MaxDownloads.
+func (r *Repository) ClaimBackupShareDownload(id uuid.UUID) (bool, error) {
+	claimed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var share models.BackupShare
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&share, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		now := time.Now()
+		if !share.Usable(now) {
+			return nil
+		}
+		claimed = true
+		return tx.Model(&share).Updates(map[string]interface{}{
+			"download_count":   gorm.Expr("download_count + 1"),
+			"last_download_at": now,
+		}).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to claim backup share download: %w", err)
+	}
+	return claimed, nil
+}
+
+// RevokeBackupShare revokes the share link of backupID whose ID or token
+// hash matches. It returns the share, or nil when none matches; revoking an
+// already revoked link keeps its original RevokedAt.
+func (r *Repository) RevokeBackupShare(backupID uuid.UUID, shareID *uuid.UUID, tokenHash string) (*models.BackupShare, error) {
+	var share models.BackupShare
+	query := r.db.Where("backup_id = ?", backupID)
+	if shareID != nil {
+		query = query.Where("id = ?", *shareID)
+	} else {
+		query = query.Where("token_hash = ?", tokenHash)
+	}
+	result := query.First(&share)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get backup share: %w", result.Error)
+	}
+	if share.RevokedAt == nil {
+		now := time.Now()
+		if err := r.db.Model(&share).Update("revoked_at", now).Error; err != nil {
+			return nil, fmt.Errorf("failed to revoke backup share: %w", err)
+		}
+		share.RevokedAt = &now
+	}
+	return &share, nil
+}
+
+// StartBackupUpload records that an upload to one destination has begun.
+func (r *Repository) StartBackupUpload(backupID uuid.UUID, storageConfig *models.StorageConfig, objectKey string) (*models.BackupUpload, error) {
+	upload := &models.BackupUpload{
+		BackupID:    backupID,
+		StorageID:   storageConfig.ID,
+		StorageName: storageConfig.Name,
+		Provider:    storageConfig.Provider,
+		ObjectKey:   objectKey,
+		Status:      models.BackupStatusRunning,
+	}
+	if err := r.db.Create(upload).Error; err != nil {
+		return nil, fmt.Errorf("failed to create backup upload: %w", err)
+	}
+	return upload, nil
+}
+
+// FinishBackupUpload marks an upload as succeeded (uploadErr nil) or failed.
+func (r *Repository) FinishBackupUpload(id uuid.UUID, uploadErr error) error {
+	updates := map[string]any{
+		"status":       models.BackupStatusSuccess,
+		"completed_at": time.Now(),
+	}
+	if uploadErr != nil {
+		updates["status"] = models.BackupStatusFailed
+		updates["error"] = uploadErr.Error()
+	}
+	if err := r.db.Model(&models.BackupUpload{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update backup upload: %w", err)
+	}
+	return nil
+}
+
 // Stats operations
 
 func (r *Repository) GetSystemStats() (*models.SystemStats, error) {
@@ -1158,9 +1844,53 @@ func (r *Repository) GetSystemStats() (*models.SystemStats, error) {
 
 	stats.TotalStorageUsedBytes = sumResult.Total
 
+	var pausedDatabases int64
+	r.db.Model(&models.DatabaseConfig{}).Where("paused = ?", true).Count(&pausedDatabases)
+	stats.PausedDatabases = int(pausedDatabases)
+
+	r.fillLifetimeBackupStats(stats, r.db.Model(&models.Backup{}))
+
 	return stats, nil
 }
 
+// fillLifetimeBackupStats sets the all-time backup counts and date range
+// from the backups selected by scope.
+func (r *Repository) fillLifetimeBackupStats(stats *models.SystemStats, scope *gorm.DB) {
+	stats.BackupsByStatus = map[models.BackupStatus]int{
+		models.BackupStatusSuccess: 0,
+		models.BackupStatusFailed:  0,
+		models.BackupStatusRunning: 0,
+		models.BackupStatusPending: 0,
+		models.BackupStatusDeleted: 0,
+	}
+	if scope == nil {
+		return
+	}
+
+	var counts []struct {
+		Status models.BackupStatus
+		Count  int
+	}
+	scope.Session(&gorm.Session{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&counts)
+	for _, c := range counts {
+		stats.BackupsByStatus[c.Status] = c.Count
+		stats.TotalBackups += c.Count
+	}
+
+	var span struct {
+		Oldest *time.Time
+		Newest *time.Time
+	}
+	scope.Session(&gorm.Session{}).
+		Select("MIN(started_at) AS oldest, MAX(started_at) AS newest").
+		Scan(&span)
+	stats.OldestBackupAt = span.Oldest
+	stats.NewestBackupAt = span.Newest
+}
+
 // GetSystemStatsByUser returns system stats filtered by user's resources
 func (r *Repository) GetSystemStatsByUser(userID uuid.UUID, isAdmin bool) (*models.SystemStats, error) {
 	// If admin, return all stats
@@ -1226,6 +1956,18 @@ func (r *Repository) GetSystemStatsByUser(userID uuid.UUID, isAdmin bool) (*mode
 
 	stats.TotalStorageUsedBytes = sumResult.Total
 
+	var pausedDatabases int64
+	r.db.Model(&models.DatabaseConfig{}).
+		Where("paused = ? AND user_id = ?", true, userID).
+		Count(&pausedDatabases)
+	stats.PausedDatabases = int(pausedDatabases)
+
+	var scope *gorm.DB
+	if len(dbIDs) > 0 {
+		scope = r.db.Model(&models.Backup{}).Where("database_id IN ?", dbIDs)
+	}
+	r.fillLifetimeBackupStats(stats, scope)
+
 	return stats, nil
 }
 
@@ -1253,6 +1995,10 @@ func (r *Repository) CreateRestoreJob(backupID uuid.UUID, req *models.RestoreReq
 		if req.TargetPassword != "" {
 			job.TargetPassword = &req.TargetPassword
 		}
+		job.TargetDatabaseID = req.TargetDatabaseID
+		job.ServerConnectionID = req.ServerConnectionID
+		job.Tables = req.Tables
+		job.IsTest = req.Test
 	}
 
 	result := r.db.Create(job)
@@ -1263,10 +2009,55 @@ func (r *Repository) CreateRestoreJob(backupID uuid.UUID, req *models.RestoreReq
 	return job, nil
 }
 
+// MarkRestoreJobRunning flips a pending restore job to running and resets
+// started_at, so time spent waiting for a restore slot is not counted.
+func (r *Repository) MarkRestoreJobRunning(id uuid.UUID) error {
+	return r.db.Model(&models.RestoreJob{}).Where("id = ?", id).
+		Updates(map[string]any{"status": models.BackupStatusRunning, "started_at": time.Now()}).Error
+}
+
+// FinishRestoreJob records a restore job's outcome. errMsg is stored only
+// for failures; testResult (JSON) only for test restores.
+func (r *Repository) FinishRestoreJob(id uuid.UUID, status models.BackupStatus, errMsg string, testResult *string) error {
+	updates := map[string]any{
+		"status":       status,
+		"completed_at": time.Now(),
+	}
+	if errMsg != "" {
+		updates["error_message"] = errMsg
+	}
+	if testResult != nil {
+		updates["test_result"] = *testResult
+	}
+	return r.db.Model(&models.RestoreJob{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// GetRestoreJobByUser returns a restore job whose backup belongs to userID
+// (any job for admins), or nil when there is none.
+func (r *Repository) GetRestoreJobByUser(id, userID uuid.UUID, isAdmin bool) (*models.RestoreJob, error) {
+	var job models.RestoreJob
+	query := r.db.
+		Joins("JOIN backups ON restore_jobs.backup_id = backups.id").
+		Joins("JOIN database_configs ON backups.database_id = database_configs.id").
+		Where("restore_jobs.id = ?", id)
+	if !isAdmin {
+		query = query.Where("database_configs.user_id = ?", userID)
+	}
+	result := query.First(&job)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get restore job: %w", result.Error)
+	}
+	return &job, nil
+}
+
 // Activity Log operations
 
 // CreateActivityLog creates a new activity log entry
 func (r *Repository) CreateActivityLog(log *models.ActivityLog) error {
+	log.Metadata = sanitizeActivityMetadata(log.Action, log.Metadata)
 	result := r.db.Create(log)
 	if result.Error != nil {
 		return fmt.Errorf("failed to create activity log: %w", result.Error)
@@ -1293,11 +2084,6 @@ func (r *Repository) LogActivity(userID *uuid.UUID, action models.ActivityLogAct
 		}
 	}
 
-	// If metadata is empty, set it to null or empty JSON object
-	if metadata == "" {
-		metadata = "{}"
-	}
-
 	log := &models.ActivityLog{
 		UserID:      userID,
 		Action:      action,
@@ -1313,6 +2099,25 @@ func (r *Repository) LogActivity(userID *uuid.UUID, action models.ActivityLogAct
 	return r.CreateActivityLog(log)
 }
 
+// sanitizeActivityMetadata keeps the jsonb column valid and bounded. Audit
+// writes must not fail because of a bad payload, so instead of rejecting the
+// entry, invalid or oversized metadata is replaced with a small marker
+// object and the event itself is still recorded.
+func sanitizeActivityMetadata(action models.ActivityLogAction, metadata string) string {
+	if metadata == "" {
+		return "{}"
+	}
+	if len(metadata) > models.ActivityLogMetadataMaxBytes {
+		log.Printf("[ACTIVITY_LOG] ⚠️  Dropping %d-byte metadata for %s (limit %d)", len(metadata), action, models.ActivityLogMetadataMaxBytes)
+		return fmt.Sprintf(`{"metadata_truncated":true,"original_bytes":%d}`, len(metadata))
+	}
+	if !json.Valid([]byte(metadata)) {
+		log.Printf("[ACTIVITY_LOG] ⚠️  Dropping invalid JSON metadata for %s", action)
+		return `{"metadata_invalid":true}`
+	}
+	return metadata
+}
+
 // GetActivityLog retrieves a single activity log by ID
 func (r *Repository) GetActivityLog(id uuid.UUID) (*models.ActivityLog, error) {
 	var log models.ActivityLog
@@ -1393,6 +2198,9 @@ func (r *Repository) ListActivityLogsByUser(userID uuid.UUID, isAdmin bool, para
 	var total int64
 
 	query := r.db.Model(&models.ActivityLog{}).Preload("User")
+	if params.ExcludeMetadata {
+		query = query.Omit("metadata")
+	}
 
 	// Apply user filter - admins can see all, regular users only their own logs
 	if !isAdmin {
@@ -1427,18 +2235,22 @@ func (r *Repository) ListActivityLogsByUser(userID uuid.UUID, isAdmin bool, para
 		return nil, 0, fmt.Errorf("failed to count activity logs: %w", err)
 	}
 
-	// Apply pagination
+	// Apply pagination. A cursor takes precedence over offset; id breaks
+	// ties between entries written in the same microsecond.
 	limit := params.Limit
 	if limit <= 0 {
-		limit = 50
+		limit = models.DefaultActivityLogLimit
 	}
 	offset := params.Offset
-	if offset < 0 {
+	if offset < 0 || params.Cursor != nil {
 		offset = 0
 	}
+	if params.Cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", params.Cursor.CreatedAt, params.Cursor.ID)
+	}
 
 	// Retrieve logs
-	result := query.Order("created_at DESC").
+	result := query.Order("created_at DESC, id DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&logs)
@@ -1484,6 +2296,21 @@ func (r *Repository) DeleteOldActivityLogs(olderThan time.Time) (int64, error) {
 	return result.RowsAffected, nil
 }
 
+// DeleteExpiredOTPTokens removes OTP tokens that expired, or were used,
+// before olderThan. Only expired or used rows are touched, so pending codes
+// and their lockout counters survive.
+func (r *Repository) DeleteExpiredOTPTokens(olderThan time.Time) (int64, error) {
+	result := r.db.
+		Where("expires_at < ? OR (used = ? AND created_at < ?)", olderThan, true, olderThan).
+		Delete(&models.OTPToken{})
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired OTP tokens: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
 // ========================================
 // Two-Factor Authentication Operations
 // ========================================
@@ -1503,6 +2330,75 @@ func (r *Repository) GetUserByID(id uuid.UUID) (*models.User, error) {
 	return &user, nil
 }
 
+// Role-change refusals returned by SetUserRole.
+var (
+	ErrLastAdmin     = errors.New("cannot remove the last admin")
+	ErrDemoPromotion = errors.New("demo users cannot be admins or viewers")
+	ErrRoleConflict  = errors.New("a user cannot be both admin and viewer")
+)
+
+// ListUsers returns every user, oldest first.
+func (r *Repository) ListUsers() ([]*models.User, error) {
+	var users []*models.User
+	if err := r.db.Order("created_at ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// SetUserRole sets a user's admin and viewer flags and returns the updated
+// row, or nil when the user does not exist. A nil flag keeps its current
+// value. Every admin row is locked so two concurrent demotions cannot leave
+// the system without an admin.
+func (r *Repository) SetUserRole(id uuid.UUID, isAdmin, isViewer *bool) (*models.User, error) {
+	var user *models.User
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var admins []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").Where("is_admin = ?", true).Find(&admins).Error; err != nil {
+			return err
+		}
+
+		var target models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&target, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		admin, viewer := target.IsAdmin, target.IsViewer
+		if isAdmin != nil {
+			admin = *isAdmin
+		}
+		if isViewer != nil {
+			viewer = *isViewer
+		}
+		if (admin || viewer) && target.IsDemo {
+			return ErrDemoPromotion
+		}
+		if admin && viewer {
+			return ErrRoleConflict
+		}
+		if !admin && target.IsAdmin && len(admins) <= 1 {
+			return ErrLastAdmin
+		}
+
+		if err := tx.Model(&target).Updates(map[string]any{"is_admin": admin, "is_viewer": viewer}).Error; err != nil {
+			return err
+		}
+		target.IsAdmin, target.IsViewer = admin, viewer
+		user = &target
+		return nil
+	})
+	if errors.Is(err, ErrLastAdmin) || errors.Is(err, ErrDemoPromotion) || errors.Is(err, ErrRoleConflict) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set user role: %w", err)
+	}
+	return user, nil
+}
+
 // SetPendingUser2FASecret stores an unverified TOTP secret in the pending
 // column with an expiry. Replaces any prior pending secret for the same
 // user (treats Setup2FA as idempotent within the pending window). The
@@ -1528,6 +2424,60 @@ func (r *Repository) SetPendingUser2FASecret(userID uuid.UUID, secret string, tt
 	return nil
 }
 
+// TwoFactorFailureResult reports the user's 2FA failure state after a bad
+// code. JustLocked is true only on the attempt that triggered the lockout,
+// so the caller notifies once rather than on every blocked request.
+type TwoFactorFailureResult struct {
+	FailedAttempts int
+	LockedUntil    *time.Time
+	JustLocked     bool
+}
+
+// RecordUser2FAFailure increments the user's consecutive 2FA failure count
+// under a row lock and, on reaching TwoFactorMaxFailedAttempts, locks 2FA
+// verification for TwoFactorLockoutDuration. The counter restarts at zero
+// once locked so the user gets a fresh allowance after the lockout.
+func (r *Repository) RecordUser2FAFailure(userID uuid.UUID) (TwoFactorFailureResult, error) {
+	var result TwoFactorFailureResult
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "two_factor_failed_attempts").
+			First(&user, "id = ?", userID).Error; err != nil {
+			return fmt.Errorf("load user: %w", err)
+		}
+
+		attempts := user.TwoFactorFailedAttempts + 1
+		updates := map[string]any{"two_factor_failed_attempts": attempts}
+		if attempts >= models.TwoFactorMaxFailedAttempts {
+			lockedUntil := time.Now().Add(models.TwoFactorLockoutDuration)
+			updates["two_factor_failed_attempts"] = 0
+			updates["two_factor_locked_until"] = lockedUntil
+			result.LockedUntil = &lockedUntil
+			result.JustLocked = true
+		}
+		result.FailedAttempts = attempts
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to record 2FA failure: %w", err)
+	}
+	return result, nil
+}
+
+// ResetUser2FAFailures clears the failure counter and any lockout after a
+// successful 2FA verification.
+func (r *Repository) ResetUser2FAFailures(userID uuid.UUID) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"two_factor_failed_attempts": 0,
+		"two_factor_locked_until":    nil,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to reset 2FA failures: %w", result.Error)
+	}
+	return nil
+}
+
 // PromotePendingUser2FASecret moves the pending secret into the active
 // TwoFactorSecret column, clears the pending fields, and enables 2FA with
 // the provided backup codes. Performed in a single transaction to avoid a
@@ -1618,10 +2568,147 @@ func (r *Repository) GetUser2FAStatus(userID uuid.UUID) (enabled bool, backupCod
 	return user.TwoFactorEnabled, len(user.TwoFactorBackupCodes), user.TwoFactorVerifiedAt, nil
 }
 
+// ========================================
+// WebAuthn (passkey) Operations
+// ========================================
+
+// SetPendingWebAuthnChallenge stores the challenge of a passkey ceremony,
+// replacing any earlier one.
+func (r *Repository) SetPendingWebAuthnChallenge(userID uuid.UUID, challenge string, ttl time.Duration) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]any{
+			"pending_web_authn_challenge":            challenge,
+			"pending_web_authn_challenge_expires_at": time.Now().Add(ttl),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to set WebAuthn challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ConsumeWebAuthnChallenge returns the user's pending passkey challenge and
+// clears it, so each challenge verifies at most one response. It returns
+// "" when there is none or it has expired.
+func (r *Repository) ConsumeWebAuthnChallenge(userID uuid.UUID) (string, error) {
+	var challenge string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "pending_web_authn_challenge", "pending_web_authn_challenge_expires_at").
+			First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if user.PendingWebAuthnChallenge == "" {
+			return nil
+		}
+		if user.PendingWebAuthnChallengeExpiresAt != nil && time.Now().Before(*user.PendingWebAuthnChallengeExpiresAt) {
+			challenge = user.PendingWebAuthnChallenge
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).
+			Updates(map[string]any{
+				"pending_web_authn_challenge":            "",
+				"pending_web_authn_challenge_expires_at": nil,
+			}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to consume WebAuthn challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+// CreateWebAuthnCredential stores a verified passkey. A credential ID that
+// is already registered yields a *ConflictError.
+func (r *Repository) CreateWebAuthnCredential(cred *models.WebAuthnCredential) error {
+	if err := r.db.Create(cred).Error; err != nil {
+		return fmt.Errorf("failed to create WebAuthn credential: %w", conflictOr(err, "id", base64.RawURLEncoding.EncodeToString(cred.CredentialID)))
+	}
+	return nil
+}
+
+// ListWebAuthnCredentials returns the user's passkeys, oldest first.
+func (r *Repository) ListWebAuthnCredentials(userID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	var creds []*models.WebAuthnCredential
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&creds).Error; err != nil {
+		return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// CountWebAuthnCredentials returns how many passkeys the user has.
+func (r *Repository) CountWebAuthnCredentials(userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := r.db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count WebAuthn credentials: %w", err)
+	}
+	return count, nil
+}
+
+// GetWebAuthnCredential finds one of the user's passkeys by its
+// authenticator-assigned ID. It returns nil when there is none.
+func (r *Repository) GetWebAuthnCredential(userID uuid.UUID, credentialID []byte) (*models.WebAuthnCredential, error) {
+	var cred models.WebAuthnCredential
+	err := r.db.Where("user_id = ? AND credential_id = ?", userID, credentialID).First(&cred).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WebAuthn credential: %w", err)
+	}
+	return &cred, nil
+}
+
+// RecordWebAuthnUse stores the signature counter after a successful login.
+func (r *Repository) RecordWebAuthnUse(id uuid.UUID, signCount uint32) error {
+	result := r.db.Model(&models.WebAuthnCredential{}).Where("id = ?", id).
+		Updates(map[string]any{"sign_count": int64(signCount), "last_used_at": time.Now()})
+	if result.Error != nil {
+		return fmt.Errorf("failed to record WebAuthn use: %w", result.Error)
+	}
+	return nil
+}
+
+// DeleteWebAuthnCredential removes one of the user's passkeys and reports
+// whether it existed.
+func (r *Repository) DeleteWebAuthnCredential(userID, id uuid.UUID) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete WebAuthn credential: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 // ========================================
 // User Profile Operations
 // ========================================
 
+// UpdateUserOTPDestination stores the user's login OTP channel and target.
+// Targets not used by the selected channel are cleared.
+func (r *Repository) UpdateUserOTPDestination(userID uuid.UUID, channel models.OTPChannel, webhookURL, email string) error {
+	updates := map[string]any{
+		"otp_channel":             channel,
+		"otp_discord_webhook_url": "",
+		"otp_email":               "",
+	}
+	switch channel {
+	case models.OTPChannelDiscord:
+		updates["otp_discord_webhook_url"] = webhookURL
+	case models.OTPChannelEmail:
+		updates["otp_email"] = email
+	}
+	result := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update OTP destination: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 // UpdateUserProfilePicture updates the user's profile picture stored as binary data
 func (r *Repository) UpdateUserProfilePicture(userID uuid.UUID, imageData []byte, mimeType string) error {
 	result := r.db.Model(&models.User{}).
@@ -1732,7 +2819,7 @@ func (r *Repository) CreateLabel(userID uuid.UUID, input *models.LabelInput) (*m
 
 	result := r.db.Create(label)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to create label: %w", result.Error)
+		return nil, fmt.Errorf("failed to create label: %w", conflictOr(result.Error, "name", input.Name))
 	}
 
 	return label, nil
@@ -1855,7 +2942,7 @@ func (r *Repository) UpdateLabel(id, userID uuid.UUID, isAdmin bool, input *mode
 
 	result := r.db.Save(&label)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to update label: %w", result.Error)
+		return nil, fmt.Errorf("failed to update label: %w", conflictOr(result.Error, "name", input.Name))
 	}
 
 	return &label, nil
@@ -2338,3 +3425,63 @@ func (r *Repository) UpdateServerConnectionTestResult(id uuid.UUID, status, sslM
 		"last_test_error":    errMessage,
 	}).Error
 }
+
+// SecretRotationResult reports what RotateServerConnectionSecrets touched.
+// Failed lists the IDs whose ciphertext could not be re-encrypted (usually
+// because it was sealed under a key other than the "old" one).
+type SecretRotationResult struct {
+	Scanned int         `json:"scanned"`
+	Rotated int         `json:"rotated"`
+	Failed  []uuid.UUID `json:"failed,omitempty"`
+	DryRun  bool        `json:"dry_run"`
+}
+
+// RotateServerConnectionSecrets re-encrypts every stored server connection
+// password with reencrypt inside a single transaction. Any failure rolls
+// the whole batch back so the table is never left with a mix of old- and
+// new-key ciphertexts. With dryRun the re-encryption is still exercised (to
+// prove the old key opens every row) but nothing is written.
+func (r *Repository) RotateServerConnectionSecrets(reencrypt func(ciphertext string) (string, error), dryRun bool) (*SecretRotationResult, error) {
+	result := &SecretRotationResult{DryRun: dryRun}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var items []*models.ServerConnection
+		if err := tx.Select("id", "password").Find(&items).Error; err != nil {
+			return fmt.Errorf("load server connections: %w", err)
+		}
+		result.Scanned = len(items)
+
+		for _, sc := range items {
+			rotated, err := reencrypt(sc.Password)
+			if err != nil {
+				result.Failed = append(result.Failed, sc.ID)
+				continue
+			}
+			if !dryRun {
+				if err := tx.Model(&models.ServerConnection{}).
+					Where("id = ?", sc.ID).
+					Update("password", rotated).Error; err != nil {
+					return fmt.Errorf("update server connection %s: %w", sc.ID, err)
+				}
+			}
+			result.Rotated++
+		}
+
+		if len(result.Failed) > 0 && !dryRun {
+			return fmt.Errorf("%d of %d secrets could not be decrypted with the old key", len(result.Failed), result.Scanned)
+		}
+		return nil
+	})
+	if err != nil {
+		result.Rotated = 0
+		return result, err
+	}
+
+	return result, nil
+}
+
+// escapeLike escapes the LIKE/ILIKE wildcards in user-supplied search text so
+// "50%" matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
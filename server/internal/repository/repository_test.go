@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// countingDriver is a database/sql driver that records every query and
+// answers label listings with a fixed number of rows and everything else
+// with no rows. It lets tests bound how many round trips a repository
+// method makes without a live PostgreSQL.
+type countingDriver struct {
+	mu      sync.Mutex
+	queries []string
+	labels  int
+}
+
+func (d *countingDriver) Open(string) (driver.Conn, error) { return &countingConn{d: d}, nil }
+
+type countingConn struct{ d *countingDriver }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return &countingStmt{d: c.d, query: query}, nil
+}
+func (c *countingConn) Close() error { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions not supported")
+}
+
+type countingStmt struct {
+	d     *countingDriver
+	query string
+}
+
+func (s *countingStmt) Close() error  { return nil }
+func (s *countingStmt) NumInput() int { return -1 }
+func (s *countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *countingStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	s.d.queries = append(s.d.queries, s.query)
+	s.d.mu.Unlock()
+
+	if strings.Contains(s.query, `FROM "labels"`) {
+		rows := &fakeRows{cols: []string{"id", "user_id", "name", "color", "description", "created_at", "updated_at"}}
+		for i := 0; i < s.d.labels; i++ {
+			rows.data = append(rows.data, []driver.Value{
+				uuid.NewString(), uuid.NewString(), fmt.Sprintf("label-%02d", i), "#3b82f6", "", time.Now(), time.Now(),
+			})
+		}
+		return rows, nil
+	}
+	return &fakeRows{cols: []string{"label_id", "count"}}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	data [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.data[0])
+	r.data = r.data[1:]
+	return nil
+}
+
+func newCountingRepo(t *testing.T, labels int) (*Repository, *countingDriver) {
+	t.Helper()
+	d := &countingDriver{labels: labels}
+	return openFakeRepo(t, d), d
+}
+
+// openFakeRepo returns a Repository backed by the fake driver d.
+func openFakeRepo(t *testing.T, d driver.Driver) *Repository {
+	t.Helper()
+	name := "fake-" + uuid.NewString()
+	sql.Register(name, d)
+	sqlDB, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return NewGORM(db)
+}
+
+// TestListLabelsByUser_BoundedQueries checks usage counts are loaded with a
+// fixed number of grouped queries rather than one per label.
+func TestListLabelsByUser_BoundedQueries(t *testing.T) {
+	repo, d := newCountingRepo(t, 50)
+
+	labels, err := repo.ListLabelsByUser(uuid.New(), false)
+	if err != nil {
+		t.Fatalf("ListLabelsByUser: %v", err)
+	}
+	if len(labels) != 50 {
+		t.Fatalf("got %d labels, want 50", len(labels))
+	}
+	if len(d.queries) != 4 {
+		t.Errorf("ListLabelsByUser issued %d queries for 50 labels, want 4:\n%s", len(d.queries), strings.Join(d.queries, "\n"))
+	}
+}
+
+// scriptedDriver answers every query and exec with respond.
+type scriptedDriver struct {
+	respond func(query string) (driver.Rows, error)
+}
+
+func (d *scriptedDriver) Open(string) (driver.Conn, error) { return &scriptedConn{d: d}, nil }
+
+type scriptedConn struct{ d *scriptedDriver }
+
+func (c *scriptedConn) Prepare(query string) (driver.Stmt, error) {
+	return &scriptedStmt{d: c.d, query: query}, nil
+}
+func (c *scriptedConn) Close() error { return nil }
+func (c *scriptedConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions not supported")
+}
+
+type scriptedStmt struct {
+	d     *scriptedDriver
+	query string
+}
+
+func (s *scriptedStmt) Close() error  { return nil }
+func (s *scriptedStmt) NumInput() int { return -1 }
+func (s *scriptedStmt) Exec([]driver.Value) (driver.Result, error) {
+	if _, err := s.d.respond(s.query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+func (s *scriptedStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.d.respond(s.query)
+}
+
+// countRows answers a SELECT count(*) with n.
+func countRows(n int64) *fakeRows {
+	return &fakeRows{cols: []string{"count"}, data: [][]driver.Value{{n}}}
+}
+
+// TestCreateLabel_DuplicateNameConflict checks a unique violation on the
+// label name surfaces as a ConflictError naming the field.
+func TestCreateLabel_DuplicateNameConflict(t *testing.T) {
+	repo := openFakeRepo(t, &scriptedDriver{respond: func(query string) (driver.Rows, error) {
+		if strings.HasPrefix(query, "INSERT") {
+			return nil, &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "idx_user_label_name"}
+		}
+		return countRows(3), nil
+	}})
+
+	_, err := repo.CreateLabel(uuid.New(), &models.LabelInput{Name: "production", Color: "#3b82f6"})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("CreateLabel error = %v, want ConflictError", err)
+	}
+	if conflict.Field != "name" || conflict.Value != "production" {
+		t.Errorf("conflict = %+v, want name \"production\"", conflict)
+	}
+}
+
+// TestCreateStorageConfig_DuplicateNameConflict checks a second storage
+// config with the same name is refused before anything is inserted.
+func TestCreateStorageConfig_DuplicateNameConflict(t *testing.T) {
+	var inserted bool
+	repo := openFakeRepo(t, &scriptedDriver{respond: func(query string) (driver.Rows, error) {
+		if strings.HasPrefix(query, "INSERT") {
+			inserted = true
+			return &fakeRows{cols: []string{"id"}, data: [][]driver.Value{{uuid.NewString()}}}, nil
+		}
+		return countRows(1), nil
+	}})
+
+	_, err := repo.CreateStorageConfig(uuid.New(), &models.StorageConfigInput{Name: "Primary S3", Provider: models.StorageProviderS3})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Field != "name" {
+		t.Fatalf("CreateStorageConfig error = %v, want name ConflictError", err)
+	}
+	if inserted {
+		t.Error("duplicate storage config was inserted")
+	}
+}
+
+// TestUpdateStorageConfigByUser_KeepsName checks that saving a config
+// without renaming it skips the duplicate-name check, so rows that predate
+// the unique index stay editable.
+func TestUpdateStorageConfigByUser_KeepsName(t *testing.T) {
+	id, userID := uuid.New(), uuid.New()
+	var counted bool
+	repo := openFakeRepo(t, &scriptedDriver{respond: func(query string) (driver.Rows, error) {
+		if strings.Contains(query, "count(*)") {
+			counted = true
+			return countRows(1), nil
+		}
+		return &fakeRows{
+			cols: []string{"id", "user_id", "name", "provider"},
+			data: [][]driver.Value{{id.String(), userID.String(), "Primary S3", "s3"}},
+		}, nil
+	}})
+
+	_, err := repo.UpdateStorageConfigByUser(id, userID, false, &models.StorageConfigInput{Name: "Primary S3", Provider: models.StorageProviderS3})
+	if err != nil {
+		t.Fatalf("UpdateStorageConfigByUser: %v", err)
+	}
+	if counted {
+		t.Error("an unchanged name was checked for duplicates")
+	}
+}
+
+// TestCreateStorageConfig_UniqueIndexConflict checks a race past the name
+// check still surfaces as a ConflictError via the unique index.
+func TestCreateStorageConfig_UniqueIndexConflict(t *testing.T) {
+	repo := openFakeRepo(t, &scriptedDriver{respond: func(query string) (driver.Rows, error) {
+		if strings.HasPrefix(query, "INSERT") {
+			return nil, &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "idx_storage_configs_user_lower_name"}
+		}
+		return countRows(0), nil
+	}})
+
+	_, err := repo.CreateStorageConfig(uuid.New(), &models.StorageConfigInput{Name: "primary s3", Provider: models.StorageProviderS3})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Field != "name" {
+		t.Fatalf("CreateStorageConfig error = %v, want name ConflictError", err)
+	}
+}
+
+// TestConflictOr_PassesOtherErrors checks non-unique errors are untouched.
+func TestConflictOr_PassesOtherErrors(t *testing.T) {
+	other := &pgconn.PgError{Code: "23503"}
+	if err := conflictOr(other, "name", "x"); err != other {
+		t.Errorf("conflictOr(foreign key violation) = %v, want it unchanged", err)
+	}
+}
+
+// TestListAllBackupsByUser_FiltersAndPages checks the filters are applied in
+// SQL, the total is counted before paging, and the page is one joined query.
+func TestListAllBackupsByUser_FiltersAndPages(t *testing.T) {
+	var queries []string
+	repo := openFakeRepo(t, &scriptedDriver{respond: func(query string) (driver.Rows, error) {
+		queries = append(queries, query)
+		if strings.Contains(query, "count(*)") {
+			return countRows(57), nil
+		}
+		return &fakeRows{cols: []string{"id"}}, nil
+	}})
+
+	status := models.BackupStatusSuccess
+	label := uuid.New()
+	minSize := int64(1 << 20)
+	_, total, err := repo.ListAllBackupsByUser(uuid.New(), false, &models.BackupListParams{
+		Status:       &status,
+		LabelID:      &label,
+		MinSizeBytes: &minSize,
+		Limit:        20,
+		Offset:       40,
+	})
+	if err != nil {
+		t.Fatalf("ListAllBackupsByUser: %v", err)
+	}
+	if total != 57 {
+		t.Errorf("total = %d, want 57", total)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("ran %d queries, want count + page: %q", len(queries), queries)
+	}
+	for _, q := range queries {
+		for _, want := range []string{"JOIN database_configs", "database_configs.user_id", "backups.status", "backups.size_bytes >=", "database_labels"} {
+			if !strings.Contains(q, want) {
+				t.Errorf("query %q lacks %q", q, want)
+			}
+		}
+	}
+	if strings.Contains(queries[0], "LIMIT") {
+		t.Errorf("count query is paged: %q", queries[0])
+	}
+	if !strings.Contains(queries[1], "LIMIT") || !strings.Contains(queries[1], "OFFSET") {
+		t.Errorf("page query %q is not paged", queries[1])
+	}
+}
+
+// TestListBackupsByDatabaseByUser_CountsBeforePaging checks a database's
+// history reports its total alongside the requested page.
+func TestListBackupsByDatabaseByUser_CountsBeforePaging(t *testing.T) {
+	var queries []string
+	repo := openFakeRepo(t, &scriptedDriver{respond: func(query string) (driver.Rows, error) {
+		queries = append(queries, query)
+		if strings.Contains(query, "count(*)") {
+			return countRows(12), nil
+		}
+		return &fakeRows{cols: []string{"id"}}, nil
+	}})
+
+	_, total, err := repo.ListBackupsByDatabaseByUser(uuid.New(), uuid.New(), false, &models.BackupListParams{Limit: 5, Offset: 10})
+	if err != nil {
+		t.Fatalf("ListBackupsByDatabaseByUser: %v", err)
+	}
+	if total != 12 {
+		t.Errorf("total = %d, want 12", total)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("ran %d queries, want count + page: %q", len(queries), queries)
+	}
+	if strings.Contains(queries[0], "LIMIT") {
+		t.Errorf("count query is paged: %q", queries[0])
+	}
+	if !strings.Contains(queries[1], "LIMIT") || !strings.Contains(queries[1], "OFFSET") {
+		t.Errorf("page query %q is not paged", queries[1])
+	}
+}
@@ -0,0 +1,196 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/notification"
+)
+
+// DefaultFreshnessGrace is how long after a missed trigger a database
+// counts as stale, leaving time for a queued or long-running backup.
+const DefaultFreshnessGrace = time.Hour
+
+// freshness tracks overdue databases between checks so each missed trigger
+// is alerted once.
+type freshness struct {
+	mu    sync.Mutex
+	grace time.Duration
+	// alerted maps database IDs to the missed trigger already alerted on.
+	alerted map[uuid.UUID]time.Time
+	stale   int
+	stop    chan struct{}
+}
+
+// SetFreshnessGrace sets how long past a missed trigger a database becomes
+// stale. d <= 0 means DefaultFreshnessGrace.
+func (s *Scheduler) SetFreshnessGrace(d time.Duration) {
+	s.fresh.mu.Lock()
+	defer s.fresh.mu.Unlock()
+	s.fresh.grace = d
+}
+
+func (s *Scheduler) freshnessGrace() time.Duration {
+	s.fresh.mu.Lock()
+	defer s.fresh.mu.Unlock()
+	if s.fresh.grace <= 0 {
+		return DefaultFreshnessGrace
+	}
+	return s.fresh.grace
+}
+
+// Overdue reports whether config missed a scheduled backup: its schedule
+// fired after the last successful backup (or after the config was created,
+// or its snooze ended) and nothing succeeded within the grace period. It
+// returns the missed trigger. Disabled, paused and snoozed databases are
+// never overdue. Maintenance mode is ignored so the flag stays truthful;
+// the checker only holds back alerts.
+func (s *Scheduler) Overdue(config *models.DatabaseConfig, now time.Time) (time.Time, bool) {
+	return s.overdue(config, now, s.freshnessGrace())
+}
+
+func (s *Scheduler) overdue(config *models.DatabaseConfig, now time.Time, grace time.Duration) (time.Time, bool) {
+	if !config.Enabled || config.Paused {
+		return time.Time{}, false
+	}
+	schedule, err := s.parser.Parse(config.Schedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	since := config.CreatedAt
+	if config.LastSuccessAt != nil && config.LastSuccessAt.After(since) {
+		since = *config.LastSuccessAt
+	}
+	if config.SnoozedUntil != nil {
+		if now.Before(*config.SnoozedUntil) {
+			return time.Time{}, false
+		}
+		if config.SnoozedUntil.After(since) {
+			since = *config.SnoozedUntil
+		}
+	}
+	missed := schedule.Next(since)
+	if missed.IsZero() {
+		return time.Time{}, false
+	}
+	// A jittered run may legitimately start up to its window late.
+	return missed, now.After(missed.Add(s.jitterWindow(config) + grace))
+}
+
+// StartFreshnessChecker checks every interval for databases whose scheduled
+// backup is overdue, and alerts once per missed trigger through the
+// database's notification config and the activity log. This catches
+// backups that silently stopped, which failure alerts never see. It runs
+// until Stop.
+func (s *Scheduler) StartFreshnessChecker(interval time.Duration) {
+	stop := make(chan struct{})
+	s.fresh.mu.Lock()
+	s.fresh.stop = stop
+	s.fresh.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		s.checkFreshness(time.Now())
+		for {
+			select {
+			case now := <-ticker.C:
+				s.checkFreshness(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	logging.Infof("Backup freshness check runs every %s (grace %s)", interval, s.freshnessGrace())
+}
+
+// stopFreshnessChecker ends the loop started by StartFreshnessChecker.
+func (s *Scheduler) stopFreshnessChecker() {
+	s.fresh.mu.Lock()
+	defer s.fresh.mu.Unlock()
+	if s.fresh.stop != nil {
+		close(s.fresh.stop)
+		s.fresh.stop = nil
+	}
+}
+
+// checkFreshness runs one pass over the scheduled databases.
+func (s *Scheduler) checkFreshness(now time.Time) {
+	configs, err := s.listScheduled()
+	if err != nil {
+		logging.Errorf("Backup freshness check failed: %v", err)
+		return
+	}
+
+	grace := s.freshnessGrace()
+	var newlyStale []*models.DatabaseConfig
+	var missedAt []time.Time
+	s.fresh.mu.Lock()
+	seen := make(map[uuid.UUID]bool, len(configs))
+	stale := 0
+	for _, config := range configs {
+		missed, overdue := s.overdue(config, now, grace)
+		if !overdue {
+			continue
+		}
+		stale++
+		seen[config.ID] = true
+		if s.fresh.alerted[config.ID].Equal(missed) {
+			continue
+		}
+		// Alerts wait out maintenance; the next pass after it sends them.
+		if s.InMaintenance() {
+			continue
+		}
+		s.fresh.alerted[config.ID] = missed
+		newlyStale = append(newlyStale, config)
+		missedAt = append(missedAt, missed)
+	}
+	for id := range s.fresh.alerted {
+		if !seen[id] {
+			delete(s.fresh.alerted, id)
+		}
+	}
+	s.fresh.stale = stale
+	s.fresh.mu.Unlock()
+
+	for i, config := range newlyStale {
+		s.alertOverdue(config, missedAt[i])
+	}
+}
+
+// staleCount is the number of overdue databases at the last check.
+func (s *Scheduler) staleCount() int {
+	s.fresh.mu.Lock()
+	defer s.fresh.mu.Unlock()
+	return s.fresh.stale
+}
+
+// alertOverdueBackup logs and notifies that config missed the trigger at
+// missed.
+func (s *Scheduler) alertOverdueBackup(config *models.DatabaseConfig, missed time.Time) {
+	last := "never"
+	if config.LastSuccessAt != nil {
+		last = config.LastSuccessAt.UTC().Format(time.RFC3339)
+	}
+	logging.Warnf("⚠️  Backup overdue for %s: scheduled %s, last success %s", config.Name, missed.UTC().Format(time.RFC3339), last)
+
+	meta := fmt.Sprintf(`{"missed_at":%q,"last_success_at":%q}`, missed.UTC().Format(time.RFC3339), last)
+	if err := s.repo.LogActivity(&config.UserID, models.ActionBackupOverdue, models.LogLevelWarning,
+		"database", &config.ID, config.Name,
+		fmt.Sprintf("No successful backup of %q since the run scheduled at %s", config.Name, missed.UTC().Format(time.RFC3339)),
+		meta, ""); err != nil {
+		logging.Errorf("Failed to log overdue backup for %s: %v", config.Name, err)
+	}
+
+	notifier := notification.NotifierFromConfig(config.Notification)
+	msg := fmt.Sprintf("⏰ **Backup Overdue**\n📦 Database: %s\n🗓️ Missed run: %s\n✅ Last success: %s\nNo backup has succeeded since; check the scheduler and the database's recent runs.",
+		config.Name, missed.UTC().Format(time.RFC3339), last)
+	if err := notifier.SendMessage(msg); err != nil {
+		logging.Errorf("Failed to send overdue alert for %s: %v", config.Name, err)
+	}
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/robfig/cron/v3"
+)
+
+// SetJitter sets the default start jitter window for databases without
+// their own jitter_minutes. Zero (the default) starts runs on the dot.
+func (s *Scheduler) SetJitter(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jitter = window
+}
+
+// jitterWindow is config's jitter_minutes, or the default when unset.
+func (s *Scheduler) jitterWindow(config *models.DatabaseConfig) time.Duration {
+	if config.JitterMinutes != nil {
+		return time.Duration(*config.JitterMinutes) * time.Minute
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jitter
+}
+
+// startDelay picks a random delay in [0, window) for one scheduled run of
+// config. Nothing is persisted; each trigger draws afresh.
+func (s *Scheduler) startDelay(config *models.DatabaseConfig) (delay, window time.Duration) {
+	window = s.jitterWindow(config)
+	if window <= 0 {
+		return 0, 0
+	}
+	return rand.N(window), window
+}
+
+// stillScheduled reports whether entryID is still dbID's cron entry, i.e.
+// the job was not removed or replaced while its run waited out the jitter.
+func (s *Scheduler) stillScheduled(dbID uuid.UUID, entryID cron.EntryID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.jobMap[dbID]
+	return ok && current == entryID
+}
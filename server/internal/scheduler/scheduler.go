@@ -1,40 +1,90 @@
 package scheduler
 
 import (
-	"log"
+	"fmt"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/monzim/db_proxy/v1/internal/backup"
+	"github.com/monzim/db_proxy/v1/internal/logging"
 	"github.com/monzim/db_proxy/v1/internal/models"
 	"github.com/monzim/db_proxy/v1/internal/repository"
+	"github.com/monzim/db_proxy/v1/internal/validator"
 	"github.com/robfig/cron/v3"
 )
 
 // Scheduler manages scheduled backups. jobMap is guarded by mu because
 // configuration changes from the API can race with cron-fired callbacks.
+// mu is held across the whole remove+add of a job so concurrent updates of
+// the same database can never leave two cron entries behind.
 type Scheduler struct {
 	mu        sync.Mutex
 	cron      *cron.Cron
+	parser    cron.ScheduleParser
 	repo      *repository.Repository
 	backupSvc *backup.Service
 	jobMap    map[uuid.UUID]cron.EntryID // Maps database ID to cron entry ID
+	// runBackup executes one scheduled backup. It is backupSvc.ExecuteBackup
+	// outside of tests.
+	runBackup func(*models.DatabaseConfig) error
+	// snoozed maps database IDs to the time their snooze ends; scheduled
+	// runs before then are skipped.
+	snoozed map[uuid.UUID]time.Time
+	// clearSnooze drops an expired snooze from the stored config. It is
+	// repo.ClearExpiredSnooze outside of tests.
+	clearSnooze func(uuid.UUID) error
+	// setJobError stores a database's scheduler error; "" clears it. It is
+	// repo.SetSchedulerError outside of tests.
+	setJobError func(uuid.UUID, string) error
+	// listScheduled and alertOverdue back the freshness check. They are
+	// repo.ListScheduledDatabaseConfigs and alertOverdueBackup outside of
+	// tests.
+	listScheduled func() ([]*models.DatabaseConfig, error)
+	alertOverdue  func(*models.DatabaseConfig, time.Time)
+	fresh         freshness
+	// maintenance, while set, makes every cron trigger a no-op without
+	// touching the registered jobs.
+	maintenance atomic.Bool
+	// jitter is the default random start delay window; sleep waits it out
+	// (time.Sleep outside of tests).
+	jitter time.Duration
+	sleep  func(time.Duration)
+	// slots bounds concurrent scheduled backups; nil means unlimited.
+	slots         chan struct{}
+	maxConcurrent int
+	queued        atomic.Int64
+	running       atomic.Int64
+	recent        recentRuns
 }
 
-// NewScheduler creates a new scheduler
-func NewScheduler(repo *repository.Repository, backupSvc *backup.Service) *Scheduler {
-	return &Scheduler{
-		cron:      cron.New(),
-		repo:      repo,
-		backupSvc: backupSvc,
-		jobMap:    make(map[uuid.UUID]cron.EntryID),
+// NewScheduler creates a new scheduler. cronSeconds enables the optional
+// leading seconds field in schedules (see validator.CronParser).
+func NewScheduler(repo *repository.Repository, backupSvc *backup.Service, cronSeconds bool) *Scheduler {
+	parser := validator.CronParser(cronSeconds)
+	s := &Scheduler{
+		cron:          cron.New(cron.WithParser(parser)),
+		parser:        parser,
+		repo:          repo,
+		backupSvc:     backupSvc,
+		jobMap:        make(map[uuid.UUID]cron.EntryID),
+		runBackup:     backupSvc.ExecuteBackup,
+		snoozed:       make(map[uuid.UUID]time.Time),
+		clearSnooze:   repo.ClearExpiredSnooze,
+		setJobError:   repo.SetSchedulerError,
+		listScheduled: repo.ListScheduledDatabaseConfigs,
+		fresh:         freshness{alerted: make(map[uuid.UUID]time.Time)},
+		sleep:         time.Sleep,
 	}
+	s.alertOverdue = s.alertOverdueBackup
+	return s
 }
 
 // Start starts the scheduler and loads all database configurations
 func (s *Scheduler) Start() error {
-	log.Println("Starting backup scheduler...")
+	logging.Infof("Starting backup scheduler...")
 
 	// Load all database configurations
 	configs, err := s.repo.ListDatabaseConfigs()
@@ -46,85 +96,254 @@ func (s *Scheduler) Start() error {
 	for _, config := range configs {
 		if config.Enabled && !config.Paused {
 			if err := s.AddJob(config); err != nil {
-				log.Printf("Failed to schedule backup for %s: %v", config.Name, err)
+				logging.Errorf("Failed to schedule backup for %s: %v", config.Name, err)
 			}
 		}
 	}
 
 	s.cron.Start()
-	log.Printf("Scheduler started with %d active jobs", len(s.jobMap))
+	s.mu.Lock()
+	active := len(s.jobMap)
+	s.mu.Unlock()
+	logging.Infof("Scheduler started with %d active jobs", active)
 
 	return nil
 }
 
 // Stop stops the scheduler
 func (s *Scheduler) Stop() {
-	log.Println("Stopping backup scheduler...")
+	logging.Infof("Stopping backup scheduler...")
+	s.stopFreshnessChecker()
 	s.cron.Stop()
 }
 
-// AddJob adds a new backup job to the scheduler
+// SetMaintenance switches maintenance mode on or off. Jobs stay registered
+// so leaving maintenance resumes the normal schedule immediately.
+func (s *Scheduler) SetMaintenance(enabled bool) {
+	if s.maintenance.Swap(enabled) != enabled {
+		if enabled {
+			logging.Infof("Maintenance mode enabled: scheduled backups are paused")
+		} else {
+			logging.Infof("Maintenance mode disabled: scheduled backups resumed")
+		}
+	}
+}
+
+// InMaintenance reports whether maintenance mode is on.
+func (s *Scheduler) InMaintenance() bool {
+	return s.maintenance.Load()
+}
+
+// AddJob adds a new backup job to the scheduler, replacing any existing job
+// for the same database.
 func (s *Scheduler) AddJob(config *models.DatabaseConfig) error {
-	// Remove existing job if any
-	s.RemoveJob(config.ID)
+	s.mu.Lock()
+	err := s.replaceJobLocked(config)
+	s.mu.Unlock()
+	s.recordJobResult(config, err)
+	return err
+}
+
+// RemoveJob removes a backup job from the scheduler. A backup that is
+// already running is not cancelled.
+func (s *Scheduler) RemoveJob(dbID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeJobLocked(dbID)
+}
+
+// UpdateJob atomically swaps the job for config.ID. An in-flight run keeps
+// the config snapshot it was triggered with; the new settings apply from the
+// next trigger.
+func (s *Scheduler) UpdateJob(config *models.DatabaseConfig) error {
+	s.mu.Lock()
+	err := s.replaceJobLocked(config)
+	s.mu.Unlock()
+	s.recordJobResult(config, err)
+	return err
+}
+
+// recordJobResult stores a failure to schedule config as its scheduler
+// error, or clears an earlier one once scheduling succeeds. config is
+// updated to match so the caller's response shows it.
+func (s *Scheduler) recordJobResult(config *models.DatabaseConfig, err error) {
+	if err == nil {
+		if config.SchedulerError == nil {
+			return
+		}
+		config.SchedulerError = nil
+		config.SchedulerErrorAt = nil
+		s.recordJobError(config.ID, "")
+		return
+	}
+	msg := fmt.Sprintf("failed to schedule backups: %v", err)
+	now := time.Now()
+	config.SchedulerError = &msg
+	config.SchedulerErrorAt = &now
+	s.recordJobError(config.ID, msg)
+}
+
+// recordJobError stores msg as dbID's scheduler error; "" clears it.
+func (s *Scheduler) recordJobError(dbID uuid.UUID, msg string) {
+	if err := s.setJobError(dbID, msg); err != nil {
+		logging.Errorf("Failed to record scheduler error for database %s: %v", dbID, err)
+	}
+}
+
+// replaceJobLocked removes any job for config.ID and schedules a new one
+// from a snapshot of config. Caller must hold s.mu.
+func (s *Scheduler) replaceJobLocked(config *models.DatabaseConfig) error {
+	s.removeJobLocked(config.ID)
 
 	if !config.Enabled || config.Paused {
 		return nil
 	}
+	if config.SnoozedUntil != nil {
+		s.snoozed[config.ID] = *config.SnoozedUntil
+	}
 
-	// Capture config in closure
-	dbConfig := config
+	// Snapshot the config so later mutations of the caller's struct (the
+	// handlers reuse it after calling us) never leak into a run.
+	snapshot := snapshotConfig(config)
 
-	entryID, err := s.cron.AddFunc(config.Schedule, func() {
-		runJobWithRecover(dbConfig.Name, func() error {
-			log.Printf("Executing scheduled backup for: %s", dbConfig.Name)
-			return s.backupSvc.ExecuteBackup(dbConfig)
-		})
+	// entryID is read by the callback under s.mu, which we hold until it
+	// is set below.
+	var entryID cron.EntryID
+	var err error
+	entryID, err = s.cron.AddFunc(snapshot.Schedule, func() {
+		// Each trigger gets its own copy: the backup service may write to
+		// the config it is handed (e.g. the detected server version).
+		dbConfig := snapshotConfig(snapshot)
+		if delay, window := s.startDelay(dbConfig); delay > 0 {
+			logging.Infof("Delaying scheduled backup for %s by %s (jitter up to %s)", dbConfig.Name, delay.Round(time.Second), window)
+			s.sleep(delay)
+			if !s.stillScheduled(dbConfig.ID, entryID) {
+				logging.Infof("Skipping delayed backup for %s: its schedule changed during the jitter delay", dbConfig.Name)
+				return
+			}
+		}
+		if s.InMaintenance() {
+			logging.Infof("Skipping scheduled backup for %s: maintenance mode is enabled", dbConfig.Name)
+			return
+		}
+		if until, ok := s.snoozedAt(dbConfig.ID, time.Now()); ok {
+			logging.Infof("Skipping scheduled backup for %s: snoozed until %s", dbConfig.Name, until.Format(time.RFC3339))
+			return
+		}
+		s.runTracked(dbConfig)
 	})
-
 	if err != nil {
 		return err
 	}
 
-	s.mu.Lock()
 	s.jobMap[config.ID] = entryID
-	s.mu.Unlock()
-	log.Printf("Scheduled backup for %s with cron: %s", config.Name, config.Schedule)
+	logging.Debugf("Scheduled backup for %s with cron: %s", config.Name, config.Schedule)
 
 	return nil
 }
 
-// RemoveJob removes a backup job from the scheduler
-func (s *Scheduler) RemoveJob(dbID uuid.UUID) {
-	s.mu.Lock()
+// removeJobLocked drops the cron entry for dbID. Caller must hold s.mu.
+func (s *Scheduler) removeJobLocked(dbID uuid.UUID) {
+	delete(s.snoozed, dbID)
 	entryID, exists := s.jobMap[dbID]
-	if exists {
-		delete(s.jobMap, dbID)
+	if !exists {
+		return
+	}
+	delete(s.jobMap, dbID)
+	s.cron.Remove(entryID)
+	logging.Debugf("Removed backup job for database ID: %s", dbID)
+}
+
+// snoozedAt reports whether scheduled runs of dbID are snoozed at now and
+// until when. A snooze that has run out is forgotten and cleared from the
+// stored config.
+func (s *Scheduler) snoozedAt(dbID uuid.UUID, now time.Time) (time.Time, bool) {
+	s.mu.Lock()
+	until, ok := s.snoozed[dbID]
+	expired := ok && !now.Before(until)
+	if expired {
+		delete(s.snoozed, dbID)
 	}
 	s.mu.Unlock()
-	if exists {
-		s.cron.Remove(entryID)
-		log.Printf("Removed backup job for database ID: %s", dbID)
+
+	if expired {
+		if err := s.clearSnooze(dbID); err != nil {
+			logging.Errorf("Failed to clear expired snooze for database %s: %v", dbID, err)
+		}
+		return until, false
 	}
+	return until, ok
 }
 
-// UpdateJob updates an existing backup job
-func (s *Scheduler) UpdateJob(config *models.DatabaseConfig) error {
-	s.RemoveJob(config.ID)
-	return s.AddJob(config)
+// snapshotConfig returns a copy of config that shares no mutable state with
+// the original.
+func snapshotConfig(config *models.DatabaseConfig) *models.DatabaseConfig {
+	c := *config
+	if config.NotificationID != nil {
+		id := *config.NotificationID
+		c.NotificationID = &id
+	}
+	if config.VersionLastChecked != nil {
+		t := *config.VersionLastChecked
+		c.VersionLastChecked = &t
+	}
+	if config.TriggerTokenHash != nil {
+		h := *config.TriggerTokenHash
+		c.TriggerTokenHash = &h
+	}
+	if config.MaxSizeBytes != nil {
+		n := *config.MaxSizeBytes
+		c.MaxSizeBytes = &n
+	}
+	if config.SnoozedUntil != nil {
+		t := *config.SnoozedUntil
+		c.SnoozedUntil = &t
+	}
+	if config.JitterMinutes != nil {
+		n := *config.JitterMinutes
+		c.JitterMinutes = &n
+	}
+	if config.Blobs != nil {
+		b := *config.Blobs
+		c.Blobs = &b
+	}
+	if config.SchedulerError != nil {
+		msg := *config.SchedulerError
+		c.SchedulerError = &msg
+	}
+	if config.SchedulerErrorAt != nil {
+		t := *config.SchedulerErrorAt
+		c.SchedulerErrorAt = &t
+	}
+	if config.Notification != nil {
+		n := *config.Notification
+		c.Notification = &n
+	}
+	c.Labels = append([]models.Label(nil), config.Labels...)
+	c.ExtraArgs = append(config.ExtraArgs[:0:0], config.ExtraArgs...)
+	if config.PgEnv != nil {
+		c.PgEnv = make(map[string]string, len(config.PgEnv))
+		for k, v := range config.PgEnv {
+			c.PgEnv[k] = v
+		}
+	}
+	return &c
 }
 
 // runJobWithRecover runs fn and contains any panic so the calling cron
 // goroutine survives. Without this, a panic in user-supplied backup logic
-// would kill the cron runner and silently stop ALL scheduled jobs.
-func runJobWithRecover(name string, fn func() error) {
+// would kill the cron runner and silently stop ALL scheduled jobs. It
+// reports whether fn panicked.
+func runJobWithRecover(name string, fn func() error) (panicked error) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("PANIC in scheduled job %q: %v\n%s", name, r, debug.Stack())
+			logging.Errorf("PANIC in scheduled job %q: %v\n%s", name, r, debug.Stack())
+			panicked = fmt.Errorf("scheduled run crashed: %v", r)
 		}
 	}()
 
 	if err := fn(); err != nil {
-		log.Printf("Scheduled job %q failed: %v", name, err)
+		logging.Errorf("Scheduled job %q failed: %v", name, err)
 	}
+	return nil
 }
@@ -2,7 +2,14 @@ package scheduler
 
 import (
 	"errors"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/validator"
+	"github.com/robfig/cron/v3"
 )
 
 // TestRunJobWithRecover_PanicContained ensures that a panic inside the job
@@ -52,3 +59,527 @@ func TestRunJobWithRecover_Success(t *testing.T) {
 		t.Fatal("job function was not invoked")
 	}
 }
+
+// newTestScheduler returns a scheduler whose backups are handled by run
+// instead of the real backup service. The cron runner is never started;
+// tests fire entries by hand.
+func newTestScheduler(run func(*models.DatabaseConfig) error) *Scheduler {
+	return &Scheduler{
+		cron:          cron.New(),
+		parser:        validator.CronParser(false),
+		jobMap:        make(map[uuid.UUID]cron.EntryID),
+		runBackup:     run,
+		snoozed:       make(map[uuid.UUID]time.Time),
+		clearSnooze:   func(uuid.UUID) error { return nil },
+		setJobError:   func(uuid.UUID, string) error { return nil },
+		listScheduled: func() ([]*models.DatabaseConfig, error) { return nil, nil },
+		alertOverdue:  func(*models.DatabaseConfig, time.Time) {},
+		fresh:         freshness{alerted: make(map[uuid.UUID]time.Time)},
+		sleep:         func(time.Duration) {},
+	}
+}
+
+// TestUpdateJob_InFlightRunKeepsSnapshot updates a config while a fake
+// backup is running and checks the run keeps the settings it was triggered
+// with, the swap leaves exactly one cron entry, and nothing panics.
+func TestUpdateJob_InFlightRunKeepsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	seen := make(chan models.DatabaseConfig, 2)
+	s := newTestScheduler(func(cfg *models.DatabaseConfig) error {
+		started <- struct{}{}
+		<-release
+		seen <- *cfg
+		return nil
+	})
+
+	notif := uuid.New()
+	wantNotif := notif
+	cfg := &models.DatabaseConfig{
+		ID: uuid.New(), Name: "orders", Schedule: "0 2 * * *",
+		Enabled: true, NotificationID: &notif,
+	}
+	if err := s.AddJob(cfg); err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+
+	s.mu.Lock()
+	oldEntry := s.cron.Entry(s.jobMap[cfg.ID])
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		oldEntry.WrappedJob.Run()
+		close(done)
+	}()
+	<-started
+
+	// Mutate the caller's struct the way UpdateDatabaseConfig does, then swap.
+	cfg.Name = "orders-renamed"
+	cfg.Schedule = "*/5 * * * *"
+	*cfg.NotificationID = uuid.New()
+	if err := s.UpdateJob(cfg); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+
+	close(release)
+	<-done
+
+	got := <-seen
+	if got.Name != "orders" || got.Schedule != "0 2 * * *" {
+		t.Errorf("in-flight run saw updated config: name=%q schedule=%q", got.Name, got.Schedule)
+	}
+	if *got.NotificationID != wantNotif {
+		t.Errorf("in-flight run saw mutated NotificationID")
+	}
+
+	if n := len(s.cron.Entries()); n != 1 {
+		t.Fatalf("cron has %d entries after update, want 1", n)
+	}
+	s.mu.Lock()
+	newEntry := s.cron.Entry(s.jobMap[cfg.ID])
+	s.mu.Unlock()
+	if newEntry.ID == oldEntry.ID {
+		t.Fatal("UpdateJob did not replace the cron entry")
+	}
+
+	// The next trigger uses the new settings.
+	go newEntry.WrappedJob.Run()
+	<-started
+	next := <-seen
+	if next.Name != "orders-renamed" {
+		t.Errorf("next run used name %q, want orders-renamed", next.Name)
+	}
+}
+
+// TestUpdateJob_ConcurrentUpdatesLeaveOneEntry hammers UpdateJob from many
+// goroutines; the atomic swap must leave exactly one cron entry.
+func TestUpdateJob_ConcurrentUpdatesLeaveOneEntry(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler(func(*models.DatabaseConfig) error { return nil })
+	id := uuid.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg := &models.DatabaseConfig{ID: id, Name: "db", Schedule: "0 2 * * *", Enabled: true}
+			if i%2 == 0 {
+				cfg.Schedule = "0 3 * * *"
+			}
+			if err := s.UpdateJob(cfg); err != nil {
+				t.Errorf("UpdateJob: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(s.cron.Entries()); n != 1 {
+		t.Fatalf("cron has %d entries, want 1", n)
+	}
+}
+
+// TestUpdateJob_PausedRemovesEntry checks that updating to a paused config
+// unschedules the database.
+func TestUpdateJob_PausedRemovesEntry(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler(func(*models.DatabaseConfig) error { return nil })
+	cfg := &models.DatabaseConfig{ID: uuid.New(), Name: "db", Schedule: "0 2 * * *", Enabled: true}
+	if err := s.AddJob(cfg); err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	cfg.Paused = true
+	if err := s.UpdateJob(cfg); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	if n := len(s.cron.Entries()); n != 0 {
+		t.Fatalf("cron has %d entries, want 0", n)
+	}
+}
+
+// TestMaintenance_SkipsRunsAndKeepsJobs checks that maintenance mode turns
+// triggers into no-ops without dropping the cron entry, and that runs
+// resume once it is switched off.
+func TestMaintenance_SkipsRunsAndKeepsJobs(t *testing.T) {
+	t.Parallel()
+
+	runs := 0
+	s := newTestScheduler(func(*models.DatabaseConfig) error {
+		runs++
+		return nil
+	})
+	cfg := &models.DatabaseConfig{ID: uuid.New(), Name: "orders", Schedule: "0 2 * * *", Enabled: true}
+	if err := s.AddJob(cfg); err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+
+	s.SetMaintenance(true)
+	s.cron.Entry(s.jobMap[cfg.ID]).WrappedJob.Run()
+	if runs != 0 {
+		t.Fatalf("backup ran %d times during maintenance, want 0", runs)
+	}
+	if n := len(s.cron.Entries()); n != 1 {
+		t.Fatalf("cron has %d entries during maintenance, want 1", n)
+	}
+
+	s.SetMaintenance(false)
+	s.cron.Entry(s.jobMap[cfg.ID]).WrappedJob.Run()
+	if runs != 1 {
+		t.Fatalf("backup ran %d times after maintenance, want 1", runs)
+	}
+}
+
+// TestSnooze_SkipsRunsUntilExpiry checks that a snoozed database skips its
+// scheduled runs, and that the first trigger after the snooze ends runs the
+// backup and clears the stored snooze.
+func TestSnooze_SkipsRunsUntilExpiry(t *testing.T) {
+	t.Parallel()
+
+	runs := 0
+	s := newTestScheduler(func(*models.DatabaseConfig) error {
+		runs++
+		return nil
+	})
+	var cleared uuid.UUID
+	s.clearSnooze = func(id uuid.UUID) error {
+		cleared = id
+		return nil
+	}
+	until := time.Now().Add(time.Hour)
+	cfg := &models.DatabaseConfig{ID: uuid.New(), Name: "orders", Schedule: "0 2 * * *", Enabled: true, SnoozedUntil: &until}
+	if err := s.AddJob(cfg); err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+
+	s.cron.Entry(s.jobMap[cfg.ID]).WrappedJob.Run()
+	if runs != 0 {
+		t.Fatalf("backup ran %d times while snoozed, want 0", runs)
+	}
+
+	s.mu.Lock()
+	s.snoozed[cfg.ID] = time.Now().Add(-time.Minute)
+	s.mu.Unlock()
+	s.cron.Entry(s.jobMap[cfg.ID]).WrappedJob.Run()
+	if runs != 1 {
+		t.Fatalf("backup ran %d times after snooze ended, want 1", runs)
+	}
+	if cleared != cfg.ID {
+		t.Fatalf("expired snooze not cleared from the stored config")
+	}
+}
+
+// TestJitter_DelaysWithinWindow checks a scheduled run waits a random delay
+// inside the database's window (overriding the default), that jitter 0
+// disables the default, and that a run whose job was replaced during the
+// delay is dropped.
+func TestJitter_DelaysWithinWindow(t *testing.T) {
+	t.Parallel()
+
+	runs := 0
+	s := newTestScheduler(func(*models.DatabaseConfig) error {
+		runs++
+		return nil
+	})
+	s.SetJitter(time.Hour)
+	var delays []time.Duration
+	var onSleep func()
+	s.sleep = func(d time.Duration) {
+		delays = append(delays, d)
+		if onSleep != nil {
+			onSleep()
+		}
+	}
+
+	ten, zero := 10, 0
+	cfg := &models.DatabaseConfig{ID: uuid.New(), Name: "orders", Schedule: "0 2 * * *", Enabled: true, JitterMinutes: &ten}
+	if err := s.AddJob(cfg); err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		s.cron.Entry(s.jobMap[cfg.ID]).WrappedJob.Run()
+	}
+	if runs != 20 {
+		t.Fatalf("backup ran %d times, want 20", runs)
+	}
+	for _, d := range delays {
+		if d < 0 || d >= 10*time.Minute {
+			t.Fatalf("delay %s outside [0, 10m)", d)
+		}
+	}
+
+	// A replace during the delay drops the waiting run.
+	entry := s.cron.Entry(s.jobMap[cfg.ID])
+	onSleep = func() {
+		if err := s.UpdateJob(cfg); err != nil {
+			t.Errorf("UpdateJob: %v", err)
+		}
+	}
+	entry.WrappedJob.Run()
+	if runs != 20 {
+		t.Fatalf("run replaced during its delay still ran")
+	}
+	onSleep = nil
+
+	// jitter_minutes 0 opts out of the one-hour default.
+	delays = nil
+	cfg.JitterMinutes = &zero
+	if err := s.UpdateJob(cfg); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	s.cron.Entry(s.jobMap[cfg.ID]).WrappedJob.Run()
+	if len(delays) != 0 || runs != 21 {
+		t.Fatalf("jitter 0: delays %v, runs %d; want no delay and a run", delays, runs)
+	}
+}
+
+// TestStatus_CountsQueuedAndRunning fires more triggers than the
+// concurrency limit allows and checks the extra run is reported as queued,
+// then that finished runs land in the last-hour counts.
+func TestStatus_CountsQueuedAndRunning(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	s := newTestScheduler(func(cfg *models.DatabaseConfig) error {
+		started <- struct{}{}
+		<-release
+		if cfg.Name == "broken" {
+			return errors.New("pg_dump failed")
+		}
+		return nil
+	})
+	s.SetMaxConcurrent(2)
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "broken"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			s.runTracked(&models.DatabaseConfig{Name: name})
+		}(name)
+	}
+	<-started
+	<-started
+	waitFor(t, func() bool { return s.Status().Queued == 1 })
+
+	st := s.Status()
+	if st.Running != 2 || st.MaxConcurrent != 2 {
+		t.Fatalf("status = %+v, want 2 running of max 2", st)
+	}
+
+	close(release)
+	wg.Wait()
+
+	st = s.Status()
+	if st.Running != 0 || st.Queued != 0 {
+		t.Fatalf("status after runs = %+v, want nothing in flight", st)
+	}
+	if st.CompletedLastHour != 2 || st.FailedLastHour != 1 {
+		t.Fatalf("status after runs = %+v, want 2 completed and 1 failed", st)
+	}
+}
+
+// TestRunQueued_SharesLimitAndCounters checks a manual run takes a slot
+// from the scheduled ones and is counted in Status like them.
+func TestRunQueued_SharesLimitAndCounters(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	s := newTestScheduler(func(*models.DatabaseConfig) error {
+		<-release
+		return nil
+	})
+	s.SetMaxConcurrent(1)
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunQueued(func() error {
+			close(started)
+			<-release
+			return errors.New("pg_dump failed")
+		})
+	}()
+	<-started
+	if st := s.Status(); st.Running != 1 {
+		t.Fatalf("status = %+v, want the manual run counted as running", st)
+	}
+
+	go s.runTracked(&models.DatabaseConfig{Name: "scheduled"})
+	waitFor(t, func() bool { return s.Status().Queued == 1 })
+
+	close(release)
+	if err := <-done; err == nil {
+		t.Fatal("RunQueued dropped the run's error")
+	}
+	waitFor(t, func() bool { st := s.Status(); return st.Running == 0 && st.Queued == 0 })
+	if st := s.Status(); st.CompletedLastHour != 1 || st.FailedLastHour != 1 {
+		t.Fatalf("status = %+v, want 1 completed and 1 failed", st)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestOverdue checks the freshness rule against an hourly schedule with the
+// default one-hour grace.
+func TestOverdue(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler(func(*models.DatabaseConfig) error { return nil })
+	created := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.UTC) }
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name        string
+		lastSuccess *time.Time
+		snoozed     *time.Time
+		paused      bool
+		now         time.Time
+		want        bool
+	}{
+		{"never backed up, within grace", nil, nil, false, at(1, 59), false},
+		{"never backed up, past grace", nil, nil, false, at(2, 1), true},
+		{"recent success", ptr(at(3, 0)), nil, false, at(4, 30), false},
+		{"success stopped", ptr(at(3, 0)), nil, false, at(5, 1), true},
+		{"paused", ptr(at(3, 0)), nil, true, at(9, 0), false},
+		{"snoozed", ptr(at(3, 0)), ptr(at(10, 0)), false, at(9, 0), false},
+		{"snooze just ended", ptr(at(3, 0)), ptr(at(8, 0)), false, at(9, 30), false},
+	}
+	for _, tt := range tests {
+		config := &models.DatabaseConfig{
+			Name:          tt.name,
+			Schedule:      "0 * * * *",
+			Enabled:       true,
+			Paused:        tt.paused,
+			CreatedAt:     created,
+			LastSuccessAt: tt.lastSuccess,
+			SnoozedUntil:  tt.snoozed,
+		}
+		if _, got := s.Overdue(config, tt.now); got != tt.want {
+			t.Errorf("%s: Overdue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCheckFreshness_AlertsOncePerMissedRun checks that an overdue database
+// is alerted once, counted in Status, held back during maintenance, and
+// alerted again only for a new missed run.
+func TestCheckFreshness_AlertsOncePerMissedRun(t *testing.T) {
+	t.Parallel()
+
+	s := newTestScheduler(func(*models.DatabaseConfig) error { return nil })
+	created := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC)
+	config := &models.DatabaseConfig{
+		ID:        uuid.New(),
+		Name:      "orders",
+		Schedule:  "0 * * * *",
+		Enabled:   true,
+		CreatedAt: created,
+	}
+	s.listScheduled = func() ([]*models.DatabaseConfig, error) {
+		return []*models.DatabaseConfig{config}, nil
+	}
+	var alerts []time.Time
+	s.alertOverdue = func(_ *models.DatabaseConfig, missed time.Time) {
+		alerts = append(alerts, missed)
+	}
+
+	s.SetMaintenance(true)
+	s.checkFreshness(created.Add(3 * time.Hour))
+	if len(alerts) != 0 {
+		t.Fatalf("alerted during maintenance: %v", alerts)
+	}
+	if got := s.Status().StaleDatabases; got != 1 {
+		t.Fatalf("StaleDatabases = %d, want 1", got)
+	}
+
+	s.SetMaintenance(false)
+	s.checkFreshness(created.Add(3 * time.Hour))
+	s.checkFreshness(created.Add(4 * time.Hour))
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+
+	// A success clears the flag; the next missed run alerts again.
+	success := created.Add(5 * time.Hour)
+	config.LastSuccessAt = &success
+	s.checkFreshness(success)
+	if got := s.Status().StaleDatabases; got != 0 {
+		t.Fatalf("StaleDatabases after success = %d, want 0", got)
+	}
+	s.checkFreshness(success.Add(3 * time.Hour))
+	if len(alerts) != 2 || !alerts[1].After(alerts[0]) {
+		t.Fatalf("alerts = %v, want a second, later missed run", alerts)
+	}
+}
+
+// TestSchedulerError_RecordedAndCleared checks that a rejected schedule is
+// stored as the database's scheduler error, a crashed run replaces it, and
+// a successful scheduled run clears it.
+func TestSchedulerError_RecordedAndCleared(t *testing.T) {
+	t.Parallel()
+
+	var panicRun bool
+	s := newTestScheduler(func(*models.DatabaseConfig) error {
+		if panicRun {
+			panic("boom")
+		}
+		return nil
+	})
+	var mu sync.Mutex
+	stored := map[uuid.UUID]string{}
+	s.setJobError = func(id uuid.UUID, msg string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		stored[id] = msg
+		return nil
+	}
+	get := func(id uuid.UUID) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return stored[id]
+	}
+
+	cfg := &models.DatabaseConfig{ID: uuid.New(), Name: "orders", Schedule: "0 2 * *", Enabled: true}
+	if err := s.AddJob(cfg); err == nil {
+		t.Fatal("AddJob accepted an invalid schedule")
+	}
+	if get(cfg.ID) == "" || cfg.SchedulerError == nil || *cfg.SchedulerError != get(cfg.ID) {
+		t.Fatalf("scheduler error not recorded: stored %q, config %v", get(cfg.ID), cfg.SchedulerError)
+	}
+
+	cfg.Schedule = "0 2 * * *"
+	if err := s.UpdateJob(cfg); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	if get(cfg.ID) != "" || cfg.SchedulerError != nil || cfg.SchedulerErrorAt != nil {
+		t.Fatalf("scheduler error not cleared after rescheduling: stored %q", get(cfg.ID))
+	}
+
+	panicRun = true
+	s.runTracked(cfg)
+	if got := get(cfg.ID); got == "" {
+		t.Fatal("crashed run did not record a scheduler error")
+	}
+
+	panicRun = false
+	s.runTracked(cfg)
+	if got := get(cfg.ID); got != "" {
+		t.Fatalf("successful run left scheduler error %q", got)
+	}
+}
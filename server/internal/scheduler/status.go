@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/logging"
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// recentWindow is how far back CompletedLastHour/FailedLastHour look.
+const recentWindow = time.Hour
+
+// recentRuns keeps the finish times of scheduled runs inside recentWindow.
+type recentRuns struct {
+	mu        sync.Mutex
+	completed []time.Time
+	failed    []time.Time
+}
+
+func (r *recentRuns) record(at time.Time, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if failed {
+		r.failed = append(pruneBefore(r.failed, at.Add(-recentWindow)), at)
+	} else {
+		r.completed = append(pruneBefore(r.completed, at.Add(-recentWindow)), at)
+	}
+}
+
+func (r *recentRuns) counts(now time.Time) (completed, failed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed = pruneBefore(r.completed, now.Add(-recentWindow))
+	r.failed = pruneBefore(r.failed, now.Add(-recentWindow))
+	return len(r.completed), len(r.failed)
+}
+
+// pruneBefore drops the leading entries older than cutoff; times are
+// appended in order so the slice stays sorted.
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// SetMaxConcurrent limits how many scheduled backups run at once; extra
+// triggers wait their turn. n <= 0 means unlimited. Call before Start.
+func (s *Scheduler) SetMaxConcurrent(n int) {
+	s.maxConcurrent = 0
+	s.slots = nil
+	if n > 0 {
+		s.maxConcurrent = n
+		s.slots = make(chan struct{}, n)
+	}
+}
+
+// acquireSlot waits for a free backup slot under the SetMaxConcurrent limit
+// and returns the function that frees it; without a limit it returns at once.
+func (s *Scheduler) acquireSlot() (release func()) {
+	if s.slots == nil {
+		return func() {}
+	}
+	s.queued.Add(1)
+	s.slots <- struct{}{}
+	s.queued.Add(-1)
+	return func() { <-s.slots }
+}
+
+// RunQueued runs a manual backup under the SetMaxConcurrent limit, waiting
+// for a free slot first. Bulk manual runs use it so they share the limit
+// with scheduled backups and show up in Status the same way.
+func (s *Scheduler) RunQueued(run func() error) error {
+	var err error
+	s.track(func() bool {
+		err = run()
+		return err != nil
+	})
+	return err
+}
+
+// runTracked runs one scheduled backup, waiting for a free slot first and
+// keeping the live counters Status reports.
+func (s *Scheduler) runTracked(dbConfig *models.DatabaseConfig) {
+	var panicked error
+	failed := true
+	s.track(func() bool {
+		panicked = runJobWithRecover(dbConfig.Name, func() error {
+			logging.Infof("Executing scheduled backup for: %s", dbConfig.Name)
+			err := s.runBackup(dbConfig)
+			failed = err != nil
+			return err
+		})
+		return failed
+	})
+	// A failed backup is reported on its own record; only runs that never
+	// got that far are scheduler errors.
+	switch {
+	case panicked != nil:
+		s.recordJobError(dbConfig.ID, panicked.Error())
+	case !failed:
+		s.recordJobError(dbConfig.ID, "")
+	}
+}
+
+// track holds a backup slot while run executes and keeps the running and
+// last-hour counters; run reports whether the backup failed.
+func (s *Scheduler) track(run func() (failed bool)) {
+	defer s.acquireSlot()()
+
+	s.running.Add(1)
+	failed := true
+	defer func() {
+		s.running.Add(-1)
+		s.recent.record(time.Now(), failed)
+	}()
+
+	failed = run()
+}
+
+// Status reports live scheduler counts without touching the database.
+func (s *Scheduler) Status() models.SchedulerStatus {
+	s.mu.Lock()
+	jobs := len(s.jobMap)
+	s.mu.Unlock()
+	completed, failed := s.recent.counts(time.Now())
+	return models.SchedulerStatus{
+		ScheduledJobs:     jobs,
+		Queued:            int(s.queued.Load()),
+		Running:           int(s.running.Load()),
+		MaxConcurrent:     s.maxConcurrent,
+		CompletedLastHour: completed,
+		FailedLastHour:    failed,
+		Maintenance:       s.InMaintenance(),
+		StaleDatabases:    s.staleCount(),
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultStallTimeout is how long a storage connection may go without
+// moving a byte before the request fails.
+const DefaultStallTimeout = 2 * time.Minute
+
+// HTTPOptions tune the HTTP client used for storage requests.
+type HTTPOptions struct {
+	// StallTimeout fails a request once no data has been sent or received
+	// for this long. It bounds stuck connections without capping how long
+	// a large, steadily moving transfer may take. 0 disables it.
+	StallTimeout time.Duration
+	// ProxyURL routes every storage request through this proxy. Nil uses
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY from the environment.
+	ProxyURL *url.URL
+}
+
+var (
+	httpMu     sync.RWMutex
+	httpClient = newHTTPClient(HTTPOptions{StallTimeout: DefaultStallTimeout})
+)
+
+// SetHTTPOptions replaces the HTTP client used by storage clients created
+// afterwards.
+func SetHTTPOptions(opts HTTPOptions) error {
+	if opts.StallTimeout < 0 {
+		return fmt.Errorf("stall timeout must not be negative")
+	}
+	if opts.ProxyURL != nil && (opts.ProxyURL.Scheme == "" || opts.ProxyURL.Host == "") {
+		return fmt.Errorf("invalid proxy URL %q", opts.ProxyURL.Redacted())
+	}
+	client := newHTTPClient(opts)
+	httpMu.Lock()
+	defer httpMu.Unlock()
+	httpClient = client
+	return nil
+}
+
+func currentHTTPClient() *http.Client {
+	httpMu.RLock()
+	defer httpMu.RUnlock()
+	return httpClient
+}
+
+func newHTTPClient(opts HTTPOptions) *http.Client {
+	proxy := http.ProxyFromEnvironment
+	if opts.ProxyURL != nil {
+		proxy = http.ProxyURL(opts.ProxyURL)
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil || opts.StallTimeout <= 0 {
+				return conn, err
+			}
+			return &stallConn{Conn: conn, timeout: opts.StallTimeout}, nil
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: opts.StallTimeout,
+	}
+	return &http.Client{Transport: transport}
+}
+
+// stallConn pushes its deadline forward on every read and write, so it
+// only expires when the connection stops moving data in both directions.
+// Writes extend reads too: the transport keeps a read pending while a
+// large part is still being sent. The proxy tunnel and TLS run on top of
+// it, so they are covered as well.
+type stallConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *stallConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *stallConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
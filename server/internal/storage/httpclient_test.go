@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// stallingServer accepts requests and never answers until the client goes
+// away, like a storage endpoint behind a dead link.
+func stallingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStallTimeout_FailsStuckRequest(t *testing.T) {
+	srv := stallingServer(t)
+	client := newHTTPClient(HTTPOptions{StallTimeout: 200 * time.Millisecond})
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a stalled endpoint succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("stalled request took %s to fail", elapsed)
+	}
+}
+
+func TestStallTimeout_AllowsSlowSteadyTransfer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		for i := 0; i < 8; i++ {
+			w.Write([]byte("x"))
+			flusher.Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	// The whole body takes longer than the stall timeout, but bytes keep
+	// arriving well within it.
+	client := newHTTPClient(HTTPOptions{StallTimeout: 400 * time.Millisecond})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || len(body) != 8 {
+		t.Fatalf("read %d bytes, %v; want 8", len(body), err)
+	}
+}
+
+func TestUploadFileWithProgress_ContextCancelAborts(t *testing.T) {
+	srv := stallingServer(t)
+	if err := SetHTTPOptions(HTTPOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetHTTPOptions(HTTPOptions{StallTimeout: DefaultStallTimeout}) })
+
+	client, err := NewStorageClient(&models.StorageConfig{
+		Provider:  models.StorageProviderS3,
+		Bucket:    "backups",
+		Region:    "us-east-1",
+		Endpoint:  srv.URL,
+		AccessKey: "key",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewStorageClient: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(path, []byte("SELECT 1;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := client.UploadFileWithProgress(ctx, path, "db/dump.sql", nil, nil); err == nil {
+		t.Fatal("upload to a stalled endpoint succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("cancelled upload took %s to return", elapsed)
+	}
+}
+
+func TestSetHTTPOptions_RejectsInvalid(t *testing.T) {
+	if err := SetHTTPOptions(HTTPOptions{StallTimeout: -time.Second}); err == nil {
+		t.Error("negative stall timeout accepted")
+	}
+}
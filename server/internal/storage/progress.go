@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// ProgressFunc is told how many bytes of a transfer are done and the total
+// size. Multipart workers call it concurrently.
+type ProgressFunc func(done, total int64)
+
+// transferCount is the shared byte count of one transfer, kept between 0
+// and total.
+type transferCount struct {
+	total int64
+	done  atomic.Int64
+	fn    ProgressFunc
+}
+
+func (c *transferCount) add(n int64) {
+	if n == 0 {
+		return
+	}
+	done := c.done.Add(n)
+	switch {
+	case done < 0:
+		done = 0
+	case c.total > 0 && done > c.total:
+		done = c.total
+	}
+	c.fn(done, c.total)
+}
+
+// progressFile counts bytes the downloader writes to a file. It keeps
+// io.WriterAt so s3manager still downloads ranges in parallel.
+type progressFile struct {
+	*os.File
+	count *transferCount
+}
+
+func (p *progressFile) WriteAt(b []byte, off int64) (int, error) {
+	n, err := p.File.WriteAt(b, off)
+	p.count.add(int64(n))
+	return n, err
+}
+
+// countSent is an s3manager request option that counts each part's body
+// as the HTTP client sends it. Counting reads of the file instead would
+// report every byte twice: SigV4 reads the whole body to hash it before the
+// request goes out. A retried attempt takes back what the failed one
+// counted.
+func (c *transferCount) countSent(r *request.Request) {
+	var current *sentBody
+	r.Handlers.Send.PushFront(func(r *request.Request) {
+		if current != nil {
+			current.retract()
+		}
+		current = nil
+		if r.HTTPRequest.Body == nil || r.HTTPRequest.Body == http.NoBody {
+			return
+		}
+		current = &sentBody{ReadCloser: r.HTTPRequest.Body, count: c}
+		r.HTTPRequest.Body = current
+	})
+}
+
+// sentBody counts the bytes read from one attempt's request body.
+type sentBody struct {
+	io.ReadCloser
+	count   *transferCount
+	n       atomic.Int64
+	retired atomic.Bool
+}
+
+func (b *sentBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && !b.retired.Load() {
+		b.n.Add(int64(n))
+		b.count.add(int64(n))
+	}
+	return n, err
+}
+
+// retract stops counting this attempt and removes what it counted. The
+// transport may still be reading an abandoned body after a retry starts.
+func (b *sentBody) retract() {
+	b.retired.Store(true)
+	b.count.add(-b.n.Load())
+}
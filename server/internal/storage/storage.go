@@ -2,16 +2,22 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/monzim/db_proxy/v1/internal/models"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 )
 
 // Per-operation timeouts. Backups can be many GB, so the upload/download
@@ -34,22 +40,71 @@ type StorageClient struct {
 	uploader   *s3manager.Uploader
 	downloader *s3manager.Downloader
 	bucket     string
+	provider   models.StorageProvider
+	region     string
+	endpoint   string
 }
 
-// NewStorageClient creates a new storage client based on configuration
+// Object ACL values accepted by SetObjectACL.
+const (
+	ObjectACLPrivate                = s3.ObjectCannedACLPrivate
+	ObjectACLBucketOwnerFullControl = s3.ObjectCannedACLBucketOwnerFullControl
+	ObjectACLNone                   = "none"
+)
+
+// objectACL is the canned ACL set on every S3 upload. By default none is
+// sent: new buckets have ACLs disabled (Object Ownership
+// BucketOwnerEnforced) and reject uploads that carry one, and objects are
+// private unless a bucket policy says otherwise.
+var objectACL = ObjectACLNone
+
+// SetObjectACL chooses the canned ACL for uploads. "none" (or "") sends no
+// ACL. Set private or bucket-owner-full-control only for buckets that
+// still use ACLs. R2 has no ACLs and is never sent one.
+func SetObjectACL(acl string) error {
+	if acl == "" {
+		acl = ObjectACLNone
+	}
+	switch acl {
+	case ObjectACLPrivate, ObjectACLBucketOwnerFullControl, ObjectACLNone:
+		objectACL = acl
+		return nil
+	}
+	return fmt.Errorf("unsupported object ACL %q (want %s, %s or %s)",
+		acl, ObjectACLPrivate, ObjectACLBucketOwnerFullControl, ObjectACLNone)
+}
+
+// ErrRegionMismatch is returned by CheckRegion when the bucket lives in a
+// different region than its storage config says.
+var ErrRegionMismatch = errors.New("bucket region mismatch")
+
+// NewStorageClient creates a new storage client based on configuration.
+// ${VAR} references in the endpoint and region are resolved now, so one
+// config can follow each deployment's environment (see
+// utils.ExpandEnvRefs).
 func NewStorageClient(config *models.StorageConfig) (*StorageClient, error) {
+	region, err := utils.ExpandEnvRefs(config.Region)
+	if err != nil {
+		return nil, fmt.Errorf("storage region: %w", err)
+	}
+	endpoint, err := utils.ExpandEnvRefs(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("storage endpoint: %w", err)
+	}
+
 	awsConfig := &aws.Config{
 		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
+		HTTPClient:  currentHTTPClient(),
 	}
 
 	// Set region for S3
-	if config.Region != "" {
-		awsConfig.Region = aws.String(config.Region)
+	if region != "" {
+		awsConfig.Region = aws.String(region)
 	}
 
 	// Set custom endpoint for R2 or custom S3-compatible storage
-	if config.Endpoint != "" {
-		awsConfig.Endpoint = aws.String(config.Endpoint)
+	if endpoint != "" {
+		awsConfig.Endpoint = aws.String(endpoint)
 		awsConfig.S3ForcePathStyle = aws.Bool(true) // Required for R2 and some S3-compatible services
 	}
 
@@ -70,7 +125,10 @@ func NewStorageClient(config *models.StorageConfig) (*StorageClient, error) {
 			d.PartSize = multipartPartSize
 			d.Concurrency = multipartConcurrency
 		}),
-		bucket: config.Bucket,
+		bucket:   config.Bucket,
+		provider: config.Provider,
+		region:   region,
+		endpoint: endpoint,
 	}, nil
 }
 
@@ -79,26 +137,47 @@ func NewStorageClient(config *models.StorageConfig) (*StorageClient, error) {
 // Without the timeout a stuck connection could pin a backup goroutine
 // forever and exhaust the worker pool.
 func (sc *StorageClient) UploadFile(filePath, objectKey string, metadata map[string]string) error {
+	return sc.UploadFileWithProgress(context.Background(), filePath, objectKey, metadata, nil)
+}
+
+// UploadFileWithProgress is UploadFile that reports bytes sent to storage
+// to progress as the upload proceeds. A nil progress reports nothing.
+// Cancelling ctx aborts the in-flight requests.
+func (sc *StorageClient) UploadFileWithProgress(ctx context.Context, filePath, objectKey string, metadata map[string]string, progress ProgressFunc) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
+	var opts []func(*s3manager.Uploader)
+	if progress != nil {
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+		count := &transferCount{total: info.Size(), fn: progress}
+		opts = append(opts, s3manager.WithUploaderRequestOptions(count.countSent))
+	}
+
 	awsMetadata := make(map[string]*string, len(metadata))
 	for k, v := range metadata {
 		awsMetadata[k] = aws.String(v)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), storageUploadTimeout)
+	ctx, cancel := context.WithTimeout(ctx, storageUploadTimeout)
 	defer cancel()
 
-	_, err = sc.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+	input := &s3manager.UploadInput{
 		Bucket:   aws.String(sc.bucket),
 		Key:      aws.String(objectKey),
 		Body:     file,
 		Metadata: awsMetadata,
-	})
+	}
+	if sc.provider == models.StorageProviderS3 && objectACL != ObjectACLNone {
+		input.ACL = aws.String(objectACL)
+	}
+	_, err = sc.uploader.UploadWithContext(ctx, input, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
@@ -109,6 +188,13 @@ func (sc *StorageClient) UploadFile(filePath, objectKey string, metadata map[str
 // the s3manager downloader, which transparently parallelises range reads
 // for large objects.
 func (sc *StorageClient) DownloadFile(objectKey, destinationPath string) error {
+	return sc.DownloadFileWithProgress(objectKey, destinationPath, nil)
+}
+
+// DownloadFileWithProgress is DownloadFile that reports bytes written to
+// progress. The total comes from a HEAD request up front; a nil progress
+// skips it and reports nothing.
+func (sc *StorageClient) DownloadFileWithProgress(objectKey, destinationPath string, progress ProgressFunc) error {
 	file, err := os.Create(destinationPath)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
@@ -118,7 +204,19 @@ func (sc *StorageClient) DownloadFile(objectKey, destinationPath string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), storageDownloadTimeout)
 	defer cancel()
 
-	if _, err := sc.downloader.DownloadWithContext(ctx, file, &s3.GetObjectInput{
+	var dest io.WriterAt = file
+	if progress != nil {
+		head, err := sc.s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(sc.bucket),
+			Key:    aws.String(objectKey),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to head object: %w", err)
+		}
+		dest = &progressFile{File: file, count: &transferCount{total: aws.Int64Value(head.ContentLength), fn: progress}}
+	}
+
+	if _, err := sc.downloader.DownloadWithContext(ctx, dest, &s3.GetObjectInput{
 		Bucket: aws.String(sc.bucket),
 		Key:    aws.String(objectKey),
 	}); err != nil {
@@ -127,6 +225,45 @@ func (sc *StorageClient) DownloadFile(objectKey, destinationPath string) error {
 	return nil
 }
 
+// GetMetadata returns the user metadata stored on an object. Keys are
+// lower-cased so callers can look up the names they passed to UploadFile.
+func (sc *StorageClient) GetMetadata(objectKey string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageMetaTimeout)
+	defer cancel()
+
+	out, err := sc.s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(sc.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to head object: %w", err)
+	}
+	metadata := make(map[string]string, len(out.Metadata))
+	for k, v := range out.Metadata {
+		metadata[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return metadata, nil
+}
+
+// ObjectExists reports whether objectKey is already present in the bucket.
+func (sc *StorageClient) ObjectExists(ctx context.Context, objectKey string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, storageMetaTimeout)
+	defer cancel()
+
+	_, err := sc.s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(sc.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err == nil {
+		return true, nil
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to head object: %w", err)
+}
+
 // DeleteFile deletes a file from cloud storage
 func (sc *StorageClient) DeleteFile(objectKey string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), storageMetaTimeout)
@@ -157,9 +294,69 @@ func (sc *StorageClient) ListFiles(prefix string) ([]*s3.Object, error) {
 	return result.Contents, nil
 }
 
+// PrefixUsage counts the objects under prefix and sums their sizes, paging
+// through the whole listing. It can be slow on large buckets, so callers
+// bound it with ctx.
+func (sc *StorageClient) PrefixUsage(ctx context.Context, prefix string) (objects int64, totalBytes int64, err error) {
+	err = sc.s3Client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(sc.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			objects++
+			totalBytes += aws.Int64Value(obj.Size)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return objects, totalBytes, nil
+}
+
+// Ping checks that the bucket exists and the credentials can reach it.
+func (sc *StorageClient) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageMetaTimeout)
+	defer cancel()
+
+	_, err := sc.s3Client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(sc.bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	return nil
+}
+
+// CheckRegion looks up the bucket's actual region and compares it with the
+// configured one. A mismatch makes uploads fail with confusing redirect or
+// signature errors, so it is reported as ErrRegionMismatch. Only AWS S3 is
+// checked; R2 and custom endpoints have no meaningful region. The detected
+// region is returned when known.
+func (sc *StorageClient) CheckRegion() (string, error) {
+	if sc.provider != models.StorageProviderS3 || sc.endpoint != "" {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageMetaTimeout)
+	defer cancel()
+
+	actual, err := s3manager.GetBucketRegionWithClient(ctx, sc.s3Client, sc.bucket)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine bucket region: %w", err)
+	}
+	if sc.region != "" && !strings.EqualFold(actual, sc.region) {
+		return actual, fmt.Errorf("%w: bucket %q is in %s but the storage config says %s", ErrRegionMismatch, sc.bucket, actual, sc.region)
+	}
+	return actual, nil
+}
+
+// BackupKeyPrefix is the key prefix every backup object is stored under.
+const BackupKeyPrefix = "backups/"
+
 // GetObjectKey generates the S3 key for a backup file
 func GetObjectKey(configID, filename string) string {
-	return fmt.Sprintf("backups/%s/%s", configID, filename)
+	return fmt.Sprintf("%s%s/%s", BackupKeyPrefix, configID, filename)
 }
 
 // PresignDownload returns a time-limited GET URL the browser can hit
@@ -182,3 +379,33 @@ func (sc *StorageClient) PresignDownload(objectKey, downloadFilename string, ttl
 	}
 	return url, nil
 }
+
+// OpenObject streams an object's body. The caller closes the reader; ctx
+// bounds the whole transfer.
+func (sc *StorageClient) OpenObject(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	out, err := sc.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(sc.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return out.Body, nil
+}
+
+// OpenObjectWithSize is OpenObject that also returns the object's length,
+// for writers such as tar that need it before the body.
+func (sc *StorageClient) OpenObjectWithSize(ctx context.Context, objectKey string) (io.ReadCloser, int64, error) {
+	out, err := sc.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(sc.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open object: %w", err)
+	}
+	if out.ContentLength == nil {
+		out.Body.Close()
+		return nil, 0, fmt.Errorf("storage did not report the size of %s", objectKey)
+	}
+	return out.Body, *out.ContentLength, nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestUploadFile_ACLOnlyWhenConfigured checks that uploads carry no ACL by
+// default, so buckets with ACLs disabled accept them, and carry the
+// configured one otherwise.
+func TestUploadFile_ACLOnlyWhenConfigured(t *testing.T) {
+	var acl []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.Method == http.MethodPut {
+			acl = append(acl, r.Header.Get("X-Amz-Acl"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { SetObjectACL(ObjectACLNone) })
+
+	client, err := NewStorageClient(&models.StorageConfig{
+		Provider:  models.StorageProviderS3,
+		Bucket:    "backups",
+		Region:    "us-east-1",
+		Endpoint:  srv.URL,
+		AccessKey: "key",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewStorageClient: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	if err := os.WriteFile(path, []byte("SELECT 1;\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetObjectACL(""); err != nil {
+		t.Fatalf("SetObjectACL(\"\"): %v", err)
+	}
+	if err := client.UploadFile(path, "db/dump.sql", nil); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if err := SetObjectACL(ObjectACLPrivate); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UploadFile(path, "db/dump.sql", nil); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	if len(acl) != 2 || acl[0] != "" || acl[1] != ObjectACLPrivate {
+		t.Errorf("x-amz-acl headers = %q, want [\"\" %q]", acl, ObjectACLPrivate)
+	}
+}
+
+// TestUploadFileWithProgress_CountsSentBytes checks upload progress follows
+// the bytes sent, not the file reads: SigV4 hashes the whole body before
+// the request goes out, which used to report the upload as done before any
+// of it reached storage.
+func TestUploadFileWithProgress_CountsSentBytes(t *testing.T) {
+	// Below the multipart part size, and larger than loopback can buffer
+	// before the server starts reading.
+	const size = 14 << 20
+
+	var (
+		mu      sync.Mutex
+		done    int64
+		atStart int64 = -1
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		atStart = done
+		mu.Unlock()
+		io.Copy(io.Discard, r.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := NewStorageClient(&models.StorageConfig{
+		Provider:  models.StorageProviderS3,
+		Bucket:    "backups",
+		Region:    "us-east-1",
+		Endpoint:  srv.URL,
+		AccessKey: "key",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewStorageClient: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "dump.bak")
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.UploadFileWithProgress(context.Background(), path, "db/dump.bak", nil, func(d, total int64) {
+		if total != size {
+			t.Errorf("total = %d, want %d", total, size)
+		}
+		mu.Lock()
+		done = d
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("UploadFileWithProgress: %v", err)
+	}
+	if atStart < 0 || atStart >= size {
+		t.Errorf("progress when storage started reading = %d of %d, want the upload still in flight", atStart, size)
+	}
+	if done != size {
+		t.Errorf("final progress = %d, want %d", done, size)
+	}
+}
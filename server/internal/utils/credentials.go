@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// placeholderSecrets are values copied from docs and examples, compared
+// after lower-casing and dropping everything but letters and digits.
+var placeholderSecrets = map[string]bool{
+	"youraccesskey":                          true,
+	"yoursecretkey":                          true,
+	"yourpassword":                           true,
+	"securepassword":                         true,
+	"password":                               true,
+	"changeme":                               true,
+	"secret":                                 true,
+	"example":                                true,
+	"placeholder":                            true,
+	"accesskey":                              true,
+	"secretkey":                              true,
+	"akiaiosfodnn7example":                   true,
+	"wjalrxutnfemik7mdengbpxrficyexamplekey": true,
+}
+
+// CredentialWarnings returns human-readable warnings when value looks like
+// an example placeholder or is shorter than minLength (0 skips the length
+// check). They are advisory: real credentials can be short, so callers
+// report them rather than rejecting the input.
+func CredentialWarnings(field, value string, minLength int) []string {
+	if value == "" {
+		return nil
+	}
+	var warnings []string
+	if isPlaceholderSecret(value) {
+		warnings = append(warnings, fmt.Sprintf("%s looks like a placeholder from the examples; backups will fail until the real value is set", field))
+	}
+	if minLength > 0 && len(value) < minLength {
+		warnings = append(warnings, fmt.Sprintf("%s is shorter than %d characters", field, minLength))
+	}
+	return warnings
+}
+
+func isPlaceholderSecret(value string) bool {
+	v := strings.ToLower(strings.TrimSpace(value))
+	if strings.Contains(v, "...") || (strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">")) {
+		return true
+	}
+	var b strings.Builder
+	for _, r := range v {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	norm := b.String()
+	if norm == "" || strings.Trim(norm, "x") == "" {
+		return true
+	}
+	return placeholderSecrets[norm] || strings.HasPrefix(norm, "your")
+}
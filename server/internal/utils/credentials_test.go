@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestCredentialWarnings(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 0},
+		{"your-secret-key", 1},
+		{"secure_password", 1},
+		{"<password>", 1},
+		{"ABC-DEF...", 1},
+		{"xxxxxxxxxxxx", 1},
+		{"wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", 1},
+		{"changeme", 1},
+		{"pw", 1},
+		{"ab", 1},
+		{"x", 2},
+		{"u7Qm2pL9vZr4", 0},
+	}
+	for _, tt := range tests {
+		if got := CredentialWarnings("password", tt.value, 8); len(got) != tt.want {
+			t.Errorf("CredentialWarnings(%q) = %q, want %d warning(s)", tt.value, got, tt.want)
+		}
+	}
+	if got := CredentialWarnings("password", "pw", 0); len(got) != 0 {
+		t.Errorf("length check with minLength 0 = %q, want none", got)
+	}
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// envRefRe matches ${NAME} references.
+var envRefRe = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// envNameRe is a valid environment variable name.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// allowedEnvRefs lists the variables storage endpoints and regions may
+// reference. Empty (the default) disables interpolation: configs are
+// written by API users, and an unrestricted ${JWT_SECRET} in an endpoint
+// would send the server's secrets to a host of their choosing.
+var (
+	envRefsMu      sync.RWMutex
+	allowedEnvRefs map[string]bool
+)
+
+// SetAllowedEnvRefs sets the variables ExpandEnvRefs may resolve.
+func SetAllowedEnvRefs(names []string) error {
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !envNameRe.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		allowed[name] = true
+	}
+	envRefsMu.Lock()
+	defer envRefsMu.Unlock()
+	allowedEnvRefs = allowed
+	return nil
+}
+
+// ValidateEnvRefs checks that every ${NAME} in s is allowed and currently
+// set. A value without references is always valid.
+func ValidateEnvRefs(s string) error {
+	_, err := ExpandEnvRefs(s)
+	return err
+}
+
+// ExpandEnvRefs replaces each ${NAME} in s with the variable's value. Only
+// variables passed to SetAllowedEnvRefs are resolved, and each must be set
+// and non-empty. Values without "${" are returned unchanged; "$" alone has
+// no special meaning.
+func ExpandEnvRefs(s string) (string, error) {
+	envRefsMu.RLock()
+	defer envRefsMu.RUnlock()
+
+	var firstErr error
+	expanded := envRefRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if firstErr != nil {
+			return ref
+		}
+		switch {
+		case !envNameRe.MatchString(name):
+			firstErr = fmt.Errorf("invalid environment variable reference %q", ref)
+		case len(allowedEnvRefs) == 0:
+			firstErr = fmt.Errorf("environment variable references are disabled; list %s in STORAGE_ENV_VARS to allow it", name)
+		case !allowedEnvRefs[name]:
+			firstErr = fmt.Errorf("environment variable %s is not listed in STORAGE_ENV_VARS", name)
+		default:
+			value, ok := os.LookupEnv(name)
+			if !ok || value == "" {
+				firstErr = fmt.Errorf("environment variable %s is not set", name)
+				return ref
+			}
+			return value
+		}
+		return ref
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return expanded, nil
+}
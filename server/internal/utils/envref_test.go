@@ -0,0 +1,39 @@
+package utils
+
+import "testing"
+
+func TestExpandEnvRefs(t *testing.T) {
+	t.Setenv("DS_TEST_ENDPOINT", "https://acct.r2.cloudflarestorage.com")
+	t.Setenv("DS_TEST_SECRET", "hunter2")
+	t.Setenv("DS_TEST_EMPTY", "")
+	if err := SetAllowedEnvRefs([]string{"DS_TEST_ENDPOINT", "DS_TEST_EMPTY", "DS_TEST_UNSET"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetAllowedEnvRefs(nil) })
+
+	valid := map[string]string{
+		"":                              "",
+		"auto":                          "auto",
+		"https://s3.amazonaws.com":      "https://s3.amazonaws.com",
+		"$DS_TEST_ENDPOINT":             "$DS_TEST_ENDPOINT",
+		"${DS_TEST_ENDPOINT}":           "https://acct.r2.cloudflarestorage.com",
+		"${DS_TEST_ENDPOINT}/bucket-ns": "https://acct.r2.cloudflarestorage.com/bucket-ns",
+	}
+	for in, want := range valid {
+		got, err := ExpandEnvRefs(in)
+		if err != nil || got != want {
+			t.Errorf("ExpandEnvRefs(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"${DS_TEST_SECRET}", "${DS_TEST_EMPTY}", "${DS_TEST_UNSET}", "${1BAD}", "${}"} {
+		if _, err := ExpandEnvRefs(in); err == nil {
+			t.Errorf("ExpandEnvRefs(%q) succeeded, want error", in)
+		}
+	}
+
+	SetAllowedEnvRefs(nil)
+	if _, err := ExpandEnvRefs("${DS_TEST_ENDPOINT}"); err == nil {
+		t.Error("ExpandEnvRefs succeeded with interpolation disabled")
+	}
+}
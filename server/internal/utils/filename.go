@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultFilenameTemplate is used when a database has no template of its
+// own. It yields e.g. "proddb_20250101_020000_<backup id>".
+const DefaultFilenameTemplate = "{dbname}_{timestamp}_{id}"
+
+// MaxFilenameTemplateLength bounds a template before expansion.
+const MaxFilenameTemplateLength = 200
+
+// FilenameVars are the values substituted into a filename template.
+type FilenameVars struct {
+	Name     string    // database config name
+	DBName   string    // PostgreSQL database name
+	BackupID string    // backup UUID
+	Time     time.Time // backup start time
+}
+
+var (
+	filenameTokenRe   = regexp.MustCompile(`\{[^{}]*\}`)
+	filenameLiteralRe = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+	filenameUnsafeRe  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// filenameTokens maps each supported token to its expansion.
+var filenameTokens = map[string]func(FilenameVars) string{
+	"{name}":      func(v FilenameVars) string { return v.Name },
+	"{dbname}":    func(v FilenameVars) string { return v.DBName },
+	"{id}":        func(v FilenameVars) string { return v.BackupID },
+	"{date}":      func(v FilenameVars) string { return v.Time.Format("20060102") },
+	"{time}":      func(v FilenameVars) string { return v.Time.Format("150405") },
+	"{timestamp}": func(v FilenameVars) string { return v.Time.Format("20060102_150405") },
+}
+
+// ValidateFilenameTemplate checks a backup filename template. Outside of
+// tokens only letters, digits, '.', '_' and '-' are allowed, and {id} is
+// required so two backups can never map to the same object key. The file
+// extension is added by the backup service and must not be included.
+func ValidateFilenameTemplate(tmpl string) error {
+	if len(tmpl) > MaxFilenameTemplateLength {
+		return fmt.Errorf("template must be at most %d characters", MaxFilenameTemplateLength)
+	}
+	for _, tok := range filenameTokenRe.FindAllString(tmpl, -1) {
+		if _, ok := filenameTokens[tok]; !ok {
+			return fmt.Errorf("unknown token %s (allowed: {name}, {dbname}, {id}, {date}, {time}, {timestamp})", tok)
+		}
+	}
+	if !filenameLiteralRe.MatchString(filenameTokenRe.ReplaceAllString(tmpl, "")) {
+		return fmt.Errorf("template may only contain letters, digits, '.', '_', '-' and tokens")
+	}
+	if !strings.Contains(tmpl, "{id}") {
+		return fmt.Errorf("template must contain {id}")
+	}
+	return nil
+}
+
+// RenderFilename expands tmpl (DefaultFilenameTemplate when empty). Token
+// values are sanitised to the same safe character set, so a config name
+// with spaces or slashes cannot escape the database's key prefix.
+func RenderFilename(tmpl string, vars FilenameVars) string {
+	if tmpl == "" {
+		tmpl = DefaultFilenameTemplate
+	}
+	return filenameTokenRe.ReplaceAllStringFunc(tmpl, func(tok string) string {
+		expand, ok := filenameTokens[tok]
+		if !ok {
+			return ""
+		}
+		return filenameUnsafeRe.ReplaceAllString(expand(vars), "_")
+	})
+}
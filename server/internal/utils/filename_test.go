@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{DefaultFilenameTemplate, false},
+		{"{name}-{date}-{time}-{id}", false},
+		{"prod.{id}", false},
+		{"{dbname}_{timestamp}", true}, // missing {id}
+		{"{dbname}_{host}_{id}", true}, // unknown token
+		{"../{id}", true},              // path separator
+		{"backup {id}", true},          // space
+		{"{id}" + string(make([]byte, MaxFilenameTemplateLength)), true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateFilenameTemplate(tt.tmpl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateFilenameTemplate(%q) error = %v, wantErr %v", tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRenderFilename(t *testing.T) {
+	t.Parallel()
+
+	vars := FilenameVars{
+		Name:     "Prod DB/eu",
+		DBName:   "proddb",
+		BackupID: "b1",
+		Time:     time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"", "proddb_20250102_030405_b1"},
+		{"{name}_{date}_{id}", "Prod_DB_eu_20250102_b1"},
+		{"{dbname}-{time}-{id}", "proddb-030405-b1"},
+	}
+
+	for _, tt := range tests {
+		if got := RenderFilename(tt.tmpl, vars); got != tt.want {
+			t.Errorf("RenderFilename(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
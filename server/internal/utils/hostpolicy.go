@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HostPolicy decides which database hosts DumpStation may connect to.
+// Patterns are exact hostnames ("db.example.com"), leading wildcards
+// ("*.example.com", which does not match "example.com" itself), IP
+// addresses or CIDRs ("10.0.0.0/8"). Hostnames are resolved so CIDR
+// patterns also apply to names that point into the range.
+//
+// A host is rejected if it matches any deny pattern. When the allow list is
+// non-empty, a host must also match an allow pattern by name or have every
+// resolved address inside an allowed CIDR. An empty policy allows all hosts.
+type HostPolicy struct {
+	allowNames []string
+	allowNets  []*net.IPNet
+	denyNames  []string
+	denyNets   []*net.IPNet
+	lookupIP   func(host string) ([]net.IP, error)
+}
+
+// NewHostPolicy parses allow and deny patterns.
+func NewHostPolicy(allow, deny []string) (*HostPolicy, error) {
+	p := &HostPolicy{lookupIP: net.LookupIP}
+	var err error
+	if p.allowNames, p.allowNets, err = parseHostPatterns(allow); err != nil {
+		return nil, err
+	}
+	if p.denyNames, p.denyNets, err = parseHostPatterns(deny); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseHostPatterns(patterns []string) ([]string, []*net.IPNet, error) {
+	var (
+		names []string
+		nets  []*net.IPNet
+	)
+	for _, raw := range patterns {
+		pattern := strings.ToLower(strings.TrimSpace(raw))
+		switch {
+		case pattern == "":
+			continue
+		case strings.Contains(pattern, "/"):
+			_, n, err := net.ParseCIDR(pattern)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid CIDR %q: %w", raw, err)
+			}
+			nets = append(nets, n)
+		case net.ParseIP(pattern) != nil:
+			ip := net.ParseIP(pattern)
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		case strings.Contains(strings.TrimPrefix(pattern, "*."), "*"):
+			return nil, nil, fmt.Errorf("invalid host pattern %q: only a leading \"*.\" wildcard is supported", raw)
+		default:
+			names = append(names, strings.TrimSuffix(pattern, "."))
+		}
+	}
+	return names, nets, nil
+}
+
+// Enabled reports whether the policy has any patterns.
+func (p *HostPolicy) Enabled() bool {
+	return p != nil && len(p.allowNames)+len(p.allowNets)+len(p.denyNames)+len(p.denyNets) > 0
+}
+
+// Check returns an error describing why host is not allowed, or nil.
+func (p *HostPolicy) Check(host string) error {
+	if !p.Enabled() {
+		return nil
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" {
+		return fmt.Errorf("host is required")
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if len(p.allowNets)+len(p.denyNets) > 0 {
+		resolved, err := p.lookupIP(host)
+		if err != nil && len(p.allowNames)+len(p.allowNets) > 0 && !matchHostName(p.allowNames, host) {
+			return fmt.Errorf("host %q could not be resolved to check it against the allowed hosts", host)
+		}
+		ips = resolved
+	}
+
+	if matchHostName(p.denyNames, host) {
+		return fmt.Errorf("host %q is not allowed by this server's host policy", host)
+	}
+	for _, ip := range ips {
+		if matchIPNet(p.denyNets, ip) {
+			return fmt.Errorf("host %q resolves to %s, which is not allowed by this server's host policy", host, ip)
+		}
+	}
+
+	if len(p.allowNames)+len(p.allowNets) == 0 || matchHostName(p.allowNames, host) {
+		return nil
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("host %q is not in this server's allowed hosts", host)
+	}
+	for _, ip := range ips {
+		if !matchIPNet(p.allowNets, ip) {
+			return fmt.Errorf("host %q resolves to %s, which is not in this server's allowed hosts", host, ip)
+		}
+	}
+	return nil
+}
+
+func matchHostName(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIPNet(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
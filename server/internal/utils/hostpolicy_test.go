@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestHostPolicy(t *testing.T) {
+	p, err := NewHostPolicy(
+		[]string{"*.example.com", "10.0.0.0/8", "db.partner.io"},
+		[]string{"10.0.0.5", "secret.example.com", "169.254.0.0/16"},
+	)
+	if err != nil {
+		t.Fatalf("NewHostPolicy: %v", err)
+	}
+	p.lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "internal.corp":
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		case "metadata.cloud":
+			return []net.IP{net.ParseIP("169.254.169.254")}, nil
+		case "a.example.com", "db.partner.io":
+			return []net.IP{net.ParseIP("203.0.113.10")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"a.example.com", true},
+		{"A.Example.com.", true},
+		{"example.com", false},
+		{"secret.example.com", false},
+		{"db.partner.io", true},
+		{"10.9.9.9", true},
+		{"10.0.0.5", false},
+		{"internal.corp", true},
+		{"metadata.cloud", false},
+		{"192.168.1.1", false},
+		{"unknown.host", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.host)
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%q) = %v, want allowed", tt.host, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("Check(%q) allowed, want rejected", tt.host)
+		}
+	}
+}
+
+func TestHostPolicy_EmptyAllowsAll(t *testing.T) {
+	p, err := NewHostPolicy(nil, nil)
+	if err != nil {
+		t.Fatalf("NewHostPolicy: %v", err)
+	}
+	if p.Enabled() {
+		t.Error("empty policy reports Enabled")
+	}
+	if err := p.Check("127.0.0.1"); err != nil {
+		t.Errorf("empty policy rejected host: %v", err)
+	}
+}
+
+func TestNewHostPolicy_InvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"10.0.0.0/33", "db.*.example.com", "not a cidr/8"} {
+		if _, err := NewHostPolicy([]string{pattern}, nil); err == nil {
+			t.Errorf("NewHostPolicy(%q) succeeded, want error", pattern)
+		}
+	}
+}
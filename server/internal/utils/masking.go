@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -74,6 +75,14 @@ func MaskPort(port int) string {
 	return "****"
 }
 
+// MaskConnectionTarget describes a database connection for logs and audit
+// entries with every part masked.
+// Example: ("admin", "db.example.com", 5432, "production") →
+// "adm***@***.example.com:****/pro***"
+func MaskConnectionTarget(username, host string, port int, dbname string) string {
+	return fmt.Sprintf("%s@%s:%s/%s", MaskUsername(username), MaskHostname(host), MaskPort(port), MaskDatabaseName(dbname))
+}
+
 // isIPAddress checks if the given string is an IP address (simple check)
 func isIPAddress(host string) bool {
 	// Check for IPv4 pattern (contains only digits and dots)
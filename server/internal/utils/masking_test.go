@@ -95,6 +95,13 @@ func TestMaskPort(t *testing.T) {
 	}
 }
 
+func TestMaskConnectionTarget(t *testing.T) {
+	got := MaskConnectionTarget("admin", "db.example.com", 5432, "production")
+	if want := "adm***@***.example.com:****/pro***"; got != want {
+		t.Errorf("MaskConnectionTarget = %q, want %q", got, want)
+	}
+}
+
 func TestMaskBucketName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxMessageTemplateLength keeps a rendered notification well inside
+// Discord's 2000-character message limit.
+const MaxMessageTemplateLength = 1000
+
+// MessageVars are the values substituted into a notification template.
+type MessageVars struct {
+	Database string // database config name
+	Size     string // human-readable backup size; empty on failure
+	Duration string // backup duration; empty on failure
+	Status   string // "success" or "failed"
+	Error    string // error message; empty on success
+}
+
+var messageTokenRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// messageTokens maps each supported placeholder to its expansion.
+var messageTokens = map[string]func(MessageVars) string{
+	"{database}": func(v MessageVars) string { return v.Database },
+	"{size}":     func(v MessageVars) string { return v.Size },
+	"{duration}": func(v MessageVars) string { return v.Duration },
+	"{status}":   func(v MessageVars) string { return v.Status },
+	"{error}":    func(v MessageVars) string { return v.Error },
+}
+
+// ValidateMessageTemplate checks a notification template: it must not be
+// blank, must fit MaxMessageTemplateLength, and may only use the known
+// placeholders.
+func ValidateMessageTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("template must not be blank")
+	}
+	if len(tmpl) > MaxMessageTemplateLength {
+		return fmt.Errorf("template must be at most %d characters", MaxMessageTemplateLength)
+	}
+	for _, tok := range messageTokenRe.FindAllString(tmpl, -1) {
+		if _, ok := messageTokens[tok]; !ok {
+			return fmt.Errorf("unknown placeholder %s (allowed: {database}, {size}, {duration}, {status}, {error})", tok)
+		}
+	}
+	return nil
+}
+
+// RenderMessage expands the placeholders in tmpl. Unknown placeholders are
+// left as written.
+func RenderMessage(tmpl string, vars MessageVars) string {
+	return messageTokenRe.ReplaceAllStringFunc(tmpl, func(tok string) string {
+		expand, ok := messageTokens[tok]
+		if !ok {
+			return tok
+		}
+		return expand(vars)
+	})
+}
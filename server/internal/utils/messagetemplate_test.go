@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestValidateMessageTemplate(t *testing.T) {
+	for _, tmpl := range []string{
+		"{database} backed up ({size} in {duration})",
+		"[{status}] {database}: {error}\nhttps://dash.example.com",
+	} {
+		if err := ValidateMessageTemplate(tmpl); err != nil {
+			t.Errorf("ValidateMessageTemplate(%q) = %v, want nil", tmpl, err)
+		}
+	}
+	for _, tmpl := range []string{"", "   ", "{dbname} done", "{database} {}"} {
+		if err := ValidateMessageTemplate(tmpl); err == nil {
+			t.Errorf("ValidateMessageTemplate(%q) succeeded, want error", tmpl)
+		}
+	}
+}
+
+func TestRenderMessage(t *testing.T) {
+	vars := MessageVars{Database: "prod", Size: "1.5 MB", Duration: "3s", Status: "success"}
+	got := RenderMessage("[{status}] {database}: {size} in {duration}{error} {other}", vars)
+	if want := "[success] prod: 1.5 MB in 3s {other}"; got != want {
+		t.Errorf("RenderMessage = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxPgDumpExtraArgs bounds the number of extra pg_dump arguments.
+const MaxPgDumpExtraArgs = 20
+
+// pgDumpFlags lists the pg_dump long options users may add, and whether
+// each takes a value. Anything that picks the output file, format,
+// connection or credentials is managed by DumpStation and left out, as are
+// options that read files or run commands.
+var pgDumpFlags = map[string]bool{
+	"--schema":                        true,
+	"--exclude-schema":                true,
+	"--table":                         true,
+	"--exclude-table":                 true,
+	"--exclude-table-data":            true,
+	"--extension":                     true,
+	"--exclude-extension":             true,
+	"--include-foreign-data":          true,
+	"--section":                       true,
+	"--encoding":                      true,
+	"--rows-per-insert":               true,
+	"--serializable-deferrable":       false,
+	"--quote-all-identifiers":         false,
+	"--inserts":                       false,
+	"--column-inserts":                false,
+	"--on-conflict-do-nothing":        false,
+	"--disable-triggers":              false,
+	"--disable-dollar-quoting":        false,
+	"--enable-row-security":           false,
+	"--load-via-partition-root":       false,
+	"--no-comments":                   false,
+	"--no-publications":               false,
+	"--no-subscriptions":              false,
+	"--no-security-labels":            false,
+	"--no-tablespaces":                false,
+	"--no-table-access-method":        false,
+	"--no-toast-compression":          false,
+	"--no-unlogged-table-data":        false,
+	"--no-synchronized-snapshots":     false,
+	"--no-blobs":                      false,
+	"--no-large-objects":              false,
+	"--use-set-session-authorization": false,
+}
+
+// ValidatePgDumpExtraArgs checks user-supplied pg_dump arguments against
+// the allowlist. Each argument must be a single long option; values are
+// attached with "=" ("--exclude-schema=audit") so a value can never be read
+// as a separate option. Arguments are passed to pg_dump directly, never
+// through a shell.
+func ValidatePgDumpExtraArgs(args []string) error {
+	if len(args) > MaxPgDumpExtraArgs {
+		return fmt.Errorf("at most %d extra arguments are allowed", MaxPgDumpExtraArgs)
+	}
+	for _, arg := range args {
+		if len(arg) > 200 {
+			return fmt.Errorf("argument %.20q... is too long", arg)
+		}
+		if strings.IndexFunc(arg, unicode.IsControl) >= 0 {
+			return fmt.Errorf("argument %q contains control characters", arg)
+		}
+		flag, value, hasValue := strings.Cut(arg, "=")
+		takesValue, ok := pgDumpFlags[flag]
+		if !ok {
+			return fmt.Errorf("argument %q is not an allowed pg_dump option", flag)
+		}
+		if takesValue && (!hasValue || value == "") {
+			return fmt.Errorf("%s needs a value, e.g. %s=name", flag, flag)
+		}
+		if !takesValue && hasValue {
+			return fmt.Errorf("%s does not take a value", flag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import "testing"
+
+func TestValidatePgDumpExtraArgs(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"--exclude-schema=audit", "--serializable-deferrable"},
+		{"--table=public.orders", "--exclude-table-data=logs_*", "--no-comments"},
+	}
+	for _, args := range valid {
+		if err := ValidatePgDumpExtraArgs(args); err != nil {
+			t.Errorf("ValidatePgDumpExtraArgs(%q) = %v, want nil", args, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"--file=/etc/passwd"},
+		{"-f", "/tmp/x"},
+		{"--host=evil.example.com"},
+		{"--format=d"},
+		{"--exclude-schema"},
+		{"--exclude-schema="},
+		{"--exclude-schema", "audit"},
+		{"--no-comments=yes"},
+		{"--table=a\nb"},
+		{"; rm -rf /"},
+	}
+	for _, args := range invalid {
+		if err := ValidatePgDumpExtraArgs(args); err == nil {
+			t.Errorf("ValidatePgDumpExtraArgs(%q) succeeded, want error", args)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxPgEnvVars bounds the number of per-database PG* environment variables.
+const MaxPgEnvVars = 10
+
+// pgEnvVars lists the libpq environment variables a database config may
+// set for pg_dump, pg_restore and psql. Connection target, credentials
+// and timeouts (PGHOST, PGUSER, PGPASSFILE, …) are managed by DumpStation,
+// and variables naming files on the server (PGSSLKEY, PGSERVICEFILE, …)
+// are left out so a config can't make the tools read arbitrary files.
+var pgEnvVars = map[string]bool{
+	"PGOPTIONS":               true,
+	"PGSSLMODE":               true,
+	"PGSSLMINPROTOCOLVERSION": true,
+	"PGSSLMAXPROTOCOLVERSION": true,
+	"PGSSLNEGOTIATION":        true,
+	"PGGSSENCMODE":            true,
+	"PGKRBSRVNAME":            true,
+	"PGREQUIREAUTH":           true,
+	"PGCHANNELBINDING":        true,
+	"PGTARGETSESSIONATTRS":    true,
+	"PGLOADBALANCEHOSTS":      true,
+	"PGCLIENTENCODING":        true,
+	"PGDATESTYLE":             true,
+	"PGTZ":                    true,
+	"PGGEQO":                  true,
+	"PGSSLCOMPRESSION":        true,
+	"PGSSLCERTMODE":           true,
+	"PGREQUIRESSL":            true,
+}
+
+// pgSSLModes are the values libpq accepts for PGSSLMODE.
+var pgSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// ValidatePgEnv checks per-database environment variables against the
+// allowlist. Values are passed to the tools' environment directly, never
+// through a shell, but control characters are still refused so a value
+// can't smuggle in a second variable.
+func ValidatePgEnv(env map[string]string) error {
+	if len(env) > MaxPgEnvVars {
+		return fmt.Errorf("at most %d environment variables are allowed", MaxPgEnvVars)
+	}
+	for _, key := range SortedPgEnvKeys(env) {
+		value := env[key]
+		if !strings.HasPrefix(key, "PG") {
+			return fmt.Errorf("%s is not a PG* variable", key)
+		}
+		if !pgEnvVars[key] {
+			return fmt.Errorf("%s is not an allowed environment variable", key)
+		}
+		if value == "" || len(value) > 500 {
+			return fmt.Errorf("%s must be 1 to 500 characters", key)
+		}
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return fmt.Errorf("%s contains control characters", key)
+		}
+		if key == "PGSSLMODE" && !pgSSLModes[value] {
+			return fmt.Errorf("PGSSLMODE must be one of disable, allow, prefer, require, verify-ca or verify-full")
+		}
+	}
+	return nil
+}
+
+// SortedPgEnvKeys returns env's keys in a stable order.
+func SortedPgEnvKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
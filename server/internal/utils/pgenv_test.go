@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func TestValidatePgEnv(t *testing.T) {
+	valid := []map[string]string{
+		nil,
+		{"PGOPTIONS": "-c search_path=app,public"},
+		{"PGSSLMODE": "verify-full", "PGTARGETSESSIONATTRS": "read-only"},
+	}
+	for _, env := range valid {
+		if err := ValidatePgEnv(env); err != nil {
+			t.Errorf("ValidatePgEnv(%v) = %v, want nil", env, err)
+		}
+	}
+
+	invalid := []map[string]string{
+		{"LD_PRELOAD": "/tmp/evil.so"},
+		{"PATH": "/tmp"},
+		{"PGPASSWORD": "secret"},
+		{"PGPASSFILE": "/etc/shadow"},
+		{"PGHOST": "evil.example.com"},
+		{"PGSERVICEFILE": "/tmp/service"},
+		{"PGSSLKEY": "/root/.ssh/id_rsa"},
+		{"PGSSLMODE": "sometimes"},
+		{"PGOPTIONS": ""},
+		{"PGOPTIONS": "-c search_path=app\nPGHOST=evil"},
+	}
+	for _, env := range invalid {
+		if err := ValidatePgEnv(env); err == nil {
+			t.Errorf("ValidatePgEnv(%v) = nil, want error", env)
+		}
+	}
+
+	tooMany := map[string]string{}
+	for key := range pgEnvVars {
+		tooMany[key] = "x"
+	}
+	if len(tooMany) > MaxPgEnvVars {
+		if err := ValidatePgEnv(tooMany); err == nil {
+			t.Errorf("ValidatePgEnv with %d variables = nil, want error", len(tooMany))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily time-of-day range such as "01:00-05:00". The start
+// is inclusive and the end exclusive; a window whose end is before its
+// start wraps past midnight ("22:00-02:00").
+type TimeWindow struct {
+	start, end int // minutes after midnight
+}
+
+// ParseTimeWindow parses "HH:MM-HH:MM" (24-hour clock).
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("time window %q must look like HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	if start == end {
+		return TimeWindow{}, fmt.Errorf("time window %q is empty; start and end must differ", s)
+	}
+	return TimeWindow{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t's wall-clock time, in t's location, falls in
+// the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// String formats the window as "HH:MM-HH:MM".
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
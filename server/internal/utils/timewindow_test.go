@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeWindow_Contains(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		c, err := time.Parse("15:04", hhmm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2026, 1, 1, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		window string
+		at     string
+		want   bool
+	}{
+		{"01:00-05:00", "01:00", true},
+		{"01:00-05:00", "04:59", true},
+		{"01:00-05:00", "05:00", false},
+		{"01:00-05:00", "00:59", false},
+		{"01:00-05:00", "13:00", false},
+		{"22:00-02:00", "23:30", true},
+		{"22:00-02:00", "01:59", true},
+		{"22:00-02:00", "02:00", false},
+		{"22:00-02:00", "12:00", false},
+	}
+	for _, tt := range tests {
+		w, err := ParseTimeWindow(tt.window)
+		if err != nil {
+			t.Fatalf("ParseTimeWindow(%q): %v", tt.window, err)
+		}
+		if got := w.Contains(at(tt.at)); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.window, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeWindow_Invalid(t *testing.T) {
+	for _, s := range []string{"", "01:00", "1-5", "25:00-05:00", "01:00-01:00", "01:60-05:00"} {
+		if _, err := ParseTimeWindow(s); err == nil {
+			t.Errorf("ParseTimeWindow(%q) succeeded, want error", s)
+		}
+	}
+	if w, err := ParseTimeWindow(" 1:05 - 5:00 "); err != nil || w.String() != "01:05-05:00" {
+		t.Errorf("ParseTimeWindow with spaces = %v, %v; want 01:05-05:00", w, err)
+	}
+}
@@ -5,13 +5,23 @@ import (
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/monzim/db_proxy/v1/internal/utils"
 	"github.com/robfig/cron/v3"
 )
 
-// cronParser uses standard 5-field cron expressions ("minute hour dom month dow"),
-// matching the format the scheduler hands to robfig/cron at runtime. Keep them
-// in sync so anything that validates here will also schedule correctly.
-var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+// CronParser returns the parser shared by schedule validation and the
+// scheduler, so anything that validates here will also schedule correctly.
+// Expressions are standard 5-field ("minute hour dom month dow"). With
+// seconds enabled an optional leading seconds field is also accepted
+// ("*/30 * * * * *" runs every 30 seconds); 5-field expressions keep their
+// meaning either way.
+func CronParser(seconds bool) cron.Parser {
+	fields := cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow
+	if seconds {
+		fields |= cron.SecondOptional
+	}
+	return cron.NewParser(fields)
+}
 
 // Validator wraps the validator instance
 type Validator struct {
@@ -31,18 +41,60 @@ type ValidationErrorResponse struct {
 	Errors  []ValidationError `json:"errors"`
 }
 
-// New creates a new validator instance
+// New creates a new validator instance accepting 5-field cron schedules.
 func New() *Validator {
+	return NewWithCronSeconds(false)
+}
+
+// NewWithCronSeconds creates a validator whose `cron` tag also accepts an
+// optional seconds field. It must match the scheduler's setting.
+func NewWithCronSeconds(seconds bool) *Validator {
 	v := validator.New()
+	parser := CronParser(seconds)
 	// Register `cron` tag so models can mark fields as cron expressions and
 	// get the same parse rules the scheduler enforces at runtime.
-	if err := v.RegisterValidation("cron", validateCron); err != nil {
+	if err := v.RegisterValidation("cron", func(fl validator.FieldLevel) bool {
+		return validateCron(parser, fl)
+	}); err != nil {
 		panic(fmt.Sprintf("validator: failed to register cron tag: %v", err))
 	}
+	if err := v.RegisterValidation("filename_template", func(fl validator.FieldLevel) bool {
+		return utils.ValidateFilenameTemplate(fl.Field().String()) == nil
+	}); err != nil {
+		panic(fmt.Sprintf("validator: failed to register filename_template tag: %v", err))
+	}
+	if err := v.RegisterValidation("time_window", func(fl validator.FieldLevel) bool {
+		_, err := utils.ParseTimeWindow(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		panic(fmt.Sprintf("validator: failed to register time_window tag: %v", err))
+	}
+	if err := v.RegisterValidation("pg_dump_args", func(fl validator.FieldLevel) bool {
+		args, ok := fl.Field().Interface().([]string)
+		return ok && utils.ValidatePgDumpExtraArgs(args) == nil
+	}); err != nil {
+		panic(fmt.Sprintf("validator: failed to register pg_dump_args tag: %v", err))
+	}
+	if err := v.RegisterValidation("pg_env", func(fl validator.FieldLevel) bool {
+		env, ok := fl.Field().Interface().(map[string]string)
+		return ok && utils.ValidatePgEnv(env) == nil
+	}); err != nil {
+		panic(fmt.Sprintf("validator: failed to register pg_env tag: %v", err))
+	}
+	if err := v.RegisterValidation("message_template", func(fl validator.FieldLevel) bool {
+		return utils.ValidateMessageTemplate(fl.Field().String()) == nil
+	}); err != nil {
+		panic(fmt.Sprintf("validator: failed to register message_template tag: %v", err))
+	}
+	if err := v.RegisterValidation("env_refs", func(fl validator.FieldLevel) bool {
+		return utils.ValidateEnvRefs(fl.Field().String()) == nil
+	}); err != nil {
+		panic(fmt.Sprintf("validator: failed to register env_refs tag: %v", err))
+	}
 	return &Validator{validate: v}
 }
 
-func validateCron(fl validator.FieldLevel) bool {
+func validateCron(cronParser cron.Parser, fl validator.FieldLevel) bool {
 	expr := strings.TrimSpace(fl.Field().String())
 	if expr == "" {
 		// `required` tag handles emptiness; treat empty as valid here so the
@@ -53,7 +105,8 @@ func validateCron(fl validator.FieldLevel) bool {
 	return err == nil
 }
 
-// Validate validates a struct and returns formatted error messages
+// Validate validates a struct and returns formatted error messages. Every
+// failing field is reported, not just the first.
 func (v *Validator) Validate(data interface{}) (*ValidationErrorResponse, error) {
 	err := v.validate.Struct(data)
 	if err == nil {
@@ -189,6 +242,24 @@ func getErrorMessage(fieldName, tag, param string, kind string) string {
 	case "cron":
 		return fmt.Sprintf("%s must be a valid cron expression (minute hour dom month dow)", readableField)
 
+	case "filename_template":
+		return fmt.Sprintf("%s must contain {id}, use only {name}, {dbname}, {id}, {date}, {time}, {timestamp} and letters, digits, '.', '_', '-'", readableField)
+
+	case "time_window":
+		return fmt.Sprintf("%s must be a daily window like 01:00-05:00 (HH:MM-HH:MM, 24-hour)", readableField)
+
+	case "pg_dump_args":
+		return fmt.Sprintf("%s may only contain allowlisted pg_dump long options, with values attached as --option=value (at most %d)", readableField, utils.MaxPgDumpExtraArgs)
+
+	case "pg_env":
+		return fmt.Sprintf("%s may only set allowlisted PG* variables such as PGOPTIONS or PGSSLMODE, with non-empty values (at most %d)", readableField, utils.MaxPgEnvVars)
+
+	case "message_template":
+		return fmt.Sprintf("%s may only use {database}, {size}, {duration}, {status} and {error}, up to %d characters", readableField, utils.MaxMessageTemplateLength)
+
+	case "env_refs":
+		return fmt.Sprintf("%s may only reference ${VARS} listed in STORAGE_ENV_VARS and set on the server", readableField)
+
 	default:
 		return fmt.Sprintf("%s failed validation on tag: %s", readableField, tag)
 	}
@@ -0,0 +1,107 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/monzim/db_proxy/v1/internal/models"
+)
+
+// TestValidate_ReportsEveryInvalidField submits a DatabaseConfigInput with
+// several bad fields and expects one error per field, not just the first.
+func TestValidate_ReportsEveryInvalidField(t *testing.T) {
+	input := &models.DatabaseConfigInput{
+		Name:     "",
+		Host:     "",
+		Port:     70000,
+		DBName:   "app",
+		Username: "backup",
+		Password: "secret",
+		Schedule: "not a cron",
+		RotationPolicy: models.RotationPolicy{
+			Type:  "weekly",
+			Value: 0,
+		},
+	}
+
+	resp, err := New().Validate(input)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("expected validation errors, got none")
+	}
+
+	got := make(map[string]string, len(resp.Errors))
+	for _, e := range resp.Errors {
+		got[e.Field] = e.Message
+	}
+	// Fields keep their Go names, the format clients already parse.
+	want := []string{
+		"Name",
+		"Host",
+		"Port",
+		"Schedule",
+		"StorageID",
+		"Type",
+		"Value",
+	}
+	for _, field := range want {
+		if _, ok := got[field]; !ok {
+			t.Errorf("missing error for %q; got %v", field, got)
+		}
+	}
+	if len(resp.Errors) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(resp.Errors), len(want), got)
+	}
+	if resp.Code != "VALIDATION_ERROR" {
+		t.Errorf("Code = %q, want VALIDATION_ERROR", resp.Code)
+	}
+}
+
+func TestValidate_ValidInput(t *testing.T) {
+	input := &models.DatabaseConfigInput{
+		Name:     "Production DB",
+		Host:     "db.example.com",
+		Port:     5432,
+		DBName:   "app",
+		Username: "backup",
+		Password: "secret",
+		Schedule: "0 2 * * *",
+		RotationPolicy: models.RotationPolicy{
+			Type:  models.RotationPolicyDays,
+			Value: 30,
+		},
+	}
+	input.StorageID[0] = 1
+
+	resp, err := New().Validate(input)
+	if err != nil || resp != nil {
+		t.Fatalf("Validate = %+v, %v; want no errors", resp, err)
+	}
+}
+
+func TestCronSeconds(t *testing.T) {
+	type schedule struct {
+		Schedule string `json:"schedule" validate:"required,cron"`
+	}
+	tests := []struct {
+		expr    string
+		seconds bool
+		valid   bool
+	}{
+		{"0 2 * * *", false, true},
+		{"0 2 * * *", true, true},
+		{"*/30 * * * * *", false, false},
+		{"*/30 * * * * *", true, true},
+		{"61 * * * * *", true, false},
+	}
+	for _, tt := range tests {
+		resp, err := NewWithCronSeconds(tt.seconds).Validate(&schedule{Schedule: tt.expr})
+		if err != nil {
+			t.Fatalf("Validate(%q): %v", tt.expr, err)
+		}
+		if got := resp == nil; got != tt.valid {
+			t.Errorf("%q with seconds=%v: valid = %v, want %v", tt.expr, tt.seconds, got, tt.valid)
+		}
+	}
+}